@@ -0,0 +1,110 @@
+package webtea
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/sync/errgroup"
+)
+
+// ACMEOptions serves the web terminal over https with certificates of an ACME
+// CA, Let's Encrypt by default, when it isn't served on a tailnet which has
+// certificates of its own.
+type ACMEOptions struct {
+	// Hosts are the domains certificates are requested for, no other is
+	// served
+	Hosts []string
+
+	// CacheDir keeps the account key and the certificates across restarts,
+	// they are requested again on every start when empty and the CA rate
+	// limits are quickly reached
+	CacheDir string
+
+	// Email is the contact of the account, for problems with the
+	// certificates
+	Email string
+
+	// DirectoryURL is the directory of the CA, Let's Encrypt when empty
+	DirectoryURL string
+
+	// Redirect serves the http-01 challenges of the CA and redirects every
+	// other request to https, e.g. a listener on port 80. Without it the CA
+	// uses the tls-alpn-01 challenge on port 443.
+	Redirect net.Listener
+}
+
+// WithACME serves https on the listener of RunHTTP with the certificates of
+// o, obtained and renewed as they are needed.
+func WithACME(o ACMEOptions) HTTPOption {
+	return func(c *httpConfig) {
+		c.acme = &o
+	}
+}
+
+// manager returns the autocert.Manager of o.
+func (o ACMEOptions) manager() (*autocert.Manager, error) {
+	if len(o.Hosts) == 0 {
+		return nil, errors.New("acme: no hosts to request certificates for")
+	}
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(o.Hosts...),
+		Email:      o.Email,
+	}
+	if o.CacheDir != "" {
+		m.Cache = autocert.DirCache(o.CacheDir)
+	}
+	if o.DirectoryURL != "" {
+		m.Client = &acme.Client{DirectoryURL: o.DirectoryURL}
+	}
+	return m, nil
+}
+
+// listen returns l serving tls with the certificates of m, and runs the
+// redirect of o in grp until ctx is done.
+func (o ACMEOptions) listen(ctx context.Context, grp *errgroup.Group, cancel context.CancelCauseFunc, l net.Listener, m *autocert.Manager) net.Listener {
+	if o.Redirect != nil {
+		srv := &http.Server{
+			Handler:           m.HTTPHandler(nil),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		grp.Go(func() error {
+			if err := srv.Serve(o.Redirect); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				cancel(err)
+				return err
+			}
+			return nil
+		})
+		grp.Go(func() error {
+			<-ctx.Done()
+			return srv.Close()
+		})
+	}
+	return tlsListener{l, m.TLSConfig()}
+}
+
+// tlsListener is tls.NewListener that can be unwrapped, so the listener can
+// still be handed off.
+type tlsListener struct {
+	net.Listener
+	config *tls.Config
+}
+
+func (l tlsListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return tls.Server(conn, l.config), nil
+}
+
+// Unwrap returns the listener without tls.
+func (l tlsListener) Unwrap() net.Listener {
+	return l.Listener
+}
@@ -0,0 +1,79 @@
+package webtea
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// cacheCert writes a self-signed certificate of host to the autocert cache
+// in dir, as if it was obtained from the CA.
+func cacheCert(t *testing.T, dir, host string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	b := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	b = append(b, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, host), b, 0o600))
+}
+
+func TestACME(t *testing.T) {
+	_, err := ACMEOptions{}.manager()
+	require.ErrorContains(t, err, "no hosts")
+
+	dir := t.TempDir()
+	cacheCert(t, dir, "webtea.example.com")
+	m, err := ACMEOptions{Hosts: []string{"webtea.example.com"}, CacheDir: dir}.manager()
+	require.NoError(t, err)
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	l := tlsListener{tcp, m.TLSConfig()}
+	require.Equal(t, tcp, l.Unwrap())
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	// the cached certificate is served for the host, others are refused
+	conn, err := tls.Dial("tcp", tcp.Addr().String(), &tls.Config{ServerName: "webtea.example.com", InsecureSkipVerify: true})
+	require.NoError(t, err)
+	require.Equal(t, "webtea.example.com", conn.ConnectionState().PeerCertificates[0].Subject.CommonName)
+	conn.Close()
+
+	_, err = tls.Dial("tcp", tcp.Addr().String(), &tls.Config{ServerName: "evil.example.com", InsecureSkipVerify: true})
+	require.Error(t, err)
+
+	// plain http is redirected to https
+	rec := httptest.NewRecorder()
+	m.HTTPHandler(nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://webtea.example.com/room?x=1", nil))
+	require.Equal(t, http.StatusFound, rec.Code)
+	require.Equal(t, "https://webtea.example.com/room?x=1", rec.Header().Get("Location"))
+}
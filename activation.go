@@ -0,0 +1,128 @@
+package webtea
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listenFdsStart is the first file descriptor passed by systemd socket
+// activation, see sd_listen_fds(3). It is only changed by tests.
+var listenFdsStart = 3
+
+// Activation holds the listening sockets inherited from systemd. Because the
+// sockets are owned by systemd they stay open while the service restarts, so
+// no connections are refused in between.
+type Activation struct {
+	named   map[string]net.Listener
+	unnamed []net.Listener
+}
+
+// SystemdActivation collects the listeners passed by systemd through
+// LISTEN_FDS, or by the Handoff of the parent process. The LISTEN_* variables
+// are unset so they aren't inherited by child processes. When the process
+// wasn't socket activated the Activation is empty and every Listen falls back
+// to net.Listen.
+func SystemdActivation() (*Activation, error) {
+	a := &Activation{named: make(map[string]net.Listener)}
+
+	pid, fds := os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS")
+	names := os.Getenv("LISTEN_FDNAMES")
+	handoff := handedOff()
+	defer func() {
+		os.Unsetenv(handoffEnv)
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	if fds == "" || (pid != strconv.Itoa(os.Getpid()) && !handoff) {
+		return a, nil
+	}
+
+	n, err := strconv.Atoi(fds)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LISTEN_FDS: %w", err)
+	}
+
+	var fdNames []string
+	if names != "" {
+		fdNames = strings.Split(names, ":")
+	}
+
+	var errs []error
+	for i := range n {
+		name := fmt.Sprintf("LISTEN_FD_%d", listenFdsStart+i)
+		if i < len(fdNames) && fdNames[i] != "" {
+			name = fdNames[i]
+		}
+
+		f := os.NewFile(uintptr(listenFdsStart+i), name)
+		// FileListener dups the descriptor so the original can be closed
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("socket %s: %w", name, err))
+			continue
+		}
+
+		if i < len(fdNames) && fdNames[i] != "" {
+			a.named[name] = l
+		} else {
+			a.unnamed = append(a.unnamed, l)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		a.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+// Listen returns the inherited listener named by the FileDescriptorName= of
+// the systemd socket unit. Sockets without a name are handed out in the order
+// they were passed. When none is left it falls back to net.Listen.
+func (a *Activation) Listen(name, network, addr string) (net.Listener, error) {
+	if l, ok := a.Take(name); ok {
+		return l, nil
+	}
+	return net.Listen(network, addr)
+}
+
+// Take returns the inherited listener named name, or the next one without a
+// name, like Listen. It reports false when none is left, so the caller can
+// fall back to listeners of its own, e.g. on the tailnet.
+func (a *Activation) Take(name string) (net.Listener, bool) {
+	if l, ok := a.named[name]; ok {
+		delete(a.named, name)
+		return l, true
+	}
+	if len(a.unnamed) > 0 {
+		l := a.unnamed[0]
+		a.unnamed = a.unnamed[1:]
+		return l, true
+	}
+	return nil, false
+}
+
+// Activated reports if any inherited sockets haven't been handed out yet.
+func (a *Activation) Activated() bool {
+	return len(a.named) > 0 || len(a.unnamed) > 0
+}
+
+// Close closes the inherited listeners that were never used.
+func (a *Activation) Close() error {
+	var errs []error
+	for name, l := range a.named {
+		errs = append(errs, l.Close())
+		delete(a.named, name)
+	}
+	for _, l := range a.unnamed {
+		errs = append(errs, l.Close())
+	}
+	a.unnamed = nil
+	return errors.Join(errs...)
+}
@@ -0,0 +1,84 @@
+package webtea
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// activate passes l to SystemdActivation as if systemd had started the
+// process with it.
+func activate(t *testing.T, l net.Listener, names string) (*Activation, error) {
+	f, err := listenerFile(l)
+	require.NoError(t, err)
+	// SystemdActivation takes ownership of the descriptor, so it must not
+	// belong to an os.File that would close it again
+	fd, err := syscall.Dup(int(f.Fd()))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	start := listenFdsStart
+	listenFdsStart = fd
+	t.Cleanup(func() { listenFdsStart = start })
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "1")
+	t.Setenv("LISTEN_FDNAMES", names)
+	return SystemdActivation()
+}
+
+func TestSystemdActivation(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	a, err := activate(t, l, "http")
+	require.NoError(t, err)
+	require.True(t, a.Activated())
+	require.Empty(t, os.Getenv("LISTEN_FDS"), "LISTEN_* are unset")
+
+	inherited, err := a.Listen("http", "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer inherited.Close()
+	require.Equal(t, l.Addr().String(), inherited.Addr().String())
+	require.False(t, a.Activated())
+
+	// the inherited socket is used up, so it falls back to net.Listen
+	fallback, err := a.Listen("http", "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer fallback.Close()
+	require.NotEqual(t, l.Addr().String(), fallback.Addr().String())
+}
+
+func TestSystemdActivationUnnamed(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	a, err := activate(t, l, "")
+	require.NoError(t, err)
+	inherited, err := a.Listen("ssh", "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer inherited.Close()
+	require.Equal(t, l.Addr().String(), inherited.Addr().String())
+
+	_, ok := a.Take("ssh")
+	require.False(t, ok, "Take doesn't fall back to net.Listen")
+}
+
+func TestSystemdActivationNotActivated(t *testing.T) {
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+	a, err := SystemdActivation()
+	require.NoError(t, err)
+	require.False(t, a.Activated(), "the sockets are for another process")
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "one")
+	_, err = SystemdActivation()
+	require.ErrorContains(t, err, "invalid LISTEN_FDS")
+}
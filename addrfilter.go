@@ -0,0 +1,130 @@
+package webtea
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+
+	"github.com/charmbracelet/log"
+)
+
+// AddrFilter allows or denies connections by their remote address. Deny
+// rules win over allow rules and when there are no allow rules every
+// address not denied is allowed. The rules can be replaced with Set while
+// listeners are using the filter.
+type AddrFilter struct {
+	rules atomic.Pointer[addrRules]
+}
+
+type addrRules struct {
+	allow, deny []netip.Prefix
+}
+
+// NewAddrFilter parses allow and deny, which may be IP addresses or CIDR
+// prefixes.
+func NewAddrFilter(allow, deny []string) (*AddrFilter, error) {
+	f := &AddrFilter{}
+	if err := f.Set(allow, deny); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Set replaces the rules. The existing rules are kept if any fail to parse.
+func (f *AddrFilter) Set(allow, deny []string) error {
+	var (
+		r   addrRules
+		err error
+	)
+	if r.allow, err = parsePrefixes(allow); err != nil {
+		return fmt.Errorf("allow: %w", err)
+	}
+	if r.deny, err = parsePrefixes(deny); err != nil {
+		return fmt.Errorf("deny: %w", err)
+	}
+	f.rules.Store(&r)
+	return nil
+}
+
+func parsePrefixes(list []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return prefixes, nil
+}
+
+// Allowed reports if connections from addr are allowed. Addresses that
+// aren't IPs, like unix sockets, are only allowed when there are no allow
+// rules.
+func (f *AddrFilter) Allowed(addr net.Addr) bool {
+	r := f.rules.Load()
+	if r == nil {
+		return true
+	}
+
+	ap, err := netip.ParseAddrPort(addr.String())
+	if err != nil {
+		return len(r.allow) == 0
+	}
+	ip := ap.Addr().Unmap()
+
+	for _, p := range r.deny {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	if len(r.allow) == 0 {
+		return true
+	}
+	for _, p := range r.allow {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+type filterListener struct {
+	net.Listener
+	filter *AddrFilter
+}
+
+// FilterListener closes connections rejected by f as soon as they are
+// accepted, before any session, pty or program is created for them.
+func FilterListener(l net.Listener, f *AddrFilter) net.Listener {
+	return &filterListener{l, f}
+}
+
+func (l *filterListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.filter.Allowed(conn.RemoteAddr()) {
+			return conn, nil
+		}
+		log.Warn("connection rejected by address filter", "raddr", conn.RemoteAddr(), "laddr", conn.LocalAddr())
+		conn.Close()
+	}
+}
+
+// Unwrap returns the filtered listener.
+func (l *filterListener) Unwrap() net.Listener {
+	return l.Listener
+}
@@ -0,0 +1,31 @@
+package webtea
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddrFilter(t *testing.T) {
+	tcp := func(s string) net.Addr {
+		addr, err := net.ResolveTCPAddr("tcp", s)
+		require.NoError(t, err)
+		return addr
+	}
+
+	f, err := NewAddrFilter(nil, []string{"100.64.0.7"})
+	require.NoError(t, err)
+	require.True(t, f.Allowed(tcp("100.64.0.1:22")))
+	require.False(t, f.Allowed(tcp("100.64.0.7:22")))
+
+	require.NoError(t, f.Set([]string{"100.64.0.0/10", "fd7a:115c:a1e0::/48"}, []string{"100.64.0.7"}))
+	require.True(t, f.Allowed(tcp("100.64.0.1:22")))
+	require.True(t, f.Allowed(tcp("[fd7a:115c:a1e0::1]:22")))
+	require.False(t, f.Allowed(tcp("100.64.0.7:22")))
+	require.False(t, f.Allowed(tcp("192.168.1.1:22")))
+	require.False(t, f.Allowed(&net.UnixAddr{Name: "@", Net: "unix"}))
+
+	require.Error(t, f.Set([]string{"not an ip"}, nil))
+	require.True(t, f.Allowed(tcp("100.64.0.1:22")), "rules are kept when Set fails")
+}
@@ -0,0 +1,103 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/ghthor/webtea/logpolicy"
+	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/mpty/mptymsg"
+	"github.com/ghthor/webtea/serverinfo"
+	"github.com/ghthor/webtea/tshelper"
+)
+
+// APIHandler serves a read only JSON status API for dashboards and scripts.
+//
+//	GET /api/sessions  the connected sessions, see mpty.SessionInfo
+//	GET /api/stats     the program statistics, see mpty.Stats
+//	GET /api/logs      the counts of the rate limited log events, see logpolicy
+//
+// Requests are only served when allow returns true.
+func APIHandler(p mpty.Program, allow func(*http.Request) bool) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/sessions", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, p.Sessions())
+	})
+	mux.HandleFunc("GET /api/stats", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, p.Stats())
+	})
+	mux.HandleFunc("GET /api/logs", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, logpolicy.Counts())
+	})
+
+	return gate(allow, mux)
+}
+
+// InfoHandler serves the metadata of the server as JSON.
+//
+//	GET /api/info  the build, uptime, clients and recorder, see serverinfo.Info
+//
+// Requests are only served when allow returns true.
+func InfoHandler(s *serverinfo.Server, allow func(*http.Request) bool) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/info", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, s.Info())
+	})
+	return gate(allow, mux)
+}
+
+// ListenersHandler serves the connections of each listener as JSON.
+//
+//	GET /api/listeners  the accepted, active and closed connections by name
+//
+// Requests are only served when allow returns true.
+func ListenersHandler(c tshelper.ConnCounter, allow func(*http.Request) bool) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/listeners", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, c.ConnStats())
+	})
+	return gate(allow, mux)
+}
+
+// ProjectionsHandler serves the state of each of the projections as JSON.
+//
+//	GET /api/projections/{name}
+//
+// Requests are only served when allow returns true.
+func ProjectionsHandler(allow func(*http.Request) bool, ps ...mptymsg.Projection) http.Handler {
+	mux := http.NewServeMux()
+	for _, p := range ps {
+		mux.HandleFunc("GET /api/projections/"+p.Name(), func(w http.ResponseWriter, r *http.Request) {
+			state, err := p.MarshalState()
+			if err != nil {
+				log.Warn("api projection", "name", p.Name(), "error", err)
+				http.Error(w, "projection unavailable", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(state)
+		})
+	}
+
+	return gate(allow, mux)
+}
+
+// gate serves the requests allow returns true for with h, the others are
+// forbidden.
+func gate(allow func(*http.Request) bool, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if allow == nil || !allow(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Warn("api response", "error", err)
+	}
+}
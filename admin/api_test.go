@@ -0,0 +1,35 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ghthor/webtea/tshelper"
+	"github.com/stretchr/testify/require"
+)
+
+type conns map[string]tshelper.ConnStats
+
+func (c conns) ConnStats() map[string]tshelper.ConnStats { return c }
+
+func TestListenersHandler(t *testing.T) {
+	serve := func(allow func(*http.Request) bool, method string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h := ListenersHandler(conns{"ssh": {Accepted: 2, Active: 1, Closed: 1}}, allow)
+		h.ServeHTTP(w, httptest.NewRequest(method, "/api/listeners", nil))
+		return w
+	}
+	yes := func(*http.Request) bool { return true }
+
+	require.Equal(t, http.StatusForbidden, serve(nil, http.MethodGet).Code)
+	require.Equal(t, http.StatusForbidden, serve(func(*http.Request) bool { return false }, http.MethodGet).Code)
+
+	w := serve(yes, http.MethodPost)
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	require.Contains(t, w.Header().Get("Allow"), http.MethodGet)
+
+	w = serve(yes, http.MethodGet)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"ssh":{"accepted":2,"active":1,"closed":1}}`, w.Body.String())
+}
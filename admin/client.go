@@ -0,0 +1,49 @@
+package admin
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// Do runs a single command on the console listening on the unix socket at
+// path, copying its output to w.
+func Do(ctx context.Context, path string, w io.Writer, args ...string) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", path)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if _, err := fmt.Fprintln(conn, strings.Join(args, " ")); err != nil {
+		return err
+	}
+
+	s := bufio.NewScanner(conn)
+	for s.Scan() {
+		line := s.Text()
+		switch {
+		case line == replyOk:
+			return nil
+		case strings.HasPrefix(line, replyErr):
+			return errors.New(strings.TrimPrefix(line, replyErr))
+		case strings.HasPrefix(line, replyOk):
+			// an output line escaped by the console
+			line = line[len(replyOk):]
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
@@ -0,0 +1,186 @@
+// Package admin is an operator console served on a local socket. It lets
+// operators inspect and manage a running server without joining it as a
+// privileged user.
+//
+// The protocol is line based. A client writes a single command line and the
+// console answers with any number of output lines followed by a terminating
+// line, "." on success or "! <error>" when the command failed. Output lines
+// starting with "." or "!" are escaped with another "." in front, like the
+// dot-stuffing of SMTP, so they can't be read as the terminating line.
+package admin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/charmbracelet/log"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	replyOk  = "."
+	replyErr = "! "
+)
+
+type Command struct {
+	// Use is a short use description. The first word will be used as the
+	// command
+	Use string
+
+	// Short is a description that will be displayed in the help
+	Short string
+
+	// Run writes the output of the command to w
+	Run func(w io.Writer, args []string) error
+}
+
+type Console struct {
+	cmds map[string]Command
+}
+
+func NewConsole(cmds ...Command) *Console {
+	c := &Console{cmds: make(map[string]Command, len(cmds)+1)}
+	c.Handle(Command{
+		Use:   "help",
+		Short: "List the available commands.",
+		Run:   c.help,
+	})
+	for _, cmd := range cmds {
+		c.Handle(cmd)
+	}
+	return c
+}
+
+func (c *Console) Handle(cmd Command) {
+	key, _, _ := strings.Cut(cmd.Use, " ")
+	c.cmds[key] = cmd
+}
+
+func (c *Console) help(w io.Writer, args []string) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	keys := make([]string, 0, len(c.cmds))
+	for key := range c.cmds {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		fmt.Fprintf(tw, "%s\t- %s\n", c.cmds[key].Use, c.cmds[key].Short)
+	}
+	return tw.Flush()
+}
+
+// Exec runs a single command line writing the output and terminating line
+// to w.
+func (c *Console) Exec(w io.Writer, line string) error {
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		_, err := fmt.Fprintln(w, replyOk)
+		return err
+	}
+
+	cmd, ok := c.cmds[args[0]]
+	if !ok {
+		_, err := fmt.Fprintf(w, "%sunknown command: %s\n", replyErr, args[0])
+		return err
+	}
+
+	out := &stuffer{w: w}
+	err := cmd.Run(out, args)
+	if out.mid {
+		// the terminating line is a line of its own
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		_, err = fmt.Fprintf(w, "%s%s\n", replyErr, strings.ReplaceAll(err.Error(), "\n", " "))
+		return err
+	}
+	_, err = fmt.Fprintln(w, replyOk)
+	return err
+}
+
+// stuffer escapes the output lines of a command starting with "." or "!"
+// with another "." in front, see Do.
+type stuffer struct {
+	w io.Writer
+	// mid is set in the middle of a line
+	mid bool
+}
+
+func (s *stuffer) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		if !s.mid && (p[0] == '.' || p[0] == '!') {
+			if _, err := io.WriteString(s.w, "."); err != nil {
+				return n, err
+			}
+		}
+		line := p
+		if i := bytes.IndexByte(p, '\n'); i >= 0 {
+			line = p[:i+1]
+		}
+		m, err := s.w.Write(line)
+		n += m
+		if err != nil {
+			return n, err
+		}
+		s.mid = line[len(line)-1] != '\n'
+		p = p[len(line):]
+	}
+	return n, nil
+}
+
+// Run serves the console on l, which should be a unix socket only
+// accessible to operators, see webtea.ListenUnix.
+func (c *Console) Run(ctx context.Context, grp *errgroup.Group, cancel context.CancelCauseFunc, l net.Listener) error {
+	var conns sync.WaitGroup
+	grp.Go(func() error {
+		defer conns.Wait()
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				if errors.Is(err, net.ErrClosed) {
+					return nil
+				}
+				cancel(err)
+				return err
+			}
+
+			conns.Add(1)
+			go func() {
+				defer conns.Done()
+				c.serveConn(ctx, conn)
+			}()
+		}
+	})
+	grp.Go(func() error {
+		<-ctx.Done()
+		return l.Close()
+	})
+	return nil
+}
+
+func (c *Console) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	s := bufio.NewScanner(conn)
+	for s.Scan() {
+		line := s.Text()
+		log.Info("admin", "cmd", line)
+		if err := c.Exec(conn, line); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestConsole(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "admin.sock")
+	l, err := net.Listen("unix", path)
+	require.NoError(t, err)
+
+	console := NewConsole(Command{
+		Use:   "echo ARGS...",
+		Short: "Echo the arguments.",
+		Run: func(w io.Writer, args []string) error {
+			_, err := io.WriteString(w, strings.Join(args[1:], "\n")+"\n")
+			return err
+		},
+	}, Command{
+		Use: "nicks",
+		Run: func(w io.Writer, args []string) error {
+			// output that looks like the terminating lines of a reply
+			_, err := io.WriteString(w, ".\n! not an error\n..\nlast")
+			return err
+		},
+	}, Command{
+		Use: "fail",
+		Run: func(w io.Writer, args []string) error {
+			return errors.New("failed")
+		},
+	})
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	grp := &errgroup.Group{}
+	require.NoError(t, console.Run(ctx, grp, cancel, l))
+
+	var b strings.Builder
+	require.NoError(t, Do(ctx, path, &b, "echo", "a", "b"))
+	require.Equal(t, "a\nb\n", b.String())
+
+	b.Reset()
+	require.NoError(t, Do(ctx, path, &b, "nicks"))
+	require.Equal(t, ".\n! not an error\n..\nlast\n", b.String())
+
+	b.Reset()
+	require.NoError(t, Do(ctx, path, &b, "help"))
+	require.Contains(t, b.String(), "echo ARGS...")
+
+	require.EqualError(t, Do(ctx, path, io.Discard, "fail"), "failed")
+	require.EqualError(t, Do(ctx, path, io.Discard, "nope"), "unknown command: nope")
+
+	cancel(nil)
+	require.NoError(t, grp.Wait())
+}
@@ -0,0 +1,63 @@
+package admin
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/ghthor/webtea/ansiimg"
+)
+
+// PreviewHandler serves the output of view rasterized to a PNG of cols by
+// rows cells, for link previews and dashboards. A rendered image is reused
+// for every so view is rendered at most once per interval however often the
+// preview is requested. Requests are only served when allow returns true.
+func PreviewHandler(view func() string, cols, rows int, every time.Duration, allow func(*http.Request) bool) http.Handler {
+	var (
+		mu       sync.Mutex
+		png      []byte
+		rendered time.Time
+	)
+
+	render := func() ([]byte, time.Time, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if png != nil && time.Since(rendered) < every {
+			return png, rendered, nil
+		}
+
+		var b bytes.Buffer
+		if err := ansiimg.EncodePNG(&b, view(), cols, rows); err != nil {
+			return nil, time.Time{}, err
+		}
+		png, rendered = b.Bytes(), time.Now()
+		return png, rendered, nil
+	}
+
+	return gate(allow, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		img, at, err := render()
+		if err != nil {
+			log.Warn("preview render", "error", err)
+			http.Error(w, "preview unavailable", http.StatusInternalServerError)
+			return
+		}
+
+		h := w.Header()
+		h.Set("Content-Type", "image/png")
+		h.Set("Content-Length", strconv.Itoa(len(img)))
+		h.Set("Cache-Control", "private, max-age="+strconv.Itoa(int(every.Seconds())))
+		h.Set("Last-Modified", at.UTC().Format(http.TimeFormat))
+		if r.Method == http.MethodGet {
+			w.Write(img)
+		}
+	}))
+}
@@ -0,0 +1,90 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"time"
+)
+
+// maxProfile bounds how long the profile command captures the cpu
+const maxProfile = 5 * time.Minute
+
+// ProfileCommand captures a cpu profile of the running server, and optionally
+// its heap, into dir so performance issues can be investigated with go tool
+// pprof after a live event.
+func ProfileCommand(dir string) Command {
+	return Command{
+		Use:   "profile DURATION [heap]",
+		Short: "Capture a cpu profile, and the heap, to the profile directory.",
+		Run: func(w io.Writer, args []string) error {
+			if len(args) < 2 || len(args) > 3 || (len(args) == 3 && args[2] != "heap") {
+				return errors.New("usage: profile DURATION [heap]")
+			}
+			d, err := time.ParseDuration(args[1])
+			if err != nil || d <= 0 || d > maxProfile {
+				return fmt.Errorf("duration must be between 0 and %s", maxProfile)
+			}
+			paths, err := captureProfile(dir, d, len(args) == 3, time.Now())
+			for _, path := range paths {
+				fmt.Fprintln(w, path)
+			}
+			return err
+		},
+	}
+}
+
+// captureProfile writes the cpu profile of the next d, and the heap after it,
+// to dir and returns the files written.
+func captureProfile(dir string, d time.Duration, heap bool, now time.Time) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	stamp := now.UTC().Format("20060102T150405Z")
+
+	cpu := filepath.Join(dir, "cpu-"+stamp+".pprof")
+	if err := writeProfile(cpu, func(f *os.File) error {
+		if err := pprof.StartCPUProfile(f); err != nil {
+			return err
+		}
+		time.Sleep(d)
+		pprof.StopCPUProfile()
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	paths := []string{cpu}
+	if !heap {
+		return paths, nil
+	}
+
+	mem := filepath.Join(dir, "heap-"+stamp+".pprof")
+	if err := writeProfile(mem, func(f *os.File) error {
+		// the heap profile is as of the last gc
+		runtime.GC()
+		return pprof.WriteHeapProfile(f)
+	}); err != nil {
+		return paths, err
+	}
+	return append(paths, mem), nil
+}
+
+// writeProfile creates path for write, removing it if write fails.
+func writeProfile(path string, write func(*os.File) error) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
@@ -0,0 +1,40 @@
+package admin
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptureProfile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "profiles")
+	now := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+
+	paths, err := captureProfile(dir, 10*time.Millisecond, true, now)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(dir, "cpu-20240501T123000Z.pprof"),
+		filepath.Join(dir, "heap-20240501T123000Z.pprof"),
+	}, paths)
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		require.NoError(t, err)
+		require.NotZero(t, fi.Size())
+	}
+
+	// profiles are never overwritten
+	_, err = captureProfile(dir, 10*time.Millisecond, false, now)
+	require.ErrorIs(t, err, os.ErrExist)
+}
+
+func TestProfileCommandUsage(t *testing.T) {
+	cmd := ProfileCommand(t.TempDir())
+	require.Error(t, cmd.Run(io.Discard, []string{"profile"}))
+	require.Error(t, cmd.Run(io.Discard, []string{"profile", "soon"}))
+	require.Error(t, cmd.Run(io.Discard, []string{"profile", "1h"}))
+	require.Error(t, cmd.Run(io.Discard, []string{"profile", "1s", "goroutines"}))
+}
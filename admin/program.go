@@ -0,0 +1,96 @@
+package admin
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ghthor/webtea/mpty"
+)
+
+// ProgramCommands are the commands for managing the sessions of a mpty
+// Program.
+func ProgramCommands(p mpty.Program) []Command {
+	return []Command{{
+		Use:   "sessions",
+		Short: "List connected sessions.",
+		Run: func(w io.Writer, args []string) error {
+			tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tTRANSPORT\tCONNECTED\tLAG")
+			for _, s := range p.Sessions() {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n",
+					s.Id, s.Transport,
+					s.Connected.Format(time.DateTime),
+					s.Lag.Round(time.Millisecond),
+				)
+			}
+			return tw.Flush()
+		},
+	}, {
+		Use:   "kick ID|IDENTITY",
+		Short: "Disconnect a session, or every session of an identity.",
+		Run: func(w io.Writer, args []string) error {
+			if len(args) < 2 || len(args) > 3 {
+				return errors.New("usage: kick ID|IDENTITY")
+			}
+			// session ids contain a space so they arrive split in two
+			n := p.Kick(strings.Join(args[1:], " "))
+			if n == 0 {
+				return fmt.Errorf("no session matches %s", args[1])
+			}
+			_, err := fmt.Fprintf(w, "kicked %d sessions\n", n)
+			return err
+		},
+	}, {
+		Use:   "broadcast MESSAGE",
+		Short: "Send a message to every connected client.",
+		Run: func(w io.Writer, args []string) error {
+			if len(args) < 2 {
+				return errors.New("usage: broadcast MESSAGE")
+			}
+			p.Announce(strings.Join(args[1:], " "))
+			return nil
+		},
+	}, {
+		Use:   "stats",
+		Short: "Show session and broadcast statistics.",
+		Run: func(w io.Writer, args []string) error {
+			s := p.Stats()
+			tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+			fmt.Fprintf(tw, "uptime\t%s\n", time.Since(s.Started).Round(time.Second))
+			fmt.Fprintf(tw, "sessions\t%d\n", s.Sessions)
+			fmt.Fprintf(tw, "identities\t%d\n", s.Identities)
+			fmt.Fprintf(tw, "subscribers\t%d\n", s.Subscribers)
+			fmt.Fprintf(tw, "ring size\t%d\n", s.RingSize)
+			fmt.Fprintf(tw, "memory pressure\t%d\n", s.MemoryPressure)
+			if s.Stalled {
+				fmt.Fprintf(tw, "ALERT\tupdate loop stalled, see the log for the goroutine stacks\n")
+			}
+			fmt.Fprintf(tw, "stalls\t%d\n", s.Stalls)
+			// the message types Main is slowest to handle most often first
+			types := slices.SortedFunc(maps.Keys(s.SlowHandlers), func(a, b string) int {
+				return cmp.Compare(s.SlowHandlers[b].Count, s.SlowHandlers[a].Count)
+			})
+			for _, typ := range types {
+				h := s.SlowHandlers[typ]
+				fmt.Fprintf(tw, "slow %s\t%d, max %s, %v\n", typ, h.Count, h.Max, h.Buckets)
+			}
+			return tw.Flush()
+		},
+	}, {
+		Use:   "snapshot",
+		Short: "Save the program state for the next process to resume.",
+		Run: func(w io.Writer, args []string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			return p.Snapshot(ctx)
+		},
+	}}
+}
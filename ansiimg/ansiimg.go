@@ -0,0 +1,144 @@
+// Package ansiimg rasterizes text styled with ANSI escape sequences, like the
+// view of a bubbletea model, to an image.
+package ansiimg
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+
+	"github.com/charmbracelet/x/cellbuf"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+var (
+	// DefaultForeground is used for cells without a foreground color
+	DefaultForeground color.Color = color.RGBA{0xd0, 0xd0, 0xd0, 0xff}
+
+	// DefaultBackground is used for cells without a background color
+	DefaultBackground color.Color = color.Black
+)
+
+// face only has glyphs for ASCII, every other rune is drawn with a
+// replacement glyph unless it has a fallback or is a block element.
+var face = basicfont.Face7x13
+
+// CellSize is the size in pixels of a single cell.
+var CellSize = image.Pt(face.Advance, face.Height)
+
+// fallback approximates box drawing characters, which lipgloss borders and
+// tables are made of, with ASCII.
+var fallback = map[rune]rune{
+	'│': '|', '┃': '|', '║': '|',
+	'─': '-', '━': '-', '═': '=',
+	'┌': '+', '┐': '+', '└': '+', '┘': '+',
+	'├': '+', '┤': '+', '┬': '+', '┴': '+', '┼': '+',
+	'╭': '+', '╮': '+', '╯': '+', '╰': '+',
+	'…': '~', '•': '*', '·': '.',
+}
+
+// Render draws s on a grid of cols by rows cells. Text outside of the grid
+// is dropped.
+func Render(s string, cols, rows int) *image.RGBA {
+	buf := cellbuf.NewBuffer(cols, rows)
+	cellbuf.SetContent(buf, s)
+
+	img := image.NewRGBA(image.Rect(0, 0, cols*CellSize.X, rows*CellSize.Y))
+	draw.Draw(img, img.Bounds(), image.NewUniform(DefaultBackground), image.Point{}, draw.Src)
+
+	d := font.Drawer{Dst: img, Face: face}
+	for y := range rows {
+		for x := range cols {
+			c := buf.Cell(x, y)
+			if c == nil || c.Width == 0 {
+				continue
+			}
+
+			fg, bg := colors(c.Style)
+			rect := image.Rect(x*CellSize.X, y*CellSize.Y, (x+c.Width)*CellSize.X, (y+1)*CellSize.Y)
+			if bg != DefaultBackground {
+				draw.Draw(img, rect, image.NewUniform(bg), image.Point{}, draw.Src)
+			}
+
+			r := c.Rune
+			if block, ok := blockRect(r, rect); ok {
+				draw.Draw(img, block, image.NewUniform(fg), image.Point{}, draw.Src)
+				continue
+			}
+			if r == 0 || r == ' ' {
+				continue
+			}
+			if f, ok := fallback[r]; ok {
+				r = f
+			}
+
+			d.Src = image.NewUniform(fg)
+			d.Dot = fixed.P(rect.Min.X, rect.Min.Y+face.Ascent)
+			d.DrawString(string(r))
+			if c.Style.Attrs&cellbuf.BoldAttr != 0 {
+				// there's no bold face, draw it again shifted by a pixel
+				d.Dot = fixed.P(rect.Min.X+1, rect.Min.Y+face.Ascent)
+				d.DrawString(string(r))
+			}
+		}
+	}
+	return img
+}
+
+// EncodePNG renders s with Render and writes it to w as a PNG.
+func EncodePNG(w io.Writer, s string, cols, rows int) error {
+	return png.Encode(w, Render(s, cols, rows))
+}
+
+func colors(s cellbuf.Style) (fg, bg color.Color) {
+	fg, bg = DefaultForeground, DefaultBackground
+	if s.Fg != nil {
+		fg = s.Fg
+	}
+	if s.Bg != nil {
+		bg = s.Bg
+	}
+	if s.Attrs&cellbuf.ReverseAttr != 0 {
+		fg, bg = bg, fg
+	}
+	if s.Attrs&cellbuf.FaintAttr != 0 {
+		fg = blend(fg, bg)
+	}
+	return fg, bg
+}
+
+// blend returns the color halfway between a and b
+func blend(a, b color.Color) color.Color {
+	ar, ag, ab, _ := a.RGBA()
+	br, bg, bb, _ := b.RGBA()
+	return color.RGBA64{
+		R: uint16((ar + br) / 2),
+		G: uint16((ag + bg) / 2),
+		B: uint16((ab + bb) / 2),
+		A: 0xffff,
+	}
+}
+
+// blockRect returns the part of the cell at rect covered by the block
+// element r, these are drawn as rectangles since games use them as pixels.
+func blockRect(r rune, rect image.Rectangle) (image.Rectangle, bool) {
+	midY := rect.Min.Y + rect.Dy()/2
+	midX := rect.Min.X + rect.Dx()/2
+	switch r {
+	case '█':
+		return rect, true
+	case '▀':
+		return image.Rect(rect.Min.X, rect.Min.Y, rect.Max.X, midY), true
+	case '▄':
+		return image.Rect(rect.Min.X, midY, rect.Max.X, rect.Max.Y), true
+	case '▌':
+		return image.Rect(rect.Min.X, rect.Min.Y, midX, rect.Max.Y), true
+	case '▐':
+		return image.Rect(midX, rect.Min.Y, rect.Max.X, rect.Max.Y), true
+	}
+	return rect, false
+}
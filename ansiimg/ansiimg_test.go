@@ -0,0 +1,28 @@
+package ansiimg
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	img := Render("\x1b[41m \x1b[0mA\n\x1b[32m█", 3, 2)
+
+	if got, want := img.Bounds().Size(), image.Pt(3*CellSize.X, 2*CellSize.Y); got != want {
+		t.Fatalf("size %v, want %v", got, want)
+	}
+
+	// red background of the first cell
+	if c := color.RGBAModel.Convert(img.At(1, 1)).(color.RGBA); c.R < 0x80 || c.G != 0 {
+		t.Errorf("first cell background %v", c)
+	}
+	// green full block in the first cell of the second row
+	if c := color.RGBAModel.Convert(img.At(1, CellSize.Y+1)).(color.RGBA); c.G < 0x80 || c.R != 0 {
+		t.Errorf("block %v", c)
+	}
+	// empty cell keeps the default background
+	if c := img.At(2*CellSize.X+1, 1); c != color.RGBAModel.Convert(DefaultBackground) {
+		t.Errorf("empty cell %v", c)
+	}
+}
@@ -0,0 +1,147 @@
+package webtea
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/ghthor/gotty/v2/server"
+)
+
+// DefaultAssetMaxAge is how long browsers may use the gotty static assets
+// before revalidating them with their ETag.
+const DefaultAssetMaxAge = time.Hour
+
+// WithAssetMaxAge sets the Cache-Control max-age of the gotty static assets.
+// Zero makes browsers revalidate the assets on every page load.
+func WithAssetMaxAge(d time.Duration) HTTPOption {
+	return func(c *httpConfig) {
+		c.assetMaxAge = d
+	}
+}
+
+// The gotty javascript bundle is most of an initial page load. The static
+// assets are served by webtea instead of being proxied to gotty so they can
+// be compressed once and revalidated with an ETag instead of being gzipped on
+// every request. Brotli is preferred over gzip when the browser accepts both.
+
+type gottyAsset struct {
+	name string
+
+	once        sync.Once
+	raw, gz, br []byte
+	etag        string
+	modified    time.Time
+	err         error
+}
+
+func (a *gottyAsset) load() {
+	a.raw, a.err = server.Asset(a.name)
+	if a.err != nil {
+		return
+	}
+	if info, err := server.AssetInfo(a.name); err == nil {
+		a.modified = info.ModTime()
+	}
+
+	sum := sha256.Sum256(a.raw)
+	a.etag = hex.EncodeToString(sum[:16])
+
+	var b bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&b, gzip.BestCompression)
+	zw.Write(a.raw)
+	zw.Close()
+	if b.Len() < len(a.raw) {
+		a.gz = b.Bytes()
+	}
+
+	var br bytes.Buffer
+	bw := brotli.NewWriterLevel(&br, brotli.BestCompression)
+	bw.Write(a.raw)
+	bw.Close()
+	if br.Len() < len(a.raw) {
+		a.br = br.Bytes()
+	}
+}
+
+// gottyAssets are the static files of gotty, except the index which is
+// rendered by gotty itself.
+var gottyAssets = sync.OnceValue(func() map[string]*gottyAsset {
+	assets := make(map[string]*gottyAsset)
+	for _, name := range server.AssetNames() {
+		if name == "static/index.html" {
+			continue
+		}
+		assets[strings.TrimPrefix(name, "static/")] = &gottyAsset{name: name}
+	}
+	return assets
+})
+
+// assetHandler serves the gotty static asset at the request path, which must
+// already have the mount prefix removed.
+func assetHandler(maxAge time.Duration) http.Handler {
+	cacheControl := fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds()))
+	if maxAge <= 0 {
+		cacheControl = "no-cache"
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a, ok := gottyAssets()[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		a.once.Do(a.load)
+		if a.err != nil {
+			http.Error(w, "asset unavailable", http.StatusInternalServerError)
+			return
+		}
+
+		h := w.Header()
+		h.Set("Cache-Control", cacheControl)
+		h.Add("Vary", "Accept-Encoding")
+
+		body, etag := a.raw, a.etag
+		switch {
+		case a.br != nil && acceptsEncoding(r, "br"):
+			body, etag = a.br, a.etag+"-br"
+			h.Set("Content-Encoding", "br")
+		case a.gz != nil && acceptsEncoding(r, "gzip"):
+			body, etag = a.gz, a.etag+"-gzip"
+			h.Set("Content-Encoding", "gzip")
+		}
+		h.Set("ETag", `"`+etag+`"`)
+
+		// ServeContent answers If-None-Match and picks the Content-Type
+		// from the name
+		http.ServeContent(w, r, path.Base(a.name), a.modified, bytes.NewReader(body))
+	})
+}
+
+// acceptsEncoding reports if the Accept-Encoding of r allows coding
+func acceptsEncoding(r *http.Request, coding string) bool {
+	for _, v := range r.Header.Values("Accept-Encoding") {
+		for _, part := range strings.Split(v, ",") {
+			name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+			if !strings.EqualFold(strings.TrimSpace(name), coding) {
+				continue
+			}
+			q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+			if !ok {
+				return true
+			}
+			weight, err := strconv.ParseFloat(q, 64)
+			return err == nil && weight > 0
+		}
+	}
+	return false
+}
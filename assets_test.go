@@ -0,0 +1,62 @@
+package webtea
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAssetHandler(t *testing.T) {
+	h := assetHandler(DefaultAssetMaxAge)
+
+	req := httptest.NewRequest("GET", "/js/gotty-bundle.js", nil)
+	req.Header.Set("Accept-Encoding", "br, gzip;q=0.8")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d", w.Code)
+	}
+	if enc := w.Header().Get("Content-Encoding"); enc != "br" {
+		t.Errorf("Content-Encoding %q", enc)
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "public, max-age=3600" {
+		t.Errorf("Cache-Control %q", cc)
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("missing ETag")
+	}
+
+	req = httptest.NewRequest("GET", "/js/gotty-bundle.js", nil)
+	req.Header.Set("Accept-Encoding", "br;q=0, gzip")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if enc := w.Header().Get("Content-Encoding"); enc != "gzip" {
+		t.Errorf("Content-Encoding %q when brotli is refused", enc)
+	}
+
+	req = httptest.NewRequest("GET", "/js/gotty-bundle.js", nil)
+	req.Header.Set("Accept-Encoding", "br")
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("revalidation status %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/js/gotty-bundle.js", nil)
+	req.Header.Set("Accept-Encoding", "gzip;q=0")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if enc := w.Header().Get("Content-Encoding"); enc != "" {
+		t.Errorf("Content-Encoding %q when gzip is refused", enc)
+	}
+
+	req = httptest.NewRequest("GET", "/index.html", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("index status %d", w.Code)
+	}
+}
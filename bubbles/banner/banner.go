@@ -0,0 +1,123 @@
+// Package banner greets a client with the name of the server drawn in a block
+// font and the message of the day, before it's shown the app, e.g. the chat.
+package banner
+
+import (
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type Config struct {
+	// Title is drawn in the block font, e.g. the name of the server
+	Title string
+	// MOTD is the message of the day shown below the title
+	MOTD string
+	// Color is the lipgloss color of the title, e.g. "205" or "#ff5f87"
+	Color string
+	// Duration is how long the banner is shown, until a key is pressed when 0
+	Duration time.Duration
+}
+
+// Enabled reports if there is a banner to show.
+func (c Config) Enabled() bool {
+	return c.Title != "" || c.MOTD != ""
+}
+
+// Model is the banner of a single client, it is done once it's dismissed by
+// a key press or its duration has passed.
+type Model struct {
+	cfg Config
+
+	title, motd, hint lipgloss.Style
+
+	width, height int
+	done          bool
+}
+
+// dismissMsg ends the banner it was scheduled for
+type dismissMsg struct{ m *Model }
+
+// New returns the banner of cfg rendered with r, see
+// mpty.ClientInfoModel.Renderer.
+func New(cfg Config, r *lipgloss.Renderer) *Model {
+	m := &Model{
+		cfg:   cfg,
+		title: r.NewStyle().Bold(true),
+		motd:  r.NewStyle().Align(lipgloss.Center),
+		hint:  r.NewStyle().Faint(true),
+		done:  !cfg.Enabled(),
+	}
+	if cfg.Color != "" {
+		m.title = m.title.Foreground(lipgloss.Color(cfg.Color))
+	}
+	return m
+}
+
+func (m *Model) Init() tea.Cmd {
+	if m.done || m.cfg.Duration <= 0 {
+		return nil
+	}
+	return tea.Tick(m.cfg.Duration, func(time.Time) tea.Msg {
+		return dismissMsg{m}
+	})
+}
+
+// Update dismisses the banner, it returns true if the banner used msg.
+func (m *Model) Update(msg tea.Msg) bool {
+	if m.done {
+		return false
+	}
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		return false
+	case tea.KeyMsg:
+		m.done = true
+		return true
+	case dismissMsg:
+		if msg.m == m {
+			m.done = true
+			return true
+		}
+	}
+	return false
+}
+
+// Done reports if the banner has been dismissed.
+func (m *Model) Done() bool {
+	return m.done
+}
+
+// SetSize sets the size of the terminal the banner is centered in.
+func (m *Model) SetSize(width, height int) {
+	m.width, m.height = width, height
+}
+
+func (m *Model) View() string {
+	var parts []string
+	add := func(part string) {
+		if len(parts) > 0 {
+			parts = append(parts, "")
+		}
+		parts = append(parts, part)
+	}
+	if m.cfg.Title != "" {
+		title := strings.Join(Lines(m.cfg.Title, '█'), "\n")
+		if lipgloss.Width(title) > m.width {
+			// the block font doesn't fit, the title is shown as is
+			title = m.cfg.Title
+		}
+		add(m.title.Render(title))
+	}
+	if m.cfg.MOTD != "" {
+		add(m.motd.Width(min(m.width, 72)).Render(m.cfg.MOTD))
+	}
+	if m.cfg.Duration <= 0 {
+		add(m.hint.Render("press any key"))
+	}
+	block := lipgloss.JoinVertical(lipgloss.Center, parts...)
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, block)
+}
@@ -0,0 +1,49 @@
+package banner
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLines(t *testing.T) {
+	require.Equal(t, []string{
+		"#  # ###",
+		"#  #  # ",
+		"####  # ",
+		"#  #  # ",
+		"#  # ###",
+	}, Lines("hi", '#'))
+
+	// unknown runes are drawn as a space
+	require.Equal(t, Lines(" ", '#'), Lines("~", '#'))
+}
+
+func TestModel(t *testing.T) {
+	m := New(Config{Title: "webtea", MOTD: "welcome"}, lipgloss.DefaultRenderer())
+	require.Nil(t, m.Init())
+	require.False(t, m.Update(tea.WindowSizeMsg{Width: 80, Height: 24}))
+
+	view := m.View()
+	require.Len(t, strings.Split(view, "\n"), 24)
+	require.Contains(t, view, "welcome")
+	require.Contains(t, view, "█")
+
+	// the title is shown as is on narrow terminals
+	m.SetSize(10, 24)
+	require.Contains(t, m.View(), "webtea")
+
+	require.True(t, m.Update(tea.KeyMsg{Type: tea.KeyEnter}))
+	require.True(t, m.Done())
+	require.False(t, m.Update(tea.KeyMsg{Type: tea.KeyEnter}))
+
+	m = New(Config{Title: "webtea", Duration: time.Millisecond}, lipgloss.DefaultRenderer())
+	require.True(t, m.Update(m.Init()()))
+	require.True(t, m.Done())
+
+	require.True(t, New(Config{}, lipgloss.DefaultRenderer()).Done())
+}
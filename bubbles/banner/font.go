@@ -0,0 +1,80 @@
+package banner
+
+import (
+	"strings"
+	"unicode"
+)
+
+// fontHeight is the number of lines of every glyph
+const fontHeight = 5
+
+// font is a block font of the letters, digits and a few symbols, a # is a
+// filled cell. Lower case letters are drawn upper case and other runes as a
+// space.
+var font = map[rune][fontHeight]string{
+	'A': {" ## ", "#  #", "####", "#  #", "#  #"},
+	'B': {"### ", "#  #", "### ", "#  #", "### "},
+	'C': {" ###", "#   ", "#   ", "#   ", " ###"},
+	'D': {"### ", "#  #", "#  #", "#  #", "### "},
+	'E': {"####", "#   ", "### ", "#   ", "####"},
+	'F': {"####", "#   ", "### ", "#   ", "#   "},
+	'G': {" ###", "#   ", "# ##", "#  #", " ###"},
+	'H': {"#  #", "#  #", "####", "#  #", "#  #"},
+	'I': {"###", " # ", " # ", " # ", "###"},
+	'J': {"  ##", "   #", "   #", "#  #", " ## "},
+	'K': {"#  #", "# # ", "##  ", "# # ", "#  #"},
+	'L': {"#   ", "#   ", "#   ", "#   ", "####"},
+	'M': {"#   #", "## ##", "# # #", "#   #", "#   #"},
+	'N': {"#   #", "##  #", "# # #", "#  ##", "#   #"},
+	'O': {" ## ", "#  #", "#  #", "#  #", " ## "},
+	'P': {"### ", "#  #", "### ", "#   ", "#   "},
+	'Q': {" ## ", "#  #", "#  #", "# ##", " ###"},
+	'R': {"### ", "#  #", "### ", "# # ", "#  #"},
+	'S': {" ###", "#   ", " ## ", "   #", "### "},
+	'T': {"#####", "  #  ", "  #  ", "  #  ", "  #  "},
+	'U': {"#  #", "#  #", "#  #", "#  #", " ## "},
+	'V': {"#   #", "#   #", "#   #", " # # ", "  #  "},
+	'W': {"#   #", "#   #", "# # #", "## ##", "#   #"},
+	'X': {"#   #", " # # ", "  #  ", " # # ", "#   #"},
+	'Y': {"#   #", " # # ", "  #  ", "  #  ", "  #  "},
+	'Z': {"####", "   #", "  # ", " #  ", "####"},
+	'0': {" ## ", "#  #", "#  #", "#  #", " ## "},
+	'1': {" # ", "## ", " # ", " # ", "###"},
+	'2': {"### ", "   #", " ## ", "#   ", "####"},
+	'3': {"### ", "   #", " ## ", "   #", "### "},
+	'4': {"#  #", "#  #", "####", "   #", "   #"},
+	'5': {"####", "#   ", "### ", "   #", "### "},
+	'6': {" ## ", "#   ", "### ", "#  #", " ## "},
+	'7': {"####", "   #", "  # ", " #  ", " #  "},
+	'8': {" ## ", "#  #", " ## ", "#  #", " ## "},
+	'9': {" ## ", "#  #", " ###", "   #", " ## "},
+	' ': {"  ", "  ", "  ", "  ", "  "},
+	'-': {"   ", "   ", "###", "   ", "   "},
+	'_': {"    ", "    ", "    ", "    ", "####"},
+	'.': {" ", " ", " ", " ", "#"},
+	'!': {"#", "#", "#", " ", "#"},
+	'?': {"### ", "   #", " ## ", "    ", " #  "},
+}
+
+// Lines returns text drawn in the block font with fill, a column apart.
+func Lines(text string, fill rune) []string {
+	var rows [fontHeight]strings.Builder
+	for i, r := range text {
+		g, ok := font[unicode.ToUpper(r)]
+		if !ok {
+			g = font[' ']
+		}
+		for y := range g {
+			if i > 0 {
+				rows[y].WriteByte(' ')
+			}
+			rows[y].WriteString(strings.ReplaceAll(g[y], "#", string(fill)))
+		}
+	}
+
+	lines := make([]string, 0, fontHeight)
+	for y := range rows {
+		lines = append(lines, rows[y].String())
+	}
+	return lines
+}
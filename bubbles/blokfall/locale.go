@@ -0,0 +1,18 @@
+package blokfall
+
+import "github.com/ghthor/webtea/i18n"
+
+func init() {
+	i18n.Register(i18n.English, map[string]string{
+		"blokfall.hud.lines": "ln",
+		"blokfall.hud.level": "lv",
+
+		"blokfall.hud.game_over": "GAME OVER",
+	})
+	i18n.Register(i18n.Spanish, map[string]string{
+		"blokfall.hud.lines": "ln",
+		"blokfall.hud.level": "nv",
+
+		"blokfall.hud.game_over": "FIN",
+	})
+}
@@ -13,6 +13,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/table"
+	"github.com/ghthor/webtea/i18n"
 	"github.com/ghthor/webtea/unsafering"
 )
 
@@ -27,7 +28,7 @@ const (
 )
 
 func New() *Model {
-	return &Model{}
+	return &Model{Locale: i18n.Default}
 }
 
 type tableView struct {
@@ -70,7 +71,14 @@ type Model struct {
 	linesScored int
 	score       uint64
 
+	// over is set once a new piece has no room on the board
+	over bool
+
 	debug bool
+
+	// Locale of the HUD labels. Multiplayer views are shared by every
+	// player so this is the server default rather than per client.
+	Locale i18n.Locale
 }
 
 func (m Model) Height() int {
@@ -188,7 +196,7 @@ func (m *Model) UpdateBlokFallShouldRender(msg tea.Msg) (*Model, tea.Cmd, bool)
 }
 
 func (m *Model) HandleInput(msg MultiPieceInput) (*Model, tea.Cmd) {
-	if msg.Idx >= len(m.pieces) {
+	if m.over || msg.Idx >= len(m.pieces) {
 		return m, nil
 	}
 
@@ -295,10 +303,31 @@ func (m *Model) LockPiece(i int) tea.Cmd {
 
 	m.pieces[i] = m.PullNext()
 	m.render = true
+	if m.board.Collides(m.pieces[i]) {
+		// the board is full, every piece stops falling
+		m.over = true
+		return nil
+	}
 	return m.NewTick(i)
 }
 
-// TODO: game over
+// Over reports if the board has filled up, the game continues after a reset.
+func (m *Model) Over() bool {
+	return m.over
+}
+
+// Result is the outcome of a game.
+type Result struct {
+	Score uint64 `json:"score"`
+	Lines int    `json:"lines"`
+	Level int    `json:"level"`
+}
+
+// Result returns the score of the game so far.
+func (m *Model) Result() Result {
+	return Result{Score: m.score, Lines: m.linesScored, Level: m.level}
+}
+
 // TODO: add persistant leaderboard & players list
 func (m *Model) Score(lines int) {
 	switch lines {
@@ -321,7 +350,7 @@ func (m *Model) Score(lines int) {
 
 func (m *Model) HandleTickMsg(msg TickMsg) tea.Cmd {
 	i := msg.Idx
-	if i >= len(m.pieces) {
+	if m.over || i >= len(m.pieces) {
 		return nil
 	}
 
@@ -346,7 +375,7 @@ func (m *Model) HandleTickMsg(msg TickMsg) tea.Cmd {
 
 func (m *Model) HandleLockMsg(msg LockMsg) tea.Cmd {
 	i := msg.Idx
-	if i >= len(m.pieces) {
+	if m.over || i >= len(m.pieces) {
 		return nil
 	}
 
@@ -419,9 +448,12 @@ func (m *Model) ViewNextPiecesTo(w io.Writer) {
 func (m *Model) ViewScoreTo(w io.Writer) {
 	t := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
 	fmt.Fprintln(w)
-	fmt.Fprintf(w, "ln\t%d\t\n", m.linesScored)
-	fmt.Fprintf(w, "lv\t%d\t\n", m.level)
+	fmt.Fprintf(w, "%s\t%d\t\n", i18n.T(m.Locale, "blokfall.hud.lines"), m.linesScored)
+	fmt.Fprintf(w, "%s\t%d\t\n", i18n.T(m.Locale, "blokfall.hud.level"), m.level)
 	fmt.Fprintf(w, "%d\n", m.score)
+	if m.over {
+		fmt.Fprintln(w, i18n.T(m.Locale, "blokfall.hud.game_over"))
+	}
 	t.Flush()
 }
 
@@ -701,6 +733,7 @@ func (m *Model) Reset(lv int) tea.Cmd {
 	m.level = lv
 	m.linesScored = 0
 	m.score = 0
+	m.over = false
 	return tea.Batch(cmds...)
 }
 
@@ -711,3 +744,41 @@ func (m *Model) SetLevel(i int) {
 func (m *Model) newRandPiece() *Piece {
 	return NewPiece(RandShape(), m.board.Width/2, 0)
 }
+
+// Snapshot is the state of a game that can be resumed after a restart. The
+// falling pieces aren't included, the players get new ones when they rejoin.
+type Snapshot struct {
+	Cells [][]uint8 `json:"cells"`
+	Level int       `json:"level"`
+	Lines int       `json:"lines"`
+	Score uint64    `json:"score"`
+}
+
+func (m *Model) Snapshot() Snapshot {
+	cells := make([][]uint8, len(m.board.Cells))
+	for y := range cells {
+		cells[y] = slices.Clone(m.board.Cells[y])
+	}
+	return Snapshot{
+		Cells: cells,
+		Level: m.level,
+		Lines: m.linesScored,
+		Score: m.score,
+	}
+}
+
+// Restore continues the game of s, it must be called after Init. The board is
+// left empty when s is from a board of a different size.
+func (m *Model) Restore(s Snapshot) {
+	m.level, m.linesScored, m.score = s.Level, s.Lines, s.Score
+	if len(s.Cells) != m.board.Height {
+		return
+	}
+	for y := range s.Cells {
+		if len(s.Cells[y]) != m.board.Width {
+			m.board.Reset()
+			return
+		}
+		copy(m.board.Cells[y], s.Cells[y])
+	}
+}
@@ -1,95 +1,237 @@
 package blokfall
 
 import (
+	"maps"
+	"slices"
+	"time"
+
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/mpty/mptymsg"
 	"github.com/golang-cz/ringbuf"
 )
 
+func init() {
+	mptymsg.Register(GameOverMsg{})
+}
+
 type (
 	MPConnectPlayerMsg    mpty.ClientId
 	MPDisconnectPlayerMsg mpty.ClientId
 
+	// MPPlayerJoinedMsg is broadcast when a player joins the game
+	MPPlayerJoinedMsg mpty.ClientId
+
 	MPView  *string
 	MPInput struct {
 		Id  mpty.ClientId
 		Cmd Input
 	}
+
+	// MPJoinTableMsg seats a player at Table, a game of its own apart from
+	// the shared game, e.g. for a tournament match. The table is created by
+	// its first player.
+	MPJoinTableMsg struct {
+		Id    mpty.ClientId
+		Table string
+	}
+	// MPCloseTableMsg ends the game at a table, e.g. when its time is up.
+	MPCloseTableMsg string
+	// MPTableView is broadcast with the view of the game at Table, View is
+	// nil once the table has closed.
+	MPTableView struct {
+		Table string
+		View  *string
+	}
+
+	// tableMsg is a message of the game at table, the tables can't tell
+	// their ticks apart otherwise
+	tableMsg struct {
+		table string
+		msg   tea.Msg
+	}
 )
 
+// GameOverMsg is sent to the program when the board of a game has filled up
+// or its table was closed. It is recorded, so the results outlive the game.
+type GameOverMsg struct {
+	At time.Time
+	// Table is empty for the shared game
+	Table   string
+	Players []mpty.ClientId
+	Result
+
+	recId int64
+}
+
+var _ mptymsg.Recordable = GameOverMsg{}
+
+func (m GameOverMsg) TypeName() string { return "blokfall.GameOver" }
+func (m GameOverMsg) Ts() time.Time    { return m.At }
+
+func (m GameOverMsg) SetId(id int64) mptymsg.Recordable {
+	m.recId = id
+	return m
+}
+
+// FrameInterval is the fixed timestep of the MPModel, the changes of a frame
+// are broadcast as a single MPView at its end. The gravity of every level is
+// a multiple of it and both are ticks of the system clock, so pieces fall on
+// frame boundaries.
+const FrameInterval = 50 * time.Millisecond
+
+// frameMsg ends a frame of the MPModel
+type frameMsg time.Time
+
 type MPModel struct {
 	broadcaster *ringbuf.RingBuffer[tea.Msg]
+	now         time.Time
 
 	blokfall *Model
 
 	players map[mpty.ClientId]int
+	// over is set once the game over of the shared game has been sent
+	over bool
+
+	// dirty is set when the game changed during the frame, framing while
+	// frames are ticking
+	dirty, framing bool
+
+	// resume is continued by the next game that starts
+	resume *Snapshot
+
+	tables map[string]*mpTable
+	// seats are the tables of the players seated at one
+	seats map[mpty.ClientId]string
+
+	dispatch mpty.Dispatcher
+}
+
+// mpTable is a game at a table
+type mpTable struct {
+	game    *Model
+	players map[mpty.ClientId]int
+	// seated are all the players that have played at the table
+	seated []mpty.ClientId
 }
 
 func (m *MPModel) Init() tea.Cmd {
 	if m.players == nil {
 		m.players = make(map[mpty.ClientId]int, 10)
 	}
+	if m.tables == nil {
+		m.tables = make(map[string]*mpTable)
+		m.seats = make(map[mpty.ClientId]string)
+	}
+	if m.dispatch.Unhandled == nil {
+		m.handle()
+	}
 
 	return nil
 }
 
-func (m *MPModel) UpdateBlokFall(msg tea.Msg) tea.Cmd {
-	var (
-		cmd       tea.Cmd
-		cmds      []tea.Cmd
-		blokfallMsg = msg
-	)
+// handle registers the handlers of the messages of the MPModel, the others
+// are the messages of the shared game.
+func (m *MPModel) handle() {
+	d := &m.dispatch
+	d.Unhandled = m.updateGame
 
-	switch msg := msg.(type) {
-	case *ringbuf.RingBuffer[tea.Msg]:
+	mpty.Handle(d, func(msg *ringbuf.RingBuffer[tea.Msg]) tea.Cmd {
 		m.broadcaster = msg
+		return m.updateGame(msg)
+	})
+	mpty.Handle(d, func(msg frameMsg) tea.Cmd {
+		return m.frame()
+	})
+	mpty.Handle(d, func(msg time.Time) tea.Cmd {
+		m.now = msg
+		return m.updateGame(msg)
+	})
 
-	case MPConnectPlayerMsg:
-		if _, ok := m.players[mpty.ClientId(msg)]; ok {
-			break
-		}
-
-		if m.blokfall == nil {
-			m.blokfall = New()
-			cmds = append(cmds, m.blokfall.Init())
-		}
-
-		m.players[mpty.ClientId(msg)], cmd = m.blokfall.InsertNewPiece()
-		cmds = append(cmds, cmd)
-
-		// TODO: system connected to blokfall
-		m.broadcaster.Write(m.blokfallView())
-		return tea.Batch(cmds...)
-
-	case MPDisconnectPlayerMsg:
+	mpty.Handle(d, m.connectPlayer)
+	mpty.Handle(d, func(msg MPDisconnectPlayerMsg) tea.Cmd {
 		// TODO: system disconnected from blokfall
 		m.removePlayer(mpty.ClientId(msg))
-	case mpty.ClientDisconnectMsg:
+		return m.leaveTable(mpty.ClientId(msg))
+	})
+	mpty.Handle(d, func(msg mpty.ClientDisconnectMsg) tea.Cmd {
 		// TODO: system disconnected from blokfall
 		m.removePlayer(mpty.ClientId(msg))
+		return m.leaveTable(mpty.ClientId(msg))
+	})
 
-	case MPInput:
-		piece := m.players[msg.Id]
-		blokfallMsg = MultiPieceInput{
-			msg.Cmd,
-			piece,
+	mpty.Handle(d, m.joinTable)
+	mpty.Handle(d, func(msg MPCloseTableMsg) tea.Cmd {
+		return m.closeTable(string(msg))
+	})
+	mpty.Handle(d, func(msg tableMsg) tea.Cmd {
+		return m.updateTable(msg.table, msg.msg)
+	})
+
+	mpty.Handle(d, func(msg MPInput) tea.Cmd {
+		if table, ok := m.seats[msg.Id]; ok {
+			return m.updateTable(table, MultiPieceInput{
+				msg.Cmd,
+				m.tables[table].players[msg.Id],
+			})
 		}
+		return m.updateGame(MultiPieceInput{
+			msg.Cmd,
+			m.players[msg.Id],
+		})
+	})
+}
+
+func (m *MPModel) UpdateBlokFall(msg tea.Msg) tea.Cmd {
+	return m.dispatch.Dispatch(msg)
+}
+
+func (m *MPModel) connectPlayer(msg MPConnectPlayerMsg) tea.Cmd {
+	if _, ok := m.players[mpty.ClientId(msg)]; ok {
+		return nil
 	}
 
-	if m.blokfall != nil {
-		var (
-			cmd      tea.Cmd
-			modified bool
-		)
-		m.blokfall, cmd, modified = m.blokfall.UpdateBlokFallShouldRender(blokfallMsg)
-		if modified {
-			m.broadcaster.Write(m.blokfallView())
+	var cmd tea.Cmd
+	cmds := make([]tea.Cmd, 0, 3)
+	if m.blokfall == nil {
+		m.blokfall = New()
+		cmds = append(cmds, m.blokfall.Init())
+		if m.resume != nil {
+			m.blokfall.Restore(*m.resume)
+			m.resume = nil
 		}
-		return cmd
 	}
 
-	return nil
+	m.players[mpty.ClientId(msg)], cmd = m.blokfall.InsertNewPiece()
+	cmds = append(cmds, cmd)
+
+	// TODO: system connected to blokfall
+	m.broadcaster.Write(MPPlayerJoinedMsg(msg))
+	m.dirty = true
+	cmds = append(cmds, m.startFrames())
+	return tea.Batch(cmds...)
+}
+
+// updateGame updates the shared game with msg, if one is being played.
+func (m *MPModel) updateGame(msg tea.Msg) tea.Cmd {
+	if m.blokfall == nil {
+		return nil
+	}
+
+	var (
+		cmd      tea.Cmd
+		modified bool
+	)
+	m.blokfall, cmd, modified = m.blokfall.UpdateBlokFallShouldRender(msg)
+	m.dirty = m.dirty || modified
+	if m.blokfall.Over() != m.over {
+		m.over = m.blokfall.Over()
+		if m.over {
+			cmd = tea.Batch(cmd, m.gameOver("", m.blokfall, slices.Sorted(maps.Keys(m.players))))
+		}
+	}
+	return cmd
 }
 
 func (m *MPModel) removePlayer(id mpty.ClientId) {
@@ -101,9 +243,38 @@ func (m *MPModel) removePlayer(id mpty.ClientId) {
 	if len(m.players) == 0 {
 		m.broadcaster.Write(MPView(nil))
 		m.blokfall = nil
+		m.dirty = false
+		m.over = false
 	} else {
+		m.dirty = true
+	}
+}
+
+// startFrames ticks the frames of a game unless they already are.
+func (m *MPModel) startFrames() tea.Cmd {
+	if m.framing {
+		return nil
+	}
+	m.framing = true
+	return nextFrame()
+}
+
+// frame broadcasts the view when the game changed during the frame that
+// ended, the frames stop with the game.
+func (m *MPModel) frame() tea.Cmd {
+	if m.blokfall == nil {
+		m.framing = false
+		return nil
+	}
+	if m.dirty {
+		m.dirty = false
 		m.broadcaster.Write(m.blokfallView())
 	}
+	return nextFrame()
+}
+
+func nextFrame() tea.Cmd {
+	return tea.Tick(FrameInterval, func(t time.Time) tea.Msg { return frameMsg(t) })
 }
 
 func (m *MPModel) blokfallView() MPView {
@@ -113,3 +284,136 @@ func (m *MPModel) blokfallView() MPView {
 	v = lipgloss.JoinHorizontal(lipgloss.Top, inputs, v)
 	return MPView(&v)
 }
+
+// joinTable seats a player at a table, they leave the table they were
+// seated at before.
+func (m *MPModel) joinTable(msg MPJoinTableMsg) tea.Cmd {
+	if m.seats[msg.Id] == msg.Table {
+		return nil
+	}
+	cmds := []tea.Cmd{m.leaveTable(msg.Id)}
+
+	t, ok := m.tables[msg.Table]
+	if !ok {
+		t = &mpTable{game: New(), players: make(map[mpty.ClientId]int, 1)}
+		m.tables[msg.Table] = t
+		cmds = append(cmds, tableCmd(msg.Table, t.game.Init()))
+	}
+
+	var cmd tea.Cmd
+	t.players[msg.Id], cmd = t.game.InsertNewPiece()
+	t.seated = append(t.seated, msg.Id)
+	m.seats[msg.Id] = msg.Table
+	cmds = append(cmds, tableCmd(msg.Table, cmd))
+
+	m.broadcaster.Write(m.tableView(msg.Table, t))
+	return tea.Batch(cmds...)
+}
+
+// leaveTable removes a player from their table, the table closes once it
+// has no players left.
+func (m *MPModel) leaveTable(id mpty.ClientId) tea.Cmd {
+	table, ok := m.seats[id]
+	if !ok {
+		return nil
+	}
+	delete(m.seats, id)
+
+	t := m.tables[table]
+	t.game.RemovePiece(t.players[id])
+	delete(t.players, id)
+	if len(t.players) == 0 {
+		return m.closeTable(table)
+	}
+	m.broadcaster.Write(m.tableView(table, t))
+	return nil
+}
+
+// closeTable ends the game at table and sends its result.
+func (m *MPModel) closeTable(table string) tea.Cmd {
+	t, ok := m.tables[table]
+	if !ok {
+		return nil
+	}
+	delete(m.tables, table)
+	for id := range t.players {
+		delete(m.seats, id)
+	}
+
+	m.broadcaster.Write(MPTableView{Table: table})
+	return m.gameOver(table, t.game, t.seated)
+}
+
+func (m *MPModel) updateTable(table string, msg tea.Msg) tea.Cmd {
+	t, ok := m.tables[table]
+	if !ok {
+		// the table closed while the message was pending
+		return nil
+	}
+
+	game, cmd, modified := t.game.UpdateBlokFallShouldRender(msg)
+	if game.Over() {
+		return m.closeTable(table)
+	}
+	if modified {
+		m.broadcaster.Write(m.tableView(table, t))
+	}
+	return tableCmd(table, cmd)
+}
+
+func (m *MPModel) tableView(table string, t *mpTable) MPTableView {
+	v := t.game.View()
+	return MPTableView{Table: table, View: &v}
+}
+
+// gameOver returns the command that sends the result of game to the
+// program, where it is recorded.
+func (m *MPModel) gameOver(table string, game *Model, players []mpty.ClientId) tea.Cmd {
+	msg := GameOverMsg{
+		At:      m.now,
+		Table:   table,
+		Players: players,
+		Result:  game.Result(),
+	}
+	if msg.At.IsZero() {
+		msg.At = time.Now()
+	}
+	return func() tea.Msg { return msg }
+}
+
+// tableCmd tags the messages of cmd with table, so they are delivered to the
+// game at the table.
+func tableCmd(table string, cmd tea.Cmd) tea.Cmd {
+	if cmd == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		switch msg := cmd().(type) {
+		case nil:
+			return nil
+		case tea.BatchMsg:
+			cmds := make(tea.BatchMsg, len(msg))
+			for i := range msg {
+				cmds[i] = tableCmd(table, msg[i])
+			}
+			return cmds
+		default:
+			return tableMsg{table: table, msg: msg}
+		}
+	}
+}
+
+// Snapshot returns the running game, or the game waiting to be resumed. It is
+// nil when there is neither. Games at tables aren't included.
+func (m *MPModel) Snapshot() *Snapshot {
+	if m.blokfall == nil {
+		return m.resume
+	}
+	s := m.blokfall.Snapshot()
+	return &s
+}
+
+// Resume continues s when the next game starts.
+func (m *MPModel) Resume(s *Snapshot) {
+	m.resume = s
+}
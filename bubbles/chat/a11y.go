@@ -0,0 +1,57 @@
+package chat
+
+import (
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// In accessible mode the alt screen, table and overlay layout are replaced by
+// printing each new message as a plain line above the command line. Screen
+// readers follow appended output much better than a redrawn viewport.
+
+func (m *Client) queueLinear(msg Msg) {
+	if !m.accessible {
+		return
+	}
+	m.linear = append(m.linear, m.linearLine(msg))
+}
+
+func (m *Client) linearLine(msg Msg) string {
+	var b strings.Builder
+	if m.showTimestamp && !msg.At.IsZero() {
+		b.WriteString(msg.At.Format(time.TimeOnly))
+		b.WriteString(" ")
+	}
+	b.WriteString(m.nickLabel(msg))
+	b.WriteString(": ")
+
+	text := m.msgText(msg)
+	// indent continuation lines so they aren't read as a new speaker
+	b.WriteString(strings.ReplaceAll(text, "\n", "\n  "))
+	return b.String()
+}
+
+// flushLinear prints any messages queued since the last update
+func (m *Client) flushLinear() tea.Cmd {
+	if len(m.linear) == 0 {
+		return nil
+	}
+	cmd := tea.Println(strings.Join(m.linear, "\n"))
+	m.linear = m.linear[:0]
+	return cmd
+}
+
+func (m *Client) setAccessible(on bool) tea.Cmd {
+	m.accessible = on
+	if on {
+		return tea.ExitAltScreen
+	}
+	m.linear = m.linear[:0]
+	return tea.EnterAltScreen
+}
+
+func (m *Client) viewLinear() string {
+	return m.cmdLine.View()
+}
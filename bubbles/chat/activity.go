@@ -0,0 +1,64 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ghthor/webtea/mpty"
+)
+
+// shades are the cells of a Heatmap from no messages to the peak
+var shades = []rune(" ░▒▓█")
+
+// ActivityReq asks for the Activity of the room, see Stats.
+type ActivityReq struct {
+	Requestor mpty.ClientId
+	Activity  Activity
+}
+
+func (m *ServerModel) activityReq(r ActivityReq) ActivityReq {
+	if m.Stats == nil {
+		return r
+	}
+	m.Stats.Read(func(s Stats) {
+		r.Activity = s.Activity
+	})
+	return r
+}
+
+// Peak is the most messages sent in an hour of a.
+func (a Activity) Peak() int {
+	peak := 0
+	for _, day := range a {
+		for _, n := range day {
+			peak = max(peak, n)
+		}
+	}
+	return peak
+}
+
+// Heatmap draws a with a row of block characters per day, starting on
+// Monday, and a column per hour. The shade of a cell is its share of the
+// Peak, any message at all is drawn.
+func (a Activity) Heatmap() string {
+	var b strings.Builder
+	b.WriteString("    ")
+	for h := 0; h < 24; h += 6 {
+		fmt.Fprintf(&b, "%-6d", h)
+	}
+
+	peak := a.Peak()
+	for i := range a {
+		day := (time.Monday + time.Weekday(i)) % 7
+		fmt.Fprintf(&b, "\n%.3s ", day)
+		for _, n := range a[day] {
+			level := 0
+			if peak > 0 {
+				level = (n*(len(shades)-1) + peak - 1) / peak
+			}
+			b.WriteRune(shades[level])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,147 @@
+package chat
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Every feature that wants the users attention rings the bell through
+// Client.Bell so the users BellPolicy is applied consistently. Nothing else
+// should write a raw "\a" to the terminal.
+
+type BellMode int
+
+const (
+	BellAudible BellMode = iota
+	BellVisual
+	BellOff
+)
+
+var bellModes = []string{"audible", "visual", "off"}
+
+func (b BellMode) String() string {
+	if int(b) < len(bellModes) {
+		return bellModes[b]
+	}
+	return fmt.Sprintf("BellMode(%d)", int(b))
+}
+
+func ParseBellMode(s string) (BellMode, bool) {
+	i := slices.Index(bellModes, strings.ToLower(s))
+	if i < 0 {
+		return BellOff, false
+	}
+	return BellMode(i), true
+}
+
+// BellEvent is the reason the bell is being rung.
+type BellEvent string
+
+const (
+	BellMention BellEvent = "mention"
+	// BellGame is rung when another player joins the blokfall game
+	BellGame BellEvent = "game"
+)
+
+// BellPolicy is a clients bell setting and the events it is muted for.
+type BellPolicy struct {
+	Mode  BellMode
+	Muted map[BellEvent]bool
+}
+
+func (p BellPolicy) Rings(ev BellEvent) bool {
+	return p.Mode != BellOff && !p.Muted[ev]
+}
+
+const (
+	bellAudible      = "\a"
+	bellVisualStart  = "\x1b[?5h" // DECSCNM reverse video
+	bellVisualEnd    = "\x1b[?5l"
+	bellFlashTimeout = 150 * time.Millisecond
+)
+
+type bellFlashDoneMsg struct{}
+
+type bellState struct {
+	ringing  bool
+	flashing bool
+	restore  bool
+}
+
+// Bell rings the bell for ev according to the clients policy. The audible
+// bell is written with the next frame and the visual bell flashes the screen
+// in reverse video for a moment.
+func (m *Client) Bell(ev BellEvent) tea.Cmd {
+	if !m.bellPolicy.Rings(ev) {
+		return nil
+	}
+
+	switch m.bellPolicy.Mode {
+	case BellAudible:
+		m.bell.ringing = true
+	case BellVisual:
+		if m.bell.flashing {
+			return nil
+		}
+		m.bell.flashing = true
+		return tea.Tick(bellFlashTimeout, func(time.Time) tea.Msg {
+			return bellFlashDoneMsg{}
+		})
+	}
+	return nil
+}
+
+func (m *Client) viewBell(w io.Writer) {
+	if m.bell.ringing {
+		fmt.Fprint(w, bellAudible)
+		m.bell.ringing = false
+	}
+	if m.bell.flashing {
+		fmt.Fprint(w, bellVisualStart)
+	} else if m.bell.restore {
+		fmt.Fprint(w, bellVisualEnd)
+		m.bell.restore = false
+	}
+}
+
+func (m *Client) updateBell(msg tea.Msg) {
+	if _, ok := msg.(bellFlashDoneMsg); ok {
+		m.bell.flashing = false
+		m.bell.restore = true
+	}
+}
+
+// mentions reports if msg from someone else contains @nick or @name of this
+// client.
+func (m *Client) mentions(msg Msg) bool {
+	return mentions(msg, m.info.Identity(), m.profiles)
+}
+
+// mentions reports if msg from someone other than self contains @nick or
+// @name of self, its name is the display name of its profile.
+func mentions(msg Msg, self string, profiles map[string]Profile) bool {
+	if msg.Who == self {
+		return false
+	}
+	switch msg.Who {
+	case SysNick, InfoNick, HelpNick, ErrNick:
+		return false
+	}
+
+	str := strings.ToLower(msg.Str)
+	names := []string{NickFromWho(self), Display.Name(self, "")}
+	if p, ok := profiles[self]; ok && p.DisplayName != "" {
+		names = append(names, p.DisplayName)
+	}
+	for _, name := range names {
+		if strings.Contains(str, "@"+strings.ToLower(name)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,281 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+	"github.com/ghthor/webtea/bubbles/blokfall"
+	"github.com/ghthor/webtea/logpolicy"
+	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/roles"
+)
+
+// handleBroadcasts registers the handlers of the messages broadcast by the
+// ServerModel and the mpty Program.
+func (m *Client) handleBroadcasts() {
+	d := &m.broadcast
+
+	mpty.Handle(d, func(msg time.Time) tea.Cmd {
+		var cmd tea.Cmd
+		m.info, cmd = m.info.UpdateInfo(msg)
+		return cmd
+	})
+	mpty.Handle(d, func(msg mpty.GapMsg) tea.Cmd {
+		m.PrintInfoMsg(m.t("chat.missed", msg.Next-msg.After-1))
+		return nil
+	})
+	mpty.Handle(d, func(msg mpty.ResumeMsg) tea.Cmd {
+		m.resume = msg.Token
+		return nil
+	})
+
+	mpty.Handle(d, func(msg Msg) tea.Cmd {
+		if msg.Who == SysNick && m.quiet {
+			return nil
+		}
+		m.chatData.Push(msg)
+		if m.mentions(msg) {
+			return m.Bell(BellMention)
+		}
+		return nil
+	})
+	mpty.Handle(d, func(msg NamesReq) tea.Cmd {
+		if msg.Requestor == m.Id() {
+			m.chatData.Push(SysMsg(m.info.Time,
+				m.t("chat.names", len(msg.Names), strings.Join(msg.Names, ", ")),
+			))
+			if len(msg.Tailnet) > 0 {
+				m.chatData.Push(SysMsg(m.info.Time,
+					m.t("chat.names.tailnet", len(msg.Tailnet), strings.Join(msg.Tailnet, ", ")),
+				))
+			}
+		}
+		return nil
+	})
+	mpty.Handle(d, func(msg ActivityReq) tea.Cmd {
+		if msg.Requestor != m.Id() {
+			return nil
+		}
+		if peak := msg.Activity.Peak(); peak == 0 {
+			m.PrintInfoMsg(m.t("chat.stats.empty"))
+		} else {
+			m.PrintInfoMsg(m.t("chat.activity", peak) + "\n" + msg.Activity.Heatmap())
+		}
+		return nil
+	})
+	mpty.Handle(d, func(msg StatsReq) tea.Cmd {
+		if msg.Requestor != m.Id() {
+			return nil
+		}
+		if len(msg.Talkers) == 0 {
+			m.PrintInfoMsg(m.t("chat.stats.empty"))
+		} else {
+			m.PrintInfoMsg(m.t("chat.stats", formatCounts(msg.Talkers), formatCounts(msg.Words)))
+		}
+		return nil
+	})
+	mpty.Handle(d, func(msg InfoReq) tea.Cmd {
+		if msg.Requestor == m.Id() {
+			m.printInfo(msg)
+		}
+		return nil
+	})
+	mpty.Handle(d, func(msg WhoisReq) tea.Cmd {
+		if msg.Requestor != m.Id() {
+			return nil
+		}
+		if len(msg.Results) == 0 {
+			m.PrintInfoMsg(m.t("chat.user_not_found"))
+		} else {
+			m.PrintInfoMsg("\n" + strings.Join(msg.Results, "\n"))
+		}
+		return nil
+	})
+	mpty.Handle(d, func(msg FindReq) tea.Cmd {
+		if msg.Requestor != m.Id() {
+			return nil
+		}
+		if len(msg.Results) == 0 {
+			m.PrintInfoMsg(m.t("chat.find.none", msg.Query))
+			return nil
+		}
+		for _, r := range msg.Results {
+			m.PrintInfoMsg(m.t("chat.find.result", r.Name, r.Who, r.Sessions, FormatTimeAsAge(r.Since, m.info.Time)))
+		}
+		return nil
+	})
+	mpty.Handle(d, func(msg KickResult) tea.Cmd {
+		if msg.Requestor == m.Id() {
+			m.PrintInfoMsg(m.t("chat.kicked", msg.Kicked, msg.User))
+		}
+		return nil
+	})
+	mpty.Handle(d, func(msg ProfilesMsg) tea.Cmd {
+		m.profiles = msg
+		m.chatData.relabel()
+		return nil
+	})
+	mpty.Handle(d, func(msg RoomMsg) tea.Cmd {
+		room := RoomConfig(msg)
+		switch {
+		case !m.roomJoined && room.MOTD != "":
+			m.PrintInfoMsg(m.t("chat.room.motd", room.MOTD))
+		case m.roomJoined && room.Topic != m.room.Topic:
+			m.PrintInfoMsg(m.t("chat.room.topic", room.Topic))
+		}
+		m.room, m.roomJoined = room, true
+		m.setTitleVars()
+		m.viewportResize()
+		m.setTableOffset()
+		return nil
+	})
+	mpty.Handle(d, func(msg GuestPendingMsg) tea.Cmd {
+		switch {
+		case mpty.NewClientId(msg.Msg.Who, msg.Msg.Sess) == m.Id():
+			m.PrintInfoMsg(m.t("chat.guest.held"))
+		case m.info.Access.Can(roles.CanApproveGuests):
+			m.PrintInfoMsg(m.t("chat.guest.pending", m.nickLabel(msg.Msg), msg.Msg.Str, msg.Id))
+		}
+		return nil
+	})
+	mpty.Handle(d, func(msg GuestResult) tea.Cmd {
+		switch {
+		case msg.Requestor == m.Id() && msg.Err != "":
+			m.PrintInfoMsg(m.t("chat.guest.failed", msg.Text(m.locale)))
+		case msg.Requestor == m.Id() && msg.Approved:
+			m.PrintInfoMsg(m.t("chat.guest.you_approved", msg.Guest.Identity()))
+		case msg.Requestor == m.Id():
+			m.PrintInfoMsg(m.t("chat.guest.you_denied", msg.Guest.Identity()))
+		case msg.Guest == m.Id() && msg.Approved:
+			m.info.Access = msg.Access
+			m.PrintInfoMsg(m.t("chat.guest.approved"))
+		case msg.Guest == m.Id():
+			m.PrintInfoMsg(m.t("chat.guest.denied"))
+		}
+		return nil
+	})
+	mpty.Handle(d, func(msg RoomErr) tea.Cmd {
+		if msg.Requestor == m.Id() {
+			m.PrintInfoMsg(m.t("chat.room.refused", msg.Text(m.locale)))
+		}
+		return nil
+	})
+	mpty.Handle(d, func(msg ScheduleResult) tea.Cmd {
+		if msg.Requestor != m.Id() {
+			return nil
+		}
+		switch {
+		case msg.Err != "":
+			m.PrintInfoMsg(m.t("chat.schedule.refused", msg.Err))
+		case msg.Cancelled:
+			m.PrintInfoMsg(m.t("chat.schedule.cancelled", msg.Id))
+		default:
+			m.PrintInfoMsg(m.t("chat.schedule.scheduled", msg.Id, m.untilTick(msg.At)))
+		}
+		return nil
+	})
+	mpty.Handle(d, func(msg ScheduleListReq) tea.Cmd {
+		if msg.Requestor != m.Id() {
+			return nil
+		}
+		if len(msg.Scheduled) == 0 {
+			m.PrintInfoMsg(m.t("chat.schedule.none"))
+			return nil
+		}
+		for _, s := range msg.Scheduled {
+			m.PrintInfoMsg(m.t("chat.schedule.item", s.Id, m.untilTick(s.At), s.Str))
+		}
+		return nil
+	})
+	mpty.Handle(d, func(msg WelcomeBackMsg) tea.Cmd {
+		if msg.Requestor == m.Id() {
+			m.PrintInfoMsg(m.t("chat.welcome_back", FormatTimeAsAge(msg.Since, m.info.Time), msg.Messages, msg.Mentions, msg.Games))
+		}
+		return nil
+	})
+	mpty.Handle(d, func(msg DraftMsg) tea.Cmd {
+		m.restoreDraft(msg)
+		return nil
+	})
+	mpty.Handle(d, func(msg ProfileErr) tea.Cmd {
+		if msg.Requestor == m.Id() {
+			m.PrintInfoMsg(m.t("chat.profile.not_updated", msg.Err))
+		}
+		return nil
+	})
+
+	mpty.Handle(d, func(msg blokfall.MPView) tea.Cmd {
+		if m.blokfallTable == "" {
+			m.blokfallView = msg
+		}
+		return nil
+	})
+	mpty.Handle(d, func(msg blokfall.MPTableView) tea.Cmd {
+		if msg.Table == "" || msg.Table != m.blokfallTable {
+			return nil
+		}
+		if msg.View == nil {
+			// the match is over
+			m.blokfallTable, m.blokfallView = "", nil
+			return m.leaveBlokFall()
+		}
+		m.blokfallView = blokfall.MPView(msg.View)
+		return nil
+	})
+	mpty.Handle(d, func(msg TournamentSeatMsg) tea.Cmd {
+		if msg.Id != m.Id() {
+			return nil
+		}
+		m.blokfallTable, m.blokfallView = msg.Table, nil
+		m.enterBlokFall()
+		m.PrintInfoMsg(m.t("chat.tournament.seated", m.untilTick(msg.Until)))
+		return m.Bell(BellGame)
+	})
+	mpty.Handle(d, func(msg TournamentErr) tea.Cmd {
+		if msg.Requestor == m.Id() {
+			m.PrintInfoMsg(m.t("chat.tournament.refused", msg.Err))
+		}
+		return nil
+	})
+	mpty.Handle(d, func(msg blokfall.MPPlayerJoinedMsg) tea.Cmd {
+		if m.blokfallConnected && mpty.ClientId(msg) != m.Id() {
+			return m.Bell(BellGame)
+		}
+		return nil
+	})
+
+	mpty.Handle(d, func(msg mpty.MaintenanceMsg) tea.Cmd {
+		if msg.Reason == "" {
+			m.PrintInfoMsg(m.t("chat.maintenance", m.untilTick(msg.At)))
+		} else {
+			m.PrintInfoMsg(m.t("chat.maintenance.reason", m.untilTick(msg.At), msg.Reason))
+		}
+		return nil
+	})
+	mpty.Handle(d, func(msg mpty.AnnounceMsg) tea.Cmd {
+		m.PrintInfoMsg(m.t("chat.announce", msg.Str))
+		return nil
+	})
+
+	mpty.Handle(d, func(msg mpty.MemoryPressureMsg) tea.Cmd {
+		m.chatData.resize(msg.Keep(chatHistory, minChatHistory))
+		return nil
+	})
+
+	mpty.Handle(d, func(mpty.ClientConnectMsg) tea.Cmd { return nil })
+	mpty.Handle(d, func(mpty.ClientDisconnectMsg) tea.Cmd { return nil })
+
+	mpty.Handle(d, func(err error) tea.Cmd {
+		m.err = err
+		log.Warn("client fatal", "error", err, "who", m.info.Who.UserProfile.LoginName, "sess", m.info.SessionId)
+		return tea.Quit
+	})
+
+	d.Unhandled = func(msg tea.Msg) tea.Cmd {
+		logpolicy.Warn(fmt.Sprintf("chat.unhandled.%T", msg), "unhandled broadcast message", "type", fmt.Sprintf("%T", msg))
+		return nil
+	}
+}
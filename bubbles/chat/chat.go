@@ -4,6 +4,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ghthor/webtea/i18n"
 	"github.com/ghthor/webtea/mpty/mptymsg"
 )
 
@@ -25,12 +26,22 @@ type Msg struct {
 	Sess string
 	Str  string
 
+	// Device is the device the message was sent from, it is shown under
+	// DisplayDevice
+	Device string `json:",omitempty"`
+
+	// Key and Args are set on messages generated by the server so each
+	// client can render Str in its own locale.
+	Key  string   `json:",omitempty"`
+	Args []string `json:",omitempty"`
+
 	nick string
 
 	recId int64
+	seq   uint64
 }
 
-var _ mptymsg.Recordable = Msg{}
+var _ mptymsg.Sequenced = Msg{}
 
 func (m Msg) TypeName() string {
 	return "chat.Msg"
@@ -45,14 +56,35 @@ func (m Msg) SetId(id int64) mptymsg.Recordable {
 	return m
 }
 
+func (m Msg) Seq() uint64 {
+	return m.seq
+}
+
+func (m Msg) SetSeq(seq uint64) mptymsg.Recordable {
+	m.seq = seq
+	return m
+}
+
 func (m Msg) Id() string {
 	return m.Who + " " + m.Sess
 }
 
+func (m Msg) args() []any {
+	return anys(m.Args)
+}
+
+// anys converts the Args of a translated message for i18n.T.
+func anys(strs []string) []any {
+	args := make([]any, len(strs))
+	for i := range strs {
+		args[i] = strs[i]
+	}
+	return args
+}
+
 func (m Msg) Nick() string {
 	if m.nick == "" {
-		return NickFromWho(m.Who)
-
+		return Display.Name(m.Who, m.Device)
 	}
 	return m.nick
 }
@@ -62,7 +94,7 @@ func (m Msg) SetNick(s ...string) Msg {
 		return m
 	}
 	if len(s) == 0 {
-		m.nick = NickFromWho(m.Who)
+		m.nick = Display.Name(m.Who, m.Device)
 	} else {
 		m.nick = s[0]
 	}
@@ -104,6 +136,15 @@ func ErrMsg(t time.Time, err error) Msg {
 	}
 }
 
+// SysMsgT is a system message that is translated by each client.
+func SysMsgT(t time.Time, key string, args ...string) Msg {
+	m := SysMsg(t, "")
+	m.Key = key
+	m.Args = args
+	m.Str = i18n.T(i18n.Default, key, m.args()...)
+	return m
+}
+
 func SysMsg(t time.Time, msg string) Msg {
 	return Msg{
 		At:   t,
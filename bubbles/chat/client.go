@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/charmbracelet/bubbles/textinput"
@@ -12,10 +13,12 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/table"
-	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/x/ansi"
 	"github.com/ghthor/webtea/bubbles/blokfall"
+	"github.com/ghthor/webtea/i18n"
 	"github.com/ghthor/webtea/mpty"
 	"github.com/ghthor/webtea/mpty/mptymsg"
+	"github.com/ghthor/webtea/roles"
 	"github.com/ghthor/webtea/teamodel"
 	"github.com/ghthor/webtea/unsafering"
 	overlay "github.com/rmhubbert/bubbletea-overlay"
@@ -66,6 +69,13 @@ var (
 	StyleSysMsg = StyleMsgCol.Faint(true)
 )
 
+const (
+	// chatHistory is how many messages a client keeps for scrollback, and
+	// minChatHistory the fewest it's shrunk to under memory pressure
+	chatHistory    = 300
+	minChatHistory = 50
+)
+
 func NewClient(ctx context.Context, info *mpty.ClientInfoModel, cmds ...Cmd) *Client {
 	m := &Client{
 		ctx: ctx,
@@ -73,9 +83,15 @@ func NewClient(ctx context.Context, info *mpty.ClientInfoModel, cmds ...Cmd) *Cl
 		info: info,
 
 		table:    table.New(),
-		chatData: newChatData(300),
+		chatData: newChatData(chatHistory),
+		profiles: make(map[string]Profile),
+		locale:   info.Locale,
+		theme:    DetectTheme(info.Term, info.Env).WithRenderer(info.Renderer()),
 	}
+	m.chatData.label = m.nickLabel
+	m.chatData.onPush = m.queueLinear
 	m.SetupCmdPalette(cmds...)
+	m.handleBroadcasts()
 	return m
 }
 
@@ -83,24 +99,49 @@ type chatData struct {
 	*unsafering.Buffer[Msg]
 	nickWidths *unsafering.Buffer[int]
 	nickWidth  int
+
+	label  func(Msg) string
+	onPush func(Msg)
 }
 
 func newChatData(sz int) *chatData {
 	return &chatData{
 		Buffer:     unsafering.New[Msg](sz),
 		nickWidths: unsafering.New[int](sz),
+		label:      Msg.Nick,
 	}
 }
 
 func (c *chatData) Push(m Msg) {
 	c.Buffer.Push(m)
-	c.nickWidths.Push(lipgloss.Width(m.Nick()))
+	c.nickWidths.Push(lipgloss.Width(c.label(m)))
+	c.nickWidth = c.NickMaxWidth()
+	if c.onPush != nil {
+		c.onPush(m)
+	}
+}
+
+// resize keeps at most size of the most recent messages
+func (c *chatData) resize(size int) {
+	if size == c.Cap() {
+		return
+	}
+	c.Buffer.Resize(size)
+	c.nickWidths.Resize(size)
+	c.nickWidth = c.NickMaxWidth()
+}
+
+// relabel recomputes the nick column widths after the labels have changed
+func (c *chatData) relabel() {
+	for m := range c.Buffer.Iter() {
+		c.nickWidths.Push(lipgloss.Width(c.label(m)))
+	}
 	c.nickWidth = c.NickMaxWidth()
 }
 
 func (c chatData) NickMaxWidth() int {
 	w := 0
-	for m := range c.nickWidths.Iter() {
+	for m := range c.nickWidths.IterRecent(c.Len()) {
 		w = max(w, m)
 	}
 	return w
@@ -126,15 +167,51 @@ type Client struct {
 	view  viewport.Model
 
 	chatData *chatData
+	profiles map[string]Profile
 
 	blokfallView      blokfall.MPView
 	blokfallConnected bool
+	// blokfallTable is the table the client is seated at for a tournament
+	// match, the shared game isn't shown while it's set
+	blokfallTable string
 
 	overlay *overlay.Model
 
+	locale i18n.Locale
+
+	theme Theme
+
+	bellPolicy BellPolicy
+	bell       bellState
+
+	accessible bool
+	linear     []string
+
 	quiet         bool
 	showTimestamp bool
 
+	// ignored are the users whose messages are hidden, see /ignore
+	ignored []string
+
+	// draft is the message being composed, saved on the server so it
+	// survives a disconnect
+	draft draftState
+
+	// resume is the token to reconnect with, see /resume
+	resume string
+
+	// room is the last RoomConfig broadcast, its topic is shown above the
+	// command line
+	room       RoomConfig
+	roomJoined bool
+	// roomName is the room joined with /join, empty for the room connected to
+	roomName string
+
+	// titleVars are read by the terminal outside of the program
+	titleVars atomic.Pointer[map[string]any]
+
+	broadcast mpty.Dispatcher
+
 	debug bool
 
 	err error
@@ -142,11 +219,17 @@ type Client struct {
 
 var _ table.Data = &Client{}
 var _ mpty.ClientModel = &Client{}
+var _ mpty.TitleVarser = &Client{}
 
 func (m *Client) Id() mpty.ClientId {
 	return m.info.Id()
 }
 
+// Access is the role of the client, it is checked when joining the room.
+func (m *Client) Access() roles.Access {
+	return m.info.Access
+}
+
 func (m *Client) Err() error {
 	return m.err
 }
@@ -181,15 +264,34 @@ func (m *Client) At(row, cell int) string {
 		}
 		return msg.At.Format(time.TimeOnly)
 	case COL_WHO:
-		return msg.Nick()
+		return m.nickLabel(msg)
 	case COL_MSG:
-		return msg.Str
+		return m.msgText(msg)
 	default:
 	}
 
 	return ""
 }
 
+func (m *Client) msgText(msg Msg) string {
+	str := msg.Str
+	if msg.Key != "" {
+		str = i18n.T(m.locale, msg.Key, msg.args()...)
+	}
+	return m.theme.Prefix[msg.Who] + str
+}
+
+func (m *Client) nickLabel(msg Msg) string {
+	switch msg.Who {
+	case SysNick, InfoNick, HelpNick, ErrNick:
+		return msg.Nick()
+	}
+	if p, ok := m.profiles[msg.Who]; ok {
+		return p.Label()
+	}
+	return msg.Nick()
+}
+
 func (m *Client) Rows() int {
 	return m.chatData.Len()
 }
@@ -210,15 +312,15 @@ func (m *Client) styleFunc(row, col int) lipgloss.Style {
 		}
 	case COL_TS:
 		if m.showTimestamp {
-			return StyleTSCol
+			return m.theme.TSCol
 		} else {
 			return StyleZeroWidth
 		}
 	case COL_WHO:
-		s := StyleNick
+		s := m.theme.Nick
 		switch msg.Who {
 		case SysNick, InfoNick, HelpNick:
-			s = StyleSysNick
+			s = m.theme.SysNick
 		}
 		// return s
 		width := m.chatData.nickWidth + 1 + 1 // padding + border
@@ -228,9 +330,9 @@ func (m *Client) styleFunc(row, col int) lipgloss.Style {
 	case COL_MSG:
 		switch msg.Who {
 		case SysNick, InfoNick, HelpNick:
-			return StyleSysMsg
+			return m.theme.SysMsg
 		}
-		return StyleMsgCol
+		return m.theme.MsgCol
 
 	default:
 	}
@@ -301,6 +403,15 @@ func (m *Client) UpdateClient(msg tea.Msg) (mpty.ClientModel, tea.Cmd) {
 	case ChatSizeMsg:
 		m.SetSize(msg.Width, msg.Height)
 
+	case CmdLineMsg:
+		cmds = append(cmds, m.runCmdLine(string(msg)))
+
+	case confirmedMsg:
+		cmds = append(cmds, m.confirmed(msg))
+
+	case draftTickMsg:
+		cmds = append(cmds, m.saveDraft(msg))
+
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "ctrl+c":
@@ -317,7 +428,20 @@ func (m *Client) UpdateClient(msg tea.Msg) (mpty.ClientModel, tea.Cmd) {
 		}
 
 	case blokfall.MPView:
-		m.blokfallView = msg
+		if m.blokfallTable == "" {
+			m.blokfallView = msg
+		}
+
+	case mpty.SessionExpiringMsg:
+		m.PrintInfoMsg(m.t("chat.session.expiring", m.untilTick(msg.At)))
+
+	case mpty.ResumeMsg:
+		m.resume = msg.Token
+
+	case mpty.RoomJoinedMsg:
+		cmds = append(cmds, m.joinedRoom(msg.Room))
+	case mpty.RoomJoinErr:
+		m.PrintInfoMsg(m.t("chat.join.failed", msg.Room, msg.Err.Error()))
 
 	case []mptymsg.Recordable:
 		// Initial Messages from recorded datastorage
@@ -329,62 +453,65 @@ func (m *Client) UpdateClient(msg tea.Msg) (mpty.ClientModel, tea.Cmd) {
 		}
 
 	case []tea.Msg:
-		for _, msg := range msg {
-			switch msg := msg.(type) {
-			case time.Time:
-				m.info, cmd = m.info.UpdateInfo(msg)
-				cmds = append(cmds, cmd)
-			case Msg:
-				switch msg.Who {
-				case SysNick:
-					if m.quiet {
-						break
-					}
-					fallthrough
-				default:
-					m.chatData.Push(msg)
-				}
-			case NamesReq:
-				if msg.Requestor == m.Id() {
-					m.chatData.Push(SysMsg(m.info.Time,
-						fmt.Sprintf("-> %d connected: %s", len(msg.Names), strings.Join(msg.Names, ", ")),
-					))
-				}
-			case WhoisReq:
-				if msg.Requestor == m.Id() {
-					if len(msg.Results) == 0 {
-						m.PrintInfoMsg("user not found")
-					} else {
-						m.PrintInfoMsg("\n" + strings.Join(msg.Results, "\n"))
-					}
-				}
-			case blokfall.MPView:
-				m.blokfallView = msg
-
-			case mpty.ClientConnectMsg:
-			case mpty.ClientDisconnectMsg:
-
-			case error:
-				m.err = msg
-				log.Warn("client fatal", "error", msg, "who", m.info.Who.UserProfile.LoginName, "sess", m.info.Sess.RemoteAddr().String())
-				return m, tea.Quit
-			default:
-				log.Warnf("unhandled broadcast message type: %T", msg)
-			}
-		}
+		cmds = append(cmds, m.broadcast.DispatchAll(msg))
 		m.setTableOffset()
 	}
 
 	m.cmdLine, cmd = m.cmdLine.Update(msg)
 	cmds = append(cmds, cmd)
+	cmds = append(cmds, m.updateDraft())
 	m.updateSuggestions(msg)
+	m.updateBell(msg)
 
 	cmds = append(cmds, m.updateBlokFall(msg))
+	cmds = append(cmds, m.flushLinear())
 
 	m.cmds = cmds
 	return m, tea.Batch(cmds...)
 }
 
+// joinedRoom clears what the client kept of the room it left, the history
+// of the room it joined follows.
+func (m *Client) joinedRoom(room string) tea.Cmd {
+	var cmd tea.Cmd
+	if m.blokfallConnected {
+		cmd = m.leaveBlokFall()
+	}
+	m.blokfallTable, m.blokfallView = "", nil
+	m.resume = ""
+	// the motd of the joined room is shown like on connecting
+	m.roomJoined = false
+	m.roomName = room
+	m.setTitleVars()
+
+	chatData := newChatData(m.chatData.Cap())
+	chatData.label, chatData.onPush = m.chatData.label, m.chatData.onPush
+	m.chatData = chatData
+	m.PrintInfoMsg(m.t("chat.join.joined", room))
+	return cmd
+}
+
+// TitleVars returns the "room" joined with /join, the "topic" of the room
+// and the "game" being played, for the title of the terminal.
+func (m *Client) TitleVars() map[string]any {
+	if vars := m.titleVars.Load(); vars != nil {
+		return *vars
+	}
+	return map[string]any{}
+}
+
+func (m *Client) setTitleVars() {
+	game := ""
+	if m.blokfallConnected {
+		game = GameBlokfall
+	}
+	m.titleVars.Store(&map[string]any{
+		"room":  m.roomName,
+		"topic": m.room.Topic,
+		"game":  game,
+	})
+}
+
 func (m *Client) updateBlokFall(msg tea.Msg) tea.Cmd {
 	if !m.blokfallConnected {
 		return nil
@@ -411,6 +538,12 @@ func (m *Client) View() string {
 }
 
 func (m *Client) ViewTo(w io.Writer) {
+	m.viewBell(w)
+	if m.accessible {
+		fmt.Fprint(w, m.viewLinear())
+		return
+	}
+
 	// TODO: guard with render bool
 	t := m.table.Render()
 	t = lipgloss.PlaceVertical(m.ChatViewHeight(), lipgloss.Bottom, t)
@@ -431,11 +564,17 @@ func (m *Client) ViewTo(w io.Writer) {
 		fmt.Fprintln(w, v)
 	}
 
+	if m.room.Topic != "" {
+		fmt.Fprintln(w, m.theme.SysMsg.Render(ansi.Truncate(m.room.Topic, max(0, m.Width-2), "…")))
+	}
 	fmt.Fprint(w, m.cmdLine.View())
 }
 
 func (m *Client) ChatViewHeight() int {
-	// win H - cmdline H
+	// win H - cmdline H - topic H
+	if m.room.Topic != "" {
+		return max(0, m.Height-2)
+	}
 	return max(0, m.Height-1)
 }
 
@@ -493,16 +632,34 @@ func (m *Client) cmdLineExecute() tea.Cmd {
 	m.chatData.Push(Msg{
 		At:   m.info.Time,
 		Who:  m.info.Who.UserProfile.LoginName,
-		Sess: m.info.Sess.RemoteAddr().String(),
+		Sess: m.info.SessionId,
 		Str:  value,
 	})
 
+	return m.runCmdLine(argsStr)
+}
+
+// runCmdLine runs the command line, without the leader.
+func (m *Client) runCmdLine(line string) tea.Cmd {
+	cmd, _, _ := strings.Cut(line, " ")
 	c := m.cmdPalette.Find(cmd)
-	if c != nil {
-		return c.Run(c, strings.Split(argsStr, " "))
+	if c == nil {
+		return nil
+	}
+	if c.Confirm && m.info.Confirmer() != nil {
+		return m.confirmCmd(line)
 	}
+	return c.Run(c, strings.Split(line, " "))
+}
 
-	return nil
+// t translates key into the client's locale
+func (m *Client) t(key string, args ...any) string {
+	return i18n.T(m.locale, key, args...)
+}
+
+// untilTick is the time remaining until t, rounded for display
+func (m *Client) untilTick(t time.Time) time.Duration {
+	return max(0, t.Sub(m.info.Time).Round(time.Second))
 }
 
 func (m *Client) PrintInfoMsg(s string) {
@@ -516,13 +673,14 @@ func (m *Client) PrintErrMsg(err error) {
 func (m *Client) sendChatCmd(msg string) tea.Cmd {
 	var (
 		who  = m.info.Who.UserProfile.LoginName
-		sess = m.info.Sess.RemoteAddr().String()
+		sess = m.info.SessionId
 		now  = time.Now()
 		chat = Msg{
-			At:   now,
-			Who:  who,
-			Sess: sess,
-			Str:  msg,
+			At:     now,
+			Who:    who,
+			Sess:   sess,
+			Device: m.info.Device(),
+			Str:    msg,
 		}.SetNick()
 
 		send = m.Send
@@ -541,7 +699,7 @@ func (m *Client) sendChatCmd(msg string) tea.Cmd {
 func (m *Client) sendCountCmd(i int) tea.Cmd {
 	var (
 		who  = m.info.Who.UserProfile.LoginName
-		sess = m.info.Sess.RemoteAddr().String()
+		sess = m.info.SessionId
 
 		send = m.Send
 	)
@@ -3,6 +3,7 @@ package chat
 import (
 	"bytes"
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -11,8 +12,11 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/ghthor/webtea/mpty"
 	"github.com/ghthor/webtea/mpty/mptymsg"
+	"github.com/ghthor/webtea/roles"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/sync/errgroup"
+	"tailscale.com/client/tailscale/apitype"
+	"tailscale.com/tailcfg"
 )
 
 const testdataDir = "testdata"
@@ -79,3 +83,143 @@ func TestClient(t *testing.T) {
 		require.Equal(t, string(expected), got)
 	})
 }
+
+func TestChatDataResize(t *testing.T) {
+	c := newChatData(4)
+	for _, nick := range []string{"a", "longest", "bb", "ccc"} {
+		c.Push(Msg{Str: "hi"}.SetNick(nick))
+	}
+	require.Equal(t, len("longest"), c.nickWidth)
+
+	c.resize(mpty.MemoryPressureMsg{Level: 1}.Keep(4, 1))
+	require.Equal(t, 2, c.Len())
+	require.Equal(t, len("ccc"), c.nickWidth)
+
+	c.resize(mpty.MemoryPressureMsg{Level: 5}.Keep(4, 1))
+	require.Equal(t, 1, c.Len())
+	require.Equal(t, "ccc", c.ReadRecent(1)[0].Nick())
+}
+
+func TestClientJoinedRoom(t *testing.T) {
+	c := NewClient(t.Context(), &mpty.ClientInfoModel{})
+	c.UpdateClient([]mptymsg.Recordable{
+		Msg{Str: "hi"}.SetNick("a"),
+		Msg{Str: "hello"}.SetNick("b"),
+	})
+	c.UpdateClient(mpty.ResumeMsg{Token: "7"})
+	require.Equal(t, 2, c.chatData.Len())
+
+	c.UpdateClient(mpty.RoomJoinedMsg{Room: "games"})
+	require.Equal(t, 1, c.chatData.Len())
+	require.Equal(t, "you joined games", c.chatData.ReadRecent(1)[0].Str)
+	require.Empty(t, c.resume)
+
+	c.UpdateClient(mpty.RoomJoinErr{Room: "nowhere", Err: mpty.ErrUnknownRoom})
+	require.Equal(t, "can't join nowhere: no such room", c.chatData.ReadRecent(1)[0].Str)
+}
+
+func TestClientTitleVars(t *testing.T) {
+	c := NewClient(t.Context(), &mpty.ClientInfoModel{})
+	require.Empty(t, c.TitleVars())
+
+	c.UpdateClient([]tea.Msg{RoomMsg{Topic: "release day"}})
+	require.Equal(t, map[string]any{"room": "", "topic": "release day", "game": ""}, c.TitleVars())
+
+	c.UpdateClient(mpty.RoomJoinedMsg{Room: "games"})
+	c.enterBlokFall()
+	require.Equal(t, map[string]any{"room": "games", "topic": "release day", "game": GameBlokfall}, c.TitleVars())
+}
+
+func TestClientConfirm(t *testing.T) {
+	policy := roles.DefaultPolicy()
+	policy.Logins = map[string]roles.Role{"carol@example.com": roles.Moderator}
+	who := &apitype.WhoIsResponse{UserProfile: &tailcfg.UserProfile{LoginName: "carol@example.com"}}
+	info := &mpty.ClientInfoModel{Who: who, Access: policy.Access(who)}
+
+	var (
+		actions []string
+		refused = errors.New("no agent")
+		err     = refused
+	)
+	info.SetConfirmer(mpty.ConfirmFunc(func(ctx context.Context, action string) error {
+		actions = append(actions, action)
+		return err
+	}))
+	send := make(chan tea.Msg, 1)
+	c := NewClient(t.Context(), info)
+	c.Send = send
+
+	msg := c.runCmdLine("kick bob@example.com")()
+	require.Equal(t, []string{"kick bob@example.com"}, actions)
+	require.Nil(t, c.confirmed(msg.(confirmedMsg)))
+	require.Equal(t, "not confirmed: no agent", c.chatData.ReadRecent(1)[0].Str)
+	require.Empty(t, send)
+
+	err = nil
+	msg = c.runCmdLine("kick bob@example.com")()
+	c.confirmed(msg.(confirmedMsg))()
+	require.Equal(t, KickReq{Requestor: c.Id(), User: "bob@example.com"}, <-send)
+}
+
+func TestClientDraft(t *testing.T) {
+	who := &apitype.WhoIsResponse{UserProfile: &tailcfg.UserProfile{LoginName: "carol@example.com"}}
+	send := make(chan tea.Msg, 1)
+	c := NewClient(t.Context(), &mpty.ClientInfoModel{Who: who})
+	c.Send = send
+
+	c.cmdLine.SetValue("hello wor")
+	require.NotNil(t, c.updateDraft())
+	c.cmdLine.SetValue("hello world")
+	require.NotNil(t, c.updateDraft())
+	require.Nil(t, c.updateDraft())
+
+	// only the draft left unchanged is saved
+	require.Nil(t, c.saveDraft(draftTickMsg{rev: c.draft.rev - 1}))
+	c.saveDraft(draftTickMsg{rev: c.draft.rev})()
+	require.Equal(t, DraftReq{Requestor: c.Id(), Draft: "hello world"}, <-send)
+	require.Nil(t, c.saveDraft(draftTickMsg{rev: c.draft.rev}))
+
+	// commands aren't drafts
+	c.cmdLine.SetValue("/names")
+	require.NotNil(t, c.updateDraft())
+	c.saveDraft(draftTickMsg{rev: c.draft.rev})()
+	require.Equal(t, DraftReq{Requestor: c.Id(), Draft: ""}, <-send)
+
+	c = NewClient(t.Context(), &mpty.ClientInfoModel{Who: who})
+	c.restoreDraft(DraftMsg{Requestor: c.Id(), Draft: "hello world"})
+	require.Equal(t, "hello world", c.cmdLine.Value())
+	require.Equal(t, "(restored draft)", c.chatData.ReadRecent(1)[0].Str)
+	require.Nil(t, c.updateDraft())
+}
+
+func TestPlainClient(t *testing.T) {
+	send := make(chan tea.Msg, 1)
+	info := &mpty.ClientInfoModel{
+		Term:      "dumb",
+		SessionId: "s1",
+		Who:       &apitype.WhoIsResponse{UserProfile: &tailcfg.UserProfile{LoginName: "alice@example.com"}},
+	}
+	require.True(t, info.Dumb())
+
+	m := NewPlainClient(t.Context(), info)
+	require.False(t, m.AltScreen())
+	m.UpdateClient(mpty.Input(send))
+
+	_, cmd := m.UpdateClient([]tea.Msg{Msg{Who: "bob@example.com", Str: "hi\nthere"}.SetNick()})
+	require.NotNil(t, cmd)
+	require.Empty(t, m.printed)
+
+	for _, r := range "hey" {
+		m.UpdateClient(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	m.UpdateClient(tea.KeyMsg{Type: tea.KeyBackspace})
+	require.Equal(t, "> he", m.View())
+
+	_, cmd = m.UpdateClient(tea.KeyMsg{Type: tea.KeyEnter})
+	require.NotNil(t, cmd)
+	cmd()
+	msg := (<-send).(Msg)
+	require.Equal(t, "he", msg.Str)
+	require.Equal(t, "alice@example.com", msg.Who)
+	require.Equal(t, "> ", m.View())
+}
@@ -2,20 +2,24 @@ package chat
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
-	"unicode"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/ghthor/webtea/bubbles/blokfall"
+	"github.com/ghthor/webtea/i18n"
+	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/roles"
 )
 
-func formatToggle(b bool) string {
+func formatToggle(l i18n.Locale, b bool) string {
 	if b {
-		return "ON"
+		return i18n.T(l, "chat.toggle.on")
 	}
 
-	return "OFF"
+	return i18n.T(l, "chat.toggle.off")
 }
 
 /*
@@ -25,11 +29,9 @@ func formatToggle(b bool) string {
 /away [REASON]             - Set away reason, or empty to unset.
 /back                      - Clear away status.
 /focus [USER ...]          - Only show messages from focused users, or $ to reset.
-/ignore [USER]             - Hide messages from USER, /unignore USER to stop hiding.
 /msg USER MESSAGE          - Send MESSAGE to USER.
 /nick NAME                 - Rename yourself.
 /reply MESSAGE             - Reply with MESSAGE to the previous private message.
-/theme [colors|...]        - Set your color theme.
 */
 func (m *Client) SetupCmdPalette(additionalCmds ...Cmd) {
 	cmds := make([]Cmd, 0, 10)
@@ -41,25 +43,9 @@ func (m *Client) SetupCmdPalette(additionalCmds ...Cmd) {
 		Run: func(cmd *Cmd, args []string) tea.Cmd {
 			if !m.blokfallConnected {
 				m.cmdLine.Placeholder = ""
-				m.chatData.Push(HelpMsg(m.info.Time, m.cmdPalette.Usage()))
+				m.chatData.Push(HelpMsg(m.info.Time, m.cmdPalette.Usage(m.locale)))
 			} else if m.blokfallConnected {
-				m.chatData.Push(HelpMsg(m.info.Time, strings.TrimLeftFunc(`
-Each player controls a single piece. They don't collide till they are locked
-into the board enabling pieces to be combined.
-
-    [ d ]  [ f ]   [ g ]     [ j ]  [ k ]
-   ←move    move→  soft↓     ↶ CCW   CW ↷
-
-             [__ space __]
-             ⤓ hard drop ⤓
-
--> Available commands:
-/exit                      - Exit blokfall
-/blokfall reset              - Reset blokfall board
-/blokfall debug              - Toggle debugging mode
-/blokfall level <INT>        - Set current games level (speed)
-
-`, unicode.IsSpace)))
+				m.chatData.Push(HelpMsg(m.info.Time, m.t("chat.blokfall.help")))
 			}
 			return nil
 		},
@@ -99,18 +85,85 @@ into the board enabling pieces to be combined.
 		},
 	})
 
+	// stats
+	cmds = append(cmds, Cmd{
+		Use:   "stats",
+		Short: "Show the most active users and words.",
+		Run: func(cmd *Cmd, args []string) tea.Cmd {
+			var (
+				req  = StatsReq{Requestor: m.Id()}
+				send = m.Send
+			)
+			return func() tea.Msg {
+				select {
+				case <-m.ctx.Done():
+				case send <- req:
+				}
+				return nil
+			}
+		},
+	})
+
+	// activity
+	cmds = append(cmds, Cmd{
+		Use:   "activity",
+		Short: "Show when the room is active as a heatmap.",
+		Run: func(cmd *Cmd, args []string) tea.Cmd {
+			return sendMsgCmd(m.ctx, m.Send, ActivityReq{Requestor: m.Id()})
+		},
+	})
+
+	// version & uptime
+	cmds = append(cmds, Cmd{
+		Use:   "version",
+		Short: "Show the version of the server.",
+		Run: func(cmd *Cmd, args []string) tea.Cmd {
+			var (
+				req  = InfoReq{Requestor: m.Id()}
+				send = m.Send
+			)
+			return func() tea.Msg {
+				select {
+				case <-m.ctx.Done():
+				case send <- req:
+				}
+				return nil
+			}
+		},
+	}, Cmd{
+		Use:   "uptime",
+		Short: "Show how long the server is up and how many are connected.",
+		Run: func(cmd *Cmd, args []string) tea.Cmd {
+			var (
+				req  = InfoReq{Requestor: m.Id(), Uptime: true}
+				send = m.Send
+			)
+			return func() tea.Msg {
+				select {
+				case <-m.ctx.Done():
+				case send <- req:
+				}
+				return nil
+			}
+		},
+	})
+
 	// whois
 	cmds = append(cmds, Cmd{
 		Use:   "whois <USER>",
 		Short: "Infomation about USER",
 		Run: func(cmd *Cmd, args []string) tea.Cmd {
 			if len(args) == 1 {
-				m.PrintInfoMsg("argument required: " + cmd.Use)
+				m.PrintInfoMsg(m.t("chat.arg_required", cmd.Use))
 				return nil
 			}
 
 			var (
-				req  = WhoisReq{Requestor: m.Id(), User: args[1]}
+				req = WhoisReq{
+					Requestor: m.Id(),
+					User:      args[1],
+					Addrs:     m.info.Access.Can(roles.CanViewAddresses),
+				}
 				send = m.Send
 			)
 			return func() tea.Msg {
@@ -123,13 +176,95 @@ into the board enabling pieces to be combined.
 		},
 	})
 
+	// find
+	cmds = append(cmds, Cmd{
+		Use:   "find <USER>",
+		Short: "Find where USER is active by login, nick or name.",
+		Run: func(cmd *Cmd, args []string) tea.Cmd {
+			if len(args) == 1 {
+				m.PrintInfoMsg(m.t("chat.arg_required", cmd.Use))
+				return nil
+			}
+			return sendMsgCmd(m.ctx, m.Send, FindReq{Requestor: m.Id(), Query: strings.Join(args[1:], " ")})
+		},
+	})
+
+	// profile
+	cmds = append(cmds, Cmd{
+		Use:   "profile [name|pronouns|avatar] <VALUE>",
+		Short: "Show or edit your display name, pronouns and avatar badge.",
+		Run: func(cmd *Cmd, args []string) tea.Cmd {
+			p, ok := m.profiles[m.info.Identity()]
+			if !ok {
+				p = Profile{Who: m.info.Identity()}
+			}
+
+			if len(args) == 1 {
+				m.PrintInfoMsg(m.t("chat.profile.show", p.Label(), p.Name(), p.Pronouns, p.Badge()))
+				return nil
+			}
+
+			value := strings.Join(args[2:], " ")
+			switch args[1] {
+			case "name":
+				p.DisplayName = value
+			case "pronouns":
+				p.Pronouns = value
+			case "avatar":
+				p.Avatar = value
+			default:
+				m.PrintInfoMsg(m.t("chat.profile.unknown_field", args[1]))
+				return nil
+			}
+
+			return sendMsgCmd(m.ctx, m.Send, ProfileReq{Requestor: m.Id(), Profile: p})
+		},
+	})
+
 	// quiet
 	cmds = append(cmds, Cmd{
 		Use:   "quiet",
 		Short: "Toggle system announcements.",
 		Run: func(cmd *Cmd, args []string) tea.Cmd {
 			m.quiet = !m.quiet
-			m.chatData.Push(InfoMsg(m.info.Time, fmt.Sprintf("Quiet mode toggled %s", formatToggle(m.quiet))))
+			m.PrintInfoMsg(m.t("chat.quiet_toggled", formatToggle(m.locale, m.quiet)))
+			return nil
+		},
+	})
+
+	// ignore
+	cmds = append(cmds, Cmd{
+		Use:   "ignore [USER]",
+		Short: "Hide messages from USER, /unignore USER to stop hiding.",
+		Run: func(cmd *Cmd, args []string) tea.Cmd {
+			switch {
+			case len(args) == 1 && len(m.ignored) == 0:
+				m.PrintInfoMsg(m.t("chat.ignore.none"))
+			case len(args) == 1:
+				m.PrintInfoMsg(m.t("chat.ignore.list", strings.Join(m.ignored, ", ")))
+			case !slices.Contains(m.ignored, args[1]):
+				m.ignored = append(m.ignored, args[1])
+				fallthrough
+			default:
+				m.PrintInfoMsg(m.t("chat.ignored", args[1]))
+			}
+			return nil
+		},
+	})
+
+	// unignore
+	cmds = append(cmds, Cmd{
+		Use:   "unignore <USER>",
+		Short: "Stop hiding messages from USER.",
+		Run: func(cmd *Cmd, args []string) tea.Cmd {
+			if len(args) == 1 {
+				m.PrintInfoMsg(m.t("chat.arg_required", cmd.Use))
+				return nil
+			}
+			m.ignored = slices.DeleteFunc(m.ignored, func(user string) bool {
+				return user == args[1]
+			})
+			m.PrintInfoMsg(m.t("chat.unignored", args[1]))
 			return nil
 		},
 	})
@@ -141,7 +276,125 @@ into the board enabling pieces to be combined.
 		Short: "Toggle chat timestamps.",
 		Run: func(cmd *Cmd, args []string) tea.Cmd {
 			m.showTimestamp = !m.showTimestamp
-			m.chatData.Push(InfoMsg(m.info.Time, fmt.Sprintf("Timestamp is toggled %s", formatToggle(m.showTimestamp))))
+			m.PrintInfoMsg(m.t("chat.timestamp_toggled", formatToggle(m.locale, m.showTimestamp)))
+			return nil
+		},
+	})
+
+	// resume
+	cmds = append(cmds, Cmd{
+		Use:   "resume",
+		Short: "Show how to reconnect without missing messages.",
+		Run: func(cmd *Cmd, args []string) tea.Cmd {
+			if m.resume == "" {
+				m.PrintInfoMsg(m.t("chat.resume.none"))
+				return nil
+			}
+			m.PrintInfoMsg(m.t("chat.resume", mpty.ResumeEnv, m.resume, mpty.ResumeParam, m.resume))
+			return nil
+		},
+	})
+
+	// join
+	cmds = append(cmds, Cmd{
+		Use:   "join <ROOM>",
+		Short: "Leave this room for ROOM without reconnecting.",
+		Run: func(cmd *Cmd, args []string) tea.Cmd {
+			if len(args) != 2 {
+				m.PrintInfoMsg(m.t("chat.arg_required", cmd.Use))
+				return nil
+			}
+			room := args[1]
+			return func() tea.Msg { return mpty.JoinRoomMsg{Room: room} }
+		},
+	})
+
+	// theme
+	cmds = append(cmds, Cmd{
+		Use:   "theme [default|high-contrast|no-color]",
+		Short: "Show or set your color theme.",
+		Run: func(cmd *Cmd, args []string) tea.Cmd {
+			available := strings.Join(ThemeNames(), ", ")
+			if len(args) == 1 {
+				m.PrintInfoMsg(m.t("chat.theme.current", m.theme.Name, available))
+				return nil
+			}
+
+			theme, ok := FindTheme(args[1])
+			if !ok {
+				m.PrintInfoMsg(m.t("chat.theme.unknown", args[1], available))
+				return nil
+			}
+			m.theme = theme.WithRenderer(m.info.Renderer())
+			m.PrintInfoMsg(m.t("chat.theme.set", m.theme.Name))
+			return nil
+		},
+	})
+
+	// accessible
+	cmds = append(cmds, Cmd{
+		Use:     "accessible",
+		Short:   "Toggle screen reader friendly output.",
+		Aliases: []string{"a11y"},
+		Run: func(cmd *Cmd, args []string) tea.Cmd {
+			modeCmd := m.setAccessible(!m.accessible)
+			m.PrintInfoMsg(m.t("chat.accessible_toggled", formatToggle(m.locale, m.accessible)))
+			return modeCmd
+		},
+	})
+
+	// bell
+	cmds = append(cmds, Cmd{
+		Use:   "bell [audible|visual|off] [mention|game]",
+		Short: "Show or set how you are alerted.",
+		Run: func(cmd *Cmd, args []string) tea.Cmd {
+			if len(args) == 1 {
+				m.PrintInfoMsg(m.t("chat.bell.current", m.bellPolicy.Mode, strings.Join(bellModes, ", ")))
+				return nil
+			}
+
+			mode, ok := ParseBellMode(args[1])
+			if !ok {
+				m.PrintInfoMsg(m.t("chat.bell.unknown", args[1], strings.Join(bellModes, ", ")))
+				return nil
+			}
+
+			// with events only those events are muted or unmuted
+			if len(args) > 2 {
+				if m.bellPolicy.Muted == nil {
+					m.bellPolicy.Muted = make(map[BellEvent]bool)
+				}
+				for _, ev := range args[2:] {
+					m.bellPolicy.Muted[BellEvent(ev)] = mode == BellOff
+				}
+				m.PrintInfoMsg(m.t("chat.bell.events", strings.Join(args[2:], ", "), formatToggle(m.locale, mode != BellOff)))
+				return nil
+			}
+
+			m.bellPolicy.Mode = mode
+			m.PrintInfoMsg(m.t("chat.bell.set", m.bellPolicy.Mode))
+			return m.Bell("")
+		},
+	})
+
+	// lang
+	cmds = append(cmds, Cmd{
+		Use:   "lang [LANG]",
+		Short: "Show or set your language.",
+		Run: func(cmd *Cmd, args []string) tea.Cmd {
+			available := fmt.Sprint(i18n.Locales())
+			if len(args) == 1 {
+				m.PrintInfoMsg(m.t("chat.lang.current", m.locale, available))
+				return nil
+			}
+
+			l, ok := i18n.Parse(args[1])
+			if !ok {
+				m.PrintInfoMsg(m.t("chat.lang.unknown", args[1], available))
+				return nil
+			}
+			m.locale = l
+			m.PrintInfoMsg(m.t("chat.lang.set", m.locale))
 			return nil
 		},
 	})
@@ -153,13 +406,13 @@ into the board enabling pieces to be combined.
 		Hidden: true,
 		Run: func(cmd *Cmd, args []string) tea.Cmd {
 			if len(args) == 1 {
-				m.PrintInfoMsg("argument required: " + cmd.Use)
+				m.PrintInfoMsg(m.t("chat.arg_required", cmd.Use))
 				return nil
 			}
 
 			i, err := strconv.Atoi(args[1])
 			if err != nil {
-				m.PrintInfoMsg(fmt.Sprintf("%s => %v: %s", m.cmdLine.Value(), err, cmd.Use))
+				m.PrintInfoMsg(m.t("chat.arg_invalid", m.cmdLine.Value(), err, cmd.Use))
 				return nil
 			}
 			return m.sendCountCmd(i)
@@ -173,15 +426,126 @@ into the board enabling pieces to be combined.
 		Run: func(cmd *Cmd, args []string) tea.Cmd {
 			m.debug = !m.debug
 			m.cmdPalette.showHidden = m.debug
-			m.chatData.Push(InfoMsg(m.info.Time, fmt.Sprintf("Debug is toggled %s", formatToggle(m.debug))))
+			m.PrintInfoMsg(m.t("chat.debug_toggled", formatToggle(m.locale, m.debug)))
 			return nil
 		},
 	})
 
+	// announce
+	cmds = append(cmds, Cmd{
+		Use:      "announce <MESSAGE>",
+		Short:    "Announce MESSAGE to everyone.",
+		Requires: roles.CanBroadcast,
+		Run: func(cmd *Cmd, args []string) tea.Cmd {
+			if len(args) == 1 {
+				m.PrintInfoMsg(m.t("chat.arg_required", cmd.Use))
+				return nil
+			}
+			return sendMsgCmd(m.ctx, m.Send, mpty.AnnounceMsg{At: m.info.Time, Str: strings.Join(args[1:], " ")})
+		},
+	})
+
+	// kick
+	cmds = append(cmds, Cmd{
+		Use:      "kick <USER>",
+		Short:    "End every session of USER.",
+		Requires: roles.CanKick,
+		Confirm:  true,
+		Run: func(cmd *Cmd, args []string) tea.Cmd {
+			if len(args) == 1 {
+				m.PrintInfoMsg(m.t("chat.arg_required", cmd.Use))
+				return nil
+			}
+			return sendMsgCmd(m.ctx, m.Send, KickReq{Requestor: m.Id(), User: args[1]})
+		},
+	})
+
+	// approve & deny
+	cmds = append(cmds, Cmd{
+		Use:      "approve <ID>",
+		Short:    "Send the message ID of a guest and let them chat.",
+		Requires: roles.CanApproveGuests,
+		Run: func(cmd *Cmd, args []string) tea.Cmd {
+			if len(args) == 1 {
+				m.PrintInfoMsg(m.t("chat.arg_required", cmd.Use))
+				return nil
+			}
+			return sendMsgCmd(m.ctx, m.Send, GuestReq{Requestor: m.Id(), Id: args[1], Approve: true})
+		},
+	})
+	cmds = append(cmds, Cmd{
+		Use:      "deny <ID>",
+		Short:    "Drop the message ID of a guest.",
+		Requires: roles.CanApproveGuests,
+		Run: func(cmd *Cmd, args []string) tea.Cmd {
+			if len(args) == 1 {
+				m.PrintInfoMsg(m.t("chat.arg_required", cmd.Use))
+				return nil
+			}
+			return sendMsgCmd(m.ctx, m.Send, GuestReq{Requestor: m.Id(), Id: args[1]})
+		},
+	})
+
+	// room
+	cmds = append(cmds, Cmd{
+		Use:   "room [set <FIELD> <VALUE>]",
+		Short: "Show the room settings, operators can change them.",
+		Run: func(cmd *Cmd, args []string) tea.Cmd {
+			if len(args) == 1 {
+				r := m.room
+				m.PrintInfoMsg(m.t("chat.room.show", r.Topic, r.MOTD, r.SlowMode, strings.Join(r.Games, ", "), r.Retention, r.MaxMembers,
+					formatToggle(m.locale, r.InviteOnly), formatToggle(m.locale, r.Private), formatToggle(m.locale, r.HasPassword())))
+				return nil
+			}
+			if args[1] != "set" || len(args) < 3 {
+				m.PrintInfoMsg(m.t("chat.arg_required", cmd.Use))
+				return nil
+			}
+			if !m.info.Access.Can(roles.CanConfigureRoom) {
+				m.PrintInfoMsg(m.t("chat.room.denied"))
+				return nil
+			}
+			return sendMsgCmd(m.ctx, m.Send, RoomSetReq{
+				Requestor: m.Id(),
+				Field:     args[2],
+				Value:     strings.Join(args[3:], " "),
+			})
+		},
+	})
+
+	// invite
+	cmds = append(cmds, Cmd{
+		Use:      "invite [USER]",
+		Short:    "Invite USER to the room, or list the invited users.",
+		Requires: roles.CanConfigureRoom,
+		Run: func(cmd *Cmd, args []string) tea.Cmd {
+			if len(args) == 1 {
+				m.PrintInfoMsg(m.t("chat.room.invited", strings.Join(m.room.Invited, ", ")))
+				return nil
+			}
+			return sendMsgCmd(m.ctx, m.Send, RoomInviteReq{Requestor: m.Id(), User: args[1]})
+		},
+	})
+
+	// uninvite
+	cmds = append(cmds, Cmd{
+		Use:      "uninvite <USER>",
+		Short:    "Take back the invite of USER.",
+		Requires: roles.CanConfigureRoom,
+		Run: func(cmd *Cmd, args []string) tea.Cmd {
+			if len(args) == 1 {
+				m.PrintInfoMsg(m.t("chat.arg_required", cmd.Use))
+				return nil
+			}
+			return sendMsgCmd(m.ctx, m.Send, RoomInviteReq{Requestor: m.Id(), User: args[1], Revoke: true})
+		},
+	})
+
 	// blokfall
 	cmds = append(cmds, Cmd{
-		Use:   "blokfall [exit|reset|debug]",
-		Short: "Start/Join multiplayer blokfall.",
+		Use:      "blokfall [exit|reset|debug]",
+		Short:    "Start/Join multiplayer blokfall.",
+		Requires: roles.CanStartGame,
 		Run: func(cmd *Cmd, args []string) tea.Cmd {
 			args1 := ""
 			if len(args) > 1 {
@@ -193,11 +557,12 @@ into the board enabling pieces to be combined.
 				if m.blokfallConnected {
 					return nil
 				}
+				if !m.room.Allows(GameBlokfall) {
+					m.PrintInfoMsg(m.t("chat.room.refused", GameBlokfall+" is not allowed in this room"))
+					return nil
+				}
 
-				m.blokfallConnected = true
-				m.cmdLine.Prompt = "blokfall> "
-				m.cmdLine.Placeholder = "/ to open command line"
-				m.cmdLine.Blur()
+				m.enterBlokFall()
 				return sendMsgCmd(m.ctx, m.Send, blokfall.MPConnectPlayerMsg(m.Id()))
 			case "reset":
 				return sendMsgCmd(m.ctx, m.Send, blokfall.GameResetMsg(0))
@@ -223,18 +588,131 @@ into the board enabling pieces to be combined.
 		},
 	})
 
+	// tournament
+	cmds = append(cmds, Cmd{
+		Use:      "tournament [join|schedule <IN> <MATCH>]",
+		Short:    "Join the blokfall tournament, operators schedule one.",
+		Requires: roles.CanStartGame,
+		Run: func(cmd *Cmd, args []string) tea.Cmd {
+			if len(args) == 1 {
+				m.PrintInfoMsg(m.t("chat.arg_required", cmd.Use))
+				return nil
+			}
+			switch args[1] {
+			case "join":
+				return sendMsgCmd(m.ctx, m.Send, TournamentJoinReq{Requestor: m.Id()})
+			case "schedule":
+				if !m.info.Access.Can(roles.CanConfigureRoom) {
+					m.PrintInfoMsg(m.t("chat.tournament.denied"))
+					return nil
+				}
+				if len(args) < 4 {
+					m.PrintInfoMsg(m.t("chat.arg_required", cmd.Use))
+					return nil
+				}
+				in, err := time.ParseDuration(args[2])
+				if err != nil {
+					m.PrintInfoMsg(m.t("chat.arg_invalid", m.cmdLine.Value(), err, cmd.Use))
+					return nil
+				}
+				match, err := time.ParseDuration(args[3])
+				if err != nil {
+					m.PrintInfoMsg(m.t("chat.arg_invalid", m.cmdLine.Value(), err, cmd.Use))
+					return nil
+				}
+				return sendMsgCmd(m.ctx, m.Send, TournamentScheduleReq{
+					Requestor: m.Id(),
+					At:        m.info.Time.Add(in),
+					Match:     match,
+				})
+			}
+			m.PrintInfoMsg(m.t("chat.arg_required", cmd.Use))
+			return nil
+		},
+	})
+
+	// schedule
+	cmds = append(cmds, Cmd{
+		Use:   "schedule [list|cancel <ID>|<IN> <MESSAGE>]",
+		Short: "Send MESSAGE in IN, e.g. 10m, or list and cancel the scheduled messages.",
+		Run: func(cmd *Cmd, args []string) tea.Cmd {
+			if len(args) == 1 {
+				m.PrintInfoMsg(m.t("chat.arg_required", cmd.Use))
+				return nil
+			}
+			switch args[1] {
+			case "list":
+				return sendMsgCmd(m.ctx, m.Send, ScheduleListReq{Requestor: m.Id()})
+			case "cancel":
+				if len(args) < 3 {
+					m.PrintInfoMsg(m.t("chat.arg_required", cmd.Use))
+					return nil
+				}
+				return sendMsgCmd(m.ctx, m.Send, ScheduleCancelReq{Requestor: m.Id(), Id: args[2]})
+			}
+			// "/schedule in 10m hello" reads better than without the in
+			if args[1] == "in" {
+				args = args[1:]
+			}
+			if len(args) < 3 {
+				m.PrintInfoMsg(m.t("chat.arg_required", cmd.Use))
+				return nil
+			}
+			in, err := time.ParseDuration(args[1])
+			if err != nil {
+				m.PrintInfoMsg(m.t("chat.arg_invalid", m.cmdLine.Value(), err, cmd.Use))
+				return nil
+			}
+			return sendMsgCmd(m.ctx, m.Send, ScheduleReq{
+				Requestor: m.Id(),
+				At:        m.info.Time.Add(in),
+				Str:       strings.Join(args[2:], " "),
+			})
+		},
+	})
+
 	cmds = append(cmds, additionalCmds...)
 
+	// commands the client isn't permitted to run are left out entirely
+	cmds = slices.DeleteFunc(cmds, func(cmd Cmd) bool {
+		return cmd.Requires != "" && !m.info.Access.Can(cmd.Requires)
+	})
+
 	p := NewCmdPalette("/", cmds...)
 	m.cmdPalette = p
 }
 
-func (m *Client) exitBlokFallCmd() tea.Cmd {
+// enterBlokFall sends the keys of the client to the game.
+func (m *Client) enterBlokFall() {
+	m.blokfallConnected = true
+	// the game redraws the whole overlay for every move
+	m.info.SyncOutput().Set(true)
+	m.cmdLine.Prompt = "blokfall> "
+	m.cmdLine.Placeholder = "/ to open command line"
+	m.cmdLine.Blur()
+	m.setTitleVars()
+}
+
+// leaveBlokFall returns the keys of the client to the command line.
+func (m *Client) leaveBlokFall() tea.Cmd {
 	m.blokfallConnected = false
+	m.info.SyncOutput().Set(false)
 	m.cmdLine.Prompt = "> "
 	m.cmdLine.Placeholder = ""
+	m.setTitleVars()
 	if !m.cmdLine.Focused() {
 		return m.cmdLine.Focus()
 	}
+	return nil
+}
+
+func (m *Client) exitBlokFallCmd() tea.Cmd {
+	if m.blokfallTable != "" {
+		// leaving the table ends the match with the score so far
+		m.blokfallTable, m.blokfallView = "", nil
+	}
+	if cmd := m.leaveBlokFall(); cmd != nil {
+		return cmd
+	}
 	return sendMsgCmd(m.ctx, m.Send, blokfall.MPDisconnectPlayerMsg(m.Id()))
 }
@@ -2,6 +2,7 @@ package chat
 
 import (
 	"fmt"
+	"io"
 	"maps"
 	"slices"
 	"sort"
@@ -9,6 +10,8 @@ import (
 	"text/tabwriter"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ghthor/webtea/i18n"
+	"github.com/ghthor/webtea/roles"
 )
 
 type Cmd struct {
@@ -24,6 +27,14 @@ type Cmd struct {
 
 	Hidden bool
 
+	// Requires is the capability needed to use the command
+	Requires roles.Capability
+
+	// Confirm asks the user to confirm the command before it runs, when the
+	// session requires privileged actions to be confirmed, see
+	// mpty.Confirmer
+	Confirm bool
+
 	// Run is the function that is executed for the command
 	Run func(cmd *Cmd, args []string) tea.Cmd
 }
@@ -77,14 +88,12 @@ func (p CmdPalette) Find(cmd string) *Cmd {
 	return nil
 }
 
-func (p CmdPalette) Usage() string {
+// Usage renders the help for every command. Command descriptions are
+// translated using the "cmd.<name>.short" catalog key when one exists.
+func (p CmdPalette) Usage(l i18n.Locale) string {
 	var b strings.Builder
 
-	fmt.Fprintln(&b, strings.TrimSpace(`
-Type out a message and press <enter> or use a command
-
--> Available commands:
-`))
+	fmt.Fprintln(&b, i18n.T(l, "chat.usage.header"))
 
 	{
 		cmds := slices.Sorted(maps.Keys(p.cmds))
@@ -99,20 +108,13 @@ Type out a message and press <enter> or use a command
 				continue
 			}
 
-			fmt.Fprintf(t, "%s%s\t- %s", p.leader, cmd.Use, cmd.Short)
-			if len(cmd.Aliases) > 0 {
-				fmt.Fprintf(t, " (aliases: %s)", strings.Join(cmd.Aliases, ", "))
-			}
-			fmt.Fprintln(t, "\t")
+			p.usageLine(t, l, key, cmd)
 		}
 		t.Flush()
 	}
 
 	if p.showHidden {
-		fmt.Fprint(&b, `
-
--> Hidden commands:
-`)
+		fmt.Fprint(&b, "\n\n"+i18n.T(l, "chat.usage.hidden")+"\n")
 		cmds := slices.Sorted(maps.Keys(p.cmds))
 		t := tabwriter.NewWriter(&b, 1, 1, 2, ' ', 0)
 		for _, key := range cmds {
@@ -125,23 +127,26 @@ Type out a message and press <enter> or use a command
 				continue
 			}
 
-			fmt.Fprintf(t, "%s%s\t- %s", p.leader, cmd.Use, cmd.Short)
-			if len(cmd.Aliases) > 0 {
-				fmt.Fprintf(t, " (aliases: %s)", strings.Join(cmd.Aliases, ", "))
-			}
-			fmt.Fprintln(t, "\t")
+			p.usageLine(t, l, key, cmd)
 		}
 		t.Flush()
 	}
 
-	fmt.Fprint(&b, `
--> For input key mappings see:
+	fmt.Fprint(&b, "\n"+i18n.T(l, "chat.usage.keys")+`
   - https://github.com/charmbracelet/bubbles/blob/v0.21.0/textinput/textinput.go#L68
 `)
 
 	return b.String()
 }
 
+func (p CmdPalette) usageLine(w io.Writer, l i18n.Locale, key string, cmd Cmd) {
+	fmt.Fprintf(w, "%s%s\t- %s", p.leader, cmd.Use, i18n.TOr(l, "cmd."+key+".short", cmd.Short))
+	if len(cmd.Aliases) > 0 {
+		fmt.Fprintf(w, " (%s)", i18n.T(l, "chat.usage.alias", strings.Join(cmd.Aliases, ", ")))
+	}
+	fmt.Fprintln(w, "\t")
+}
+
 func (p CmdPalette) Suggestions() []string {
 	return p.suggestions
 }
@@ -0,0 +1,46 @@
+package chat
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// confirmTimeout bounds how long a command waits to be confirmed
+const confirmTimeout = time.Minute
+
+// confirmedMsg is the result of confirming the command line of a Cmd that
+// needs it
+type confirmedMsg struct {
+	line string
+	err  error
+}
+
+// confirmCmd asks the confirmer of the session to approve the command line,
+// it runs once it is.
+func (m *Client) confirmCmd(line string) tea.Cmd {
+	var (
+		ctx     = m.ctx
+		confirm = m.info.Confirmer()
+	)
+	m.PrintInfoMsg(m.t("chat.confirm.asking", m.cmdPalette.leader+line))
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(ctx, confirmTimeout)
+		defer cancel()
+		return confirmedMsg{line: line, err: confirm.Confirm(ctx, line)}
+	}
+}
+
+func (m *Client) confirmed(msg confirmedMsg) tea.Cmd {
+	if msg.err != nil {
+		m.PrintInfoMsg(m.t("chat.confirm.failed", msg.err))
+		return nil
+	}
+	cmd, _, _ := strings.Cut(msg.line, " ")
+	if c := m.cmdPalette.Find(cmd); c != nil {
+		return c.Run(c, strings.Split(msg.line, " "))
+	}
+	return nil
+}
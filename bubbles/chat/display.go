@@ -0,0 +1,57 @@
+package chat
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// DisplayPolicy is how the identities of users are shown, in the nick of
+// their messages, /names, /whois and /find. A display name chosen with
+// /profile is shown instead under every policy.
+type DisplayPolicy int
+
+const (
+	// DisplayNick shows the login up to the @
+	DisplayNick DisplayPolicy = iota
+	// DisplayLogin shows the full login, e.g. an email
+	DisplayLogin
+	// DisplayDevice shows the nick and the device a message was sent from
+	DisplayDevice
+	// DisplayAnonymized shows a name derived from the login, the login is
+	// never shown. The same login always has the same name, it can be
+	// guessed by someone who knows the login.
+	DisplayAnonymized
+)
+
+// Display is the DisplayPolicy of the server, it is set once at startup
+// before any client connects.
+var Display = DisplayNick
+
+// Name is how who is shown under p, device is the device of a message and
+// is empty when there is none.
+func (p DisplayPolicy) Name(who, device string) string {
+	switch who {
+	case SysNick, HelpNick, InfoNick, ErrNick:
+		return who
+	}
+	switch p {
+	case DisplayLogin:
+		return who
+	case DisplayDevice:
+		if device != "" {
+			return NickFromWho(who) + "/" + device
+		}
+	case DisplayAnonymized:
+		sum := sha256.Sum256([]byte(who))
+		return "anon-" + hex.EncodeToString(sum[:3])
+	}
+	return NickFromWho(who)
+}
+
+// Login is who, unless p hides logins.
+func (p DisplayPolicy) Login(who string) string {
+	if p == DisplayAnonymized {
+		return p.Name(who, "")
+	}
+	return who
+}
@@ -0,0 +1,26 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisplayPolicy(t *testing.T) {
+	const who = "alice@example.com"
+	require.Equal(t, "alice", DisplayNick.Name(who, "laptop"))
+	require.Equal(t, who, DisplayLogin.Name(who, "laptop"))
+	require.Equal(t, "alice/laptop", DisplayDevice.Name(who, "laptop"))
+	require.Equal(t, "alice", DisplayDevice.Name(who, ""))
+
+	anon := DisplayAnonymized.Name(who, "laptop")
+	require.Regexp(t, `^anon-[0-9a-f]{6}$`, anon)
+	require.Equal(t, anon, DisplayAnonymized.Name(who, ""), "the name is stable")
+	require.NotEqual(t, anon, DisplayAnonymized.Name("bob@example.com", ""))
+	require.Equal(t, anon, DisplayAnonymized.Login(who))
+	require.Equal(t, who, DisplayNick.Login(who))
+
+	for _, p := range []DisplayPolicy{DisplayNick, DisplayLogin, DisplayDevice, DisplayAnonymized} {
+		require.Equal(t, SysNick, p.Name(SysNick, ""))
+	}
+}
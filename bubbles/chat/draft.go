@@ -0,0 +1,128 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+	"github.com/ghthor/webtea/mpty"
+)
+
+const draftBucket = "chat.drafts"
+
+const (
+	// draftDelay is how long the command line is left unchanged before its
+	// draft is saved
+	draftDelay = 2 * time.Second
+	// maxDraftLen bounds the draft kept of an identity
+	maxDraftLen = 4096
+)
+
+type (
+	// DraftReq saves the message Requestor is composing, so it survives a
+	// disconnect. An empty Draft deletes it.
+	DraftReq struct {
+		Requestor mpty.ClientId
+		Draft     string
+	}
+
+	// DraftMsg restores the draft of the identity of Requestor as it
+	// connects.
+	DraftMsg struct {
+		Requestor mpty.ClientId
+		Draft     string
+	}
+
+	// draftTickMsg saves the draft unless the command line changed since
+	// rev
+	draftTickMsg struct{ rev int }
+)
+
+// draftState is the command line of a client as it was last seen and saved.
+type draftState struct {
+	value, saved string
+	rev          int
+}
+
+// updateDraft saves the draft once the command line is left unchanged for
+// draftDelay. Commands aren't drafts, only messages are kept.
+func (m *Client) updateDraft() tea.Cmd {
+	v := m.cmdLine.Value()
+	if strings.HasPrefix(v, m.cmdPalette.leader) {
+		v = ""
+	}
+	if v == m.draft.value {
+		return nil
+	}
+	m.draft.value = v
+	m.draft.rev++
+	rev := m.draft.rev
+	return tea.Tick(draftDelay, func(time.Time) tea.Msg { return draftTickMsg{rev} })
+}
+
+func (m *Client) saveDraft(msg draftTickMsg) tea.Cmd {
+	if msg.rev != m.draft.rev || m.draft.value == m.draft.saved || m.Send == nil {
+		return nil
+	}
+	m.draft.saved = m.draft.value
+	return sendMsgCmd(m.ctx, m.Send, DraftReq{Requestor: m.Id(), Draft: m.draft.value})
+}
+
+// restoreDraft fills the command line with the draft, unless something was
+// typed already.
+func (m *Client) restoreDraft(msg DraftMsg) {
+	if msg.Requestor != m.Id() || msg.Draft == "" || m.cmdLine.Value() != "" {
+		return
+	}
+	m.cmdLine.SetValue(msg.Draft)
+	m.cmdLine.CursorEnd()
+	m.draft.value, m.draft.saved = msg.Draft, msg.Draft
+	m.PrintInfoMsg(m.t("chat.draft.restored"))
+}
+
+func (m *ServerModel) loadDrafts() error {
+	if m.Store == nil {
+		return nil
+	}
+
+	raw, err := m.Store.List(draftBucket)
+	if err != nil {
+		return err
+	}
+	for who, data := range raw {
+		var d string
+		if err := json.Unmarshal(data, &d); err != nil {
+			return fmt.Errorf("draft %s: %w", who, err)
+		}
+		m.drafts[who] = d
+	}
+	return nil
+}
+
+// updateDraft keeps the draft of the identity of the requestor, drafts that
+// are too long aren't kept.
+func (m *ServerModel) updateDraft(req DraftReq) {
+	who := req.Requestor.Identity()
+	if len(req.Draft) > maxDraftLen {
+		return
+	}
+
+	var err error
+	if req.Draft == "" {
+		delete(m.drafts, who)
+		if m.Store != nil {
+			err = m.Store.Delete(draftBucket, who)
+		}
+	} else {
+		m.drafts[who] = req.Draft
+		if m.Store != nil {
+			err = m.Store.Put(draftBucket, who, req.Draft)
+		}
+	}
+	if err != nil {
+		log.Warn("failed to save draft", "who", who, "error", err)
+	}
+}
@@ -0,0 +1,64 @@
+package chat
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ghthor/webtea/mpty"
+)
+
+// FindReq searches the connected users for Query, it is answered with the
+// Results.
+type FindReq struct {
+	Requestor mpty.ClientId
+	Query     string
+	Results   []FindResult
+}
+
+// FindResult is a connected user matching a FindReq.
+type FindResult struct {
+	Who      string
+	Name     string
+	Sessions int
+	// Since is when the oldest session of Who connected
+	Since time.Time
+}
+
+// findReq matches the query against the login, nick and display name of
+// every connected user, ignoring case.
+func (m *ServerModel) findReq(r FindReq) FindReq {
+	query := strings.ToLower(r.Query)
+	for _, who := range slices.Sorted(maps.Keys(m.names)) {
+		name := Display.Name(who, "")
+		if p, ok := m.profiles[who]; ok {
+			name = p.Name()
+		}
+		if !strings.Contains(strings.ToLower(who), query) && !strings.Contains(strings.ToLower(name), query) {
+			continue
+		}
+
+		sessions := m.names[who]
+		since := slices.MinFunc(slices.Collect(maps.Values(sessions)), func(a, b time.Time) int {
+			return a.Compare(b)
+		})
+		r.Results = append(r.Results, FindResult{Who: who, Name: name, Sessions: len(sessions), Since: since})
+	}
+	slices.SortStableFunc(r.Results, func(a, b FindResult) int {
+		// exact matches first
+		return cmp.Compare(exact(b, query), exact(a, query))
+	})
+	for i := range r.Results {
+		r.Results[i].Who = Display.Login(r.Results[i].Who)
+	}
+	return r
+}
+
+func exact(r FindResult, query string) int {
+	if strings.EqualFold(r.Who, query) || strings.EqualFold(r.Name, query) || strings.EqualFold(NickFromWho(r.Who), query) {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,57 @@
+package chat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ghthor/webtea/mpty"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFind(t *testing.T) {
+	now := time.Now()
+	m := &ServerModel{
+		names: map[string]map[string]time.Time{
+			"al@example.com":    {"s1": now},
+			"alice@example.com": {"s2": now.Add(-time.Hour), "s3": now},
+			"bob@example.com":   {"s4": now},
+		},
+		profiles: map[string]Profile{
+			"bob@example.com": {Who: "bob@example.com", DisplayName: "Alfred"},
+		},
+	}
+
+	r := m.findReq(FindReq{Requestor: mpty.NewClientId("carol@example.com", "s5"), Query: "ALICE"})
+	require.Equal(t, []FindResult{
+		{Who: "alice@example.com", Name: "alice", Sessions: 2, Since: now.Add(-time.Hour)},
+	}, r.Results)
+
+	r = m.findReq(FindReq{Query: "al"})
+	require.Len(t, r.Results, 3)
+	require.Equal(t, "al@example.com", r.Results[0].Who)
+	require.Equal(t, "Alfred", r.Results[2].Name)
+
+	require.Empty(t, m.findReq(FindReq{Query: "zed"}).Results)
+}
+
+type peers []mpty.Peer
+
+func (p peers) Peers() []mpty.Peer { return p }
+
+func TestNames(t *testing.T) {
+	now := time.Now()
+	m := &ServerModel{
+		names: map[string]map[string]time.Time{
+			"bob@example.com":   {"s1": now},
+			"alice@example.com": {"s2": now},
+		},
+	}
+
+	r := m.namesReq(NamesReq{Requestor: mpty.NewClientId("alice@example.com", "s2")})
+	require.Equal(t, []string{"alice", "bob"}, r.Names)
+	require.Empty(t, r.Tailnet)
+
+	m.Tailnet = peers{{Name: "alice-laptop", Online: true}, {Name: "bob-phone"}, {Name: "carol-desktop", Online: true}}
+	r = m.namesReq(NamesReq{})
+	require.Equal(t, []string{"alice-laptop", "carol-desktop"}, r.Tailnet)
+}
@@ -0,0 +1,130 @@
+package chat
+
+import (
+	"strconv"
+
+	"github.com/ghthor/webtea/i18n"
+	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/roles"
+)
+
+type (
+	// GuestPendingMsg is broadcast when the first message of a guest is held
+	// for approval, see ServerModel.ApproveGuests. Clients that
+	// roles.CanApproveGuests show it, the guest is told it is waiting.
+	GuestPendingMsg struct {
+		Id  string
+		Msg Msg
+	}
+
+	// GuestReq approves or denies the message Id of a guest, it is answered
+	// with a GuestResult.
+	GuestReq struct {
+		Requestor mpty.ClientId
+		Id        string
+		Approve   bool
+	}
+
+	// GuestResult is sent to the Requestor of a GuestReq and to the Guest.
+	// An approved guest is promoted to Access for its session.
+	GuestResult struct {
+		Requestor mpty.ClientId
+		Guest     mpty.ClientId
+		Id        string
+		Approved  bool
+		Access    roles.Access
+		Err       string
+
+		// Key and Args translate Err, see RoomErr.
+		Key  string   `json:",omitempty"`
+		Args []string `json:",omitempty"`
+	}
+)
+
+// refuse sets the Err of r, translated by each client.
+func (r *GuestResult) refuse(key string, args ...string) {
+	r.Key, r.Args = key, args
+	r.Err = i18n.T(i18n.Default, key, anys(args)...)
+}
+
+// Text is Err in locale l.
+func (r GuestResult) Text(l i18n.Locale) string {
+	if r.Key == "" {
+		return r.Err
+	}
+	return i18n.T(l, r.Key, anys(r.Args)...)
+}
+
+// admitted keeps the access of a session the room admitted, guests are held
+// until approved by it.
+func (m *ServerModel) admitted(req mpty.JoinReq) {
+	if m.access == nil {
+		m.access = make(map[mpty.ClientId]roles.Access)
+	}
+	m.access[req.Id] = req.Access
+}
+
+// left forgets the access of the session id and discards its message
+// waiting for approval.
+func (m *ServerModel) left(id mpty.ClientId) {
+	delete(m.access, id)
+	for pid, p := range m.pending {
+		if mpty.NewClientId(p.Msg.Who, p.Msg.Sess) == id {
+			delete(m.pending, pid)
+		}
+	}
+}
+
+// held reports if msg is from a guest that isn't approved yet, its first
+// message is held for approval and the others are refused until then. It is
+// checked by Refuse so neither is recorded, an approved message is sent
+// again to be recorded like a new one.
+func (m *ServerModel) held(msg Msg) bool {
+	id := mpty.NewClientId(msg.Who, msg.Sess)
+	if a, ok := m.access[id]; !m.ApproveGuests || !ok || a.Role != roles.Guest {
+		return false
+	}
+
+	for _, p := range m.pending {
+		if mpty.NewClientId(p.Msg.Who, p.Msg.Sess) == id {
+			m.broadcaster.Write(roomErrT(id, "chat.guest.waiting"))
+			return true
+		}
+	}
+	if m.pending == nil {
+		m.pending = make(map[string]GuestPendingMsg)
+	}
+	m.pendingSeq++
+	p := GuestPendingMsg{Id: strconv.Itoa(m.pendingSeq), Msg: msg}
+	m.pending[p.Id] = p
+	m.broadcaster.Write(p)
+	return true
+}
+
+// guestReq approves or denies the held message of a guest, the message
+// approved is returned to be sent.
+func (m *ServerModel) guestReq(req GuestReq) (GuestResult, Msg) {
+	r := GuestResult{Requestor: req.Requestor, Id: req.Id, Approved: req.Approve}
+	if !m.access[req.Requestor].Can(roles.CanApproveGuests) {
+		r.refuse("chat.guest.cant_approve")
+		return r, Msg{}
+	}
+	p, ok := m.pending[req.Id]
+	if !ok {
+		r.refuse("chat.guest.not_waiting", req.Id)
+		return r, Msg{}
+	}
+	delete(m.pending, req.Id)
+
+	r.Guest = mpty.NewClientId(p.Msg.Who, p.Msg.Sess)
+	if !req.Approve {
+		return r, Msg{}
+	}
+	policy := roles.DefaultPolicy()
+	if m.Policy != nil {
+		policy = *m.Policy
+	}
+	r.Access = policy.Promote(m.access[r.Guest], roles.User)
+	m.access[r.Guest] = r.Access
+	return r, p.Msg
+}
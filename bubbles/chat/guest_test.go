@@ -0,0 +1,60 @@
+package chat
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ghthor/webtea/i18n"
+	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/roles"
+	"github.com/golang-cz/ringbuf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuestApproval(t *testing.T) {
+	m := &ServerModel{ApproveGuests: true}
+	m.Init()
+	m.Update(ringbuf.New[tea.Msg](100))
+
+	policy := roles.DefaultPolicy()
+	guest := mpty.NewClientId("guest-1", "1")
+	mod := mpty.NewClientId("carol@example.com", "1")
+	require.NoError(t, m.Admit(mpty.JoinReq{Id: guest, Access: policy.Promote(roles.Access{}, roles.Guest)}))
+	require.NoError(t, m.Admit(mpty.JoinReq{Id: mod, Access: policy.Promote(roles.Access{}, roles.Moderator)}))
+
+	// held messages are refused so Main neither numbers nor records them
+	hi := Msg{Who: "guest-1", Sess: "1", Str: "hi"}
+	require.True(t, m.Refuse(hi))
+	require.True(t, m.Refuse(Msg{Who: "guest-1", Sess: "1", Str: "anyone?"}), "a second message waits for the first")
+	require.Len(t, m.pending, 1)
+	require.False(t, m.Refuse(Msg{Who: "carol@example.com", Sess: "1", Str: "hello"}))
+
+	r, _ := m.guestReq(GuestReq{Requestor: guest, Id: "1", Approve: true})
+	require.Equal(t, "you can't approve guests", r.Err)
+	require.Equal(t, "no puedes aprobar invitados", r.Text(i18n.Spanish))
+	r, _ = m.guestReq(GuestReq{Requestor: mod, Id: "2", Approve: true})
+	require.Equal(t, "no guest message 2 is waiting", r.Err)
+
+	// the approved message is sent back to Main to be recorded as a new one
+	_, cmd := m.Update(GuestReq{Requestor: mod, Id: "1", Approve: true})
+	require.NotNil(t, cmd)
+	require.Equal(t, hi, cmd())
+	require.Empty(t, m.pending)
+	require.Equal(t, roles.User, m.access[guest].Role)
+	require.True(t, m.access[guest].Can(roles.CanStartGame))
+	require.False(t, m.Refuse(hi), "an approved guest chats as a user")
+
+	// a denied guest stays a guest, its next message is held again
+	other := mpty.NewClientId("guest-2", "1")
+	require.NoError(t, m.Admit(mpty.JoinReq{Id: other, Access: policy.Promote(roles.Access{}, roles.Guest)}))
+	require.True(t, m.Refuse(Msg{Who: "guest-2", Sess: "1", Str: "spam"}))
+	r, held := m.guestReq(GuestReq{Requestor: mod, Id: "2"})
+	require.Empty(t, r.Err)
+	require.Equal(t, other, r.Guest)
+	require.Zero(t, held)
+	require.True(t, m.Refuse(Msg{Who: "guest-2", Sess: "1", Str: "spam"}))
+
+	m.Update(mpty.ClientDisconnectMsg(other))
+	require.Empty(t, m.pending)
+	require.NotContains(t, m.access, other)
+}
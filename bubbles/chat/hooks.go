@@ -0,0 +1,42 @@
+package chat
+
+import (
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ghthor/webtea/hooks"
+)
+
+// HookNick is who the posts of hooks are from, unless they name another.
+const HookNick = "hook"
+
+// hookMsg is the chat message of a post of a hook
+func hookMsg(t time.Time, p hooks.Post) Msg {
+	nick := p.Nick
+	if nick == "" {
+		nick = HookNick
+	}
+	return Msg{At: t, Who: nick, Str: p.Str}.SetNick(nick)
+}
+
+// CmdLineMsg runs a command line of the client as if it was typed, e.g. by
+// an alias.
+type CmdLineMsg string
+
+// AliasCmds returns the alias hooks as commands of the client, they should
+// have been validated, see hooks.Config.
+func AliasCmds(aliases []hooks.AliasHook) []Cmd {
+	cmds := make([]Cmd, 0, len(aliases))
+	for _, a := range aliases {
+		cmds = append(cmds, Cmd{
+			Use:   a.Name,
+			Short: "Alias for " + a.Command,
+			Run: func(cmd *Cmd, args []string) tea.Cmd {
+				line := strings.Join(append([]string{a.Command}, args[1:]...), " ")
+				return func() tea.Msg { return CmdLineMsg(line) }
+			},
+		})
+	}
+	return cmds
+}
@@ -0,0 +1,20 @@
+package chat
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ghthor/webtea/hooks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHooks(t *testing.T) {
+	cmds := AliasCmds([]hooks.AliasHook{{Name: "bf", Command: "blokfall"}})
+	require.Len(t, cmds, 1)
+	require.Equal(t, CmdLineMsg("blokfall 2"), cmds[0].Run(&cmds[0], []string{"bf", "2"})())
+
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	msg := hookMsg(start, hooks.Post{Str: "hello"})
+	require.Equal(t, HookNick, msg.Who)
+	require.Equal(t, HookNick, msg.Nick())
+}
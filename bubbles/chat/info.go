@@ -0,0 +1,53 @@
+package chat
+
+import (
+	"time"
+
+	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/serverinfo"
+)
+
+// InfoReq asks for the metadata of the server, for /version and /uptime.
+type InfoReq struct {
+	Requestor mpty.ClientId
+	// Uptime shows how long the server is up instead of its build
+	Uptime bool
+	Info   serverinfo.Info
+}
+
+func (m *ServerModel) infoReq(r InfoReq) InfoReq {
+	if m.Info == nil {
+		r.Info = serverinfo.Build()
+		return r
+	}
+	r.Info = m.Info.Info()
+	return r
+}
+
+// commitLen is how much of a commit hash /version shows
+const commitLen = 12
+
+func (m *Client) printInfo(r InfoReq) {
+	info := r.Info
+	if !r.Uptime {
+		commit := info.Commit
+		switch {
+		case commit == "":
+			commit = "unknown"
+		case len(commit) > commitLen:
+			commit = commit[:commitLen]
+		}
+		recorder := info.Recorder
+		if recorder == "" {
+			recorder = "unknown"
+		}
+		m.PrintInfoMsg(m.t("chat.version", info.Version, commit, info.GoVersion, recorder))
+		return
+	}
+
+	if info.Started.IsZero() {
+		m.PrintInfoMsg(m.t("chat.uptime.unknown"))
+		return
+	}
+	m.PrintInfoMsg(m.t("chat.uptime", info.Uptime, info.Started.Format(time.DateTime), info.Clients))
+}
@@ -0,0 +1,309 @@
+package chat
+
+import "github.com/ghthor/webtea/i18n"
+
+func init() {
+	i18n.Register(i18n.English, map[string]string{
+		"chat.toggle.on":  "ON",
+		"chat.toggle.off": "OFF",
+
+		"chat.quiet_toggled":      "Quiet mode toggled %s",
+		"chat.ignored":            "Ignoring %s",
+		"chat.unignored":          "No longer ignoring %s",
+		"chat.ignore.list":        "Ignoring %s",
+		"chat.ignore.none":        "Not ignoring anyone",
+		"chat.timestamp_toggled":  "Timestamp is toggled %s",
+		"chat.debug_toggled":      "Debug is toggled %s",
+		"chat.accessible_toggled": "Accessible mode toggled %s",
+
+		"chat.arg_required":   "argument required: %s",
+		"chat.arg_invalid":    "%s => %v: %s",
+		"chat.user_not_found": "user not found",
+		"chat.names":          "-> %d connected: %s",
+		"chat.names.tailnet":  "-> %d online on the tailnet: %s",
+		"chat.stats":          "-> most active: %s\n-> top words: %s",
+		"chat.stats.empty":    "no stats yet",
+		"chat.activity":       "-> messages by day and hour (UTC), the busiest hour had %d:",
+		"chat.version":        "-> %s (commit %s) built with %s, recording to %s",
+		"chat.uptime":         "-> up %s since %s, %d connected",
+		"chat.uptime.unknown": "uptime unknown",
+		"chat.connected":      "%s connected",
+		"chat.disconnected":   "%s disconnected",
+
+		"chat.profile.show":          "%s\n    name: %s\npronouns: %s\n  avatar: %s",
+		"chat.profile.not_updated":   "profile not updated: %s",
+		"chat.draft.restored":        "(restored draft)",
+		"chat.peer.online":           "%s came online",
+		"chat.peer.offline":          "%s went offline",
+		"chat.profile.unknown_field": "unknown profile field: %s",
+
+		"chat.session.expiring":   "Your session ends in %s",
+		"chat.announce":           "[operator] %s",
+		"chat.kicked":             "ended %d sessions of %s",
+		"chat.confirm.asking":     "confirm %s with your ssh agent",
+		"chat.confirm.failed":     "not confirmed: %s",
+		"chat.missed":             "missed %d messages",
+		"chat.resume":             "to catch up on what you miss, reconnect with ssh -o SetEnv=%s=%s or open ?%s=%s",
+		"chat.resume.none":        "nothing to resume yet",
+		"chat.join.joined":        "you joined %s",
+		"chat.join.failed":        "can't join %s: %s",
+		"chat.room.show":          "topic: %s\nmotd: %s\nslow mode: %s\ngames: %s\nretention: %s\nmax members: %d\ninvite only: %s\nprivate: %s\npassword: %s",
+		"chat.room.invited":       "Invited: %s",
+		"chat.find.result":        "%s (%s) is active here with %d sessions, connected %s ago",
+		"chat.welcome_back":       "Welcome back! Since you left %s ago: %d messages, %d mentions of you, %d games played",
+		"chat.find.none":          "no active user matches %s",
+		"chat.room.topic":         "The topic is now: %s",
+		"chat.room.motd":          "[motd] %s",
+		"chat.room.refused":       "refused: %s",
+		"chat.guest.held":         "Your message is waiting for a moderator to approve it",
+		"chat.guest.pending":      "%s is a guest and wants to say: %s (/approve %[3]s or /deny %[3]s)",
+		"chat.guest.approved":     "A moderator approved you, welcome!",
+		"chat.guest.denied":       "A moderator didn't approve your message",
+		"chat.guest.you_approved": "You approved %s",
+		"chat.guest.you_denied":   "You denied %s",
+		"chat.guest.failed":       "failed: %s",
+		"chat.guest.waiting":      "your first message is still waiting for approval",
+		"chat.guest.cant_approve": "you can't approve guests",
+		"chat.guest.not_waiting":  "no guest message %s is waiting",
+		"chat.room.slowed":        "slow mode is on, wait %s between messages",
+		"chat.room.full":          "the room is full, at most %s members",
+		"chat.room.game_denied":   "%s is not allowed in this room",
+		"chat.room.denied":        "you aren't permitted to configure the room",
+		"chat.maintenance":        "The server is going down for maintenance in %s",
+		"chat.maintenance.reason": "The server is going down for maintenance in %s: %s",
+
+		"chat.theme.current": "Theme is %s, available: %s",
+		"chat.theme.set":     "Theme set to %s",
+		"chat.theme.unknown": "unknown theme %s, available: %s",
+
+		"chat.bell.current": "Bell is %s, available: %s",
+		"chat.bell.set":     "Bell set to %s",
+		"chat.bell.events":  "Bell for %s toggled %s",
+		"chat.bell.unknown": "unknown bell mode %s, available: %s",
+
+		"chat.lang.current": "Language is %s, available: %s",
+		"chat.lang.set":     "Language set to %s",
+		"chat.lang.unknown": "unknown language %s, available: %s",
+
+		"chat.usage.header": "Type out a message and press <enter> or use a command\n\n-> Available commands:",
+		"chat.usage.hidden": "-> Hidden commands:",
+		"chat.usage.keys":   "-> For input key mappings see:",
+		"chat.usage.alias":  "aliases: %s",
+
+		"chat.blokfall.help": `Each player controls a single piece. They don't collide till they are locked
+into the board enabling pieces to be combined.
+
+    [ d ]  [ f ]   [ g ]     [ j ]  [ k ]
+   ←move    move→  soft↓     ↶ CCW   CW ↷
+
+             [__ space __]
+             ⤓ hard drop ⤓
+
+-> Available commands:
+/exit                      - Exit blokfall
+/blokfall reset              - Reset blokfall board
+/blokfall debug              - Toggle debugging mode
+/blokfall level <INT>        - Set current games level (speed)
+
+`,
+
+		"cmd.exit.short":       "Exit the chat, ctrl+c will also exit",
+		"cmd.names.short":      "List users who are connected.",
+		"cmd.stats.short":      "Show the most active users and words.",
+		"cmd.activity.short":   "Show when the room is active as a heatmap.",
+		"cmd.version.short":    "Show the version of the server.",
+		"cmd.uptime.short":     "Show how long the server is up and how many are connected.",
+		"cmd.whois.short":      "Infomation about USER",
+		"cmd.find.short":       "Find where USER is active by login, nick or name.",
+		"cmd.announce.short":   "Announce MESSAGE to everyone.",
+		"cmd.room.short":       "Show the room settings, operators can change them.",
+		"cmd.invite.short":     "Invite USER to the room, or list the invited users.",
+		"cmd.uninvite.short":   "Take back the invite of USER.",
+		"cmd.kick.short":       "End every session of USER.",
+		"cmd.approve.short":    "Send the message ID of a guest and let them chat.",
+		"cmd.deny.short":       "Drop the message ID of a guest.",
+		"cmd.profile.short":    "Show or edit your display name, pronouns and avatar badge.",
+		"cmd.quiet.short":      "Toggle system announcements.",
+		"cmd.ignore.short":     "Hide messages from USER, /unignore USER to stop hiding.",
+		"cmd.unignore.short":   "Stop hiding messages from USER.",
+		"cmd.timestamp.short":  "Toggle chat timestamps.",
+		"cmd.resume.short":     "Show how to reconnect without missing messages.",
+		"cmd.join.short":       "Leave this room for ROOM without reconnecting.",
+		"cmd.lang.short":       "Show or set your language.",
+		"cmd.theme.short":      "Show or set your color theme.",
+		"cmd.bell.short":       "Show or set how you are alerted.",
+		"cmd.accessible.short": "Toggle screen reader friendly output.",
+		"cmd.blokfall.short":   "Start/Join multiplayer blokfall.",
+		"cmd.tournament.short": "Join the blokfall tournament, operators schedule one.",
+		"cmd.schedule.short":   "Send MESSAGE in IN, e.g. 10m, or list and cancel the scheduled messages.",
+
+		"chat.schedule.scheduled": "Message %s is sent in %s, /schedule cancel %[1]s to cancel it",
+		"chat.schedule.cancelled": "Message %s was cancelled",
+		"chat.schedule.refused":   "not scheduled: %s",
+		"chat.schedule.none":      "You have no messages scheduled",
+		"chat.schedule.item":      "%s in %s: %s",
+
+		"chat.blokfall.game_over":   "blokfall game over: %s points, %s lines",
+		"chat.tournament.scheduled": "A blokfall tournament starts in %s with %s matches, /tournament join to play",
+		"chat.tournament.joined":    "%s joined the tournament, %s players",
+		"chat.tournament.cancelled": "The tournament was cancelled, it needs at least 2 players",
+		"chat.tournament.round":     "Tournament round %s: %s",
+		"chat.tournament.standings": "Tournament standings:\n%s",
+		"chat.tournament.champion":  "%s won the tournament!\n%s",
+		"chat.tournament.seated":    "Your match has started, it ends in %s. Good luck!",
+		"chat.tournament.refused":   "refused: %s",
+		"chat.tournament.denied":    "you aren't permitted to schedule tournaments",
+	})
+
+	i18n.Register(i18n.Spanish, map[string]string{
+		"chat.toggle.on":  "ACTIVADO",
+		"chat.toggle.off": "DESACTIVADO",
+
+		"chat.quiet_toggled":      "Modo silencioso %s",
+		"chat.ignored":            "Ignorando a %s",
+		"chat.unignored":          "Ya no ignoras a %s",
+		"chat.ignore.list":        "Ignorando a %s",
+		"chat.ignore.none":        "No ignoras a nadie",
+		"chat.timestamp_toggled":  "Marcas de tiempo %s",
+		"chat.debug_toggled":      "Depuración %s",
+		"chat.accessible_toggled": "Modo accesible %s",
+
+		"chat.arg_required":   "argumento requerido: %s",
+		"chat.arg_invalid":    "%s => %v: %s",
+		"chat.user_not_found": "usuario no encontrado",
+		"chat.names":          "-> %d conectados: %s",
+		"chat.names.tailnet":  "-> %d en línea en la tailnet: %s",
+		"chat.stats":          "-> más activos: %s\n-> palabras frecuentes: %s",
+		"chat.stats.empty":    "aún no hay estadísticas",
+		"chat.activity":       "-> mensajes por día y hora (UTC), la hora más activa tuvo %d:",
+		"chat.version":        "-> %s (commit %s) compilado con %s, grabando en %s",
+		"chat.uptime":         "-> activo durante %s desde %s, %d conectados",
+		"chat.uptime.unknown": "tiempo activo desconocido",
+		"chat.connected":      "%s se conectó",
+		"chat.disconnected":   "%s se desconectó",
+
+		"chat.profile.show":          "%s\n  nombre: %s\npronombres: %s\n  avatar: %s",
+		"chat.profile.not_updated":   "perfil no actualizado: %s",
+		"chat.draft.restored":        "(borrador restaurado)",
+		"chat.peer.online":           "%s se conectó",
+		"chat.peer.offline":          "%s se desconectó",
+		"chat.profile.unknown_field": "campo de perfil desconocido: %s",
+
+		"chat.session.expiring":   "Tu sesión termina en %s",
+		"chat.announce":           "[operador] %s",
+		"chat.kicked":             "se terminaron %d sesiones de %s",
+		"chat.confirm.asking":     "confirma %s con tu agente ssh",
+		"chat.confirm.failed":     "no confirmado: %s",
+		"chat.missed":             "se perdieron %d mensajes",
+		"chat.resume":             "para ver lo que te pierdas, reconéctate con ssh -o SetEnv=%s=%s o abre ?%s=%s",
+		"chat.resume.none":        "aún no hay nada que reanudar",
+		"chat.join.joined":        "entraste en %s",
+		"chat.join.failed":        "no puedes entrar en %s: %s",
+		"chat.room.show":          "tema: %s\nmotd: %s\nmodo lento: %s\njuegos: %s\nretención: %s\nmáximo de miembros: %d\nsolo con invitación: %s\nprivada: %s\ncontraseña: %s",
+		"chat.room.invited":       "Invitados: %s",
+		"chat.find.result":        "%s (%s) está activo aquí con %d sesiones, conectado hace %s",
+		"chat.welcome_back":       "¡Bienvenido de nuevo! Desde que te fuiste hace %s: %d mensajes, %d menciones tuyas, %d partidas jugadas",
+		"chat.find.none":          "ningún usuario activo coincide con %s",
+		"chat.room.topic":         "El tema cambió a: %s",
+		"chat.room.motd":          "[motd] %s",
+		"chat.room.refused":       "rechazado: %s",
+		"chat.guest.held":         "Tu mensaje espera la aprobación de un moderador",
+		"chat.guest.pending":      "%s es un invitado y quiere decir: %s (/approve %[3]s o /deny %[3]s)",
+		"chat.guest.approved":     "Un moderador te aprobó, ¡bienvenido!",
+		"chat.guest.denied":       "Un moderador no aprobó tu mensaje",
+		"chat.guest.you_approved": "Aprobaste a %s",
+		"chat.guest.you_denied":   "Rechazaste a %s",
+		"chat.guest.failed":       "falló: %s",
+		"chat.guest.waiting":      "tu primer mensaje aún espera aprobación",
+		"chat.guest.cant_approve": "no puedes aprobar invitados",
+		"chat.guest.not_waiting":  "ningún mensaje de invitado %s está esperando",
+		"chat.room.slowed":        "el modo lento está activo, espera %s entre mensajes",
+		"chat.room.full":          "la sala está llena, como máximo %s miembros",
+		"chat.room.game_denied":   "%s no está permitido en esta sala",
+		"chat.room.denied":        "no tienes permiso para configurar la sala",
+		"chat.maintenance":        "El servidor se apagará por mantenimiento en %s",
+		"chat.maintenance.reason": "El servidor se apagará por mantenimiento en %s: %s",
+
+		"chat.theme.current": "El tema es %s, disponibles: %s",
+		"chat.theme.set":     "Tema cambiado a %s",
+		"chat.theme.unknown": "tema desconocido %s, disponibles: %s",
+
+		"chat.bell.current": "La campana es %s, disponibles: %s",
+		"chat.bell.set":     "Campana cambiada a %s",
+		"chat.bell.events":  "Campana para %s %s",
+		"chat.bell.unknown": "modo de campana desconocido %s, disponibles: %s",
+
+		"chat.lang.current": "El idioma es %s, disponibles: %s",
+		"chat.lang.set":     "Idioma cambiado a %s",
+		"chat.lang.unknown": "idioma desconocido %s, disponibles: %s",
+
+		"chat.usage.header": "Escribe un mensaje y presiona <enter> o usa un comando\n\n-> Comandos disponibles:",
+		"chat.usage.hidden": "-> Comandos ocultos:",
+		"chat.usage.keys":   "-> Para los atajos de teclado ver:",
+		"chat.usage.alias":  "alias: %s",
+
+		"chat.blokfall.help": `Cada jugador controla una sola pieza. No chocan hasta que se fijan
+en el tablero, lo que permite combinar piezas.
+
+    [ d ]  [ f ]   [ g ]     [ j ]  [ k ]
+   ←mover  mover→  bajar↓    ↶ izq   der ↷
+
+             [__ espacio __]
+             ⤓ caída rápida ⤓
+
+-> Comandos disponibles:
+/exit                      - Salir de blokfall
+/blokfall reset              - Reiniciar el tablero
+/blokfall debug              - Alternar modo de depuración
+/blokfall level <INT>        - Cambiar el nivel (velocidad)
+
+`,
+
+		"cmd.exit.short":       "Salir del chat, ctrl+c también sale",
+		"cmd.names.short":      "Lista los usuarios conectados.",
+		"cmd.stats.short":      "Muestra los usuarios y palabras más activos.",
+		"cmd.activity.short":   "Muestra cuándo la sala está activa como un mapa de calor.",
+		"cmd.version.short":    "Muestra la versión del servidor.",
+		"cmd.uptime.short":     "Muestra cuánto lleva activo el servidor y cuántos están conectados.",
+		"cmd.whois.short":      "Información sobre USER",
+		"cmd.find.short":       "Busca dónde está activo USER por login, apodo o nombre.",
+		"cmd.announce.short":   "Anuncia MESSAGE a todos.",
+		"cmd.room.short":       "Muestra la configuración de la sala, los operadores pueden cambiarla.",
+		"cmd.invite.short":     "Invita a USER a la sala, o lista los invitados.",
+		"cmd.uninvite.short":   "Retira la invitación de USER.",
+		"cmd.kick.short":       "Termina todas las sesiones de USER.",
+		"cmd.approve.short":    "Envía el mensaje ID de un invitado y le deja chatear.",
+		"cmd.deny.short":       "Descarta el mensaje ID de un invitado.",
+		"cmd.profile.short":    "Muestra o edita tu nombre, pronombres y avatar.",
+		"cmd.quiet.short":      "Alterna los anuncios del sistema.",
+		"cmd.ignore.short":     "Oculta los mensajes de USER, /unignore USER para dejar de ocultarlos.",
+		"cmd.unignore.short":   "Deja de ocultar los mensajes de USER.",
+		"cmd.timestamp.short":  "Alterna las marcas de tiempo.",
+		"cmd.resume.short":     "Muestra cómo reconectarte sin perder mensajes.",
+		"cmd.join.short":       "Deja esta sala por ROOM sin reconectarte.",
+		"cmd.lang.short":       "Muestra o cambia tu idioma.",
+		"cmd.theme.short":      "Muestra o cambia tu tema de colores.",
+		"cmd.bell.short":       "Muestra o cambia cómo se te avisa.",
+		"cmd.accessible.short": "Alterna la salida para lectores de pantalla.",
+		"cmd.blokfall.short":   "Iniciar/unirse a blokfall multijugador.",
+		"cmd.tournament.short": "Unirse al torneo de blokfall, los operadores lo programan.",
+		"cmd.schedule.short":   "Envía MESSAGE dentro de IN, p. ej. 10m, o lista y cancela los mensajes programados.",
+
+		"chat.schedule.scheduled": "El mensaje %s se envía en %s, /schedule cancel %[1]s para cancelarlo",
+		"chat.schedule.cancelled": "El mensaje %s se canceló",
+		"chat.schedule.refused":   "no programado: %s",
+		"chat.schedule.none":      "No tienes mensajes programados",
+		"chat.schedule.item":      "%s en %s: %s",
+
+		"chat.blokfall.game_over":   "fin de la partida de blokfall: %s puntos, %s líneas",
+		"chat.tournament.scheduled": "Un torneo de blokfall empieza en %s con partidas de %s, /tournament join para jugar",
+		"chat.tournament.joined":    "%s se unió al torneo, %s jugadores",
+		"chat.tournament.cancelled": "El torneo se canceló, necesita al menos 2 jugadores",
+		"chat.tournament.round":     "Ronda %s del torneo: %s",
+		"chat.tournament.standings": "Clasificación del torneo:\n%s",
+		"chat.tournament.champion":  "¡%s ganó el torneo!\n%s",
+		"chat.tournament.seated":    "Tu partida empezó, termina en %s. ¡Suerte!",
+		"chat.tournament.refused":   "rechazado: %s",
+		"chat.tournament.denied":    "no tienes permiso para programar torneos",
+	})
+}
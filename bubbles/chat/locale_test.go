@@ -0,0 +1,16 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/ghthor/webtea/i18n"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalesComplete(t *testing.T) {
+	en := i18n.Keys(i18n.English)
+	require.NotEmpty(t, en)
+	for _, l := range i18n.Locales() {
+		require.Equal(t, en, i18n.Keys(l), "the keys of %s", l)
+	}
+}
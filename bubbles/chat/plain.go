@@ -0,0 +1,159 @@
+package chat
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ghthor/webtea/i18n"
+	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/mpty/mptymsg"
+	"github.com/ghthor/webtea/roles"
+)
+
+// PlainClient is the chat for terminals that can't draw the Client, see
+// mpty.ClientInfoModel.Dumb. There is no alt screen, table or overlay: each
+// message is printed as a line and the line typed is sent on enter. Commands
+// aren't run, they are sent as text like the messages of rpc frontends,
+// except /quit.
+type PlainClient struct {
+	info *mpty.ClientInfoModel
+
+	ctx  context.Context
+	Send mpty.Input
+
+	// line is what is being typed
+	line []rune
+	// printed are the lines printed since the last update
+	printed []string
+}
+
+var _ mpty.ClientModel = &PlainClient{}
+var _ mpty.AltScreener = &PlainClient{}
+var _ mpty.Interested = &PlainClient{}
+
+func NewPlainClient(ctx context.Context, info *mpty.ClientInfoModel) *PlainClient {
+	return &PlainClient{ctx: ctx, info: info}
+}
+
+func (m *PlainClient) Id() mpty.ClientId {
+	return m.info.Id()
+}
+
+// Access is the role of the client, it is checked when joining the room.
+func (m *PlainClient) Access() roles.Access {
+	return m.info.Access
+}
+
+func (m *PlainClient) Err() error {
+	return nil
+}
+
+// AltScreen is false, the lines printed are the history of the chat.
+func (m *PlainClient) AltScreen() bool {
+	return false
+}
+
+// Interest is the messages printed, the games and everything else the
+// Client draws are never delivered.
+func (m *PlainClient) Interest() mpty.Interest {
+	return mpty.Types(Msg{}, RoomErr{}, GuestPendingMsg{})
+}
+
+func (m *PlainClient) Init() tea.Cmd {
+	return nil
+}
+
+func (m *PlainClient) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	return m.UpdateClient(msg)
+}
+
+func (m *PlainClient) UpdateClient(msg tea.Msg) (mpty.ClientModel, tea.Cmd) {
+	var cmd, send tea.Cmd
+	m.info, cmd = m.info.UpdateInfo(msg)
+
+	switch msg := msg.(type) {
+	case mpty.Input:
+		m.Send = msg
+
+	case []mptymsg.Recordable:
+		for _, msg := range msg {
+			if msg, ok := msg.(Msg); ok {
+				m.print(msg)
+			}
+		}
+
+	case []tea.Msg:
+		for _, msg := range msg {
+			switch msg := msg.(type) {
+			case Msg:
+				m.print(msg)
+			case RoomErr:
+				if msg.Requestor == m.Id() {
+					m.print(InfoMsg(m.info.Time, msg.Text(m.info.Locale)))
+				}
+			case GuestPendingMsg:
+				if mpty.NewClientId(msg.Msg.Who, msg.Msg.Sess) == m.Id() {
+					m.print(InfoMsg(m.info.Time, i18n.T(m.info.Locale, "chat.guest.held")))
+				}
+			}
+		}
+
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyCtrlC, tea.KeyCtrlD:
+			return m, tea.Quit
+		case tea.KeyEnter:
+			line := strings.TrimSpace(string(m.line))
+			m.line = m.line[:0]
+			if line == "/quit" {
+				return m, tea.Quit
+			}
+			send = m.send(line)
+		case tea.KeyBackspace:
+			if len(m.line) > 0 {
+				m.line = m.line[:len(m.line)-1]
+			}
+		case tea.KeyRunes, tea.KeySpace:
+			m.line = append(m.line, msg.Runes...)
+		}
+	}
+
+	return m, tea.Batch(cmd, send, m.flush())
+}
+
+func (m *PlainClient) send(line string) tea.Cmd {
+	if line == "" || m.Send == nil {
+		return nil
+	}
+	return sendMsgCmd(m.ctx, m.Send, Msg{
+		At:     time.Now(),
+		Who:    m.info.Identity(),
+		Sess:   m.info.SessionId,
+		Device: m.info.Device(),
+		Str:    line,
+	}.SetNick())
+}
+
+func (m *PlainClient) print(msg Msg) {
+	str := msg.Str
+	if msg.Key != "" {
+		str = i18n.T(m.info.Locale, msg.Key, msg.args()...)
+	}
+	// continuation lines are indented so they aren't read as a new speaker
+	m.printed = append(m.printed, msg.Nick()+": "+strings.ReplaceAll(str, "\n", "\n  "))
+}
+
+func (m *PlainClient) flush() tea.Cmd {
+	if len(m.printed) == 0 {
+		return nil
+	}
+	cmd := tea.Println(strings.Join(m.printed, "\n"))
+	m.printed = m.printed[:0]
+	return cmd
+}
+
+func (m *PlainClient) View() string {
+	return "> " + string(m.line)
+}
@@ -0,0 +1,127 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ghthor/webtea/mpty"
+)
+
+const profileBucket = "chat.profiles"
+
+const (
+	maxDisplayNameLen = 32
+	maxPronounsLen    = 16
+)
+
+// Profile is the user editable presentation of an identity.
+type Profile struct {
+	Who         string
+	DisplayName string
+	Pronouns    string
+
+	// Avatar is a two character badge rendered beside messages. When empty
+	// the initials of the name are used.
+	Avatar string
+}
+
+type (
+	ProfileReq struct {
+		Requestor mpty.ClientId
+		Profile   Profile
+	}
+
+	ProfileErr struct {
+		Requestor mpty.ClientId
+		Err       string
+	}
+
+	// ProfilesMsg is broadcast with every known profile whenever one changes
+	// or a client connects.
+	ProfilesMsg map[string]Profile
+)
+
+func (p Profile) Name() string {
+	if p.DisplayName != "" {
+		return p.DisplayName
+	}
+	return Display.Name(p.Who, "")
+}
+
+func (p Profile) Badge() string {
+	if p.Avatar != "" {
+		return p.Avatar
+	}
+
+	words := strings.Fields(p.Name())
+	switch len(words) {
+	case 0:
+		return ""
+	case 1:
+		r := []rune(words[0])
+		return strings.ToUpper(string(r[:min(2, len(r))]))
+	default:
+		first, _ := utf8.DecodeRuneInString(words[0])
+		second, _ := utf8.DecodeRuneInString(words[1])
+		return strings.ToUpper(string([]rune{first, second}))
+	}
+}
+
+// Label is the string shown in the nick column for messages from Who.
+func (p Profile) Label() string {
+	return fmt.Sprintf("[%s] %s", p.Badge(), p.Name())
+}
+
+func (p Profile) Validate() error {
+	switch {
+	case utf8.RuneCountInString(p.DisplayName) > maxDisplayNameLen:
+		return fmt.Errorf("display name is longer than %d characters", maxDisplayNameLen)
+	case utf8.RuneCountInString(p.Pronouns) > maxPronounsLen:
+		return fmt.Errorf("pronouns are longer than %d characters", maxPronounsLen)
+	case utf8.RuneCountInString(p.Avatar) > 2:
+		return fmt.Errorf("avatar must be at most 2 characters")
+	}
+	return nil
+}
+
+func (m *ServerModel) loadProfiles() error {
+	if m.Store == nil {
+		return nil
+	}
+
+	raw, err := m.Store.List(profileBucket)
+	if err != nil {
+		return err
+	}
+	for who, data := range raw {
+		var p Profile
+		if err := json.Unmarshal(data, &p); err != nil {
+			return fmt.Errorf("profile %s: %w", who, err)
+		}
+		m.profiles[who] = p
+	}
+	return nil
+}
+
+func (m *ServerModel) updateProfile(req ProfileReq) error {
+	p := req.Profile
+	p.Who = req.Requestor.Identity()
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	m.profiles[p.Who] = p
+	if m.Store != nil {
+		if err := m.Store.Put(profileBucket, p.Who, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *ServerModel) profilesMsg() ProfilesMsg {
+	return ProfilesMsg(maps.Clone(m.profiles))
+}
@@ -0,0 +1,429 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+	"github.com/ghthor/webtea/bubbles/blokfall"
+	"github.com/ghthor/webtea/i18n"
+	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/mpty/mptymsg"
+	"github.com/ghthor/webtea/roles"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	roomBucket = "chat.room"
+	roomKey    = "config"
+)
+
+const (
+	maxTopicLen = 80
+	maxMOTDLen  = 500
+
+	// GameBlokfall is the name of the blokfall game in RoomConfig.Games
+	GameBlokfall = "blokfall"
+
+	// pruneInterval is how often the history retention is enforced
+	pruneInterval = time.Minute
+
+	// redactedHash replaces the password hash broadcast to clients
+	redactedHash = "redacted"
+)
+
+var (
+	// ErrNoRoom refuses clients that aren't invited to a private room, which
+	// doesn't admit to existing
+	ErrNoRoom      = errors.New("no such room")
+	ErrInviteOnly  = errors.New("the room is invite only, ask an operator for an invite")
+	ErrNeedsPasswd = errors.New("the room requires a password, set " + mpty.PasswordEnv + " or ?" + mpty.PasswordParam + "=")
+)
+
+// RoomFields are the fields of a RoomConfig editable with /room set.
+var RoomFields = []string{"topic", "motd", "slowmode", "games", "retention", "max_members", "invite_only", "private", "password"}
+
+// RoomConfig is the operator editable configuration of the room. The zero
+// value is an unrestricted room without a topic.
+type RoomConfig struct {
+	Topic string
+	// MOTD is shown to every client when it connects
+	MOTD string
+
+	// SlowMode is the minimum time between the messages of a user
+	SlowMode time.Duration
+	// Games are the games that may be started, all of them when empty
+	Games []string
+	// Retention is how long the message history is kept, forever when 0
+	Retention time.Duration
+	// MaxMembers limits the users connected at once, unlimited when 0
+	MaxMembers int
+
+	// InviteOnly rooms admit only the Invited users and operators. Private
+	// rooms are invite only and pretend not to exist to everyone else.
+	InviteOnly bool
+	Private    bool
+	Invited    []string
+	// PasswordHash is the bcrypt hash of the password users who aren't
+	// invited must join with, no password is required when empty
+	PasswordHash string
+}
+
+type (
+	// RoomSetReq sets Field of the RoomConfig to Value, it is sent by
+	// clients that can roles.CanConfigureRoom.
+	RoomSetReq struct {
+		Requestor mpty.ClientId
+		Field     string
+		Value     string
+	}
+
+	// RoomInviteReq adds User to the invited users of the room, or removes
+	// them with Revoke. It is sent by clients that can
+	// roles.CanConfigureRoom.
+	RoomInviteReq struct {
+		Requestor mpty.ClientId
+		User      string
+		Revoke    bool
+	}
+
+	// RoomErr is sent to a client when a request was refused by the
+	// RoomConfig.
+	RoomErr struct {
+		Requestor mpty.ClientId
+		Err       string
+
+		// Key and Args are set on the refusals of the room so each client
+		// can render Err in its own locale, like those of a Msg.
+		Key  string   `json:",omitempty"`
+		Args []string `json:",omitempty"`
+	}
+
+	// RoomMsg is broadcast with the RoomConfig whenever it changes or a
+	// client connects.
+	RoomMsg RoomConfig
+)
+
+// roomErrT is a RoomErr that is translated by each client.
+func roomErrT(id mpty.ClientId, key string, args ...string) RoomErr {
+	return RoomErr{Requestor: id, Key: key, Args: args, Err: i18n.T(i18n.Default, key, anys(args)...)}
+}
+
+// Text is Err in locale l.
+func (e RoomErr) Text(l i18n.Locale) string {
+	if e.Key == "" {
+		return e.Err
+	}
+	return i18n.T(l, e.Key, anys(e.Args)...)
+}
+
+// Allows reports if game may be started in the room.
+func (c RoomConfig) Allows(game string) bool {
+	return len(c.Games) == 0 || slices.Contains(c.Games, game)
+}
+
+// HasPassword reports if a password is required to join the room.
+func (c RoomConfig) HasPassword() bool {
+	return c.PasswordHash != ""
+}
+
+// Invites reports if who is invited to the room.
+func (c RoomConfig) Invites(who string) bool {
+	return slices.Contains(c.Invited, who)
+}
+
+// Set parses value into field, one of RoomFields. Durations are go
+// durations, e.g. 30s or 720h, and games are separated by commas. An empty
+// value resets field.
+func (c *RoomConfig) Set(field, value string) error {
+	var err error
+	switch field {
+	case "topic":
+		c.Topic = value
+	case "motd":
+		c.MOTD = value
+	case "slowmode":
+		c.SlowMode, err = parseDuration(value)
+	case "retention":
+		c.Retention, err = parseDuration(value)
+	case "games":
+		c.Games = nil
+		for game := range strings.SplitSeq(value, ",") {
+			if game = strings.TrimSpace(game); game != "" {
+				c.Games = append(c.Games, game)
+			}
+		}
+	case "max_members":
+		c.MaxMembers = 0
+		if value != "" {
+			c.MaxMembers, err = strconv.Atoi(value)
+		}
+	case "invite_only":
+		c.InviteOnly, err = parseBool(value)
+	case "private":
+		c.Private, err = parseBool(value)
+	case "password":
+		c.PasswordHash = ""
+		if value != "" {
+			var hash []byte
+			hash, err = bcrypt.GenerateFromPassword([]byte(value), bcrypt.DefaultCost)
+			c.PasswordHash = string(hash)
+		}
+	default:
+		return fmt.Errorf("unknown room field %s, available: %s", field, strings.Join(RoomFields, ", "))
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", field, err)
+	}
+	return c.Validate()
+}
+
+func parseBool(s string) (bool, error) {
+	if s == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(s)
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
+
+func (c RoomConfig) Validate() error {
+	switch {
+	case utf8.RuneCountInString(c.Topic) > maxTopicLen:
+		return fmt.Errorf("topic is longer than %d characters", maxTopicLen)
+	case utf8.RuneCountInString(c.MOTD) > maxMOTDLen:
+		return fmt.Errorf("motd is longer than %d characters", maxMOTDLen)
+	case c.SlowMode < 0, c.Retention < 0, c.MaxMembers < 0:
+		return fmt.Errorf("limits must not be negative")
+	}
+	return nil
+}
+
+func (m *ServerModel) loadRoom() error {
+	if m.Store == nil {
+		return nil
+	}
+	_, err := m.Store.Get(roomBucket, roomKey, &m.room)
+	return err
+}
+
+// roomPasswordMsg is the password of a RoomSetReq hashed by a command,
+// bcrypt is too slow for the Main program.
+type roomPasswordMsg struct {
+	req  RoomSetReq
+	hash string
+	err  error
+}
+
+func hashPassword(req RoomSetReq) tea.Cmd {
+	return func() tea.Msg {
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.Value), bcrypt.DefaultCost)
+		return roomPasswordMsg{req: req, hash: string(hash), err: err}
+	}
+}
+
+// setRoom applies req, the RoomConfig is broadcast once it changed.
+func (m *ServerModel) setRoom(req RoomSetReq, set func(*RoomConfig) error) {
+	if err := m.updateRoom(req, set); err != nil {
+		m.broadcaster.Write(RoomErr{Requestor: req.Requestor, Err: err.Error()})
+		return
+	}
+	m.broadcaster.Write(m.roomMsg())
+}
+
+func (m *ServerModel) updateRoom(req RoomSetReq, set func(*RoomConfig) error) error {
+	room := m.room
+	room.Games = slices.Clone(room.Games)
+	if err := set(&room); err != nil {
+		return err
+	}
+
+	if m.Store != nil {
+		if err := m.Store.Put(roomBucket, roomKey, room); err != nil {
+			return err
+		}
+	}
+	m.room = room
+	value := req.Value
+	if req.Field == "password" {
+		value = redactedHash
+	}
+	log.Info("room", "by", req.Requestor, "field", req.Field, "value", value)
+	return nil
+}
+
+func (m *ServerModel) inviteRoom(req RoomInviteReq) error {
+	room := m.room
+	room.Invited = slices.DeleteFunc(slices.Clone(room.Invited), func(who string) bool {
+		return who == req.User
+	})
+	if !req.Revoke {
+		room.Invited = append(room.Invited, req.User)
+	}
+
+	if m.Store != nil {
+		if err := m.Store.Put(roomBucket, roomKey, room); err != nil {
+			return err
+		}
+	}
+	m.room = room
+	log.Info("room invite", "by", req.Requestor, "user", req.User, "revoke", req.Revoke)
+	return nil
+}
+
+func (m *ServerModel) roomMsg() RoomMsg {
+	room := m.room
+	room.Games = slices.Clone(room.Games)
+	room.Invited = slices.Clone(room.Invited)
+	if room.HasPassword() {
+		room.PasswordHash = redactedHash
+	}
+	return RoomMsg(room)
+}
+
+var _ mpty.Admitter = &ServerModel{}
+
+// Admit enforces the invites of the room, the password was checked by
+// Verify. Operators and invited users are always admitted.
+func (m *ServerModel) Admit(req mpty.JoinReq) error {
+	if err := m.admit(req); err != nil {
+		return err
+	}
+	m.admitted(req)
+	return nil
+}
+
+func (m *ServerModel) admit(req mpty.JoinReq) error {
+	if m.exempt(req) {
+		return nil
+	}
+	switch {
+	case m.room.Private:
+		return ErrNoRoom
+	case m.room.InviteOnly:
+		return ErrInviteOnly
+	}
+	return nil
+}
+
+// exempt reports if req is admitted whatever the room requires, operators
+// and invited users are.
+func (m *ServerModel) exempt(req mpty.JoinReq) bool {
+	return req.Access.Can(roles.CanConfigureRoom) || m.room.Invites(req.Id.Identity())
+}
+
+var _ mpty.Verifier = &ServerModel{}
+
+// Verify compares the password of a client that isn't otherwise admitted
+// with the hash of the room, bcrypt is too slow for the Main program.
+func (m *ServerModel) Verify(req mpty.JoinReq) func() error {
+	// private and invite only rooms are refused by Admit
+	if m.exempt(req) || m.room.Private || m.room.InviteOnly || !m.room.HasPassword() {
+		return nil
+	}
+	hash := []byte(m.room.PasswordHash)
+	return func() error {
+		if bcrypt.CompareHashAndPassword(hash, []byte(req.Password)) != nil {
+			return ErrNeedsPasswd
+		}
+		return nil
+	}
+}
+
+var _ mpty.Refuser = &ServerModel{}
+
+// Refuse keeps the messages of guests waiting for approval and those sent
+// too soon in slow mode from being recorded, see held. The sender of a
+// message sent too soon is told to wait.
+func (m *ServerModel) Refuse(rec mptymsg.Recordable) bool {
+	msg, ok := rec.(Msg)
+	if !ok {
+		return false
+	}
+	if m.held(msg) {
+		return true
+	}
+	if !m.slowed(msg) {
+		return false
+	}
+	m.broadcaster.Write(roomErrT(mpty.NewClientId(msg.Who, msg.Sess), "chat.room.slowed", m.room.SlowMode.String()))
+	return true
+}
+
+// slowed reports if msg was sent sooner than the slow mode allows after the
+// previous message of its user.
+func (m *ServerModel) slowed(msg Msg) bool {
+	if m.room.SlowMode <= 0 || msg.Who == SysNick {
+		return false
+	}
+	if last, ok := m.spoke[msg.Who]; ok && msg.At.Sub(last) < m.room.SlowMode {
+		return true
+	}
+	m.spoke[msg.Who] = msg.At
+	return false
+}
+
+// full reports if who would exceed the member limit of the room.
+func (m *ServerModel) full(who string) bool {
+	if m.room.MaxMembers <= 0 {
+		return false
+	}
+	if _, ok := m.names[who]; ok {
+		return false
+	}
+	return len(m.names) >= m.room.MaxMembers
+}
+
+// refuse ends the session of a client the room has no space for.
+func (m *ServerModel) refuse(id mpty.ClientId) {
+	m.broadcaster.Write(roomErrT(id, "chat.room.full", strconv.Itoa(m.room.MaxMembers)))
+	if m.Sessions == nil {
+		log.Warn("room is full but sessions can't be ended", "id", id)
+		return
+	}
+	sessions := m.Sessions
+	m.cmds = append(m.cmds, func() tea.Msg {
+		sessions.Kick(string(id))
+		return nil
+	})
+}
+
+// allowsGame refuses the games the room doesn't allow.
+func (m *ServerModel) allowsGame(msg tea.Msg) bool {
+	join, ok := msg.(blokfall.MPConnectPlayerMsg)
+	if !ok || m.room.Allows(GameBlokfall) {
+		return true
+	}
+	m.broadcaster.Write(roomErrT(mpty.ClientId(join), "chat.room.game_denied", GameBlokfall))
+	return false
+}
+
+// prune deletes the history older than the retention of the room.
+func (m *ServerModel) prune() tea.Cmd {
+	if m.room.Retention <= 0 || m.History == nil || m.tick.Sub(m.pruned) < pruneInterval {
+		return nil
+	}
+	m.pruned = m.tick
+
+	history, before := m.History, m.tick.Add(-m.room.Retention)
+	return func() tea.Msg {
+		n, err := history.Prune(before)
+		if err != nil {
+			log.Warn("failed to prune history", "error", err)
+		} else if n > 0 {
+			log.Info("pruned history", "msgs", n, "before", before)
+		}
+		return nil
+	}
+}
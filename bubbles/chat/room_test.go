@@ -0,0 +1,118 @@
+package chat
+
+import (
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ghthor/webtea/bubbles/blokfall"
+	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/roles"
+	"github.com/golang-cz/ringbuf"
+	"github.com/stretchr/testify/require"
+	"tailscale.com/client/tailscale/apitype"
+	"tailscale.com/tailcfg"
+)
+
+func TestRoomConfig(t *testing.T) {
+	var c RoomConfig
+	require.True(t, c.Allows(GameBlokfall))
+
+	require.NoError(t, c.Set("topic", "welcome"))
+	require.NoError(t, c.Set("slowmode", "30s"))
+	require.NoError(t, c.Set("games", "chess, go"))
+	require.NoError(t, c.Set("max_members", "20"))
+	require.Equal(t, RoomConfig{
+		Topic:      "welcome",
+		SlowMode:   30 * time.Second,
+		Games:      []string{"chess", "go"},
+		MaxMembers: 20,
+	}, c)
+	require.False(t, c.Allows(GameBlokfall))
+
+	require.NoError(t, c.Set("games", ""))
+	require.True(t, c.Allows(GameBlokfall))
+
+	require.ErrorContains(t, c.Set("retention", "-1h"), "must not be negative")
+	require.ErrorContains(t, c.Set("retention", "week"), "retention:")
+	require.ErrorContains(t, c.Set("color", "red"), "unknown room field color")
+}
+
+func TestRoomAdmit(t *testing.T) {
+	m := &ServerModel{}
+	m.Init()
+	join := func(who, password string) error {
+		req := mpty.JoinReq{Id: mpty.NewClientId(who, "sess"), Password: password}
+		if verify := m.Verify(req); verify != nil {
+			if err := verify(); err != nil {
+				return err
+			}
+		}
+		return m.Admit(req)
+	}
+
+	require.NoError(t, join("alice@example.com", ""))
+
+	require.NoError(t, m.room.Set("password", "hunter2"))
+	require.ErrorIs(t, join("alice@example.com", ""), ErrNeedsPasswd)
+	require.ErrorIs(t, join("alice@example.com", "hunter3"), ErrNeedsPasswd)
+	require.NoError(t, join("alice@example.com", "hunter2"))
+	require.Equal(t, redactedHash, m.roomMsg().PasswordHash)
+
+	require.NoError(t, m.inviteRoom(RoomInviteReq{User: "bob@example.com"}))
+	require.NoError(t, m.room.Set("invite_only", "true"))
+	require.ErrorIs(t, join("alice@example.com", "hunter2"), ErrInviteOnly)
+	require.NoError(t, join("bob@example.com", ""))
+
+	require.NoError(t, m.room.Set("private", "true"))
+	require.ErrorIs(t, join("alice@example.com", "hunter2"), ErrNoRoom)
+
+	policy := roles.DefaultPolicy()
+	policy.Logins = map[string]roles.Role{"carol@example.com": roles.Moderator}
+	require.NoError(t, m.Admit(mpty.JoinReq{
+		Id:     mpty.NewClientId("carol@example.com", "sess"),
+		Access: policy.Access(&apitype.WhoIsResponse{UserProfile: &tailcfg.UserProfile{LoginName: "carol@example.com"}}),
+	}))
+
+	require.NoError(t, m.inviteRoom(RoomInviteReq{User: "bob@example.com", Revoke: true}))
+	require.ErrorIs(t, join("bob@example.com", ""), ErrNoRoom)
+}
+
+func TestRoomSlowMode(t *testing.T) {
+	m := &ServerModel{}
+	m.Init()
+	m.Update(ringbuf.New[tea.Msg](100))
+	require.NoError(t, m.room.Set("slowmode", "30s"))
+
+	at := time.Now()
+	require.False(t, m.Refuse(Msg{At: at, Who: "alice@example.com", Sess: "1", Str: "hi"}))
+	require.True(t, m.Refuse(Msg{At: at.Add(time.Second), Who: "alice@example.com", Sess: "1", Str: "hi again"}))
+	require.False(t, m.Refuse(Msg{At: at.Add(time.Second), Who: "bob@example.com", Sess: "1", Str: "hi"}))
+	require.False(t, m.Refuse(Msg{At: at.Add(time.Minute), Who: "alice@example.com", Sess: "1", Str: "later"}))
+	require.False(t, m.Refuse(blokfall.GameOverMsg{}), "only chat messages are slowed")
+}
+
+func TestRoomSetPassword(t *testing.T) {
+	m := &ServerModel{}
+	m.Init()
+	m.Update(ringbuf.New[tea.Msg](100))
+	req := mpty.JoinReq{Id: mpty.NewClientId("alice@example.com", "sess")}
+
+	_, cmd := m.Update(RoomSetReq{Field: "password", Value: "hunter2"})
+	require.False(t, m.room.HasPassword(), "the password is hashed by a command")
+	require.Nil(t, m.Verify(req))
+
+	msg := cmd()
+	require.IsType(t, roomPasswordMsg{}, msg)
+	m.Update(msg)
+	require.True(t, m.room.HasPassword())
+
+	verify := m.Verify(req)
+	require.NotNil(t, verify)
+	require.ErrorIs(t, verify(), ErrNeedsPasswd)
+	req.Password = "hunter2"
+	require.NoError(t, m.Verify(req)())
+
+	m.Update(RoomSetReq{Field: "password"})
+	require.False(t, m.room.HasPassword())
+}
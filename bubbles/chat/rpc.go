@@ -0,0 +1,34 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/mpty/mptymsg"
+	"github.com/ghthor/webtea/roles"
+)
+
+var ErrEmptyMsg = errors.New("empty message")
+
+// AcceptRPC accepts the chat messages of frontends connected over rpc, see
+// tstea.Accept. Only the text of the message is kept, who sent it and when
+// is set by the server. Commands aren't run, they are sent as text.
+func AcceptRPC(id mpty.ClientId, _ roles.Access, msg mptymsg.Recordable) (tea.Msg, error) {
+	chat, ok := msg.(Msg)
+	if !ok {
+		return nil, fmt.Errorf("can't send %s messages", msg.TypeName())
+	}
+	if strings.TrimSpace(chat.Str) == "" {
+		return nil, ErrEmptyMsg
+	}
+	return Msg{
+		At:   time.Now(),
+		Who:  id.Identity(),
+		Sess: id.Session(),
+		Str:  chat.Str,
+	}.SetNick(), nil
+}
@@ -0,0 +1,24 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/roles"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptRPC(t *testing.T) {
+	id := mpty.NewClientId("alice@example.com", "s1")
+
+	msg, err := AcceptRPC(id, roles.Access{}, Msg{Who: "bob@example.com", Sess: "s2", Str: "hi"})
+	require.NoError(t, err)
+	chat := msg.(Msg)
+	require.Equal(t, "alice@example.com", chat.Who)
+	require.Equal(t, "s1", chat.Sess)
+	require.Equal(t, "hi", chat.Str)
+	require.False(t, chat.At.IsZero())
+
+	_, err = AcceptRPC(id, roles.Access{}, Msg{Str: "  "})
+	require.ErrorIs(t, err, ErrEmptyMsg)
+}
@@ -0,0 +1,168 @@
+package chat
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/ghthor/webtea/mpty"
+)
+
+const scheduleBucket = "chat.scheduled"
+
+const (
+	// maxScheduled is how many messages an identity may have scheduled
+	maxScheduled = 10
+	// maxScheduleIn is how far ahead a message may be scheduled
+	maxScheduleIn = 7 * 24 * time.Hour
+)
+
+type (
+	// ScheduleReq schedules Str to be sent by the Requestor At, it is
+	// answered with a ScheduleResult.
+	ScheduleReq struct {
+		Requestor mpty.ClientId
+		At        time.Time
+		Str       string
+	}
+
+	// ScheduleCancelReq cancels the scheduled message Id of the identity of
+	// the Requestor, it is answered with a ScheduleResult.
+	ScheduleCancelReq struct {
+		Requestor mpty.ClientId
+		Id        string
+	}
+
+	// ScheduleResult is sent to the client of a ScheduleReq or a
+	// ScheduleCancelReq, Err is why it was refused.
+	ScheduleResult struct {
+		Requestor mpty.ClientId
+		Id        string
+		At        time.Time
+		Cancelled bool
+		Err       string
+	}
+
+	// ScheduleListReq lists the Scheduled messages of the identity of the
+	// Requestor, in the order they are sent.
+	ScheduleListReq struct {
+		Requestor mpty.ClientId
+		Scheduled []ScheduledMsg
+	}
+
+	// ScheduledMsg is a message waiting to be sent, it is kept in the store
+	// so it is sent even when the server restarted in between.
+	ScheduledMsg struct {
+		Id   string
+		Who  string
+		Sess string
+		At   time.Time
+		Str  string
+	}
+)
+
+func (m *ServerModel) loadScheduled() error {
+	if m.Store == nil {
+		return nil
+	}
+
+	raw, err := m.Store.List(scheduleBucket)
+	if err != nil {
+		return err
+	}
+	for id, data := range raw {
+		var s ScheduledMsg
+		if err := json.Unmarshal(data, &s); err != nil {
+			return fmt.Errorf("scheduled message %s: %w", id, err)
+		}
+		m.scheduled[id] = s
+		if n, err := strconv.Atoi(id); err == nil {
+			m.scheduleSeq = max(m.scheduleSeq, n)
+		}
+	}
+	return nil
+}
+
+func (m *ServerModel) schedule(req ScheduleReq) (ScheduledMsg, error) {
+	who := req.Requestor.Identity()
+	switch {
+	case strings.TrimSpace(req.Str) == "":
+		return ScheduledMsg{}, fmt.Errorf("the message is empty")
+	case !req.At.After(m.tick):
+		return ScheduledMsg{}, fmt.Errorf("the message must be sent in the future")
+	case req.At.Sub(m.tick) > maxScheduleIn:
+		return ScheduledMsg{}, fmt.Errorf("messages can't be scheduled more than %s ahead", maxScheduleIn)
+	case len(m.scheduledOf(who)) >= maxScheduled:
+		return ScheduledMsg{}, fmt.Errorf("you already have %d messages scheduled", maxScheduled)
+	}
+
+	m.scheduleSeq++
+	s := ScheduledMsg{
+		Id:   strconv.Itoa(m.scheduleSeq),
+		Who:  who,
+		Sess: req.Requestor.Session(),
+		At:   req.At,
+		Str:  req.Str,
+	}
+	if m.Store != nil {
+		if err := m.Store.Put(scheduleBucket, s.Id, s); err != nil {
+			return ScheduledMsg{}, err
+		}
+	}
+	m.scheduled[s.Id] = s
+	return s, nil
+}
+
+func (m *ServerModel) cancelScheduled(req ScheduleCancelReq) error {
+	s, ok := m.scheduled[req.Id]
+	if !ok || s.Who != req.Requestor.Identity() {
+		return fmt.Errorf("you have no message %s scheduled", req.Id)
+	}
+	return m.unschedule(s.Id)
+}
+
+func (m *ServerModel) unschedule(id string) error {
+	delete(m.scheduled, id)
+	if m.Store != nil {
+		return m.Store.Delete(scheduleBucket, id)
+	}
+	return nil
+}
+
+// scheduledOf returns the scheduled messages of who in the order they are
+// sent.
+func (m *ServerModel) scheduledOf(who string) []ScheduledMsg {
+	var msgs []ScheduledMsg
+	for _, s := range m.scheduled {
+		if s.Who == who {
+			msgs = append(msgs, s)
+		}
+	}
+	slices.SortFunc(msgs, compareScheduled)
+	return msgs
+}
+
+func compareScheduled(a, b ScheduledMsg) int {
+	return cmp.Or(a.At.Compare(b.At), cmp.Compare(a.Id, b.Id))
+}
+
+// sendScheduled broadcasts the scheduled messages that are due, as sent by
+// their author.
+func (m *ServerModel) sendScheduled() {
+	due := slices.SortedFunc(maps.Values(m.scheduled), compareScheduled)
+	for _, s := range due {
+		if m.tick.Before(s.At) {
+			break
+		}
+		if err := m.unschedule(s.Id); err != nil {
+			log.Warn("failed to delete scheduled message", "id", s.Id, "error", err)
+		}
+		m.broadcaster.Write(Msg{At: m.tick, Who: s.Who, Sess: s.Sess, Str: s.Str}.SetNick())
+	}
+}
@@ -0,0 +1,53 @@
+package chat
+
+import (
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/mpty/mptymsg"
+	"github.com/golang-cz/ringbuf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedule(t *testing.T) {
+	store := mptymsg.NewMemory(10)
+	m := &ServerModel{Store: store}
+	m.Init()
+	m.Update(ringbuf.New[tea.Msg](100))
+
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	m.Update(start)
+
+	alice, bob := mpty.NewClientId("alice@example.com", "1"), mpty.NewClientId("bob@example.com", "1")
+	_, err := m.schedule(ScheduleReq{Requestor: alice, At: start, Str: "now"})
+	require.ErrorContains(t, err, "future")
+	_, err = m.schedule(ScheduleReq{Requestor: alice, At: start.Add(8 * 24 * time.Hour), Str: "later"})
+	require.ErrorContains(t, err, "ahead")
+
+	later, err := m.schedule(ScheduleReq{Requestor: alice, At: start.Add(10 * time.Minute), Str: "later"})
+	require.NoError(t, err)
+	sooner, err := m.schedule(ScheduleReq{Requestor: alice, At: start.Add(time.Minute), Str: "sooner"})
+	require.NoError(t, err)
+	require.Equal(t, []ScheduledMsg{sooner, later}, m.scheduledOf("alice@example.com"))
+
+	require.ErrorContains(t, m.cancelScheduled(ScheduleCancelReq{Requestor: bob, Id: later.Id}), "no message")
+
+	// the scheduled messages outlive a restart
+	m = &ServerModel{Store: store}
+	m.Init()
+	m.Update(ringbuf.New[tea.Msg](100))
+	m.Update(start)
+	require.Len(t, m.scheduled, 2)
+	require.NoError(t, m.cancelScheduled(ScheduleCancelReq{Requestor: alice, Id: later.Id}))
+
+	m.Update(start.Add(time.Minute))
+	require.Empty(t, m.scheduled)
+	sent, err := store.List(scheduleBucket)
+	require.NoError(t, err)
+	require.Empty(t, sent)
+	next, err := m.schedule(ScheduleReq{Requestor: alice, At: start.Add(2 * time.Minute), Str: "next"})
+	require.NoError(t, err)
+	require.NotEqual(t, sooner.Id, next.Id)
+}
@@ -1,39 +1,142 @@
 package chat
 
 import (
+	"encoding/json"
 	"fmt"
 	"maps"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/log"
 	"github.com/ghthor/webtea/bubbles/blokfall"
+	"github.com/ghthor/webtea/hooks"
+	"github.com/ghthor/webtea/logpolicy"
 	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/mpty/mptymsg"
+	"github.com/ghthor/webtea/roles"
+	"github.com/ghthor/webtea/serverinfo"
 	"github.com/golang-cz/ringbuf"
 )
 
 type NamesReq struct {
 	Requestor mpty.ClientId
 	Names     []string
+	// Tailnet are the peers online on the network of the server, connected
+	// to the chat or not, see ServerModel.Tailnet
+	Tailnet []string
 }
 
 type WhoisReq struct {
 	Requestor mpty.ClientId
 	User      string
-	Results   []string
+	// Addrs includes the remote address of each session in the results, it
+	// is only set by clients that can roles.CanViewAddresses
+	Addrs   bool
+	Results []string
+}
+
+// KickReq ends every session of User, it is sent by clients that can
+// roles.CanKick and answered with a KickResult.
+type KickReq struct {
+	Requestor mpty.ClientId
+	User      string
+}
+
+type KickResult struct {
+	Requestor mpty.ClientId
+	User      string
+	Kicked    int
 }
 
 type ServerModel struct {
+	// Store is optional and persists state like user profiles that must
+	// outlive the recorded message history.
+	Store mptymsg.Store
+
+	// Stats is optional and answers /stats, it must be registered with the
+	// recorder, see NewStatsTable.
+	Stats *mptymsg.Table[Stats]
+
+	// Sessions is optional, usually the mpty.Program. It looks up the remote
+	// addresses shown by /whois to clients that can roles.CanViewAddresses
+	// and ends sessions for /kick.
+	Sessions interface {
+		Sessions() []mpty.SessionInfo
+		Kick(idOrIdentity string) int
+	}
+
+	// Info is optional, usually a serverinfo.Server. It answers /version and
+	// /uptime, only the build is shown without it.
+	Info interface {
+		Info() serverinfo.Info
+	}
+
+	// History is optional, usually the recorder. It deletes the messages
+	// older than the retention of the RoomConfig.
+	History interface {
+		Prune(before time.Time) (int64, error)
+	}
+
+	// Recorded is optional, usually the recorder. Identities reconnecting
+	// are welcomed back with a summary of the recorded messages they missed.
+	Recorded interface {
+		Read(n int) ([]mptymsg.Recordable, error)
+	}
+
+	// ApproveGuests holds the first message of guests until a client that
+	// roles.CanApproveGuests approves it, which promotes the guest to a user
+	// for its session. Policy grants the capabilities of the user,
+	// roles.DefaultPolicy when nil.
+	ApproveGuests bool
+	Policy        *roles.Policy
+
+	// Tailnet is optional, usually a tshelper.ListenerSet. /names lists the
+	// peers of the tailnet that are online next to the chat.
+	Tailnet interface {
+		Peers() []mpty.Peer
+	}
+
+	// Hooks are the replies, scheduled messages and scripts of the room, the
+	// hooks that aren't valid are logged and left out. Plugins are the hooks
+	// added by code, they run after Hooks.
+	Hooks   hooks.Config
+	Plugins []hooks.Hook
+
 	cmds        []tea.Cmd
 	broadcaster *ringbuf.RingBuffer[tea.Msg]
 
 	tick time.Time
 
-	names map[string]map[string]time.Time
+	names    map[string]map[string]time.Time
+	profiles map[string]Profile
+	// drafts are the messages being composed by identity, see DraftReq
+	drafts map[string]string
+
+	// seen is when identities disconnected their last session, see
+	// WelcomeBackMsg
+	seen map[string]time.Time
+
+	// access is the access of the sessions admitted, see ApproveGuests
+	access map[mpty.ClientId]roles.Access
+	// pending are the messages of guests waiting for approval by id
+	pending    map[string]GuestPendingMsg
+	pendingSeq int
+
+	// scheduled are the messages waiting to be sent by id, see ScheduleReq
+	scheduled   map[string]ScheduledMsg
+	scheduleSeq int
 
-	blokfall *blokfall.MPModel
+	room   RoomConfig
+	spoke  map[string]time.Time
+	pruned time.Time
+
+	blokfall   *blokfall.MPModel
+	tournament *tournamentState
+
+	hooks *hooks.Engine
 }
 
 func (m *ServerModel) Init() tea.Cmd {
@@ -46,6 +149,45 @@ func (m *ServerModel) Init() tea.Cmd {
 	if m.blokfall == nil {
 		m.blokfall = &blokfall.MPModel{}
 	}
+	if m.spoke == nil {
+		m.spoke = make(map[string]time.Time, 10)
+		if err := m.loadRoom(); err != nil {
+			log.Warn("failed to load room config", "error", err)
+		}
+	}
+	if m.hooks == nil {
+		var err error
+		if m.hooks, err = hooks.New(m.Hooks); err != nil {
+			log.Warn("invalid hooks", "error", err)
+		}
+		for _, h := range m.Plugins {
+			m.hooks.Add(h)
+		}
+	}
+	if m.profiles == nil {
+		m.profiles = make(map[string]Profile, 10)
+		if err := m.loadProfiles(); err != nil {
+			log.Warn("failed to load profiles", "error", err)
+		}
+	}
+	if m.scheduled == nil {
+		m.scheduled = make(map[string]ScheduledMsg)
+		if err := m.loadScheduled(); err != nil {
+			log.Warn("failed to load scheduled messages", "error", err)
+		}
+	}
+	if m.seen == nil {
+		m.seen = make(map[string]time.Time)
+		if err := m.loadSeen(); err != nil {
+			log.Warn("failed to load last seen", "error", err)
+		}
+	}
+	if m.drafts == nil {
+		m.drafts = make(map[string]string)
+		if err := m.loadDrafts(); err != nil {
+			log.Warn("failed to load drafts", "error", err)
+		}
+	}
 	return tea.Batch(
 		func() tea.Msg { return time.Now() },
 		m.blokfall.Init(),
@@ -55,7 +197,9 @@ func (m *ServerModel) Init() tea.Cmd {
 func (m *ServerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	m.cmds = m.cmds[:0]
 	m.UpdateChat(msg)
-	m.cmds = append(m.cmds, m.UpdateBlokFall(msg))
+	if m.allowsGame(msg) {
+		m.cmds = append(m.cmds, m.UpdateBlokFall(msg))
+	}
 	return m, tea.Batch(m.cmds...)
 }
 
@@ -69,52 +213,224 @@ func (m *ServerModel) UpdateChat(msg tea.Msg) {
 		if m.broadcaster != nil {
 			m.broadcaster.Write(msg)
 			log.Debug("chat", "t", msg.At, "lag", lag, "who", msg.Who, "sess", msg.Sess, "msg", msg.Str)
+			if reply, ok := m.hooks.Reply(m.tick, msg.Str); ok {
+				m.broadcaster.Write(hookMsg(m.tick, reply))
+			}
 		} else {
-			log.Warn("dropped chat", "t", msg.At, "lag", lag, "who", msg.Who, "sess", msg.Sess, "msg", msg.Str)
+			logpolicy.Warn("chat.dropped", "dropped chat", "t", msg.At, "lag", lag, "who", msg.Who, "sess", msg.Sess, "msg", msg.Str)
 		}
 
 	case NamesReq:
-		msg.Names = slices.Sorted(maps.Keys(m.names))
-		for i := range msg.Names {
-			msg.Names[i] = NickFromWho(msg.Names[i])
-		}
-		m.broadcaster.Write(msg)
+		m.broadcaster.Write(m.namesReq(msg))
 
 	case WhoisReq:
 		m.broadcaster.Write(m.whoisReq(msg))
 
+	case StatsReq:
+		m.broadcaster.Write(m.statsReq(msg))
+
+	case ActivityReq:
+		m.broadcaster.Write(m.activityReq(msg))
+
+	case FindReq:
+		m.broadcaster.Write(m.findReq(msg))
+
+	case InfoReq:
+		m.broadcaster.Write(m.infoReq(msg))
+
+	case GuestReq:
+		r, held := m.guestReq(msg)
+		m.broadcaster.Write(r)
+		if r.Approved && r.Err == "" {
+			// the held message was never recorded, Main records it now
+			// with the next sequence number
+			m.cmds = append(m.cmds, func() tea.Msg {
+				return held
+			})
+		}
+
+	case KickReq:
+		if m.Sessions == nil {
+			m.broadcaster.Write(KickResult{Requestor: msg.Requestor, User: msg.User})
+			break
+		}
+		// ending a session waits on its program, which must not block the
+		// main program
+		sessions := m.Sessions
+		m.cmds = append(m.cmds, func() tea.Msg {
+			return KickResult{Requestor: msg.Requestor, User: msg.User, Kicked: sessions.Kick(msg.User)}
+		})
+
+	case KickResult:
+		log.Info("kick", "by", msg.Requestor, "user", msg.User, "sessions", msg.Kicked)
+		m.broadcaster.Write(msg)
+
+	case ProfileReq:
+		if err := m.updateProfile(msg); err != nil {
+			m.broadcaster.Write(ProfileErr{Requestor: msg.Requestor, Err: err.Error()})
+			break
+		}
+		m.broadcaster.Write(m.profilesMsg())
+
+	case ScheduleReq:
+		s, err := m.schedule(msg)
+		if err != nil {
+			m.broadcaster.Write(ScheduleResult{Requestor: msg.Requestor, Err: err.Error()})
+			break
+		}
+		m.broadcaster.Write(ScheduleResult{Requestor: msg.Requestor, Id: s.Id, At: s.At})
+
+	case ScheduleCancelReq:
+		if err := m.cancelScheduled(msg); err != nil {
+			m.broadcaster.Write(ScheduleResult{Requestor: msg.Requestor, Id: msg.Id, Err: err.Error()})
+			break
+		}
+		m.broadcaster.Write(ScheduleResult{Requestor: msg.Requestor, Id: msg.Id, Cancelled: true})
+
+	case ScheduleListReq:
+		msg.Scheduled = m.scheduledOf(msg.Requestor.Identity())
+		m.broadcaster.Write(msg)
+
+	case DraftReq:
+		m.updateDraft(msg)
+
+	case RoomSetReq:
+		if msg.Field == "password" && msg.Value != "" {
+			m.cmds = append(m.cmds, hashPassword(msg))
+			break
+		}
+		m.setRoom(msg, func(room *RoomConfig) error {
+			return room.Set(msg.Field, msg.Value)
+		})
+
+	case roomPasswordMsg:
+		m.setRoom(msg.req, func(room *RoomConfig) error {
+			if msg.err != nil {
+				return fmt.Errorf("password: %w", msg.err)
+			}
+			room.PasswordHash = msg.hash
+			return nil
+		})
+
+	case RoomInviteReq:
+		if err := m.inviteRoom(msg); err != nil {
+			m.broadcaster.Write(RoomErr{Requestor: msg.Requestor, Err: err.Error()})
+			break
+		}
+		m.broadcaster.Write(m.roomMsg())
+
+	case TournamentScheduleReq:
+		if err := m.scheduleTournament(msg); err != nil {
+			m.broadcaster.Write(TournamentErr{Requestor: msg.Requestor, Err: err.Error()})
+		}
+
+	case TournamentJoinReq:
+		if err := m.joinTournament(msg); err != nil {
+			m.broadcaster.Write(TournamentErr{Requestor: msg.Requestor, Err: err.Error()})
+		}
+
+	case blokfall.GameOverMsg:
+		if msg.Table == "" {
+			m.broadcaster.Write(SysMsgT(m.tick, "chat.blokfall.game_over",
+				strconv.FormatUint(msg.Score, 10), strconv.Itoa(msg.Lines)))
+			break
+		}
+		m.tournamentResult(msg)
+
+	case WelcomeBackMsg:
+		if msg.Messages > 0 || msg.Games > 0 {
+			m.broadcaster.Write(msg)
+		}
+
+	case mpty.PeerMsg:
+		key := "chat.peer.offline"
+		if msg.Online {
+			key = "chat.peer.online"
+		}
+		m.broadcaster.Write(SysMsgT(m.tick, key, msg.Name))
+
+	case mpty.AddrsMsg:
+		log.Info("server addresses changed", "addrs", msg.Addrs)
+
 	case mpty.ClientConnectMsg:
-		who, sess, _ := strings.Cut(string(msg), " ")
+		id := mpty.ClientId(msg)
+		who, sess := id.Identity(), id.Session()
+
+		if m.full(who) {
+			m.refuse(id)
+			break
+		}
 
 		sessions, ok := m.names[who]
 		if !ok {
 			m.names[who] = map[string]time.Time{sess: m.tick}
+			if cmd := m.welcomeBack(id); cmd != nil {
+				m.cmds = append(m.cmds, cmd)
+			}
 		} else {
 			sessions[sess] = m.tick
 		}
 
-		m.broadcaster.Write(SysMsg(m.tick,
-			fmt.Sprintf("%s connected", msg),
-		))
+		m.broadcaster.Write(m.profilesMsg())
+		m.broadcaster.Write(m.roomMsg())
+		m.broadcaster.Write(SysMsgT(m.tick, "chat.connected", string(msg)))
+		if d, ok := m.drafts[who]; ok {
+			m.broadcaster.Write(DraftMsg{Requestor: id, Draft: d})
+		}
 
 	case mpty.ClientDisconnectMsg:
-		who, sess, _ := strings.Cut(string(msg), " ")
+		id := mpty.ClientId(msg)
+		who, sess := id.Identity(), id.Session()
+		m.left(id)
 
+		// sessions refused by the member limit were never added
 		sessions, ok := m.names[who]
-		if ok {
-			delete(sessions, sess)
+		if !ok {
+			break
 		}
+		delete(sessions, sess)
+		m.leaveTournament(id)
 		if len(sessions) == 0 {
 			delete(m.names, who)
+			delete(m.spoke, who)
+			m.updateSeen(who)
 		}
 
-		m.broadcaster.Write(SysMsg(m.tick,
-			fmt.Sprintf("%s disconnected", msg),
-		))
+		m.broadcaster.Write(SysMsgT(m.tick, "chat.disconnected", string(msg)))
 
 	case time.Time:
 		m.tick = msg
+		m.cmds = append(m.cmds, m.prune())
+		m.tickTournament()
+		m.sendScheduled()
+		for _, post := range m.hooks.Due(m.tick) {
+			m.broadcaster.Write(hookMsg(m.tick, post))
+		}
+	}
+}
+
+// namesReq lists who is connected to the chat, and who is online on the
+// tailnet when the server is on one.
+func (m *ServerModel) namesReq(r NamesReq) NamesReq {
+	r.Names = slices.Sorted(maps.Keys(m.names))
+	for i := range r.Names {
+		r.Names[i] = m.displayName(r.Names[i])
+	}
+	if m.Tailnet != nil {
+		for _, p := range m.Tailnet.Peers() {
+			if p.Online {
+				r.Tailnet = append(r.Tailnet, p.Name)
+			}
+		}
+	}
+	return r
+}
+
+func (m *ServerModel) displayName(who string) string {
+	if p, ok := m.profiles[who]; ok {
+		return p.Label()
 	}
+	return Display.Name(who, "")
 }
 
 func (m *ServerModel) UpdateBlokFall(msg tea.Msg) tea.Cmd {
@@ -122,6 +438,27 @@ func (m *ServerModel) UpdateBlokFall(msg tea.Msg) tea.Cmd {
 	return cmd
 }
 
+// serverSnapshot is the state kept across restarts, the chat history and
+// profiles are already persisted by the recorder.
+type serverSnapshot struct {
+	Blokfall *blokfall.Snapshot `json:"blokfall,omitempty"`
+}
+
+var _ mpty.Snapshotter = &ServerModel{}
+
+func (m *ServerModel) Snapshot() (any, error) {
+	return serverSnapshot{Blokfall: m.blokfall.Snapshot()}, nil
+}
+
+func (m *ServerModel) Restore(raw json.RawMessage) error {
+	var s serverSnapshot
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return err
+	}
+	m.blokfall.Resume(s.Blokfall)
+	return nil
+}
+
 func (m *ServerModel) View() string {
 	return ""
 }
@@ -146,17 +483,30 @@ func FormatTimeAsAge(t time.Time, now time.Time) string {
 }
 
 func (m *ServerModel) whoisReq(r WhoisReq) WhoisReq {
+	addrs := map[mpty.ClientId]string{}
+	if r.Addrs && m.Sessions != nil {
+		for _, info := range m.Sessions.Sessions() {
+			addrs[info.Id] = info.Addr
+		}
+	}
+	withAddr := func(result, who, sess string) string {
+		if addr, ok := addrs[mpty.NewClientId(who, sess)]; ok {
+			return result + " " + addr
+		}
+		return result
+	}
+
 	sessions, ok := m.names[r.User]
 	if ok {
 		for sess := range sessions {
-			r.Results = append(r.Results, fmt.Sprintf("%s %s", r.User, sess))
+			r.Results = append(r.Results, withAddr(fmt.Sprintf("%s %s", Display.Login(r.User), sess), r.User, sess))
 		}
 		return r
 	}
 	for who, sessions := range m.names {
 		if strings.HasPrefix(who, r.User) {
 			for sess, since := range sessions {
-				r.Results = append(r.Results, fmt.Sprintf("%s %s (%s)", who, sess, FormatTimeAsAge(since, m.tick)))
+				r.Results = append(r.Results, withAddr(fmt.Sprintf("%s %s (%s)", Display.Login(who), sess, FormatTimeAsAge(since, m.tick)), who, sess))
 			}
 		}
 	}
@@ -0,0 +1,57 @@
+package chat
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/ghthor/webtea/mpty"
+)
+
+// Stages sanitizes the chat messages broadcast to the client and hides the
+// ones from users on the ignore list.
+func (m *Client) Stages() []mpty.Stage {
+	return []mpty.Stage{
+		mpty.Map(func(_ mpty.ClientModel, msg tea.Msg) tea.Msg {
+			if chat, ok := msg.(Msg); ok {
+				chat.Str = sanitize(chat.Str)
+				return chat
+			}
+			return msg
+		}),
+		mpty.Filter(func(_ mpty.ClientModel, msg tea.Msg) bool {
+			chat, ok := msg.(Msg)
+			return !ok || !m.ignores(chat.Who)
+		}),
+	}
+}
+
+var _ mpty.Stager = &Client{}
+
+// sanitize removes the escape sequences and control characters that would
+// let a message redraw the terminal of everyone reading it.
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r != '\n' && r != '\t' && unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, ansi.Strip(s))
+}
+
+// ignores reports if messages from who are hidden. Users are ignored by their
+// login, nick or display name.
+func (m *Client) ignores(who string) bool {
+	if len(m.ignored) == 0 {
+		return false
+	}
+	names := []string{who, NickFromWho(who)}
+	if p, ok := m.profiles[who]; ok {
+		names = append(names, p.Name())
+	}
+	return slices.ContainsFunc(m.ignored, func(user string) bool {
+		return slices.Contains(names, user)
+	})
+}
@@ -0,0 +1,26 @@
+package chat
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientStages(t *testing.T) {
+	c := &Client{ignored: []string{"bob"}}
+
+	msgs := []tea.Msg{
+		Msg{Who: "alice@example.com", Str: "\x1b[2J\x1b[31mhi\x1b[0m\a\tthere\n"},
+		Msg{Who: "bob@example.com", Str: "hello"},
+		NamesReq{},
+	}
+	for _, stage := range c.Stages() {
+		msgs = stage(c, msgs)
+	}
+
+	require.Equal(t, []tea.Msg{
+		Msg{Who: "alice@example.com", Str: "hi\tthere\n"},
+		NamesReq{},
+	}, msgs)
+}
@@ -0,0 +1,119 @@
+package chat
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/mpty/mptymsg"
+)
+
+const (
+	statsProjection = "chat.stats"
+
+	// minWordLen skips short words which are mostly articles and pronouns
+	minWordLen = 4
+	// maxWords bounds the vocabulary, words said once are forgotten first
+	maxWords = 10000
+
+	statsTop = 5
+)
+
+// Stats is a projection of the chat history, see NewStatsTable.
+type Stats struct {
+	// Messages is the number of messages sent by each identity
+	Messages map[string]int `json:"messages"`
+	// Words counts the words used in messages for a word cloud
+	Words map[string]int `json:"words"`
+	// Activity counts the messages by day of the week and hour, in UTC
+	Activity Activity `json:"activity"`
+}
+
+// Activity counts messages by time.Weekday and hour.
+type Activity [7][24]int
+
+// Count is an entry of a ranking in Stats.
+type Count struct {
+	Key   string
+	Count int
+}
+
+// NewStatsTable returns the projection of chat statistics. It must be
+// registered with the recorder to be kept up to date.
+func NewStatsTable() *mptymsg.Table[Stats] {
+	return mptymsg.NewTable(statsProjection, Stats{
+		Messages: make(map[string]int),
+		Words:    make(map[string]int),
+	}, reduceStats)
+}
+
+func reduceStats(s Stats, rec mptymsg.Recordable) Stats {
+	msg, ok := rec.(Msg)
+	if !ok || msg.Key != "" {
+		return s
+	}
+	switch msg.Who {
+	case SysNick, HelpNick, InfoNick, ErrNick:
+		return s
+	}
+
+	s.Messages[msg.Who]++
+	at := msg.At.UTC()
+	s.Activity[at.Weekday()][at.Hour()]++
+	for _, w := range strings.FieldsFunc(strings.ToLower(msg.Str), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if utf8.RuneCountInString(w) >= minWordLen {
+			s.Words[w]++
+		}
+	}
+	if len(s.Words) > maxWords {
+		maps.DeleteFunc(s.Words, func(_ string, n int) bool { return n == 1 })
+	}
+	return s
+}
+
+// Top returns the n highest counts of m, ties are ordered by key.
+func Top(m map[string]int, n int) []Count {
+	counts := make([]Count, 0, len(m))
+	for k, v := range m {
+		counts = append(counts, Count{k, v})
+	}
+	slices.SortFunc(counts, func(a, b Count) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), strings.Compare(a.Key, b.Key))
+	})
+	return counts[:min(n, len(counts))]
+}
+
+type StatsReq struct {
+	Requestor mpty.ClientId
+	Talkers   []Count
+	Words     []Count
+}
+
+func (m *ServerModel) statsReq(r StatsReq) StatsReq {
+	if m.Stats == nil {
+		return r
+	}
+	m.Stats.Read(func(s Stats) {
+		r.Talkers = Top(s.Messages, statsTop)
+		r.Words = Top(s.Words, statsTop)
+	})
+	for i := range r.Talkers {
+		r.Talkers[i].Key = m.displayName(r.Talkers[i].Key)
+	}
+	return r
+}
+
+func formatCounts(counts []Count) string {
+	parts := make([]string, len(counts))
+	for i, c := range counts {
+		parts[i] = c.Key + " (" + strconv.Itoa(c.Count) + ")"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,54 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsTable(t *testing.T) {
+	stats := NewStatsTable()
+	for _, msg := range []Msg{
+		{At: time.Now(), Who: "alice@example.com", Str: "Hello there, hello!"},
+		{At: time.Now(), Who: "bob@example.com", Str: "hello alice"},
+		{At: time.Now(), Who: "alice@example.com", Str: "tetris?"},
+		SysMsgT(time.Now(), "chat.connected", "carol@example.com"),
+	} {
+		stats.Apply(msg)
+	}
+
+	stats.Read(func(s Stats) {
+		require.Equal(t, []Count{{"alice@example.com", 2}, {"bob@example.com", 1}}, Top(s.Messages, 5))
+		require.Equal(t, []Count{{"hello", 3}, {"alice", 1}}, Top(s.Words, 2))
+	})
+}
+
+func TestActivity(t *testing.T) {
+	// 2025-01-06 is a Monday
+	monday := time.Date(2025, 1, 6, 9, 30, 0, 0, time.UTC)
+	stats := NewStatsTable()
+	for _, msg := range []Msg{
+		{At: monday, Who: "alice@example.com", Str: "morning"},
+		{At: monday.Add(time.Minute), Who: "bob@example.com", Str: "morning"},
+		{At: monday.Add(2 * time.Minute), Who: "alice@example.com", Str: "coffee?"},
+		{At: monday.Add(2 * time.Minute), Who: "carol@example.com", Str: "yes"},
+		{At: monday.Add(6 * 24 * time.Hour), Who: "bob@example.com", Str: "weekend"},
+		SysMsgT(monday, "chat.connected", "carol@example.com"),
+	} {
+		stats.Apply(msg)
+	}
+
+	var a Activity
+	stats.Read(func(s Stats) { a = s.Activity })
+	require.Equal(t, 4, a[time.Monday][9])
+	require.Equal(t, 1, a[time.Sunday][9])
+	require.Equal(t, 4, a.Peak())
+
+	lines := strings.Split(a.Heatmap(), "\n")
+	require.Len(t, lines, 8)
+	require.Equal(t, "    0     6     12    18    ", lines[0])
+	require.Equal(t, "Mon          █              ", lines[1])
+	require.Equal(t, "Sun          ░              ", lines[7])
+}
@@ -0,0 +1,111 @@
+package chat
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Theme is the set of styles used to render the chat table. Themes other than
+// the default avoid relying on faint text, which is hard to tell apart on
+// monochrome terminals, and prefix non chat lines with a symbol instead.
+type Theme struct {
+	Name string
+
+	TSCol   lipgloss.Style
+	Nick    lipgloss.Style
+	SysNick lipgloss.Style
+	MsgCol  lipgloss.Style
+	SysMsg  lipgloss.Style
+
+	// Prefix is prepended to messages from the system nicks
+	Prefix map[string]string
+}
+
+var symbolPrefixes = map[string]string{
+	SysNick:  "* ",
+	InfoNick: "i ",
+	HelpNick: "? ",
+	ErrNick:  "! ",
+}
+
+var (
+	ThemeDefault = Theme{
+		Name:    "default",
+		TSCol:   StyleTSCol,
+		Nick:    StyleNick,
+		SysNick: StyleSysNick,
+		MsgCol:  StyleMsgCol,
+		SysMsg:  StyleSysMsg,
+	}
+
+	ThemeHighContrast = Theme{
+		Name:    "high-contrast",
+		TSCol:   StyleTSCol.Faint(false),
+		Nick:    StyleNick.Bold(true),
+		SysNick: StyleNick.Underline(true),
+		MsgCol:  StyleMsgCol,
+		SysMsg:  StyleMsgCol.Italic(true),
+		Prefix:  symbolPrefixes,
+	}
+
+	ThemeNoColor = Theme{
+		Name:    "no-color",
+		TSCol:   StyleTSCol.Faint(false),
+		Nick:    StyleNick,
+		SysNick: StyleNick,
+		MsgCol:  StyleMsgCol,
+		SysMsg:  StyleMsgCol,
+		Prefix:  symbolPrefixes,
+	}
+
+	Themes = []Theme{ThemeDefault, ThemeHighContrast, ThemeNoColor}
+)
+
+// WithRenderer returns the theme with its styles rendered by r, so they
+// degrade to the colors of the terminal, see mpty.ClientInfoModel.Renderer.
+func (t Theme) WithRenderer(r *lipgloss.Renderer) Theme {
+	t.TSCol = t.TSCol.Renderer(r)
+	t.Nick = t.Nick.Renderer(r)
+	t.SysNick = t.SysNick.Renderer(r)
+	t.MsgCol = t.MsgCol.Renderer(r)
+	t.SysMsg = t.SysMsg.Renderer(r)
+	return t
+}
+
+func FindTheme(name string) (Theme, bool) {
+	i := slices.IndexFunc(Themes, func(t Theme) bool { return t.Name == name })
+	if i < 0 {
+		return ThemeDefault, false
+	}
+	return Themes[i], true
+}
+
+func ThemeNames() []string {
+	names := make([]string, len(Themes))
+	for i, t := range Themes {
+		names[i] = t.Name
+	}
+	return names
+}
+
+// DetectTheme selects the no-color theme when the client environment sets
+// NO_COLOR (https://no-color.org) or the terminal is monochrome.
+func DetectTheme(term string, environ []string) Theme {
+	for _, kv := range environ {
+		k, v, _ := strings.Cut(kv, "=")
+		if k == "NO_COLOR" && v != "" {
+			return ThemeNoColor
+		}
+	}
+
+	switch {
+	case term == "dumb",
+		strings.HasSuffix(term, "-mono"),
+		strings.HasSuffix(term, "-m"),
+		term == "vt100", term == "vt220":
+		return ThemeNoColor
+	}
+	return ThemeDefault
+}
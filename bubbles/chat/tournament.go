@@ -0,0 +1,202 @@
+package chat
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/ghthor/webtea/bubbles/blokfall"
+	"github.com/ghthor/webtea/bubbles/tournament"
+	"github.com/ghthor/webtea/mpty"
+)
+
+type (
+	// TournamentScheduleReq schedules a blokfall tournament that starts At,
+	// every match of it lasts at most Match. It is sent by clients that can
+	// roles.CanConfigureRoom.
+	TournamentScheduleReq struct {
+		Requestor mpty.ClientId
+		At        time.Time
+		Match     time.Duration
+	}
+
+	// TournamentJoinReq registers the Requestor for the scheduled
+	// tournament.
+	TournamentJoinReq struct {
+		Requestor mpty.ClientId
+	}
+
+	// TournamentErr is sent to a client when a tournament request was
+	// refused.
+	TournamentErr struct {
+		Requestor mpty.ClientId
+		Err       string
+	}
+
+	// TournamentSeatMsg is broadcast when a player is seated at the Table of
+	// their match, which ends by Until.
+	TournamentSeatMsg struct {
+		Id    mpty.ClientId
+		Table string
+		Until time.Time
+	}
+)
+
+// tournamentState is the scheduled or running tournament of the room
+type tournamentState struct {
+	at    time.Time
+	match time.Duration
+
+	// players are registered until the tournament starts
+	players []mpty.ClientId
+	bracket *tournament.Bracket
+
+	// deadline is when the matches of the round are ended
+	deadline time.Time
+	// tables are the players of the tables of the round still playing
+	tables map[string]mpty.ClientId
+}
+
+func (m *ServerModel) scheduleTournament(req TournamentScheduleReq) error {
+	switch {
+	case m.tournament != nil:
+		return fmt.Errorf("a tournament is already scheduled")
+	case !m.room.Allows(GameBlokfall):
+		return fmt.Errorf("%s is not allowed in this room", GameBlokfall)
+	case req.Match <= 0:
+		return fmt.Errorf("matches must last longer than 0s")
+	}
+
+	m.tournament = &tournamentState{at: req.At, match: req.Match}
+	log.Info("tournament scheduled", "by", req.Requestor, "at", req.At, "match", req.Match)
+	m.broadcaster.Write(SysMsgT(m.tick, "chat.tournament.scheduled",
+		req.At.Sub(m.tick).Round(time.Second).String(), req.Match.String()))
+	return nil
+}
+
+func (m *ServerModel) joinTournament(req TournamentJoinReq) error {
+	t := m.tournament
+	switch {
+	case t == nil:
+		return fmt.Errorf("no tournament is scheduled")
+	case t.bracket != nil:
+		return fmt.Errorf("the tournament has already started")
+	case slices.Contains(t.players, req.Requestor):
+		return fmt.Errorf("you have already joined the tournament")
+	}
+
+	t.players = append(t.players, req.Requestor)
+	m.broadcaster.Write(SysMsgT(m.tick, "chat.tournament.joined",
+		m.displayName(req.Requestor.Identity()), strconv.Itoa(len(t.players))))
+	return nil
+}
+
+// leaveTournament unregisters a disconnected client, players of a running
+// tournament forfeit by their table closing instead.
+func (m *ServerModel) leaveTournament(id mpty.ClientId) {
+	if m.tournament == nil || m.tournament.bracket != nil {
+		return
+	}
+	m.tournament.players = slices.DeleteFunc(m.tournament.players, func(p mpty.ClientId) bool {
+		return p == id
+	})
+}
+
+// tickTournament starts the scheduled tournament and ends the matches that
+// are out of time.
+func (m *ServerModel) tickTournament() {
+	t := m.tournament
+	switch {
+	case t == nil:
+	case t.bracket == nil && !m.tick.Before(t.at):
+		if len(t.players) < 2 {
+			m.tournament = nil
+			m.broadcaster.Write(SysMsgT(m.tick, "chat.tournament.cancelled"))
+			return
+		}
+		players := make([]string, len(t.players))
+		for i, p := range t.players {
+			players[i] = string(p)
+		}
+		t.bracket = tournament.New(players)
+		log.Info("tournament started", "players", len(players))
+		m.startRound()
+
+	case t.bracket != nil && !m.tick.Before(t.deadline):
+		for table := range t.tables {
+			m.cmds = append(m.cmds, m.blokfall.UpdateBlokFall(blokfall.MPCloseTableMsg(table)))
+		}
+	}
+}
+
+// startRound seats the players of the current round at their tables.
+func (m *ServerModel) startRound() {
+	t := m.tournament
+	t.deadline = m.tick.Add(t.match)
+	t.tables = make(map[string]mpty.ClientId)
+
+	round := t.bracket.Round()
+	pairings := make([]string, 0, len(t.bracket.Current()))
+	for _, match := range t.bracket.Current() {
+		if match.Bye() {
+			pairings = append(pairings, m.playerName(match.Players[0])+" (bye)")
+			continue
+		}
+		pairings = append(pairings, m.playerName(match.Players[0])+" vs "+m.playerName(match.Players[1]))
+		for _, p := range match.Players {
+			id := mpty.ClientId(p)
+			table := fmt.Sprintf("tournament/%d/%s", round, id)
+			t.tables[table] = id
+			// the client must know its table before the table is drawn
+			m.broadcaster.Write(TournamentSeatMsg{Id: id, Table: table, Until: t.deadline})
+			m.cmds = append(m.cmds, m.blokfall.UpdateBlokFall(blokfall.MPJoinTableMsg{Id: id, Table: table}))
+		}
+	}
+	m.broadcaster.Write(SysMsgT(m.tick, "chat.tournament.round", strconv.Itoa(round), strings.Join(pairings, ", ")))
+}
+
+// playerName returns the display name of a player of the bracket.
+func (m *ServerModel) playerName(player string) string {
+	return m.displayName(mpty.ClientId(player).Identity())
+}
+
+// tournamentResult records the result of a match and advances the bracket
+// once the round is done.
+func (m *ServerModel) tournamentResult(msg blokfall.GameOverMsg) {
+	t := m.tournament
+	if t == nil || t.bracket == nil {
+		return
+	}
+	player, ok := t.tables[msg.Table]
+	if !ok {
+		return
+	}
+	delete(t.tables, msg.Table)
+	t.bracket.Record(string(player), msg.Score)
+	if !t.bracket.RoundDone() {
+		return
+	}
+
+	if t.bracket.Advance() {
+		m.broadcaster.Write(SysMsgT(m.tick, "chat.tournament.standings", m.standings()))
+		m.startRound()
+		return
+	}
+
+	champion := t.bracket.Champion()
+	log.Info("tournament ended", "champion", champion)
+	m.broadcaster.Write(SysMsgT(m.tick, "chat.tournament.champion", m.playerName(champion), m.standings()))
+	m.tournament = nil
+}
+
+func (m *ServerModel) standings() string {
+	standings := m.tournament.bracket.Standings()
+	lines := make([]string, len(standings))
+	for i, s := range standings {
+		lines[i] = fmt.Sprintf("%d. %s: %d (round %d)", i+1, m.playerName(s.Player), s.Best, s.Round)
+	}
+	return strings.Join(lines, "\n")
+}
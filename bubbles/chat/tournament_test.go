@@ -0,0 +1,61 @@
+package chat
+
+import (
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ghthor/webtea/bubbles/blokfall"
+	"github.com/ghthor/webtea/mpty"
+	"github.com/golang-cz/ringbuf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTournament(t *testing.T) {
+	m := &ServerModel{}
+	m.Init()
+	m.Update(ringbuf.New[tea.Msg](100))
+
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	m.Update(start)
+
+	alice, bob := mpty.NewClientId("alice@example.com", "1"), mpty.NewClientId("bob@example.com", "1")
+	m.Update(TournamentJoinReq{Requestor: alice})
+	require.Nil(t, m.tournament, "nothing is scheduled")
+
+	m.Update(TournamentScheduleReq{At: start.Add(time.Minute), Match: 2 * time.Minute})
+	require.ErrorContains(t, m.scheduleTournament(TournamentScheduleReq{At: start, Match: time.Minute}), "already scheduled")
+	require.NoError(t, m.joinTournament(TournamentJoinReq{Requestor: alice}))
+	require.ErrorContains(t, m.joinTournament(TournamentJoinReq{Requestor: alice}), "already joined")
+	require.NoError(t, m.joinTournament(TournamentJoinReq{Requestor: bob}))
+
+	m.Update(start.Add(time.Minute))
+	require.NotNil(t, m.tournament.bracket)
+	require.Equal(t, map[string]mpty.ClientId{
+		"tournament/1/" + string(alice): alice,
+		"tournament/1/" + string(bob):   bob,
+	}, m.tournament.tables)
+
+	m.Update(blokfall.GameOverMsg{Table: "tournament/1/" + string(alice), Result: blokfall.Result{Score: 10}})
+	require.Len(t, m.tournament.tables, 1)
+	m.Update(blokfall.GameOverMsg{Table: "tournament/1/" + string(bob), Result: blokfall.Result{Score: 20}})
+	require.Nil(t, m.tournament, "the final was played")
+}
+
+func TestTournamentCancelled(t *testing.T) {
+	m := &ServerModel{}
+	m.Init()
+	m.Update(ringbuf.New[tea.Msg](100))
+
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	m.Update(start)
+	m.Update(TournamentScheduleReq{At: start.Add(time.Minute), Match: time.Minute})
+	alice := mpty.NewClientId("alice@example.com", "1")
+	m.Update(TournamentJoinReq{Requestor: alice})
+	m.Update(mpty.ClientConnectMsg(alice))
+	m.Update(mpty.ClientDisconnectMsg(alice))
+	require.Empty(t, m.tournament.players)
+
+	m.Update(start.Add(time.Minute))
+	require.Nil(t, m.tournament)
+}
@@ -0,0 +1,114 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+	"github.com/ghthor/webtea/bubbles/blokfall"
+	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/mpty/mptymsg"
+)
+
+const seenBucket = "chat.seen"
+
+const (
+	// minAway is how long an identity is away before it is welcomed back,
+	// reconnecting right away isn't worth a summary
+	minAway = time.Minute
+	// maxWelcomeBack bounds the recorded messages a summary reads, the
+	// newest are read
+	maxWelcomeBack = 1000
+)
+
+// WelcomeBackMsg summarizes what happened since the identity of the
+// Requestor was last connected, it is sent as it reconnects.
+type WelcomeBackMsg struct {
+	Requestor mpty.ClientId
+	Since     time.Time
+	// Messages are the messages of others, Mentions those mentioning the
+	// identity
+	Messages int
+	Mentions int
+	Games    int
+}
+
+func (m *ServerModel) loadSeen() error {
+	if m.Store == nil {
+		return nil
+	}
+
+	raw, err := m.Store.List(seenBucket)
+	if err != nil {
+		return err
+	}
+	for who, data := range raw {
+		var t time.Time
+		if err := json.Unmarshal(data, &t); err != nil {
+			return fmt.Errorf("last seen %s: %w", who, err)
+		}
+		m.seen[who] = t
+	}
+	return nil
+}
+
+// updateSeen keeps when who disconnected its last session.
+func (m *ServerModel) updateSeen(who string) {
+	m.seen[who] = m.tick
+	if m.Store != nil {
+		if err := m.Store.Put(seenBucket, who, m.tick); err != nil {
+			log.Warn("failed to save last seen", "who", who, "error", err)
+		}
+	}
+}
+
+// welcomeBack summarizes the recorded messages since the identity of id was
+// last seen, it is nil for identities that are new or were away briefly.
+func (m *ServerModel) welcomeBack(id mpty.ClientId) tea.Cmd {
+	who := id.Identity()
+	since, ok := m.seen[who]
+	if !ok || m.Recorded == nil || m.tick.Sub(since) < minAway {
+		return nil
+	}
+
+	// the summary is read off the event loop, with the profile as it is now
+	profiles := make(map[string]Profile, 1)
+	if p, ok := m.profiles[who]; ok {
+		profiles[who] = p
+	}
+	recorded := m.Recorded
+	return func() tea.Msg {
+		msgs, err := recorded.Read(maxWelcomeBack)
+		if err != nil {
+			log.Warn("failed to read the messages missed", "who", who, "error", err)
+			return nil
+		}
+		return summarize(id, since, msgs, profiles)
+	}
+}
+
+func summarize(id mpty.ClientId, since time.Time, msgs []mptymsg.Recordable, profiles map[string]Profile) WelcomeBackMsg {
+	who := id.Identity()
+	r := WelcomeBackMsg{Requestor: id, Since: since}
+	for _, msg := range msgs {
+		if !msg.Ts().After(since) {
+			continue
+		}
+		switch msg := msg.(type) {
+		case Msg:
+			switch msg.Who {
+			case who, SysNick, InfoNick, HelpNick, ErrNick:
+				continue
+			}
+			r.Messages++
+			if mentions(msg, who, profiles) {
+				r.Mentions++
+			}
+		case blokfall.GameOverMsg:
+			r.Games++
+		}
+	}
+	return r
+}
@@ -0,0 +1,56 @@
+package chat
+
+import (
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ghthor/webtea/bubbles/blokfall"
+	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/mpty/mptymsg"
+	"github.com/golang-cz/ringbuf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWelcomeBack(t *testing.T) {
+	store := mptymsg.NewMemory(100)
+	m := &ServerModel{Store: store, Recorded: store}
+	m.Init()
+	m.Update(ringbuf.New[tea.Msg](100))
+
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	alice := mpty.NewClientId("alice@example.com", "1")
+	m.Update(start)
+	require.Nil(t, m.welcomeBack(alice), "a new identity isn't welcomed back")
+	m.Update(mpty.ClientConnectMsg(alice))
+	m.Update(mpty.ClientDisconnectMsg(alice))
+
+	for _, msg := range []mptymsg.Recordable{
+		Msg{At: start.Add(-time.Minute), Who: "bob@example.com", Str: "before"},
+		Msg{At: start.Add(time.Minute), Who: "bob@example.com", Str: "hi @alice"},
+		Msg{At: start.Add(2 * time.Minute), Who: "carol@example.com", Str: "hello"},
+		SysMsgT(start.Add(2*time.Minute), "chat.connected", "carol@example.com"),
+		blokfall.GameOverMsg{At: start.Add(3 * time.Minute)},
+	} {
+		_, err := store.Save(msg)
+		require.NoError(t, err)
+	}
+
+	m.Update(start.Add(30 * time.Second))
+	require.Nil(t, m.welcomeBack(alice), "a brief disconnect isn't summarized")
+
+	// when alice was last seen outlives a restart
+	m = &ServerModel{Store: store, Recorded: store}
+	m.Init()
+	m.Update(ringbuf.New[tea.Msg](100))
+	m.Update(start.Add(time.Hour))
+	cmd := m.welcomeBack(alice)
+	require.NotNil(t, cmd)
+	require.Equal(t, WelcomeBackMsg{
+		Requestor: alice,
+		Since:     start,
+		Messages:  2,
+		Mentions:  1,
+		Games:     1,
+	}, cmd())
+}
@@ -0,0 +1,187 @@
+// Package tournament keeps the bracket of a single elimination tournament.
+// Players are paired in rounds, the winner of each match advances to the
+// next round until a single player is left.
+package tournament
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Match is a pairing of two players in a round. The second player of a bye
+// is empty, the first player advances without playing.
+type Match struct {
+	Players [2]string
+	Scores  [2]uint64
+	// Played reports which players have a score
+	Played [2]bool
+}
+
+// Bye reports if the match has a single player.
+func (m Match) Bye() bool {
+	return m.Players[1] == ""
+}
+
+// Done reports if the winner of the match is known.
+func (m Match) Done() bool {
+	return m.Bye() || m.Played[0] && m.Played[1]
+}
+
+// Winner returns the player that advances, or "" if the match isn't done.
+// A tie is won by the first player, the higher seed.
+func (m Match) Winner() string {
+	switch {
+	case m.Bye():
+		return m.Players[0]
+	case !m.Done():
+		return ""
+	case m.Scores[1] > m.Scores[0]:
+		return m.Players[1]
+	default:
+		return m.Players[0]
+	}
+}
+
+// Bracket is the rounds of a tournament, the last round is being played.
+type Bracket struct {
+	Rounds [][]Match
+}
+
+// New returns the bracket of players, in order of their seed. The first
+// round pairs the highest seed with the lowest, an odd player out gets a bye.
+func New(players []string) *Bracket {
+	return &Bracket{Rounds: [][]Match{pair(players)}}
+}
+
+func pair(players []string) []Match {
+	matches := make([]Match, 0, (len(players)+1)/2)
+	for i, j := 0, len(players)-1; i <= j; i, j = i+1, j-1 {
+		m := Match{Players: [2]string{players[i]}}
+		if i != j {
+			m.Players[1] = players[j]
+		}
+		matches = append(matches, m)
+	}
+	return matches
+}
+
+// Round returns the number of the round being played, starting at 1.
+func (b *Bracket) Round() int {
+	return len(b.Rounds)
+}
+
+// Current returns the matches of the round being played.
+func (b *Bracket) Current() []Match {
+	return b.Rounds[len(b.Rounds)-1]
+}
+
+// Record sets the score of player in their match of the current round. It
+// reports false if the player has no match left to play.
+func (b *Bracket) Record(player string, score uint64) bool {
+	round := b.Current()
+	for i := range round {
+		m := &round[i]
+		if m.Bye() {
+			continue
+		}
+		for p := range m.Players {
+			if m.Players[p] == player && !m.Played[p] {
+				m.Scores[p], m.Played[p] = score, true
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RoundDone reports if every match of the current round is done.
+func (b *Bracket) RoundDone() bool {
+	for _, m := range b.Current() {
+		if !m.Done() {
+			return false
+		}
+	}
+	return true
+}
+
+// Advance pairs the winners of the current round into the next round. It
+// reports false, and doesn't add a round, if the current round isn't done or
+// was the final.
+func (b *Bracket) Advance() bool {
+	if !b.RoundDone() || b.Champion() != "" {
+		return false
+	}
+	winners := make([]string, 0, len(b.Current()))
+	for _, m := range b.Current() {
+		winners = append(winners, m.Winner())
+	}
+	b.Rounds = append(b.Rounds, pair(winners))
+	return true
+}
+
+// Champion returns the winner of the final, or "" until it has been played.
+func (b *Bracket) Champion() string {
+	round := b.Current()
+	if len(round) != 1 {
+		return ""
+	}
+	return round[0].Winner()
+}
+
+// Standing is how far a player got in the tournament.
+type Standing struct {
+	Player string
+	// Round is the last round the player reached
+	Round int
+	// Best is the highest score of the player
+	Best uint64
+}
+
+// Standings returns the champion, if there is one, then the players ordered
+// by the round they reached and their best score.
+func (b *Bracket) Standings() []Standing {
+	var (
+		standings []Standing
+		index     = make(map[string]int)
+	)
+	for r, round := range b.Rounds {
+		for _, m := range round {
+			for p, player := range m.Players {
+				if player == "" {
+					continue
+				}
+				i, ok := index[player]
+				if !ok {
+					i = len(standings)
+					index[player] = i
+					standings = append(standings, Standing{Player: player})
+				}
+				s := &standings[i]
+				s.Round = r + 1
+				if m.Played[p] {
+					s.Best = max(s.Best, m.Scores[p])
+				}
+			}
+		}
+	}
+	champion := b.Champion()
+	slices.SortStableFunc(standings, func(a, b Standing) int {
+		return cmp.Or(
+			compareBool(b.Player == champion, a.Player == champion),
+			cmp.Compare(b.Round, a.Round),
+			cmp.Compare(b.Best, a.Best),
+		)
+	})
+	return standings
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
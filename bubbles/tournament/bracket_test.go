@@ -0,0 +1,53 @@
+package tournament
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBracket(t *testing.T) {
+	b := New([]string{"a", "b", "c", "d", "e"})
+	require.Equal(t, 1, b.Round())
+	require.Equal(t, []Match{
+		{Players: [2]string{"a", "e"}},
+		{Players: [2]string{"b", "d"}},
+		{Players: [2]string{"c"}},
+	}, b.Current())
+
+	require.True(t, b.Record("a", 100))
+	require.False(t, b.Record("a", 200), "a has played")
+	require.False(t, b.Record("c", 200), "c has a bye")
+	require.False(t, b.Advance())
+
+	require.True(t, b.Record("e", 300))
+	require.True(t, b.Record("b", 50))
+	require.True(t, b.Record("d", 50))
+	require.True(t, b.RoundDone())
+	require.Empty(t, b.Champion())
+	require.True(t, b.Advance())
+
+	// ties go to the higher seed
+	require.Equal(t, []Match{
+		{Players: [2]string{"e", "c"}},
+		{Players: [2]string{"b"}},
+	}, b.Current())
+
+	require.True(t, b.Record("e", 10))
+	require.True(t, b.Record("c", 20))
+	require.True(t, b.Advance())
+	require.Equal(t, []Match{{Players: [2]string{"c", "b"}}}, b.Current())
+
+	require.True(t, b.Record("c", 0))
+	require.True(t, b.Record("b", 1))
+	require.Equal(t, "b", b.Champion())
+	require.False(t, b.Advance())
+
+	require.Equal(t, []Standing{
+		{Player: "b", Round: 3, Best: 50},
+		{Player: "c", Round: 3, Best: 20},
+		{Player: "e", Round: 2, Best: 300},
+		{Player: "a", Round: 1, Best: 100},
+		{Player: "d", Round: 1, Best: 50},
+	}, b.Standings())
+}
@@ -0,0 +1,665 @@
+package webtea
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/ghthor/webtea/hooks"
+	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/roles"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the settings shared by webtea servers. It can be loaded from
+// a YAML or TOML file, WEBTEA_* environment variables and command line flags,
+// in increasing order of precedence.
+type Config struct {
+	Hostname    string `yaml:"hostname" toml:"hostname"`
+	SSHPort     int    `yaml:"ssh_port" toml:"ssh_port"`
+	HTTPPort    int    `yaml:"http_port" toml:"http_port"`
+	HostKeyPath string `yaml:"host_key_path" toml:"host_key_path"`
+
+	// HostnameCollision is what happens when another device of the tailnet
+	// has the hostname, "ignore" keeps the name tailscale gives the device,
+	// "suffix" renames it hostname-2, hostname-3 and so on and "fail" stops
+	// the server
+	HostnameCollision string `yaml:"hostname_collision" toml:"hostname_collision"`
+
+	// Ephemeral registers the tailscale device as ephemeral, it is removed
+	// from the tailnet when the server shuts down
+	Ephemeral bool `yaml:"ephemeral" toml:"ephemeral"`
+
+	// StateDir keeps the keys and login of the tailscale device, the default
+	// of tsnet when empty
+	StateDir string `yaml:"state_dir" toml:"state_dir"`
+
+	// OnShutdown is what happens to the tailscale device as the server shuts
+	// down, "keep" leaves it logged in, "logout" logs it out of the tailnet
+	// and "delete" also deletes the StateDir. Ephemeral devices are always
+	// logged out.
+	OnShutdown string `yaml:"on_shutdown" toml:"on_shutdown"`
+
+	// Guests lets peers that can't be identified in as a guest instead of
+	// ending their session
+	Guests bool `yaml:"guests" toml:"guests"`
+	// GuestApproval holds the first message of a guest until a moderator
+	// approves it with /approve, which lets the guest chat as a user
+	GuestApproval bool `yaml:"guest_approval" toml:"guest_approval"`
+
+	// Display is how users are named in the chat, "nick" is the login up to
+	// the @, "login" the full login, "device" the nick and the device the
+	// message was sent from and "anonymized" a name derived from the login
+	Display string `yaml:"display" toml:"display"`
+
+	// AnnouncePeers announces in the chat the peers of the tailnet coming
+	// online and going offline
+	AnnouncePeers bool `yaml:"announce_peers" toml:"announce_peers"`
+
+	// AuthorizedKeys is an authorized_keys file of the ssh keys that may log
+	// in from outside of the tailnet, the fallback is disabled when empty
+	AuthorizedKeys string `yaml:"authorized_keys" toml:"authorized_keys"`
+
+	// ConfirmKeys is an authorized_keys file of the ssh keys that confirm
+	// privileged commands with a forwarded agent, see tstea.WithAgentConfirm.
+	// Commands aren't confirmed when empty.
+	ConfirmKeys string `yaml:"confirm_keys" toml:"confirm_keys"`
+
+	// WhoisCache caches the identities of remote addresses, see
+	// tstea.IdentityCache
+	WhoisCache WhoisCacheConfig `yaml:"whois_cache" toml:"whois_cache"`
+
+	// FunnelPort serves the web terminal to the internet over Tailscale
+	// Funnel, one of 443, 8443 or 10000. Funnel is disabled when 0 and ssh is
+	// always tailnet only.
+	FunnelPort int `yaml:"funnel_port" toml:"funnel_port"`
+
+	// TLSPort serves the web terminal over https on the tailnet, with the
+	// certificate tailscale issues to the device. It is disabled when 0.
+	TLSPort int `yaml:"tls_port" toml:"tls_port"`
+
+	// LocalAddr also serves ssh and http on the ssh_port and http_port of
+	// this loopback address, e.g. 127.0.0.1 or ::1, next to the tailnet. It
+	// is disabled when empty.
+	LocalAddr string `yaml:"local_addr" toml:"local_addr"`
+
+	// RPCPort serves the room to frontends over JSON-RPC on the tailnet, see
+	// tstea.RPCServer. It is disabled when 0.
+	RPCPort int `yaml:"rpc_port" toml:"rpc_port"`
+
+	// RecorderDSN is the sqlite database file messages are recorded to
+	RecorderDSN string `yaml:"recorder_dsn" toml:"recorder_dsn"`
+
+	// CastDir is the directory the terminal output of every session is
+	// recorded to as asciicast files, recording is disabled when empty
+	CastDir string `yaml:"cast_dir" toml:"cast_dir"`
+
+	// Audit is where the start and end of every session is reported
+	Audit AuditConfig `yaml:"audit" toml:"audit"`
+
+	// ProfileDir is the directory the profile command of the operator
+	// console writes to
+	ProfileDir string `yaml:"profile_dir" toml:"profile_dir"`
+
+	Ring        RingConfig        `yaml:"ring" toml:"ring"`
+	Timeouts    TimeoutsConfig    `yaml:"timeouts" toml:"timeouts"`
+	Maintenance MaintenanceConfig `yaml:"maintenance" toml:"maintenance"`
+	Banner      BannerConfig      `yaml:"banner" toml:"banner"`
+
+	MaxSessions        int `yaml:"max_sessions" toml:"max_sessions"`
+	MaxSessionsPerUser int `yaml:"max_sessions_per_user" toml:"max_sessions_per_user"`
+	// SessionLimitPolicy is what happens when a user opens more than
+	// MaxSessionsPerUser sessions, "reject" refuses the new session and
+	// "close_oldest" ends the oldest one
+	SessionLimitPolicy string `yaml:"session_limit_policy" toml:"session_limit_policy"`
+
+	// MemoryBudgetMB is the memory in MiB above which history is shed, see
+	// mpty.WithMemoryBudget. It is disabled when 0.
+	MemoryBudgetMB int `yaml:"memory_budget_mb" toml:"memory_budget_mb"`
+
+	// WatchdogCancel shuts the server down when the update loop stalls
+	// instead of only reporting it
+	WatchdogCancel bool `yaml:"watchdog_cancel" toml:"watchdog_cancel"`
+
+	// RateLimit limits how fast new connections are accepted
+	RateLimit RateLimitConfig `yaml:"rate_limit" toml:"rate_limit"`
+
+	// AllowAddrs and DenyAddrs filter incoming connections by IP or CIDR
+	AllowAddrs []string `yaml:"allow_addrs" toml:"allow_addrs"`
+	DenyAddrs  []string `yaml:"deny_addrs" toml:"deny_addrs"`
+
+	// AdminSocket is the unix socket the operator console listens on, it is
+	// disabled when empty
+	AdminSocket string `yaml:"admin_socket" toml:"admin_socket"`
+
+	// PprofLogins are the tailscale logins allowed to access /debug/pprof/
+	PprofLogins []string `yaml:"pprof_logins" toml:"pprof_logins"`
+
+	// WebOrigins are hosts, besides the tailnet names of the device, whose
+	// pages may open the web terminal websocket
+	WebOrigins []string `yaml:"web_origins" toml:"web_origins"`
+
+	Roles roles.Config `yaml:"roles" toml:"roles"`
+
+	// Hooks are the replies, command aliases, scheduled messages and scripts
+	// of the room, see the hooks package
+	Hooks hooks.Config `yaml:"hooks" toml:"hooks"`
+}
+
+// RingConfig sizes the mpty broadcast ring buffer. Backpressure is what
+// happens to a client more than MaxBehind messages behind, "disconnect" ends
+// it, "drop_oldest" skips it ahead with a notice of the messages it missed
+// and "resync" skips it ahead and replays the recorded messages it missed.
+type RingConfig struct {
+	Size         int    `yaml:"size" toml:"size"`
+	StartBehind  int    `yaml:"start_behind" toml:"start_behind"`
+	MaxBehind    int    `yaml:"max_behind" toml:"max_behind"`
+	Backpressure string `yaml:"backpressure" toml:"backpressure"`
+}
+
+// WhoisCacheConfig sizes the identity cache. Identities are fresh for TTL
+// and then served for up to Stale more while they are refreshed, a zero TTL
+// disables the cache.
+type WhoisCacheConfig struct {
+	Size  int           `yaml:"size" toml:"size"`
+	TTL   time.Duration `yaml:"ttl" toml:"ttl"`
+	Stale time.Duration `yaml:"stale" toml:"stale"`
+}
+
+type TimeoutsConfig struct {
+	Shutdown time.Duration `yaml:"shutdown" toml:"shutdown"`
+	Idle     time.Duration `yaml:"idle" toml:"idle"`
+
+	// Keepalive is how often sessions are probed and KeepaliveTimeout how
+	// long they have to answer before they are closed as dead
+	Keepalive        time.Duration `yaml:"keepalive" toml:"keepalive"`
+	KeepaliveTimeout time.Duration `yaml:"keepalive_timeout" toml:"keepalive_timeout"`
+
+	// MaxSession caps how long a single session may stay connected
+	MaxSession time.Duration `yaml:"max_session" toml:"max_session"`
+
+	// TermProbe is how long ssh terminals have to answer the probe of their
+	// capabilities, they aren't probed when 0
+	TermProbe time.Duration `yaml:"term_probe" toml:"term_probe"`
+
+	// Reattach is how long the program of a closed web terminal is kept for
+	// its browser tab to reconnect, programs end with their terminal when 0
+	Reattach time.Duration `yaml:"reattach" toml:"reattach"`
+
+	// Watchdog is how long the update loop of the program may go without
+	// handling a tick before it is reported stalled, see mpty.WithWatchdog.
+	// It is disabled when 0.
+	Watchdog time.Duration `yaml:"watchdog" toml:"watchdog"`
+	// SlowHandler is how long the program may take to handle a single
+	// message before it is logged, see mpty.WithSlowHandler. It is disabled
+	// when 0.
+	SlowHandler time.Duration `yaml:"slow_handler" toml:"slow_handler"`
+}
+
+// AuditConfig is where sessions are audited to, see tstea.WithAudit. Auditing
+// is disabled when none are set.
+type AuditConfig struct {
+	// Log logs the sessions
+	Log bool `yaml:"log" toml:"log"`
+	// RecorderDSN is the sqlite database sessions are recorded to, it must
+	// not be the database of the messages
+	RecorderDSN string `yaml:"recorder_dsn" toml:"recorder_dsn"`
+	// Webhook is the http url every event is posted to as JSON
+	Webhook string `yaml:"webhook" toml:"webhook"`
+}
+
+// RateLimitConfig limits new connections in total and per source IP, see
+// RateLimiter.
+type RateLimitConfig struct {
+	Global RateLimit `yaml:"global" toml:"global"`
+	PerIP  RateLimit `yaml:"per_ip" toml:"per_ip"`
+}
+
+// MaintenanceConfig schedules a graceful shutdown at At. A zero At disables
+// it.
+type MaintenanceConfig struct {
+	At     time.Time     `yaml:"at" toml:"at"`
+	Reason string        `yaml:"reason" toml:"reason"`
+	Drain  time.Duration `yaml:"drain" toml:"drain"`
+}
+
+// BannerConfig is the banner shown to clients when they connect, see the
+// bubbles/banner package. It is disabled when Title and MOTD are empty.
+type BannerConfig struct {
+	Title    string        `yaml:"title" toml:"title"`
+	MOTD     string        `yaml:"motd" toml:"motd"`
+	Color    string        `yaml:"color" toml:"color"`
+	Duration time.Duration `yaml:"duration" toml:"duration"`
+}
+
+// funnelPorts are the ports Tailscale Funnel serves, 0 disables it
+var funnelPorts = []int{0, 443, 8443, 10000}
+
+// The values of Config.HostnameCollision
+const (
+	HostnameCollisionIgnore = "ignore"
+	HostnameCollisionSuffix = "suffix"
+	HostnameCollisionFail   = "fail"
+)
+
+// The values of Config.OnShutdown
+const (
+	OnShutdownKeep   = "keep"
+	OnShutdownLogout = "logout"
+	OnShutdownDelete = "delete"
+)
+
+// The values of Config.Display
+const (
+	DisplayNick       = "nick"
+	DisplayLogin      = "login"
+	DisplayDevice     = "device"
+	DisplayAnonymized = "anonymized"
+)
+
+// The values of RingConfig.Backpressure
+const (
+	BackpressureDisconnect = "disconnect"
+	BackpressureDropOldest = "drop_oldest"
+	BackpressureResync     = "resync"
+)
+
+// The values of Config.SessionLimitPolicy
+const (
+	SessionLimitReject      = "reject"
+	SessionLimitCloseOldest = "close_oldest"
+)
+
+func DefaultConfig() Config {
+	return Config{
+		Hostname:    "webtea",
+		SSHPort:     23234,
+		HTTPPort:    28080,
+		HostKeyPath: ".ssh/id_ed25519",
+		ProfileDir:  "profiles",
+		RecorderDSN: "msgs.db",
+
+		HostnameCollision:  HostnameCollisionIgnore,
+		OnShutdown:         OnShutdownKeep,
+		Display:            DisplayNick,
+		SessionLimitPolicy: SessionLimitReject,
+
+		Ring: RingConfig{
+			Size:        10000,
+			StartBehind: 0,
+			MaxBehind:   9000,
+
+			Backpressure: BackpressureDisconnect,
+		},
+		WhoisCache: WhoisCacheConfig{
+			Size:  1024,
+			TTL:   time.Minute,
+			Stale: 5 * time.Minute,
+		},
+		Timeouts: TimeoutsConfig{
+			Shutdown:         30 * time.Second,
+			Keepalive:        30 * time.Second,
+			KeepaliveTimeout: 15 * time.Second,
+			TermProbe:        300 * time.Millisecond,
+			Reattach:         30 * time.Second,
+			Watchdog:         10 * time.Second,
+			SlowHandler:      100 * time.Millisecond,
+		},
+	}
+}
+
+// Load fills c, which should already hold the defaults, from the file given
+// by the -config flag, the environment and then the remaining flags.
+func (c *Config) Load(fs *flag.FlagSet, args []string) error {
+	path := fs.String("config", "", "path to a yaml or toml config file")
+	c.RegisterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *path != "" {
+		if err := c.LoadFile(*path); err != nil {
+			return err
+		}
+	}
+	if err := c.LoadEnv(os.LookupEnv); err != nil {
+		return err
+	}
+
+	// Parse again so flags take precedence over the file and environment
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return c.Validate()
+}
+
+// LoadFile decodes a YAML (.yaml, .yml) or TOML (.toml) file into c. Fields
+// missing from the file are left unchanged.
+func (c *Config) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config: %w", err)
+	}
+
+	switch ext := filepath.Ext(path); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, c)
+	case ".toml":
+		err = toml.Unmarshal(data, c)
+	default:
+		return fmt.Errorf("unsupported config file type: %s", ext)
+	}
+	if err != nil {
+		return fmt.Errorf("error decoding config %s: %w", path, err)
+	}
+	return nil
+}
+
+// LoadEnv overrides c with any WEBTEA_* variables returned by lookup.
+func (c *Config) LoadEnv(lookup func(string) (string, bool)) error {
+	var errs []error
+	str := func(key string, v *string) {
+		if s, ok := lookup(key); ok {
+			*v = s
+		}
+	}
+	num := func(key string, v *int) {
+		if s, ok := lookup(key); ok {
+			i, err := strconv.Atoi(s)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+				return
+			}
+			*v = i
+		}
+	}
+	float := func(key string, v *float64) {
+		if s, ok := lookup(key); ok {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+				return
+			}
+			*v = f
+		}
+	}
+	boolean := func(key string, v *bool) {
+		if s, ok := lookup(key); ok {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+				return
+			}
+			*v = b
+		}
+	}
+	dur := func(key string, v *time.Duration) {
+		if s, ok := lookup(key); ok {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+				return
+			}
+			*v = d
+		}
+	}
+
+	str("WEBTEA_HOSTNAME", &c.Hostname)
+	num("WEBTEA_SSH_PORT", &c.SSHPort)
+	num("WEBTEA_HTTP_PORT", &c.HTTPPort)
+	str("WEBTEA_HOST_KEY_PATH", &c.HostKeyPath)
+	str("WEBTEA_HOSTNAME_COLLISION", &c.HostnameCollision)
+	str("WEBTEA_STATE_DIR", &c.StateDir)
+	str("WEBTEA_ON_SHUTDOWN", &c.OnShutdown)
+	str("WEBTEA_DISPLAY", &c.Display)
+	str("WEBTEA_AUTHORIZED_KEYS", &c.AuthorizedKeys)
+	str("WEBTEA_CONFIRM_KEYS", &c.ConfirmKeys)
+	boolean("WEBTEA_EPHEMERAL", &c.Ephemeral)
+	boolean("WEBTEA_GUESTS", &c.Guests)
+	boolean("WEBTEA_GUEST_APPROVAL", &c.GuestApproval)
+	boolean("WEBTEA_ANNOUNCE_PEERS", &c.AnnouncePeers)
+	num("WEBTEA_WHOIS_CACHE_SIZE", &c.WhoisCache.Size)
+	dur("WEBTEA_WHOIS_CACHE_TTL", &c.WhoisCache.TTL)
+	dur("WEBTEA_WHOIS_CACHE_STALE", &c.WhoisCache.Stale)
+	num("WEBTEA_FUNNEL_PORT", &c.FunnelPort)
+	num("WEBTEA_TLS_PORT", &c.TLSPort)
+	str("WEBTEA_LOCAL_ADDR", &c.LocalAddr)
+	num("WEBTEA_RPC_PORT", &c.RPCPort)
+	str("WEBTEA_RECORDER_DSN", &c.RecorderDSN)
+	str("WEBTEA_CAST_DIR", &c.CastDir)
+	boolean("WEBTEA_AUDIT_LOG", &c.Audit.Log)
+	str("WEBTEA_AUDIT_RECORDER_DSN", &c.Audit.RecorderDSN)
+	str("WEBTEA_AUDIT_WEBHOOK", &c.Audit.Webhook)
+	str("WEBTEA_PROFILE_DIR", &c.ProfileDir)
+	num("WEBTEA_RING_SIZE", &c.Ring.Size)
+	num("WEBTEA_RING_START_BEHIND", &c.Ring.StartBehind)
+	num("WEBTEA_RING_MAX_BEHIND", &c.Ring.MaxBehind)
+	str("WEBTEA_RING_BACKPRESSURE", &c.Ring.Backpressure)
+	dur("WEBTEA_SHUTDOWN_TIMEOUT", &c.Timeouts.Shutdown)
+	dur("WEBTEA_IDLE_TIMEOUT", &c.Timeouts.Idle)
+	dur("WEBTEA_KEEPALIVE", &c.Timeouts.Keepalive)
+	dur("WEBTEA_KEEPALIVE_TIMEOUT", &c.Timeouts.KeepaliveTimeout)
+	dur("WEBTEA_MAX_SESSION", &c.Timeouts.MaxSession)
+	dur("WEBTEA_TERM_PROBE", &c.Timeouts.TermProbe)
+	dur("WEBTEA_REATTACH", &c.Timeouts.Reattach)
+	dur("WEBTEA_WATCHDOG", &c.Timeouts.Watchdog)
+	dur("WEBTEA_SLOW_HANDLER", &c.Timeouts.SlowHandler)
+	boolean("WEBTEA_WATCHDOG_CANCEL", &c.WatchdogCancel)
+	if s, ok := lookup("WEBTEA_MAINTENANCE_AT"); ok {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("WEBTEA_MAINTENANCE_AT: %w", err))
+		} else {
+			c.Maintenance.At = t
+		}
+	}
+	str("WEBTEA_MAINTENANCE_REASON", &c.Maintenance.Reason)
+	dur("WEBTEA_MAINTENANCE_DRAIN", &c.Maintenance.Drain)
+	str("WEBTEA_BANNER_TITLE", &c.Banner.Title)
+	str("WEBTEA_BANNER_MOTD", &c.Banner.MOTD)
+	num("WEBTEA_MAX_SESSIONS", &c.MaxSessions)
+	num("WEBTEA_MAX_SESSIONS_PER_USER", &c.MaxSessionsPerUser)
+	str("WEBTEA_SESSION_LIMIT_POLICY", &c.SessionLimitPolicy)
+	num("WEBTEA_MEMORY_BUDGET_MB", &c.MemoryBudgetMB)
+	float("WEBTEA_RATE_LIMIT", &c.RateLimit.Global.Rate)
+	num("WEBTEA_RATE_BURST", &c.RateLimit.Global.Burst)
+	float("WEBTEA_RATE_LIMIT_PER_IP", &c.RateLimit.PerIP.Rate)
+	num("WEBTEA_RATE_BURST_PER_IP", &c.RateLimit.PerIP.Burst)
+	str("WEBTEA_ADMIN_SOCKET", &c.AdminSocket)
+	if s, ok := lookup("WEBTEA_ALLOW_ADDRS"); ok {
+		c.AllowAddrs = splitList(s)
+	}
+	if s, ok := lookup("WEBTEA_DENY_ADDRS"); ok {
+		c.DenyAddrs = splitList(s)
+	}
+	if s, ok := lookup("WEBTEA_PPROF_LOGINS"); ok {
+		c.PprofLogins = splitList(s)
+	}
+	if s, ok := lookup("WEBTEA_WEB_ORIGINS"); ok {
+		c.WebOrigins = splitList(s)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Hostname, "hostname", c.Hostname, "tailscale device hostname")
+	fs.StringVar(&c.HostnameCollision, "hostname-collision", c.HostnameCollision, "when the hostname is taken on the tailnet: ignore, suffix or fail")
+	fs.IntVar(&c.SSHPort, "ssh-port", c.SSHPort, "port for ssh listener")
+	fs.IntVar(&c.HTTPPort, "http-port", c.HTTPPort, "port for http listener")
+	fs.StringVar(&c.HostKeyPath, "host-key-path", c.HostKeyPath, "filepath to the ssh host key")
+	fs.StringVar(&c.StateDir, "state-dir", c.StateDir, "directory of the tailscale device state")
+	fs.StringVar(&c.OnShutdown, "on-shutdown", c.OnShutdown, "what happens to the tailscale device on shutdown: keep, logout or delete")
+	fs.BoolVar(&c.Ephemeral, "ephemeral", c.Ephemeral, "register the tailscale device as ephemeral, removed on shutdown")
+	fs.BoolVar(&c.Guests, "guests", c.Guests, "let peers that can't be identified in as guests")
+	fs.BoolVar(&c.GuestApproval, "guest-approval", c.GuestApproval, "hold the first message of guests for a moderator to approve")
+	fs.StringVar(&c.Display, "display", c.Display, "how users are named in the chat: nick, login, device or anonymized")
+	fs.BoolVar(&c.AnnouncePeers, "announce-peers", c.AnnouncePeers, "announce the tailnet peers coming online and going offline")
+	fs.StringVar(&c.AuthorizedKeys, "authorized-keys", c.AuthorizedKeys, "authorized_keys file of ssh keys that may log in from outside the tailnet")
+	fs.StringVar(&c.ConfirmKeys, "confirm-keys", c.ConfirmKeys, "authorized_keys file of ssh keys that confirm privileged commands with a forwarded agent")
+	fs.IntVar(&c.WhoisCache.Size, "whois-cache-size", c.WhoisCache.Size, "maximum identities cached")
+	fs.DurationVar(&c.WhoisCache.TTL, "whois-cache-ttl", c.WhoisCache.TTL, "time cached identities are fresh, 0 disables the cache")
+	fs.DurationVar(&c.WhoisCache.Stale, "whois-cache-stale", c.WhoisCache.Stale, "time stale identities are served while they are refreshed")
+	fs.IntVar(&c.FunnelPort, "funnel-port", c.FunnelPort, "serve the web terminal publicly over tailscale funnel on 443, 8443 or 10000, 0 disables it")
+	fs.IntVar(&c.TLSPort, "tls-port", c.TLSPort, "port for the tailnet https listener, 0 disables it")
+	fs.StringVar(&c.LocalAddr, "local-addr", c.LocalAddr, "loopback address to also serve ssh and http on")
+	fs.IntVar(&c.RPCPort, "rpc-port", c.RPCPort, "port for the json-rpc frontend listener, 0 disables it")
+	fs.StringVar(&c.RecorderDSN, "recorder-dsn", c.RecorderDSN, "filepath to sqlite database")
+	fs.StringVar(&c.CastDir, "cast-dir", c.CastDir, "directory sessions are recorded to as asciicast files, empty disables it")
+	fs.BoolVar(&c.Audit.Log, "audit-log", c.Audit.Log, "log the start and end of every session")
+	fs.StringVar(&c.Audit.RecorderDSN, "audit-recorder-dsn", c.Audit.RecorderDSN, "filepath to a sqlite database sessions are audited to, empty disables it")
+	fs.StringVar(&c.Audit.Webhook, "audit-webhook", c.Audit.Webhook, "url the start and end of every session is posted to, empty disables it")
+	fs.StringVar(&c.ProfileDir, "profile-dir", c.ProfileDir, "directory the admin profile command writes cpu and heap profiles to")
+	fs.IntVar(&c.MaxSessions, "max-sessions", c.MaxSessions, "maximum concurrent sessions, 0 is unlimited")
+	fs.IntVar(&c.MaxSessionsPerUser, "max-sessions-per-user", c.MaxSessionsPerUser, "maximum concurrent sessions per user, 0 is unlimited")
+	fs.StringVar(&c.SessionLimitPolicy, "session-limit-policy", c.SessionLimitPolicy, "what to do when a user exceeds max-sessions-per-user: reject or close_oldest")
+	fs.IntVar(&c.MemoryBudgetMB, "memory-budget-mb", c.MemoryBudgetMB, "memory in MiB above which history is shed, 0 disables it")
+	fs.DurationVar(&c.Timeouts.Shutdown, "shutdown-timeout", c.Timeouts.Shutdown, "time to wait for sessions to close on shutdown")
+	fs.DurationVar(&c.Timeouts.Idle, "idle-timeout", c.Timeouts.Idle, "end sessions without input for this long, 0 disables it")
+	fs.DurationVar(&c.Timeouts.Keepalive, "keepalive", c.Timeouts.Keepalive, "interval between session keepalive probes, 0 disables them")
+	fs.DurationVar(&c.Timeouts.KeepaliveTimeout, "keepalive-timeout", c.Timeouts.KeepaliveTimeout, "time a session has to answer a keepalive probe")
+	fs.DurationVar(&c.Timeouts.MaxSession, "max-session", c.Timeouts.MaxSession, "maximum duration of a session, 0 is unlimited")
+	fs.DurationVar(&c.Timeouts.TermProbe, "term-probe", c.Timeouts.TermProbe, "time ssh terminals have to answer the capability probe, 0 disables it")
+	fs.DurationVar(&c.Timeouts.Reattach, "reattach", c.Timeouts.Reattach, "time a reloaded browser tab has to get its program back, 0 disables it")
+	fs.DurationVar(&c.Timeouts.Watchdog, "watchdog", c.Timeouts.Watchdog, "time the update loop may stall before it is reported, 0 disables it")
+	fs.BoolVar(&c.WatchdogCancel, "watchdog-cancel", c.WatchdogCancel, "shut down when the update loop stalls")
+	fs.DurationVar(&c.Timeouts.SlowHandler, "slow-handler", c.Timeouts.SlowHandler, "time the program may take to handle a message before it is logged, 0 disables it")
+	fs.Func("maintenance-at", "RFC3339 time to shut down for maintenance", func(s string) error {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return err
+		}
+		c.Maintenance.At = t
+		return nil
+	})
+	fs.StringVar(&c.Maintenance.Reason, "maintenance-reason", c.Maintenance.Reason, "reason shown to users in maintenance warnings")
+	fs.DurationVar(&c.Maintenance.Drain, "maintenance-drain", c.Maintenance.Drain, "time before maintenance that new sessions are refused")
+	fs.Float64Var(&c.RateLimit.Global.Rate, "rate-limit", c.RateLimit.Global.Rate, "new connections accepted per second, 0 is unlimited")
+	fs.IntVar(&c.RateLimit.Global.Burst, "rate-burst", c.RateLimit.Global.Burst, "new connections accepted at once before the rate limit applies")
+	fs.Float64Var(&c.RateLimit.PerIP.Rate, "rate-limit-per-ip", c.RateLimit.PerIP.Rate, "new connections accepted per second from each IP, 0 is unlimited")
+	fs.IntVar(&c.RateLimit.PerIP.Burst, "rate-burst-per-ip", c.RateLimit.PerIP.Burst, "new connections accepted at once from each IP before the rate limit applies")
+	fs.Func("allow-addrs", "comma separated IPs or CIDRs allowed to connect, empty allows all", func(s string) error {
+		c.AllowAddrs = splitList(s)
+		return nil
+	})
+	fs.Func("deny-addrs", "comma separated IPs or CIDRs denied from connecting", func(s string) error {
+		c.DenyAddrs = splitList(s)
+		return nil
+	})
+	fs.StringVar(&c.AdminSocket, "admin-socket", c.AdminSocket, "path to the operator console unix socket")
+	fs.Func("pprof-logins", "comma separated tailscale logins allowed to access /debug/pprof/", func(s string) error {
+		c.PprofLogins = splitList(s)
+		return nil
+	})
+	fs.Func("web-origins", "comma separated extra hosts allowed to open the web terminal", func(s string) error {
+		c.WebOrigins = splitList(s)
+		return nil
+	})
+
+}
+
+func (c Config) Validate() error {
+	var errs []error
+	if c.Hostname == "" {
+		errs = append(errs, errors.New("hostname is required"))
+	}
+	if !slices.Contains([]string{HostnameCollisionIgnore, HostnameCollisionSuffix, HostnameCollisionFail}, c.HostnameCollision) {
+		errs = append(errs, fmt.Errorf("hostname_collision %q must be %q, %q or %q", c.HostnameCollision, HostnameCollisionIgnore, HostnameCollisionSuffix, HostnameCollisionFail))
+	}
+	if !slices.Contains([]string{OnShutdownKeep, OnShutdownLogout, OnShutdownDelete}, c.OnShutdown) {
+		errs = append(errs, fmt.Errorf("on_shutdown %q must be %q, %q or %q", c.OnShutdown, OnShutdownKeep, OnShutdownLogout, OnShutdownDelete))
+	} else if c.OnShutdown == OnShutdownDelete && c.StateDir == "" {
+		errs = append(errs, errors.New("on_shutdown delete needs a state_dir"))
+	}
+	if !slices.Contains([]string{DisplayNick, DisplayLogin, DisplayDevice, DisplayAnonymized}, c.Display) {
+		errs = append(errs, fmt.Errorf("display %q must be %q, %q, %q or %q", c.Display, DisplayNick, DisplayLogin, DisplayDevice, DisplayAnonymized))
+	}
+	if c.SSHPort <= 0 || c.SSHPort > 65535 {
+		errs = append(errs, fmt.Errorf("ssh_port %d is out of range", c.SSHPort))
+	}
+	if c.HTTPPort <= 0 || c.HTTPPort > 65535 {
+		errs = append(errs, fmt.Errorf("http_port %d is out of range", c.HTTPPort))
+	}
+	if c.SSHPort == c.HTTPPort {
+		errs = append(errs, errors.New("ssh_port and http_port must be different"))
+	}
+	if c.RPCPort < 0 || c.RPCPort > 65535 {
+		errs = append(errs, fmt.Errorf("rpc_port %d is out of range", c.RPCPort))
+	} else if c.RPCPort != 0 && (c.RPCPort == c.SSHPort || c.RPCPort == c.HTTPPort) {
+		errs = append(errs, errors.New("rpc_port must differ from ssh_port and http_port"))
+	}
+	if c.TLSPort < 0 || c.TLSPort > 65535 {
+		errs = append(errs, fmt.Errorf("tls_port %d is out of range", c.TLSPort))
+	} else if c.TLSPort != 0 && slices.Contains([]int{c.SSHPort, c.HTTPPort, c.RPCPort, c.FunnelPort}, c.TLSPort) {
+		errs = append(errs, errors.New("tls_port must differ from ssh_port, http_port, rpc_port and funnel_port"))
+	}
+	if c.LocalAddr != "" {
+		if ip, err := netip.ParseAddr(c.LocalAddr); err != nil || !ip.IsLoopback() {
+			errs = append(errs, fmt.Errorf("local_addr %q must be a loopback address", c.LocalAddr))
+		}
+	}
+	if !slices.Contains(funnelPorts, c.FunnelPort) {
+		errs = append(errs, fmt.Errorf("funnel_port %d must be 0, 443, 8443 or 10000", c.FunnelPort))
+	}
+	if err := mpty.ValidateRing(c.Ring.Size, c.Ring.StartBehind, c.Ring.MaxBehind); err != nil {
+		errs = append(errs, fmt.Errorf("ring: %w", err))
+	}
+	if !slices.Contains([]string{BackpressureDisconnect, BackpressureDropOldest, BackpressureResync}, c.Ring.Backpressure) {
+		errs = append(errs, fmt.Errorf("ring.backpressure %q must be %q, %q or %q", c.Ring.Backpressure, BackpressureDisconnect, BackpressureDropOldest, BackpressureResync))
+	}
+	if c.Timeouts.Shutdown < 0 || c.Timeouts.Idle < 0 || c.Timeouts.Keepalive < 0 || c.Timeouts.KeepaliveTimeout < 0 || c.Timeouts.MaxSession < 0 || c.Timeouts.TermProbe < 0 || c.Timeouts.Reattach < 0 || c.Timeouts.Watchdog < 0 || c.Timeouts.SlowHandler < 0 || c.Maintenance.Drain < 0 || c.Banner.Duration < 0 {
+		errs = append(errs, errors.New("timeouts must not be negative"))
+	}
+	if c.WhoisCache.Size < 0 || c.WhoisCache.TTL < 0 || c.WhoisCache.Stale < 0 {
+		errs = append(errs, errors.New("whois_cache must not be negative"))
+	}
+	if c.MaxSessions < 0 || c.MaxSessionsPerUser < 0 {
+		errs = append(errs, errors.New("session limits must not be negative"))
+	}
+	if !slices.Contains([]string{SessionLimitReject, SessionLimitCloseOldest}, c.SessionLimitPolicy) {
+		errs = append(errs, fmt.Errorf("session_limit_policy %q must be %q or %q", c.SessionLimitPolicy, SessionLimitReject, SessionLimitCloseOldest))
+	}
+	if c.Audit.RecorderDSN != "" && c.Audit.RecorderDSN == c.RecorderDSN {
+		errs = append(errs, errors.New("audit.recorder_dsn must differ from recorder_dsn"))
+	}
+	if c.Audit.Webhook != "" {
+		if u, err := url.Parse(c.Audit.Webhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("audit.webhook %q must be an http or https url", c.Audit.Webhook))
+		}
+	}
+	if c.MemoryBudgetMB < 0 {
+		errs = append(errs, errors.New("memory_budget_mb must not be negative"))
+	}
+	if !c.RateLimit.Global.valid() || !c.RateLimit.PerIP.valid() {
+		errs = append(errs, errors.New("rate limits must not be negative and their burst must be at least 1"))
+	}
+	if _, err := NewAddrFilter(c.AllowAddrs, c.DenyAddrs); err != nil {
+		errs = append(errs, fmt.Errorf("address filter: %w", err))
+	}
+	if _, err := c.Roles.Policy(); err != nil {
+		errs = append(errs, fmt.Errorf("roles: %w", err))
+	}
+	if err := c.Hooks.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("hooks: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
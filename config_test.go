@@ -0,0 +1,222 @@
+package webtea
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ghthor/webtea/hooks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigLoad(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "webtea.yaml")
+	require.NoError(t, os.WriteFile(yamlPath, []byte(`
+hostname: from-yaml
+ssh_port: 2222
+ring:
+  size: 100
+  max_behind: 90
+timeouts:
+  shutdown: 5s
+banner:
+  title: webtea
+  color: "205"
+  duration: 3s
+hooks:
+  replies:
+    - pattern: '^!ping$'
+      reply: pong
+  scheduled:
+    - every: 1h
+      message: remember to stretch
+`), 0644))
+
+	cfg := DefaultConfig()
+	require.NoError(t, cfg.LoadFile(yamlPath))
+	require.Equal(t, "from-yaml", cfg.Hostname)
+	require.Equal(t, 2222, cfg.SSHPort)
+	require.Equal(t, 28080, cfg.HTTPPort)
+	require.Equal(t, 100, cfg.Ring.Size)
+	require.Equal(t, 5*time.Second, cfg.Timeouts.Shutdown)
+	require.Equal(t, BannerConfig{Title: "webtea", Color: "205", Duration: 3 * time.Second}, cfg.Banner)
+	require.Equal(t, []hooks.ReplyHook{{Pattern: "^!ping$", Reply: "pong"}}, cfg.Hooks.Replies)
+	require.Equal(t, time.Hour, cfg.Hooks.Scheduled[0].Every)
+	require.NoError(t, cfg.Validate())
+
+	tomlPath := filepath.Join(dir, "webtea.toml")
+	require.NoError(t, os.WriteFile(tomlPath, []byte(`
+hostname = "from-toml"
+pprof_logins = ["a@example.com"]
+
+[timeouts]
+idle = "10m"
+`), 0644))
+
+	cfg = DefaultConfig()
+	require.NoError(t, cfg.LoadFile(tomlPath))
+	require.Equal(t, "from-toml", cfg.Hostname)
+	require.Equal(t, []string{"a@example.com"}, cfg.PprofLogins)
+	require.Equal(t, 10*time.Minute, cfg.Timeouts.Idle)
+
+	env := map[string]string{
+		"WEBTEA_HTTP_PORT":    "8080",
+		"WEBTEA_PPROF_LOGINS": "a@example.com, b@example.com",
+		"WEBTEA_RATE_LIMIT":   "2.5",
+		"WEBTEA_RATE_BURST":   "5",
+
+		"WEBTEA_AUTHORIZED_KEYS": "/etc/webtea/authorized_keys",
+		"WEBTEA_CONFIRM_KEYS":    "/etc/webtea/confirm_keys",
+		"WEBTEA_GUESTS":          "true",
+		"WEBTEA_ANNOUNCE_PEERS":  "true",
+		"WEBTEA_GUEST_APPROVAL":  "true",
+		"WEBTEA_EPHEMERAL":       "true",
+
+		"WEBTEA_RING_BACKPRESSURE": "drop_oldest",
+
+		"WEBTEA_WHOIS_CACHE_TTL":    "30s",
+		"WEBTEA_FUNNEL_PORT":        "8443",
+		"WEBTEA_TLS_PORT":           "443",
+		"WEBTEA_HOSTNAME_COLLISION": "suffix",
+		"WEBTEA_STATE_DIR":          "/var/lib/webtea",
+		"WEBTEA_DISPLAY":            "device",
+		"WEBTEA_ON_SHUTDOWN":        "delete",
+		"WEBTEA_LOCAL_ADDR":         "127.0.0.1",
+
+		"WEBTEA_SESSION_LIMIT_POLICY": "close_oldest",
+
+		"WEBTEA_WATCHDOG":        "20s",
+		"WEBTEA_WATCHDOG_CANCEL": "true",
+		"WEBTEA_SLOW_HANDLER":    "250ms",
+
+		"WEBTEA_AUDIT_LOG":     "true",
+		"WEBTEA_AUDIT_WEBHOOK": "https://audit.example.com/webtea",
+	}
+	require.NoError(t, cfg.LoadEnv(func(k string) (string, bool) {
+		v, ok := env[k]
+		return v, ok
+	}))
+	require.Equal(t, 8080, cfg.HTTPPort)
+	require.Equal(t, []string{"a@example.com", "b@example.com"}, cfg.PprofLogins)
+	require.Equal(t, RateLimit{Rate: 2.5, Burst: 5}, cfg.RateLimit.Global)
+	require.Equal(t, "/etc/webtea/authorized_keys", cfg.AuthorizedKeys)
+	require.Equal(t, "/etc/webtea/confirm_keys", cfg.ConfirmKeys)
+	require.True(t, cfg.Guests)
+	require.True(t, cfg.GuestApproval)
+	require.Equal(t, BackpressureDropOldest, cfg.Ring.Backpressure)
+	require.True(t, cfg.AnnouncePeers)
+	require.True(t, cfg.Ephemeral)
+	require.Equal(t, 8443, cfg.FunnelPort)
+	require.Equal(t, 443, cfg.TLSPort)
+	require.Equal(t, "127.0.0.1", cfg.LocalAddr)
+	require.Equal(t, HostnameCollisionSuffix, cfg.HostnameCollision)
+	require.Equal(t, "/var/lib/webtea", cfg.StateDir)
+	require.Equal(t, OnShutdownDelete, cfg.OnShutdown)
+	require.Equal(t, DisplayDevice, cfg.Display)
+	require.Equal(t, SessionLimitCloseOldest, cfg.SessionLimitPolicy)
+	require.Equal(t, 20*time.Second, cfg.Timeouts.Watchdog)
+	require.True(t, cfg.WatchdogCancel)
+	require.Equal(t, 250*time.Millisecond, cfg.Timeouts.SlowHandler)
+	require.Equal(t, AuditConfig{Log: true, Webhook: "https://audit.example.com/webtea"}, cfg.Audit)
+	require.Equal(t, WhoisCacheConfig{Size: 1024, TTL: 30 * time.Second, Stale: 5 * time.Minute}, cfg.WhoisCache)
+	require.NoError(t, cfg.Validate())
+
+	err := cfg.LoadEnv(func(k string) (string, bool) {
+		return "maybe", k == "WEBTEA_GUESTS"
+	})
+	require.ErrorContains(t, err, "WEBTEA_GUESTS")
+	require.True(t, cfg.Guests, "an invalid bool leaves the value")
+}
+
+func TestConfigFlagsOverrideFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webtea.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("hostname: from-yaml\nssh_port: 2222\n"), 0644))
+
+	cfg := DefaultConfig()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	require.NoError(t, cfg.Load(fs, []string{"-config", path, "-hostname", "from-flag"}))
+	require.Equal(t, "from-flag", cfg.Hostname)
+	require.Equal(t, 2222, cfg.SSHPort)
+}
+
+func TestConfigValidate(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.HTTPPort = cfg.SSHPort
+	cfg.Ring.MaxBehind = cfg.Ring.Size + 1
+	require.Error(t, cfg.Validate())
+
+	cfg = DefaultConfig()
+	cfg.RateLimit.PerIP = RateLimit{Rate: 1}
+	require.ErrorContains(t, cfg.Validate(), "burst must be at least 1")
+
+	cfg = DefaultConfig()
+	cfg.FunnelPort = 80
+	require.ErrorContains(t, cfg.Validate(), "funnel_port 80")
+
+	cfg = DefaultConfig()
+	cfg.RPCPort = cfg.SSHPort
+	require.ErrorContains(t, cfg.Validate(), "rpc_port must differ")
+
+	cfg = DefaultConfig()
+	cfg.TLSPort = cfg.HTTPPort
+	require.ErrorContains(t, cfg.Validate(), "tls_port must differ")
+
+	cfg = DefaultConfig()
+	cfg.HostnameCollision = "rename"
+	require.ErrorContains(t, cfg.Validate(), "hostname_collision")
+
+	cfg = DefaultConfig()
+	cfg.Ring.Backpressure = "block"
+	require.ErrorContains(t, cfg.Validate(), "ring.backpressure")
+
+	for _, ring := range []RingConfig{
+		{Size: 10, MaxBehind: 9},
+		{Size: 100, MaxBehind: 95},
+		{Size: 100, MaxBehind: 5},
+		{Size: 100, StartBehind: 91, MaxBehind: 90},
+	} {
+		cfg = DefaultConfig()
+		ring.Backpressure = cfg.Ring.Backpressure
+		cfg.Ring = ring
+		require.ErrorContains(t, cfg.Validate(), "ring: ", "%+v", ring)
+	}
+
+	cfg = DefaultConfig()
+	cfg.Display = "email"
+	require.ErrorContains(t, cfg.Validate(), "display")
+
+	cfg = DefaultConfig()
+	cfg.OnShutdown = OnShutdownDelete
+	require.ErrorContains(t, cfg.Validate(), "needs a state_dir")
+	cfg.StateDir = "/var/lib/webtea"
+	require.NoError(t, cfg.Validate())
+
+	cfg = DefaultConfig()
+	cfg.LocalAddr = "100.64.0.1"
+	require.ErrorContains(t, cfg.Validate(), "local_addr")
+	cfg.LocalAddr = "::1"
+	require.NoError(t, cfg.Validate())
+
+	cfg = DefaultConfig()
+	cfg.MemoryBudgetMB = -1
+	require.ErrorContains(t, cfg.Validate(), "memory_budget_mb")
+
+	cfg = DefaultConfig()
+	cfg.Audit.Webhook = "audit.example.com"
+	require.ErrorContains(t, cfg.Validate(), "audit.webhook")
+
+	cfg = DefaultConfig()
+	cfg.Audit.RecorderDSN = cfg.RecorderDSN
+	require.ErrorContains(t, cfg.Validate(), "audit.recorder_dsn")
+
+	cfg = DefaultConfig()
+	cfg.Hooks.Scheduled = []hooks.ScheduledHook{{Every: time.Second, Message: "spam"}}
+	require.ErrorContains(t, cfg.Validate(), "hooks: scheduled[0]")
+
+	cfg = DefaultConfig()
+	cfg.SessionLimitPolicy = "close_newest"
+	require.ErrorContains(t, cfg.Validate(), "session_limit_policy")
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/charmbracelet/log"
+	"github.com/ghthor/webtea"
+	"github.com/ghthor/webtea/admin"
+	"github.com/ghthor/webtea/bubbles/chat"
+	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/tshelper"
+	"github.com/ghthor/webtea/tstea"
+	"golang.org/x/sync/errgroup"
+)
+
+func loadConfig(fs *flag.FlagSet, args []string) (webtea.Config, error) {
+	cfg := webtea.DefaultConfig()
+	cfg.Hostname = "tailscale-chat"
+	return cfg, cfg.Load(fs, args)
+}
+
+// limitPolicy returns the tstea policy of the validated config value.
+func limitPolicy(policy string) tstea.LimitPolicy {
+	if policy == webtea.SessionLimitCloseOldest {
+		return tstea.CloseOldest
+	}
+	return tstea.RejectNew
+}
+
+// collisionPolicy returns the tshelper policy of the validated config value.
+func collisionPolicy(policy string) tshelper.CollisionPolicy {
+	switch policy {
+	case webtea.HostnameCollisionSuffix:
+		return tshelper.CollisionSuffix
+	case webtea.HostnameCollisionFail:
+		return tshelper.CollisionFail
+	}
+	return tshelper.CollisionIgnore
+}
+
+// displayPolicy returns the chat policy of the validated config value.
+func displayPolicy(display string) chat.DisplayPolicy {
+	switch display {
+	case webtea.DisplayLogin:
+		return chat.DisplayLogin
+	case webtea.DisplayDevice:
+		return chat.DisplayDevice
+	case webtea.DisplayAnonymized:
+		return chat.DisplayAnonymized
+	}
+	return chat.DisplayNick
+}
+
+// backpressure returns the mpty policy of the validated config value.
+func backpressure(policy string) mpty.Backpressure {
+	switch policy {
+	case webtea.BackpressureDropOldest:
+		return mpty.BackpressureDropOldest
+	case webtea.BackpressureResync:
+		return mpty.BackpressureResync
+	}
+	return mpty.BackpressureDisconnect
+}
+
+// closeMode returns the tshelper mode of the validated config value.
+func closeMode(mode string) tshelper.CloseMode {
+	switch mode {
+	case webtea.OnShutdownLogout:
+		return tshelper.CloseLogout
+	case webtea.OnShutdownDelete:
+		return tshelper.CloseDelete
+	}
+	return tshelper.CloseKeep
+}
+
+// adminMain runs a single operator console command, e.g.
+//
+//	tailscale-chat admin -admin-socket admin.sock sessions
+func adminMain(args []string) {
+	fs := flag.NewFlagSet("admin", flag.ExitOnError)
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		log.Fatal("invalid configuration", "error", err)
+	}
+	if cfg.AdminSocket == "" {
+		log.Fatal("no admin socket configured, set -admin-socket")
+	}
+
+	cmd := fs.Args()
+	if len(cmd) == 0 {
+		cmd = []string{"help"}
+	}
+	if err := admin.Do(context.Background(), cfg.AdminSocket, os.Stdout, cmd...); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func runAdminConsole(ctx context.Context, grp *errgroup.Group, cancel context.CancelCauseFunc, path, profileDir string, prog mpty.Program, limiter *tstea.SessionLimiter, addrFilter *webtea.AddrFilter, rateLimiter *webtea.RateLimiter) error {
+	l, err := webtea.ListenUnix(path, 0o600)
+	if err != nil {
+		return fmt.Errorf("admin socket: %w", err)
+	}
+
+	console := admin.NewConsole(admin.ProgramCommands(prog)...)
+	console.Handle(admin.ProfileCommand(profileDir))
+	console.Handle(admin.Command{
+		Use:   "reload",
+		Short: "Reload the configuration and apply the session limits, rate limits and address filter.",
+		Run: func(w io.Writer, args []string) error {
+			cfg, err := loadConfig(flag.NewFlagSet("reload", flag.ContinueOnError), os.Args[1:])
+			if err != nil {
+				return err
+			}
+			limiter.SetLimits(cfg.MaxSessions, cfg.MaxSessionsPerUser)
+			limiter.SetPolicy(limitPolicy(cfg.SessionLimitPolicy))
+			rateLimiter.SetLimits(cfg.RateLimit.Global, cfg.RateLimit.PerIP)
+			if err := addrFilter.Set(cfg.AllowAddrs, cfg.DenyAddrs); err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(w, "max_sessions=%d max_sessions_per_user=%d session_limit_policy=%s\n", cfg.MaxSessions, cfg.MaxSessionsPerUser, cfg.SessionLimitPolicy)
+			return err
+		},
+	})
+	return console.Run(ctx, grp, cancel, l)
+}
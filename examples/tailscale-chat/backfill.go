@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/log"
+	"github.com/ghthor/webtea/bubbles/chat"
+	"github.com/ghthor/webtea/mpty/mptymsg"
+)
+
+// backfillMain checks the recorded messages and rebuilds the projections from
+// the whole history. It opens the database directly so the server must be
+// stopped, e.g.
+//
+//	tailscale-chat backfill -recorder-dsn msgs.db -repair
+func backfillMain(args []string) {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	repair := fs.Bool("repair", false, "renumber messages saved out of timestamp order")
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		log.Fatal("invalid configuration", "error", err)
+	}
+
+	recorder, err := mptymsg.NewSqlite(context.Background(), cfg.RecorderDSN)
+	if err != nil {
+		log.Fatal("could not open sqlite", "error", err)
+	}
+	defer recorder.Close()
+
+	var rep mptymsg.Report
+	if *repair {
+		rep, err = recorder.Repair()
+	} else {
+		rep, err = recorder.Check()
+	}
+	if err != nil {
+		log.Fatal("could not check messages", "error", err)
+	}
+
+	fmt.Printf("messages=%d out_of_order=%d undecodable=%d renumbered=%t\n",
+		rep.Messages, rep.OutOfOrder, len(rep.Undecodable), rep.Renumbered)
+	for _, issue := range rep.Undecodable {
+		fmt.Fprintf(os.Stderr, "message %d: %s\n", issue.Id, issue.Err)
+	}
+	if rep.OutOfOrder > 0 && !rep.Renumbered {
+		log.Warn("messages are out of timestamp order, run with -repair")
+	}
+
+	if err := recorder.Rebuild(chat.NewStatsTable()); err != nil {
+		log.Fatal("could not rebuild projections", "error", err)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"net"
 	"os"
 	"os/signal"
+	"slices"
 	"strings"
 	"syscall"
 	"time"
@@ -24,22 +25,19 @@ import (
 	"github.com/charmbracelet/wish"
 	"github.com/charmbracelet/wish/logging"
 	"github.com/ghthor/webtea"
+	"github.com/ghthor/webtea/admin"
+	"github.com/ghthor/webtea/bubbles/banner"
 	"github.com/ghthor/webtea/bubbles/chat"
 	"github.com/ghthor/webtea/mpty"
 	"github.com/ghthor/webtea/mpty/mptymsg"
+	"github.com/ghthor/webtea/roles"
+	"github.com/ghthor/webtea/serverinfo"
 	"github.com/ghthor/webtea/tshelper"
 	"github.com/ghthor/webtea/tstea"
 	"golang.org/x/sync/errgroup"
 	"tailscale.com/client/tailscale/apitype"
 )
 
-var (
-	sshPort  int    = 23234
-	httpPort int    = 28080
-	hostname string = "tailscale-chat"
-	sqliteDb string = "msgs.db"
-)
-
 func init() {
 	switch os.Getenv("LIPGLOSS_LOG_FORMAT") {
 	case "json":
@@ -48,42 +46,216 @@ func init() {
 }
 
 func main() {
-	flag.IntVar(&sshPort, "ssh-port", 23234, "port for ssh listener")
-	flag.IntVar(&httpPort, "http-port", 28080, "port for http listener")
-	flag.StringVar(&hostname, "hostname", "tailscale-chat", "tailscale device hostname")
-	flag.StringVar(&sqliteDb, "sqlite-db", "msgs.db", "filepath to sqlite database")
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		adminMain(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		backfillMain(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "systemd-units" {
+		systemdMain(os.Args[2:])
+		return
+	}
 
-	flag.Parse()
+	cfg, err := loadConfig(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatal("invalid configuration", "error", err)
+	}
 
-	ctx, cancel := context.WithCancelCause(context.Background())
-	rootCtx := ctx
+	// Validate has already checked the roles
+	policy, _ = cfg.Roles.Policy()
+	aliases = chat.AliasCmds(cfg.Hooks.Aliases)
+	chat.Display = displayPolicy(cfg.Display)
+	greeting = banner.Config{
+		Title:    cfg.Banner.Title,
+		MOTD:     cfg.Banner.MOTD,
+		Color:    cfg.Banner.Color,
+		Duration: cfg.Banner.Duration,
+	}
 
-	ctx, sigCancel := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
-	defer sigCancel()
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
 
-	recorder, err := mptymsg.NewSqlite(ctx, sqliteDb)
+	recorder, err := mptymsg.NewSqlite(ctx, cfg.RecorderDSN)
 	if err != nil {
 		log.Fatal("could not open sqlite", "error", err)
 	}
 	defer recorder.Close()
 
-	grp, grpCtx := errgroup.WithContext(ctx)
-	mainprog := mpty.NewProgram(ctx, cancel, &chat.ServerModel{}, recorder)
-	err = mainprog.StartIn(ctx, grp)
-	if err != nil {
-		log.Fatal("could not start main program", "error", err)
+	stats := chat.NewStatsTable()
+	if err := recorder.Project(stats); err != nil {
+		log.Fatal("could not load projections", "error", err)
 	}
 
-	ts, err := tshelper.NewListeners(hostname, sshPort, httpPort)
+	chatServer := &chat.ServerModel{Store: recorder, Stats: stats, History: recorder, Recorded: recorder, Hooks: cfg.Hooks}
+	mainprog, err := mpty.NewProgram(ctx, func(error) { cancel() }, chatServer, recorder,
+		mpty.WithRingSize(cfg.Ring.Size),
+		mpty.WithStartBehind(cfg.Ring.StartBehind),
+		mpty.WithMaxBehind(cfg.Ring.MaxBehind),
+		mpty.WithBackpressure(backpressure(cfg.Ring.Backpressure)),
+		mpty.WithMemoryBudget(uint64(cfg.MemoryBudgetMB)<<20),
+		mpty.WithWatchdog(cfg.Timeouts.Watchdog, cfg.WatchdogCancel),
+		mpty.WithSlowHandler(cfg.Timeouts.SlowHandler),
+	)
+	if err != nil {
+		log.Fatal("invalid main program options", "error", err)
+	}
+	chatServer.Sessions = mainprog
+	chatServer.ApproveGuests = cfg.GuestApproval
+	chatServer.Policy = &policy
+	serverInfo := serverinfo.New(mainprog, recorder)
+	chatServer.Info = serverInfo
+
+	// sockets inherited from systemd, see the systemd-units command, or from
+	// a handoff are served instead of listening on the tailnet
+	activation, err := webtea.SystemdActivation()
+	if err != nil {
+		log.Fatal("socket activation", "error", err)
+	}
+	defer activation.Close()
+	sshL, _ := activation.Take("ssh")
+	httpL, _ := activation.Take("http")
+
+	tsOpts := []tshelper.Option{
+		tshelper.WithListeners(sshL, httpL),
+		tshelper.OnCollision(collisionPolicy(cfg.HostnameCollision)),
+		tshelper.OnClose(closeMode(cfg.OnShutdown)),
+	}
+	if cfg.StateDir != "" {
+		tsOpts = append(tsOpts, tshelper.WithStateDir(cfg.StateDir))
+	}
+	if cfg.Ephemeral {
+		tsOpts = append(tsOpts, tshelper.Ephemeral())
+	}
+	ts, err := tshelper.NewListeners(cfg.Hostname, cfg.SSHPort, cfg.HTTPPort, tsOpts...)
 	if err != nil {
 		log.Fatal("tailscale %w", err)
 	}
+	chatServer.Tailnet = ts
+	defer func() {
+		if err := ts.Close(); err != nil {
+			log.Warn("could not close tailscale", "error", err)
+		}
+	}()
+	if cfg.FunnelPort != 0 {
+		if err := ts.ListenFunnel(cfg.FunnelPort); err != nil {
+			log.Fatal("tailscale funnel", "error", err)
+		}
+	}
+	if cfg.TLSPort != 0 {
+		if err := ts.ListenTLS(cfg.TLSPort); err != nil {
+			log.Fatal("tailscale tls", "error", err)
+		}
+	}
+	if cfg.RPCPort != 0 {
+		if err := ts.ListenRPC(cfg.RPCPort); err != nil {
+			log.Fatal("tailscale rpc", "error", err)
+		}
+	}
+	// peers on the local address aren't on the tailnet, they are guests or
+	// authorized keys, see -guests and -authorized-keys
+	sshL, httpL = ts.Ssh, ts.Http
+	if cfg.LocalAddr != "" {
+		localSSH, err := net.Listen("tcp", net.JoinHostPort(cfg.LocalAddr, fmt.Sprint(cfg.SSHPort)))
+		if err != nil {
+			log.Fatal("local ssh", "error", err)
+		}
+		localHTTP, err := net.Listen("tcp", net.JoinHostPort(cfg.LocalAddr, fmt.Sprint(cfg.HTTPPort)))
+		if err != nil {
+			log.Fatal("local http", "error", err)
+		}
+		sshL = webtea.JoinListeners(sshL, localSSH)
+		httpL = webtea.JoinListeners(httpL, localHTTP)
+	}
+	var identity tstea.Identity = tstea.NewIdentityCache(tstea.Tailscale(ts.Client),
+		cfg.WhoisCache.Size, cfg.WhoisCache.TTL, cfg.WhoisCache.Stale)
+
+	// Validate has already checked the addresses
+	addrFilter, _ := webtea.NewAddrFilter(cfg.AllowAddrs, cfg.DenyAddrs)
+	rateLimiter := webtea.NewRateLimiter(cfg.RateLimit.Global, cfg.RateLimit.PerIP)
+
+	limiter := tstea.NewSessionLimiter(cfg.MaxSessions, cfg.MaxSessionsPerUser)
+	limiter.SetPolicy(limitPolicy(cfg.SessionLimitPolicy))
+	keepalive := tstea.WithKeepalive(cfg.Timeouts.Keepalive, cfg.Timeouts.KeepaliveTimeout)
+	maxSession := tstea.WithMaxSessionDuration(cfg.Timeouts.MaxSession)
+	idle := tstea.WithIdleTimeout(cfg.Timeouts.Idle)
+	cast := tstea.WithCastRecording(cfg.CastDir)
+
+	var auditSinks []tstea.AuditSink
+	if cfg.Audit.Log {
+		auditSinks = append(auditSinks, tstea.AuditLog(nil))
+	}
+	if cfg.Audit.RecorderDSN != "" {
+		auditRecorder, err := mptymsg.NewSqlite(ctx, cfg.Audit.RecorderDSN)
+		if err != nil {
+			log.Fatal("could not open audit sqlite", "error", err)
+		}
+		defer auditRecorder.Close()
+		auditSinks = append(auditSinks, tstea.AuditRecorder(auditRecorder))
+	}
+	if cfg.Audit.Webhook != "" {
+		auditSinks = append(auditSinks, tstea.AuditWebhook(ctx, cfg.Audit.Webhook, nil))
+	}
+	audit := tstea.WithAudit(auditSinks...)
+
+	// privileged commands like /kick are confirmed with the forwarded ssh
+	// agent of the operator
+	var confirmKeys *tstea.AuthorizedKeys
+	if cfg.ConfirmKeys != "" {
+		confirmKeys, err = tstea.LoadAuthorizedKeys(cfg.ConfirmKeys)
+		if err != nil {
+			log.Fatal("failed to load confirm keys", "error", err)
+		}
+	}
+	confirm := tstea.WithAgentConfirm(confirmKeys)
+
+	// peers outside of the tailnet may log in over ssh with an authorized key,
+	// or as a guest when nothing else identifies them
+	sshIdentity, webIdentity := identity, identity
+	if cfg.Guests {
+		webIdentity = tstea.Guests(identity)
+	}
+	sshAuth := func(*ssh.Server) error { return nil }
+	if cfg.AuthorizedKeys != "" {
+		keys, err := tstea.LoadAuthorizedKeys(cfg.AuthorizedKeys)
+		if err != nil {
+			log.Fatal("failed to load authorized keys", "error", err)
+		}
+		sshIdentity = tstea.PublicKeyFallback(identity, keys)
+		// guests are let in with any key
+		sshAuth = tstea.WithPublicKeyFallback(webIdentity, keys)
+	}
+	if cfg.Guests {
+		sshIdentity = tstea.Guests(sshIdentity)
+	}
+	// funneled peers are never on the tailnet, so they skip the WhoIs and are
+	// refused unless guests are let in
+	if cfg.FunnelPort != 0 {
+		public := tstea.Unidentified
+		if cfg.Guests {
+			public = tstea.Guests(public)
+		}
+		webIdentity = tstea.Funnel(webIdentity, public)
+	}
 
 	s, err := wish.NewServer(
 		// wish.WithAddress(net.JoinHostPort(host, port)),
-		wish.WithHostKeyPath(".ssh/id_ed25519"),
+		wish.WithHostKeyPath(cfg.HostKeyPath),
+		tstea.TraceHandshake(),
+		sshAuth,
 		wish.WithMiddleware(
-			tstea.WishMiddleware(ctx, ts.Client, newSshModel, mainprog.NewClientProgram()),
+			tstea.WishMiddleware(ctx, sshIdentity, newSshModel, mainprog.NewClientProgram(),
+				tstea.WithSessionLimiter(limiter),
+				keepalive,
+				maxSession,
+				idle,
+				cast,
+				audit,
+				confirm,
+				tstea.WithTermProbe(cfg.Timeouts.TermProbe),
+			),
 			logging.Middleware(),
 		),
 	)
@@ -91,67 +263,245 @@ func main() {
 		log.Fatal("Could not create SSH server", "error", err)
 	}
 	webtty := tstea.NewTeaTYFactory(
-		ctx, ts.Client, newHttpModel, mainprog.NewClientProgram(),
+		ctx, webIdentity, newHttpModel, mainprog.NewClientProgram(),
+		tstea.WithSessionLimiter(limiter),
+		keepalive,
+		maxSession,
+		idle,
+		cast,
+		audit,
+		confirm,
+		tstea.WithReattach(cfg.Timeouts.Reattach),
+	)
+
+	// frontends are only served on the tailnet, so they are always identified
+	rpcServer := tstea.NewRPCServer(
+		ctx, identity, newRPCInfo, mainprog.NewClientProgram(), chat.AcceptRPC,
+		tstea.WithSessionLimiter(limiter),
+		audit,
 	)
 
-	tsIPv4, _, err := ts.WaitForTailscaleIP(ctx)
+	tsIPv4, tsIPv6, err := ts.WaitForTailscaleIP(ctx)
 	if err != nil {
 		log.Fatal("failed to wait for tailscale IP", "error", err)
 	}
-	log.Info("Starting SSH server", "addr", net.JoinHostPort(tsIPv4.String(), fmt.Sprint(sshPort)))
-	log.Infof("Starting HTTP server http://%s:%d", tsIPv4.String(), httpPort)
 
-	err = errors.Join(
-		webtea.RunSSH(grpCtx, grp, cancel, ts.Ssh, s),
-		webtea.RunHTTP(grpCtx, grp, cancel, ts.Http, webtty, hostname),
-	)
-	if err != nil {
-		log.Fatal("failed to start webtea", "error", err)
+	// only pages served from the tailnet names of this device may drive the
+	// web terminal
+	origins := append([]string{cfg.Hostname, tsIPv4.String()}, cfg.WebOrigins...)
+	if tsIPv6.IsValid() {
+		origins = append(origins, tsIPv6.String())
+	}
+	if dnsName, err := ts.DNSName(ctx); err != nil {
+		log.Warn("could not look up tailnet dns name", "error", err)
+	} else if dnsName != "" {
+		origins = append(origins, dnsName)
+	}
+
+	httpOpts := []webtea.HTTPOption{
+		webtea.WithMiddleware(accessLog),
+		webtea.WithOrigins(origins...),
+		webtea.WithAuthToken(""),
+		// the resume token is passed as ?resume=, the tab as ?tab= and the
+		// size as ?cols=&rows=
+		webtea.WithTerminal(func(o *webtea.TerminalOptions) {
+			o.PermitArguments = true
+		}),
+		webtea.WithHandler("/healthz", serverInfo.HealthHandler()),
+		webtea.WithWebUI(webtea.WebUI{Reattach: cfg.Timeouts.Reattach > 0}),
+		// the relay forwards the pings of the keepalive to the browser, the
+		// keepalive reaps the sessions and the pings of the relay only close
+		// the connection of a browser that stopped answering
+		webtea.WithWebSocket(webtea.WebSocketOptions{
+			Compression:  true,
+			PingInterval: cfg.Timeouts.Keepalive,
+			PongTimeout:  cfg.Timeouts.KeepaliveTimeout,
+			WriteTimeout: cfg.Timeouts.KeepaliveTimeout,
+		}),
+	}
+	// the public funnel only serves the web terminal, not the operator apis
+	funnelOpts := slices.Clip(httpOpts)
+	if len(cfg.PprofLogins) > 0 {
+		httpOpts = append(httpOpts, webtea.WithPprof(
+			tstea.AllowLogins(identity, cfg.PprofLogins...),
+		))
 	}
 
-	<-ctx.Done()
-	if err = context.Cause(rootCtx); err != nil && !errors.Is(err, context.Canceled) {
-		log.Error("failed to start webtea", "error", err)
+	operator := tstea.AllowCapability(identity, policy, roles.CanOperate)
+	httpOpts = append(httpOpts, webtea.WithHandler("/api/", admin.APIHandler(mainprog, operator)))
+	httpOpts = append(httpOpts, webtea.WithHandler("/api/info", admin.InfoHandler(serverInfo, operator)))
+	httpOpts = append(httpOpts, webtea.WithHandler("/api/listeners", admin.ListenersHandler(ts, operator)))
+	httpOpts = append(httpOpts, webtea.WithHandler("/api/projections/", admin.ProjectionsHandler(operator, stats)))
+
+	roomPreview := &preview{}
+	httpOpts = append(httpOpts, webtea.WithHandler("/api/preview.png", admin.PreviewHandler(
+		roomPreview.View, previewCols, previewRows, 5*time.Second, operator,
+	)))
+
+	srvOpts := []webtea.ServerOption{
+		webtea.WithHostname(cfg.Hostname),
+		webtea.WithProgram(mainprog),
+		webtea.WithSSH(webtea.RateLimitListener(webtea.FilterListener(sshL, addrFilter), rateLimiter), s),
+		webtea.WithHTTP(webtea.RateLimitListener(webtea.FilterListener(httpL, addrFilter), rateLimiter), webtty, httpOpts...),
+		webtea.WithRunner(roomPreview.run(mainprog)),
+		webtea.WithRunner(func(ctx context.Context, grp *errgroup.Group, cancel context.CancelCauseFunc) error {
+			if ts.Funnel == nil {
+				return nil
+			}
+			log.Info("Starting funnel", "port", cfg.FunnelPort)
+			l := webtea.RateLimitListener(webtea.FilterListener(ts.Funnel, addrFilter), rateLimiter)
+			return webtea.RunHTTP(ctx, grp, cancel, l, webtty, cfg.Hostname, funnelOpts...)
+		}),
+		webtea.WithRunner(func(ctx context.Context, grp *errgroup.Group, cancel context.CancelCauseFunc) error {
+			if ts.TLS == nil {
+				return nil
+			}
+			log.Info("Starting https", "port", cfg.TLSPort)
+			l := webtea.RateLimitListener(webtea.FilterListener(ts.TLS, addrFilter), rateLimiter)
+			return webtea.RunHTTP(ctx, grp, cancel, l, webtty, cfg.Hostname, httpOpts...)
+		}),
+		webtea.WithRunner(func(ctx context.Context, grp *errgroup.Group, _ context.CancelCauseFunc) error {
+			if ts.RPC == nil {
+				return nil
+			}
+			log.Info("Starting rpc", "port", cfg.RPCPort)
+			l := webtea.RateLimitListener(webtea.FilterListener(ts.RPC, addrFilter), rateLimiter)
+			context.AfterFunc(ctx, func() { l.Close() })
+			grp.Go(func() error {
+				return rpcServer.Serve(l)
+			})
+			return nil
+		}),
+		webtea.WithRunner(func(ctx context.Context, grp *errgroup.Group, _ context.CancelCauseFunc) error {
+			// peers can't be identified while the tailnet is down
+			grp.Go(func() error {
+				ts.WatchHealth(ctx, 0, func(h tshelper.Health) {
+					if h.Up {
+						log.Info("tailnet is back, accepting sessions")
+						limiter.Resume(errTailnetDown)
+						return
+					}
+					log.Warn("tailnet is down, refusing new sessions", "state", h.State, "error", h.Err)
+					limiter.Refuse(errTailnetDown)
+				})
+				return nil
+			})
+			// the peers are listed by /names, and announced when asked to
+			var peers mpty.Input
+			if cfg.AnnouncePeers {
+				peers = mainprog.Send
+			}
+			grp.Go(func() error {
+				ts.WatchPeers(ctx, 0, peers)
+				return nil
+			})
+			return nil
+		}),
+		webtea.WithRunner(func(ctx context.Context, grp *errgroup.Group, _ context.CancelCauseFunc) error {
+			grp.Go(func() error {
+				return tstea.Maintenance{
+					At:      cfg.Maintenance.At,
+					Reason:  cfg.Maintenance.Reason,
+					Drain:   cfg.Maintenance.Drain,
+					Limiter: limiter,
+					Send:    mainprog.Send,
+				}.Run(ctx, func(error) { cancel() })
+			})
+			return nil
+		}),
+	}
+	if cfg.AdminSocket != "" {
+		srvOpts = append(srvOpts, webtea.WithRunner(func(ctx context.Context, grp *errgroup.Group, cancel context.CancelCauseFunc) error {
+			return runAdminConsole(ctx, grp, cancel, cfg.AdminSocket, cfg.ProfileDir, mainprog, limiter, addrFilter, rateLimiter)
+		}))
 	}
 
-	log.Info("Stopping SSH server")
-	err = webtea.ShutdownSSH(s, 30*time.Second)
+	srv, err := webtea.NewServer(srvOpts...)
 	if err != nil {
-		log.Error("Could not stop server", "error", err)
+		log.Fatal("could not create webtea server", "error", err)
+	}
+
+	log.Info("Starting SSH server", "addr", net.JoinHostPort(tsIPv4.String(), fmt.Sprint(cfg.SSHPort)))
+	log.Infof("Starting HTTP server http://%s:%d", tsIPv4.String(), cfg.HTTPPort)
+
+	if err = srv.Start(ctx); err != nil {
+		log.Fatal("failed to start webtea", "error", err)
+	}
+	if err = webtea.HandoffReady(); err != nil {
+		log.Warn("could not signal handoff", "error", err)
+	}
+
+	<-srv.Done()
+	if err = srv.Err(); err != nil {
+		log.Error("webtea failed", "error", err)
 	}
 
-	if err = grp.Wait(); err != nil && !errors.Is(err, context.Canceled) {
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.Timeouts.Shutdown)
+	defer shutdownCancel()
+	if err = srv.Shutdown(shutdownCtx); err != nil {
 		log.Error("error shutting down servers", "error", err)
 	}
 }
 
+// errTailnetDown refuses new sessions while the server is disconnected from
+// the tailnet
+var errTailnetDown = errors.New("the server lost its connection to the tailnet, try again later")
+
+var policy = roles.DefaultPolicy()
+
+// greeting is the banner shown to clients before the chat
+var greeting banner.Config
+
+// aliases are the commands of the alias hooks
+var aliases []chat.Cmd
+
 func newSshModel(ctx context.Context, pty ssh.Pty, sess mpty.Session, who *apitype.WhoIsResponse) mpty.ClientModel {
 	info := mpty.NewClientInfoModelFromSsh(pty, sess, who)
+	info.Access = policy.Access(who)
+	// terminals that can't draw the chat are sent plain lines
+	if info.Dumb() {
+		return chat.NewPlainClient(ctx, info)
+	}
 	return &Model{
 		ctx: ctx,
 
 		ClientInfoModel: info,
 		showInfo:        true,
+		greet:           true,
 	}
 }
 
-func newHttpModel(ctx context.Context, sess mpty.Session, who *apitype.WhoIsResponse) mpty.ClientModel {
-	info := mpty.NewClientInfoModelFromWebtty(sess, who)
+func newHttpModel(ctx context.Context, term tstea.WebTerminal, sess mpty.Session, who *apitype.WhoIsResponse) mpty.ClientModel {
+	info := mpty.NewClientInfoModelFromWebtty(term.Window, sess, who)
+	info.Access = policy.Access(who)
+	if info.Dumb() {
+		return chat.NewPlainClient(ctx, info)
+	}
 	return &Model{
 		ctx: ctx,
 
 		ClientInfoModel: info,
 		showInfo:        true,
+		greet:           true,
 	}
 }
 
+func newRPCInfo(ctx context.Context, sess mpty.Session, who *apitype.WhoIsResponse) *mpty.ClientInfoModel {
+	info := mpty.NewClientInfoModelFromRPC(sess, who)
+	info.Access = policy.Access(who)
+	return info
+}
+
 type Model struct {
 	ctx context.Context
 
 	*mpty.ClientInfoModel
 	showInfo bool
 
-	chat *chat.Client
+	// greet shows the banner before the chat
+	greet  bool
+	banner *banner.Model
+	chat   *chat.Client
 
 	b    strings.Builder
 	cmds []tea.Cmd
@@ -164,15 +514,22 @@ func (m *Model) Init() tea.Cmd {
 		m.cmds = make([]tea.Cmd, 0, 2)
 	}
 	m.configureChat()
+	var cfg banner.Config
+	if m.greet {
+		cfg = greeting
+	}
+	m.banner = banner.New(cfg, m.Renderer())
+	m.banner.SetSize(m.Width, m.Height)
 
 	return tea.Batch(
 		m.ClientInfoModel.Init(),
+		m.banner.Init(),
 		m.chat.Init(),
 	)
 }
 
 func (m *Model) configureChat() {
-	m.chat = chat.NewClient(m.ctx, m.ClientInfoModel, chat.Cmd{
+	m.chat = chat.NewClient(m.ctx, m.ClientInfoModel, append([]chat.Cmd{{
 		Use:   "info",
 		Short: "Toggle client terminal info.",
 		Run: func(cmd *chat.Cmd, args []string) tea.Cmd {
@@ -180,7 +537,7 @@ func (m *Model) configureChat() {
 			m.setChatSize()
 			return nil
 		},
-	})
+	}}, aliases...)...)
 }
 
 func (m *Model) UpdateClient(msg tea.Msg) (mpty.ClientModel, tea.Cmd) {
@@ -191,6 +548,12 @@ func (m *Model) UpdateClient(msg tea.Msg) (mpty.ClientModel, tea.Cmd) {
 	m.ClientInfoModel, cmd = m.ClientInfoModel.UpdateInfo(msg)
 	cmds = append(cmds, cmd)
 
+	// the key that dismisses the banner isn't passed on to the chat
+	if m.banner.Update(msg) {
+		m.cmds = cmds
+		return m, tea.Batch(cmds...)
+	}
+
 	switch msg.(type) {
 	case tea.WindowSizeMsg:
 		m.setChatSize()
@@ -204,11 +567,17 @@ func (m *Model) UpdateClient(msg tea.Msg) (mpty.ClientModel, tea.Cmd) {
 }
 
 func (m *Model) View() string {
+	if !m.banner.Done() {
+		return m.banner.View()
+	}
+
 	b := &m.b
 	b.Reset()
 
 	// TODO: maybe make this an overlay?
-	fmt.Fprint(b, m.ClientInfoModel.View())
+	if m.showInfo {
+		fmt.Fprint(b, m.ClientInfoModel.View())
+	}
 	m.chat.ViewTo(b)
 
 	return b.String()
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ghthor/webtea/mpty"
+	"golang.org/x/sync/errgroup"
+	"tailscale.com/client/tailscale/apitype"
+	"tailscale.com/tailcfg"
+)
+
+const (
+	previewCols = 100
+	previewRows = 30
+)
+
+type previewSession struct{}
+
+func (previewSession) RemoteAddr() net.Addr { return &net.TCPAddr{IP: net.IPv4zero} }
+
+// preview renders the room as seen by a web client that never joins it
+type preview struct {
+	observer atomic.Pointer[mpty.Observer]
+}
+
+// run observes the room once the main program has started
+func (p *preview) run(prog mpty.Program) func(context.Context, *errgroup.Group, context.CancelCauseFunc) error {
+	return func(ctx context.Context, _ *errgroup.Group, _ context.CancelCauseFunc) error {
+		who := &apitype.WhoIsResponse{
+			UserProfile: &tailcfg.UserProfile{LoginName: "preview", DisplayName: "preview"},
+		}
+		size := tea.WindowSizeMsg{Width: previewCols, Height: previewRows}
+		info := mpty.NewClientInfoModelFromWebtty(size, previewSession{}, who)
+		info.Access = policy.Access(who)
+
+		o, err := prog.Observe(ctx, &Model{ctx: ctx, ClientInfoModel: info}, size)
+		if err != nil {
+			return err
+		}
+		p.observer.Store(o)
+		return nil
+	}
+}
+
+func (p *preview) View() string {
+	if o := p.observer.Load(); o != nil {
+		return o.View()
+	}
+	return "starting"
+}
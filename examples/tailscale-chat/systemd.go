@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/charmbracelet/log"
+	"github.com/ghthor/webtea"
+)
+
+// systemdMain writes the unit files that run the server socket activated by
+// systemd, e.g.
+//
+//	tailscale-chat systemd-units -dir /etc/systemd/system -config /etc/tailscale-chat.yaml
+//
+// The sockets listen on the ssh and http ports of the config, on every
+// address of the host unless -listen-addr is set, e.g. to the tailnet address
+// of the host. Peers are still identified on the tailnet of the server.
+func systemdMain(args []string) {
+	fs := flag.NewFlagSet("systemd-units", flag.ExitOnError)
+	dir := fs.String("dir", ".", "directory the unit files are written to")
+	user := fs.String("user", "", "user the service runs as, root when empty")
+	addr := fs.String("listen-addr", "", "address the sockets listen on, every address when empty")
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		log.Fatal("invalid configuration", "error", err)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		log.Fatal("could not find the executable", "error", err)
+	}
+	execStart := exe
+	if path := fs.Lookup("config").Value.String(); path != "" {
+		if path, err = filepath.Abs(path); err != nil {
+			log.Fatal("invalid config path", "error", err)
+		}
+		execStart += " -config " + path
+	}
+
+	units := webtea.SystemdUnits{
+		Name:        cfg.Hostname,
+		Description: "tailscale-chat " + cfg.Hostname,
+		ExecStart:   execStart,
+		User:        *user,
+		Sockets: map[string]string{
+			"ssh":  net.JoinHostPort(*addr, fmt.Sprint(cfg.SSHPort)),
+			"http": net.JoinHostPort(*addr, fmt.Sprint(cfg.HTTPPort)),
+		},
+	}
+	if err := units.Write(*dir); err != nil {
+		log.Fatal("could not write the unit files", "error", err)
+	}
+	log.Info("wrote systemd units", "dir", *dir, "service", cfg.Hostname+".service")
+}
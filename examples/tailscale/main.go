@@ -8,8 +8,10 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"net"
+	"os"
 	"os/signal"
 	"strings"
 	"syscall"
@@ -29,28 +31,38 @@ import (
 	"tailscale.com/client/tailscale/apitype"
 )
 
-const (
-	sshPort  = 23234
-	httpPort = 28080
-)
-
 func main() {
+	cfg := webtea.DefaultConfig()
+	if err := cfg.Load(flag.CommandLine, os.Args[1:]); err != nil {
+		log.Fatal("invalid configuration", "error", err)
+	}
+
 	ctx, cancel := context.WithCancelCause(context.Background())
 	rootCtx := ctx
 
 	ctx, sigCancel := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
 	defer sigCancel()
 
-	ts, err := tshelper.NewListeners("webtea", sshPort, httpPort)
+	var tsOpts []tshelper.Option
+	if cfg.Ephemeral {
+		tsOpts = append(tsOpts, tshelper.Ephemeral())
+	}
+	ts, err := tshelper.NewListeners(cfg.Hostname, cfg.SSHPort, cfg.HTTPPort, tsOpts...)
 	if err != nil {
 		log.Fatal("tailscale %w", err)
 	}
+	defer func() {
+		if err := ts.Close(); err != nil {
+			log.Warn("could not close tailscale", "error", err)
+		}
+	}()
+	identity := tstea.Tailscale(ts.Client)
 
 	s, err := wish.NewServer(
 		// wish.WithAddress(net.JoinHostPort(host, port)),
-		wish.WithHostKeyPath(".ssh/id_ed25519"),
+		wish.WithHostKeyPath(cfg.HostKeyPath),
 		wish.WithMiddleware(
-			tstea.WishMiddleware(ctx, ts.Client, newSshModel, newProg),
+			tstea.WishMiddleware(ctx, identity, newSshModel, newProg),
 			logging.Middleware(),
 		),
 	)
@@ -62,16 +74,16 @@ func main() {
 	if err != nil {
 		log.Fatal("failed to wait for tailscale IP", "error", err)
 	}
-	log.Info("Starting SSH server", "addr", net.JoinHostPort(tsIPv4.String(), fmt.Sprint(sshPort)))
+	log.Info("Starting SSH server", "addr", net.JoinHostPort(tsIPv4.String(), fmt.Sprint(cfg.SSHPort)))
 
 	// TODO: print out complete http(s):// string
-	log.Info("Starting HTTP server", "addr", net.JoinHostPort(tsIPv4.String(), fmt.Sprint(httpPort)))
+	log.Info("Starting HTTP server", "addr", net.JoinHostPort(tsIPv4.String(), fmt.Sprint(cfg.HTTPPort)))
 
 	grp, grpCtx := errgroup.WithContext(ctx)
 	err = errors.Join(
 		webtea.RunSSH(grpCtx, grp, cancel, ts.Ssh, s),
 		webtea.RunHTTP(grpCtx, grp, cancel, ts.Http, tstea.NewTeaTYFactory(
-			ctx, ts.Client, newHttpModel, newProg,
+			ctx, identity, newHttpModel, newProg,
 		), "webtty"),
 	)
 	if err != nil {
@@ -84,7 +96,7 @@ func main() {
 	}
 
 	log.Info("Stopping SSH server")
-	err = webtea.ShutdownSSH(s, 30*time.Second)
+	err = webtea.ShutdownSSH(s, cfg.Timeouts.Shutdown)
 	if err != nil {
 		log.Error("Could not stop server", "error", err)
 	}
@@ -107,12 +119,16 @@ func newSshModel(ctx context.Context, pty ssh.Pty, sess mpty.Session, who *apity
 	}
 }
 
-func newHttpModel(ctx context.Context, sess mpty.Session, who *apitype.WhoIsResponse) mpty.ClientModel {
+func newHttpModel(ctx context.Context, term tstea.WebTerminal, sess mpty.Session, who *apitype.WhoIsResponse) mpty.ClientModel {
+	size := term.Window
+	if size.Width == 0 {
+		size = tea.WindowSizeMsg{Width: 80, Height: 40}
+	}
 	return &model{
 		ctx:    ctx,
 		term:   "xterm",
-		width:  80,
-		height: 40,
+		width:  size.Width,
+		height: size.Height,
 		time:   time.Now(),
 
 		sess: sess,
@@ -0,0 +1,160 @@
+package webtea
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A handoff restarts the process without refusing connections. The new
+// process is started with the listening sockets the same way systemd passes
+// them, so SystemdActivation picks them up. LISTEN_PID can't be known before
+// the new process starts so it's left empty and the new process checks its
+// parent is the one that handed off instead.
+
+const (
+	handoffEnv      = "WEBTEA_HANDOFF_PPID"
+	handoffReadyEnv = "WEBTEA_HANDOFF_READY_FD"
+)
+
+// DefaultHandoffTimeout is how long Handoff waits for the new process to
+// call HandoffReady.
+const DefaultHandoffTimeout = 30 * time.Second
+
+// Handoff starts the executable of this process again, with the same
+// arguments, passing it listeners by name. It returns once the new process
+// has called HandoffReady, after which this process should stop accepting and
+// drain its sessions. The new process is killed when it isn't ready before
+// ctx is done.
+//
+// Only listeners backed by a file descriptor, e.g. from net.Listen,
+// ListenUnix or SystemdActivation, can be handed off. Wrappers like
+// FilterListener are unwrapped.
+func Handoff(ctx context.Context, listeners map[string]net.Listener) (*os.Process, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(listeners))
+	files := make([]*os.File, 0, len(listeners)+1)
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for name, l := range listeners {
+		f, err := listenerFile(l)
+		if err != nil {
+			return nil, fmt.Errorf("listener %s: %w", name, err)
+		}
+		names = append(names, name)
+		files = append(files, f)
+	}
+
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer readyR.Close()
+	files = append(files, readyW)
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(handoffEnviron(),
+		"LISTEN_PID=",
+		"LISTEN_FDS="+strconv.Itoa(len(names)),
+		"LISTEN_FDNAMES="+strings.Join(names, ":"),
+		handoffEnv+"="+strconv.Itoa(os.Getpid()),
+		handoffReadyEnv+"="+strconv.Itoa(listenFdsStart+len(names)),
+	)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	// only the new process may hold the write end, so the read fails if it
+	// exits without being ready
+	readyW.Close()
+	files = files[:len(files)-1]
+
+	ready := make(chan error, 1)
+	go func() {
+		b := make([]byte, 1)
+		_, err := readyR.Read(b)
+		ready <- err
+	}()
+
+	select {
+	case err = <-ready:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, fmt.Errorf("new process wasn't ready: %w", err)
+	}
+
+	// the new process is not waited for, it outlives this one
+	return cmd.Process, nil
+}
+
+// HandoffReady tells the process that started this one with Handoff that the
+// listeners are being served. It does nothing when the process wasn't
+// started by a handoff.
+func HandoffReady() error {
+	fd := os.Getenv(handoffReadyEnv)
+	os.Unsetenv(handoffReadyEnv)
+	if fd == "" {
+		return nil
+	}
+
+	n, err := strconv.Atoi(fd)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", handoffReadyEnv, err)
+	}
+	f := os.NewFile(uintptr(n), "handoff-ready")
+	defer f.Close()
+	_, err = f.Write([]byte{1})
+	return err
+}
+
+// handedOff reports if this process was started by a Handoff of its parent
+func handedOff() bool {
+	ppid := os.Getenv(handoffEnv)
+	return ppid != "" && ppid == strconv.Itoa(os.Getppid())
+}
+
+// handoffEnviron is the environment of this process without the variables of
+// a previous handoff or socket activation.
+func handoffEnviron() []string {
+	env := os.Environ()
+	out := env[:0:0]
+	for _, kv := range env {
+		switch k, _, _ := strings.Cut(kv, "="); k {
+		case "LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES", handoffEnv, handoffReadyEnv:
+		default:
+			out = append(out, kv)
+		}
+	}
+	return out
+}
+
+func listenerFile(l net.Listener) (*os.File, error) {
+	for {
+		switch t := l.(type) {
+		case interface{ File() (*os.File, error) }:
+			return t.File()
+		case interface{ Unwrap() net.Listener }:
+			l = t.Unwrap()
+		default:
+			return nil, errors.New("listener has no file descriptor")
+		}
+	}
+}
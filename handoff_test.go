@@ -0,0 +1,43 @@
+package webtea
+
+import (
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenerFile(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	filter, err := NewAddrFilter(nil, nil)
+	require.NoError(t, err)
+
+	// wrappers are unwrapped down to the listener with a file descriptor
+	f, err := listenerFile(FilterListener(l, filter))
+	require.NoError(t, err)
+	defer f.Close()
+
+	fl, err := net.FileListener(f)
+	require.NoError(t, err)
+	defer fl.Close()
+	require.Equal(t, l.Addr().String(), fl.Addr().String())
+
+	_, err = listenerFile(newPipeListener(l.Addr()))
+	require.ErrorContains(t, err, "no file descriptor")
+}
+
+func TestHandoffEnviron(t *testing.T) {
+	t.Setenv("LISTEN_FDS", "2")
+	t.Setenv(handoffEnv, "1")
+	t.Setenv("WEBTEA_HOSTNAME", "chat")
+
+	env := handoffEnviron()
+	require.Contains(t, env, "WEBTEA_HOSTNAME=chat")
+	require.NotContains(t, env, "LISTEN_FDS=2")
+	require.NotContains(t, env, handoffEnv+"=1")
+	require.Equal(t, len(os.Environ())-2, len(env))
+}
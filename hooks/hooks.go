@@ -0,0 +1,260 @@
+// Package hooks are small reactions of a room an operator defines in the
+// config file, without recompiling:
+//
+//	hooks:
+//	  replies:
+//	    - pattern: '^!roll (?P<sides>\d+)$'
+//	      reply: 'rolling a d${sides}'
+//	      nick: dice
+//	  aliases:
+//	    - name: bf
+//	      command: blokfall
+//	  scheduled:
+//	    - every: 1h
+//	      message: remember to stretch
+//	  scripts:
+//	    - name: coin
+//	      source: |
+//	        function on_message(str)
+//	          if str == "!flip" then
+//	            return math.random(2) == 1 and "heads" or "tails"
+//	          end
+//	        end
+//
+// The declarative hooks are sandboxed by construction: patterns are RE2
+// regular expressions, which match in time linear to the message, only the
+// start of long messages is matched, replies are rate limited and scheduled
+// messages can't be more frequent than a minute. Scripts are Lua, without
+// the libraries reaching outside the script, and every call is bounded by a
+// timeout after which the script is disabled, see ScriptHook. No hook can
+// stall the room for long.
+//
+// Code adds its own reactions to an Engine as a Hook, see Engine.Add.
+package hooks
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultCooldown is the least time between two replies of a hook
+	DefaultCooldown = 10 * time.Second
+	// MinEvery is the shortest interval of a scheduled message
+	MinEvery = time.Minute
+
+	// maxPattern bounds the length of the patterns of reply hooks
+	maxPattern = 256
+	// maxInput is how much of a message reply hooks match against
+	maxInput = 1024
+)
+
+type Config struct {
+	Replies   []ReplyHook     `yaml:"replies" toml:"replies"`
+	Aliases   []AliasHook     `yaml:"aliases" toml:"aliases"`
+	Scheduled []ScheduledHook `yaml:"scheduled" toml:"scheduled"`
+	Scripts   []ScriptHook    `yaml:"scripts" toml:"scripts"`
+}
+
+// Hook is a reaction of a room run by an Engine, like the scripts. It is
+// called by the Main program so it must return quickly.
+type Hook interface {
+	// Reply returns the reply to the chat message str, if any
+	Reply(now time.Time, str string) (Post, bool)
+	// Due returns the messages to post at now, it is called every tick
+	Due(now time.Time) []Post
+}
+
+// ReplyHook answers the chat messages matching Pattern.
+type ReplyHook struct {
+	Pattern string `yaml:"pattern" toml:"pattern"`
+	// Reply is posted when a message matches, $1 or ${name} are replaced by
+	// the groups of Pattern like regexp.Regexp.Expand
+	Reply string `yaml:"reply" toml:"reply"`
+	// Nick is who the reply is from, the room decides when empty
+	Nick string `yaml:"nick" toml:"nick"`
+	// Cooldown is the least time between two replies of the hook,
+	// DefaultCooldown when 0
+	Cooldown time.Duration `yaml:"cooldown" toml:"cooldown"`
+}
+
+// AliasHook is a command that runs another command line, the arguments of
+// the alias are appended to it.
+type AliasHook struct {
+	Name string `yaml:"name" toml:"name"`
+	// Command is the command line without the leader, e.g. "blokfall"
+	Command string `yaml:"command" toml:"command"`
+}
+
+// ScheduledHook posts Message every Every, starting Every after the room
+// started.
+type ScheduledHook struct {
+	Every   time.Duration `yaml:"every" toml:"every"`
+	Message string        `yaml:"message" toml:"message"`
+	// Nick is who the message is from, the room decides when empty
+	Nick string `yaml:"nick" toml:"nick"`
+}
+
+// Post is a message posted by a hook.
+type Post struct {
+	Nick string
+	Str  string
+}
+
+// Engine runs the reply and scheduled hooks of a room, it is not safe for
+// concurrent use. The methods of a nil Engine do nothing.
+type Engine struct {
+	replies   []reply
+	scheduled []ScheduledHook
+	// next is when each of scheduled is posted next
+	next []time.Time
+	// hooks are the scripts and the hooks added
+	hooks []Hook
+}
+
+type reply struct {
+	ReplyHook
+	re      *regexp.Regexp
+	lastRan time.Time
+}
+
+// Validate reports the hooks that can't be compiled or would run too often.
+func (c Config) Validate() error {
+	e, err := New(c)
+	e.Close()
+	return err
+}
+
+// New compiles the hooks of c. The engine runs the valid hooks even when the
+// others are reported.
+func New(c Config) (*Engine, error) {
+	var (
+		errs []error
+		e    = &Engine{}
+	)
+	for i, r := range c.Replies {
+		if len(r.Pattern) > maxPattern {
+			errs = append(errs, fmt.Errorf("replies[%d]: pattern is longer than %d", i, maxPattern))
+			continue
+		}
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("replies[%d]: %w", i, err))
+			continue
+		}
+		if r.Reply == "" || r.Cooldown < 0 {
+			errs = append(errs, fmt.Errorf("replies[%d]: needs a reply and a cooldown of at least 0", i))
+			continue
+		}
+		if r.Cooldown == 0 {
+			r.Cooldown = DefaultCooldown
+		}
+		e.replies = append(e.replies, reply{ReplyHook: r, re: re})
+	}
+
+	aliases := make(map[string]bool, len(c.Aliases))
+	for _, a := range c.Aliases {
+		aliases[a.Name] = true
+	}
+	for i, a := range c.Aliases {
+		target, _, _ := strings.Cut(a.Command, " ")
+		switch {
+		case a.Name == "" || strings.Contains(a.Name, " "):
+			errs = append(errs, fmt.Errorf("aliases[%d]: name %q must be a single word", i, a.Name))
+		case target == "":
+			errs = append(errs, fmt.Errorf("aliases[%d]: needs a command", i))
+		case aliases[target]:
+			// aliases of aliases could loop
+			errs = append(errs, fmt.Errorf("aliases[%d]: %s is an alias itself", i, target))
+		}
+	}
+
+	for i, s := range c.Scheduled {
+		if s.Every < MinEvery || s.Message == "" {
+			errs = append(errs, fmt.Errorf("scheduled[%d]: needs a message and must be at least %s apart", i, MinEvery))
+			continue
+		}
+		e.scheduled = append(e.scheduled, s)
+	}
+	e.next = make([]time.Time, len(e.scheduled))
+
+	for i, h := range c.Scripts {
+		s, err := newScript(h)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("scripts[%d]: %w", i, err))
+			continue
+		}
+		e.hooks = append(e.hooks, s)
+	}
+	return e, errors.Join(errs...)
+}
+
+// Add runs h after the hooks of the config.
+func (e *Engine) Add(h Hook) {
+	e.hooks = append(e.hooks, h)
+}
+
+// Close releases the scripts.
+func (e *Engine) Close() {
+	if e == nil {
+		return
+	}
+	for _, h := range e.hooks {
+		if s, ok := h.(*script); ok {
+			s.close()
+		}
+	}
+}
+
+// Reply returns the reply of the first hook matching str that isn't cooling
+// down.
+func (e *Engine) Reply(now time.Time, str string) (Post, bool) {
+	if e == nil {
+		return Post{}, false
+	}
+	if len(str) > maxInput {
+		str = str[:maxInput]
+	}
+	for i := range e.replies {
+		r := &e.replies[i]
+		if now.Sub(r.lastRan) < r.Cooldown {
+			continue
+		}
+		match := r.re.FindStringSubmatchIndex(str)
+		if match == nil {
+			continue
+		}
+		r.lastRan = now
+		return Post{Nick: r.Nick, Str: string(r.re.ExpandString(nil, r.Reply, str, match))}, true
+	}
+	for _, h := range e.hooks {
+		if post, ok := h.Reply(now, str); ok {
+			return post, true
+		}
+	}
+	return Post{}, false
+}
+
+// Due returns the scheduled messages due at now.
+func (e *Engine) Due(now time.Time) []Post {
+	if e == nil {
+		return nil
+	}
+	var posts []Post
+	for i, s := range e.scheduled {
+		switch {
+		case e.next[i].IsZero():
+			e.next[i] = now.Add(s.Every)
+		case !now.Before(e.next[i]):
+			e.next[i] = now.Add(s.Every)
+			posts = append(posts, Post{Nick: s.Nick, Str: s.Message})
+		}
+	}
+	for _, h := range e.hooks {
+		posts = append(posts, h.Due(now)...)
+	}
+	return posts
+}
@@ -0,0 +1,124 @@
+package hooks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine(t *testing.T) {
+	c := Config{
+		Replies: []ReplyHook{{Pattern: `^!roll (?P<sides>\d+)$`, Reply: "rolling a d${sides}", Nick: "dice"}},
+		Scheduled: []ScheduledHook{
+			{Every: time.Hour, Message: "remember to stretch"},
+			{Every: time.Second, Message: "too often"},
+		},
+	}
+	require.ErrorContains(t, c.Validate(), "scheduled[1]")
+	e, _ := New(c)
+
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	post, ok := e.Reply(start, "!roll 20")
+	require.True(t, ok)
+	require.Equal(t, Post{Nick: "dice", Str: "rolling a d20"}, post)
+	_, ok = e.Reply(start.Add(time.Second), "!roll 6")
+	require.False(t, ok, "the hook is cooling down")
+	_, ok = e.Reply(start.Add(time.Minute), "hello")
+	require.False(t, ok)
+
+	require.Empty(t, e.Due(start))
+	require.Empty(t, e.Due(start.Add(time.Minute)))
+	require.Equal(t, []Post{{Str: "remember to stretch"}}, e.Due(start.Add(time.Hour)))
+
+	var nilEngine *Engine
+	require.Empty(t, nilEngine.Due(start))
+}
+
+func TestValidateAliases(t *testing.T) {
+	require.NoError(t, Config{Aliases: []AliasHook{{Name: "bf", Command: "blokfall"}}}.Validate())
+
+	loop := Config{Aliases: []AliasHook{{Name: "a", Command: "b"}, {Name: "b", Command: "a"}}}
+	require.ErrorContains(t, loop.Validate(), "is an alias itself")
+	require.ErrorContains(t, Config{Aliases: []AliasHook{{Name: "a b", Command: "c"}}}.Validate(), "single word")
+	require.ErrorContains(t, Config{Replies: []ReplyHook{{Pattern: "(", Reply: "x"}}}.Validate(), "replies[0]")
+}
+
+func TestScripts(t *testing.T) {
+	e, err := New(Config{Scripts: []ScriptHook{{
+		Name: "coin",
+		Nick: "coin",
+		Source: `
+flips = 0
+function on_message(str)
+  if str == "!flip" then
+    flips = flips + 1
+    return "flip " .. flips
+  end
+end
+function on_tick(unix)
+  return "it is " .. unix
+end`,
+	}}})
+	require.NoError(t, err)
+	defer e.Close()
+
+	start := time.Unix(1000, 0)
+	post, ok := e.Reply(start, "!flip")
+	require.True(t, ok)
+	require.Equal(t, Post{Nick: "coin", Str: "flip 1"}, post)
+	_, ok = e.Reply(start.Add(time.Second), "!flip")
+	require.False(t, ok, "the script is cooling down")
+	_, ok = e.Reply(start.Add(time.Minute), "hello")
+	require.False(t, ok)
+	post, _ = e.Reply(start.Add(time.Minute), "!flip")
+	require.Equal(t, "flip 2", post.Str, "the state of the script is kept")
+
+	require.Equal(t, []Post{{Nick: "coin", Str: "it is 1000"}}, e.Due(start))
+	require.Empty(t, e.Due(start.Add(time.Second)), "on_tick posts at most every MinEvery")
+}
+
+func TestScriptSandbox(t *testing.T) {
+	for name, source := range map[string]string{
+		"io":       `io.open("/etc/passwd")`,
+		"os":       `os.exit(1)`,
+		"loadfile": `loadfile("/etc/passwd")`,
+		"require":  `require("os")`,
+		"rep":      `string.rep("x", 1e9)`,
+	} {
+		_, err := New(Config{Scripts: []ScriptHook{{Name: name, Source: source}}})
+		require.ErrorContains(t, err, "scripts[0]", name)
+	}
+
+	start := time.Now()
+	e, err := New(Config{Scripts: []ScriptHook{{
+		Name:    "loop",
+		Timeout: 5 * time.Millisecond,
+		Source:  `function on_message(str) while true do end end`,
+	}}})
+	require.NoError(t, err)
+	_, ok := e.Reply(start, "hi")
+	require.False(t, ok)
+	_, ok = e.Reply(start.Add(time.Hour), "hi")
+	require.False(t, ok, "the script is disabled once it overran")
+	require.Less(t, time.Since(start), time.Second)
+
+	_, err = New(Config{Scripts: []ScriptHook{{Name: "slow", Timeout: time.Second, Source: ""}}})
+	require.ErrorContains(t, err, "timeout must be")
+}
+
+type plugin struct{}
+
+func (plugin) Reply(now time.Time, str string) (Post, bool) { return Post{Str: "plugged " + str}, true }
+func (plugin) Due(time.Time) []Post                         { return nil }
+
+func TestEngineAdd(t *testing.T) {
+	e, err := New(Config{Replies: []ReplyHook{{Pattern: `^!ping$`, Reply: "pong"}}})
+	require.NoError(t, err)
+	e.Add(plugin{})
+
+	post, _ := e.Reply(time.Now(), "!ping")
+	require.Equal(t, "pong", post.Str, "the hooks of the config run first")
+	post, _ = e.Reply(time.Now(), "hi")
+	require.Equal(t, "plugged hi", post.Str)
+}
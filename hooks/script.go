@@ -0,0 +1,216 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ghthor/webtea/logpolicy"
+	lua "github.com/yuin/gopher-lua"
+)
+
+const (
+	// DefaultTimeout bounds every call of a script that doesn't set one
+	DefaultTimeout = 10 * time.Millisecond
+	// MaxTimeout is the longest a call of a script may take, scripts run in
+	// the Main program so they stall the whole room
+	MaxTimeout = 100 * time.Millisecond
+
+	// maxSource bounds the length of a script
+	maxSource = 64 << 10
+	// maxString bounds the strings string.rep builds and the posts of
+	// scripts
+	maxString = 4096
+	// scriptRegistry bounds the values a script may hold at once
+	scriptRegistry = 64 << 10
+)
+
+// ScriptHook is a Lua script. It may define on_message(str), called with
+// every chat message, and on_tick(unix), called every second, each returning
+// the message to post or nil. Only the base, string, table and math
+// libraries are loaded, without the functions loading files or code.
+type ScriptHook struct {
+	Name   string `yaml:"name" toml:"name"`
+	Source string `yaml:"source" toml:"source"`
+	// Nick is who the posts are from, the room decides when empty
+	Nick string `yaml:"nick" toml:"nick"`
+	// Timeout bounds each call of the script, DefaultTimeout when 0
+	Timeout time.Duration `yaml:"timeout" toml:"timeout"`
+	// Cooldown is the least time between two replies of on_message,
+	// DefaultCooldown when 0. on_tick posts at most every MinEvery.
+	Cooldown time.Duration `yaml:"cooldown" toml:"cooldown"`
+}
+
+// script is a ScriptHook loaded in its own Lua state.
+type script struct {
+	ScriptHook
+	// L is nil once a call overran its timeout
+	L *lua.LState
+
+	repliedAt, postedAt time.Time
+}
+
+var errScriptDisabled = errors.New("script disabled after a timeout")
+
+// newScript loads the source of h, running its top level.
+func newScript(h ScriptHook) (*script, error) {
+	switch {
+	case h.Name == "":
+		return nil, errors.New("needs a name")
+	case len(h.Source) > maxSource:
+		return nil, fmt.Errorf("source is longer than %d", maxSource)
+	case h.Timeout < 0 || h.Timeout > MaxTimeout:
+		return nil, fmt.Errorf("timeout must be between 0 and %s", MaxTimeout)
+	case h.Cooldown < 0:
+		return nil, errors.New("cooldown must be at least 0")
+	}
+	if h.Timeout == 0 {
+		h.Timeout = DefaultTimeout
+	}
+	if h.Cooldown == 0 {
+		h.Cooldown = DefaultCooldown
+	}
+
+	L := lua.NewState(lua.Options{
+		SkipOpenLibs:        true,
+		RegistrySize:        1024,
+		RegistryMaxSize:     scriptRegistry,
+		MinimizeStackMemory: true,
+	})
+	if err := sandbox(L); err != nil {
+		L.Close()
+		return nil, err
+	}
+	s := &script{ScriptHook: h, L: L}
+	fn, err := L.LoadString(h.Source)
+	if err == nil {
+		L.Push(fn)
+		_, err = s.call(-1)
+	}
+	if err != nil {
+		s.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// sandbox opens the libraries scripts may use in L.
+func sandbox(L *lua.LState) error {
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.StringLibName, lua.OpenString},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		err := L.CallByParam(lua.P{Fn: L.NewFunction(lib.open), Protect: true}, lua.LString(lib.name))
+		if err != nil {
+			return err
+		}
+	}
+	for _, name := range []string{"dofile", "loadfile", "load", "loadstring", "require", "module", "collectgarbage"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+
+	// a single call of string.rep could allocate without bound
+	str := L.GetGlobal(lua.StringLibName).(*lua.LTable)
+	rep := str.RawGetString("rep").(*lua.LFunction).GFunction
+	str.RawSetString("rep", L.NewFunction(func(L *lua.LState) int {
+		if str, n := L.CheckString(1), L.CheckInt(2); str != "" && n > maxString/len(str) {
+			L.RaiseError("string.rep: result longer than %d", maxString)
+		}
+		return rep(L)
+	}))
+	return nil
+}
+
+// call calls the function at idx of the stack with args and returns its
+// result, in Timeout at most. A script that overran is disabled: its call
+// may be stuck in a Go function, e.g. a string match, that can't be
+// interrupted so its state is abandoned.
+func (s *script) call(idx int, args ...lua.LValue) (lua.LValue, error) {
+	L := s.L
+	fn := L.Get(idx)
+	L.Remove(idx)
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+	defer cancel()
+	L.SetContext(ctx)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- L.CallByParam(lua.P{Fn: fn, NRet: 1, Protect: true}, args...)
+	}()
+	select {
+	case err := <-done:
+		L.RemoveContext()
+		if err != nil {
+			return lua.LNil, err
+		}
+		ret := L.Get(-1)
+		L.Pop(1)
+		return ret, nil
+	case <-ctx.Done():
+		s.L = nil
+		return lua.LNil, fmt.Errorf("%w: %w", errScriptDisabled, ctx.Err())
+	}
+}
+
+// callGlobal calls the global function name of the script, if it defines
+// one, and returns the string it returned.
+func (s *script) callGlobal(name string, args ...lua.LValue) (string, bool) {
+	if s.L == nil {
+		return "", false
+	}
+	if s.L.GetGlobal(name).Type() != lua.LTFunction {
+		return "", false
+	}
+	s.L.Push(s.L.GetGlobal(name))
+	ret, err := s.call(-1, args...)
+	if err != nil {
+		logpolicy.Warn("hooks.script."+s.Name, "script failed", "script", s.Name, "function", name, "error", err)
+		return "", false
+	}
+	str, ok := ret.(lua.LString)
+	if !ok || str == "" {
+		return "", false
+	}
+	if len(str) > maxString {
+		str = str[:maxString]
+	}
+	return string(str), true
+}
+
+func (s *script) Reply(now time.Time, str string) (Post, bool) {
+	if now.Sub(s.repliedAt) < s.Cooldown {
+		return Post{}, false
+	}
+	reply, ok := s.callGlobal("on_message", lua.LString(str))
+	if !ok {
+		return Post{}, false
+	}
+	s.repliedAt = now
+	return Post{Nick: s.Nick, Str: reply}, true
+}
+
+func (s *script) Due(now time.Time) []Post {
+	if now.Sub(s.postedAt) < MinEvery {
+		return nil
+	}
+	msg, ok := s.callGlobal("on_tick", lua.LNumber(now.Unix()))
+	if !ok {
+		return nil
+	}
+	s.postedAt = now
+	return []Post{{Nick: s.Nick, Str: msg}}
+}
+
+func (s *script) close() {
+	if s.L != nil {
+		s.L.Close()
+		s.L = nil
+	}
+}
@@ -0,0 +1,123 @@
+// i18n is a minimal message catalog for user facing strings. Messages are
+// looked up by key and formatted with fmt.Sprintf. Every key should have an
+// English translation, which is used whenever a locale is missing a key.
+package i18n
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type Locale string
+
+const (
+	English Locale = "en"
+	Spanish Locale = "es"
+
+	Default = English
+)
+
+var (
+	mu       sync.RWMutex
+	catalogs = make(map[Locale]map[string]string)
+)
+
+// Register adds msgs to the catalog for locale l, replacing any existing
+// translations with the same key.
+func Register(l Locale, msgs map[string]string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	c, ok := catalogs[l]
+	if !ok {
+		c = make(map[string]string, len(msgs))
+		catalogs[l] = c
+	}
+	maps.Copy(c, msgs)
+}
+
+func lookup(l Locale, key string) (string, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if s, ok := catalogs[l][key]; ok {
+		return s, true
+	}
+	s, ok := catalogs[Default][key]
+	return s, ok
+}
+
+// T returns the translation of key in locale l formatted with args. If the
+// key has no translation the key itself is returned.
+func T(l Locale, key string, args ...any) string {
+	s, ok := lookup(l, key)
+	if !ok {
+		s = key
+	}
+	if len(args) == 0 {
+		return s
+	}
+	return fmt.Sprintf(s, args...)
+}
+
+// TOr is like T but returns def when no locale has a translation for key.
+// It's for strings that are provided by applications, like command
+// descriptions, that may not be in any catalog.
+func TOr(l Locale, key, def string) string {
+	if s, ok := lookup(l, key); ok {
+		return s
+	}
+	return def
+}
+
+// Locales returns every locale with a registered catalog.
+func Locales() []Locale {
+	mu.RLock()
+	defer mu.RUnlock()
+	return slices.Sorted(maps.Keys(catalogs))
+}
+
+// Keys returns the sorted keys of the catalog of locale l, e.g. to check a
+// translation is complete.
+func Keys(l Locale) []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return slices.Sorted(maps.Keys(catalogs[l]))
+}
+
+// Parse matches a language tag or POSIX locale, e.g. "es", "es-MX" or
+// "es_ES.UTF-8", against the registered catalogs.
+func Parse(s string) (Locale, bool) {
+	s = strings.ToLower(s)
+	s, _, _ = strings.Cut(s, ".")
+	s, _, _ = strings.Cut(s, "_")
+	s, _, _ = strings.Cut(s, "-")
+
+	mu.RLock()
+	defer mu.RUnlock()
+	if _, ok := catalogs[Locale(s)]; ok {
+		return Locale(s), true
+	}
+	return Default, false
+}
+
+// Detect selects a locale from environment variables in the format of
+// os.Environ, using the same precedence as POSIX: LC_ALL, LC_MESSAGES, LANG.
+func Detect(environ []string) Locale {
+	env := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		k, v, _ := strings.Cut(kv, "=")
+		env[k] = v
+	}
+
+	for _, k := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := env[k]; v != "" {
+			l, _ := Parse(v)
+			return l
+		}
+	}
+	return Default
+}
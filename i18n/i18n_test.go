@@ -0,0 +1,36 @@
+package i18n
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	Register(English, map[string]string{
+		"test.greeting": "hello %s",
+		"test.only_en":  "english",
+	})
+	Register(Spanish, map[string]string{
+		"test.greeting": "hola %s",
+	})
+}
+
+func TestT(t *testing.T) {
+	require.Equal(t, "hello bob", T(English, "test.greeting", "bob"))
+	require.Equal(t, "hola bob", T(Spanish, "test.greeting", "bob"))
+	require.Equal(t, "english", T(Spanish, "test.only_en"))
+	require.Equal(t, "test.missing", T(Spanish, "test.missing"))
+	require.Equal(t, "fallback", TOr(Spanish, "test.missing", "fallback"))
+	require.Equal(t, []string{"test.greeting"}, Keys(Spanish))
+}
+
+func TestDetect(t *testing.T) {
+	require.Equal(t, Spanish, Detect([]string{"LANG=es_ES.UTF-8"}))
+	require.Equal(t, English, Detect([]string{"LANG=es_ES.UTF-8", "LC_ALL=C"}))
+	require.Equal(t, English, Detect(nil))
+
+	l, ok := Parse("es-MX")
+	require.True(t, ok)
+	require.Equal(t, Spanish, l)
+}
@@ -0,0 +1,91 @@
+package webtea
+
+import (
+	"errors"
+	"net"
+	"sync"
+)
+
+// JoinListeners accepts the connections of every listener in ls as one
+// listener, so RunSSH, RunHTTP or a Server serve them all concurrently, e.g.
+// the tailnet addresses of the device and a localhost listener. Closing it
+// closes all of them, shutting down the server stops every listener at once.
+// Addr is the address of the first listener.
+//
+// A joined listener can't be handed off, see Server.Handoff.
+func JoinListeners(ls ...net.Listener) net.Listener {
+	if len(ls) == 1 {
+		return ls[0]
+	}
+	j := &joinedListener{
+		ls:    ls,
+		conns: make(chan accepted),
+		done:  make(chan struct{}),
+	}
+	for _, l := range ls {
+		go j.accept(l)
+	}
+	return j
+}
+
+type joinedListener struct {
+	ls    []net.Listener
+	conns chan accepted
+	done  chan struct{}
+	once  sync.Once
+	err   error
+}
+
+type accepted struct {
+	conn net.Conn
+	err  error
+}
+
+// accept hands the connections of l to Accept until it fails or the joined
+// listener is closed.
+func (j *joinedListener) accept(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		select {
+		case j.conns <- accepted{conn, err}:
+		case <-j.done:
+			if conn != nil {
+				conn.Close()
+			}
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Accept returns the next connection of any of the listeners, or the error of
+// the first one that failed.
+func (j *joinedListener) Accept() (net.Conn, error) {
+	select {
+	case a := <-j.conns:
+		return a.conn, a.err
+	case <-j.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close closes every listener.
+func (j *joinedListener) Close() error {
+	j.once.Do(func() {
+		close(j.done)
+		var errs []error
+		for _, l := range j.ls {
+			if err := l.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+				errs = append(errs, err)
+			}
+		}
+		j.err = errors.Join(errs...)
+	})
+	return j.err
+}
+
+func (j *joinedListener) Addr() net.Addr {
+	return j.ls[0].Addr()
+}
@@ -0,0 +1,42 @@
+package webtea
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJoinListeners(t *testing.T) {
+	l1, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	l2, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	require.Same(t, l1, JoinListeners(l1))
+
+	j := JoinListeners(l1, l2)
+	require.Equal(t, l1.Addr(), j.Addr())
+
+	// connections of both listeners are accepted
+	for _, l := range []net.Listener{l1, l2} {
+		c, err := net.Dial("tcp", l.Addr().String())
+		require.NoError(t, err)
+		defer c.Close()
+
+		conn, err := j.Accept()
+		require.NoError(t, err)
+		require.Equal(t, l.Addr().String(), conn.LocalAddr().String())
+		conn.Close()
+	}
+
+	// closing the joined listener closes all of them
+	require.NoError(t, j.Close())
+	require.NoError(t, j.Close())
+	_, err = j.Accept()
+	require.ErrorIs(t, err, net.ErrClosed)
+	for _, l := range []net.Listener{l1, l2} {
+		_, err := net.Dial("tcp", l.Addr().String())
+		require.Error(t, err)
+	}
+}
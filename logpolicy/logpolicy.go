@@ -0,0 +1,118 @@
+// Package logpolicy keeps noisy events from flooding the logs under load.
+// Each event has a key, the first line of a key is logged and the others are
+// only counted until the interval of the Limiter has passed, the next line
+// then says how many were suppressed. Every event is counted by key in
+// Counts, for the metrics endpoint, whether it was logged or not.
+//
+//	logpolicy.Warn("chat.dropped", "dropped chat", "who", msg.Who)
+package logpolicy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// DefaultInterval is the least time between two lines of a key of Default
+const DefaultInterval = time.Minute
+
+// Default is the Limiter of the package functions.
+var Default = New(DefaultInterval)
+
+// Limiter logs at most one line per key per interval. It is safe for
+// concurrent use.
+type Limiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	keys map[string]*key
+
+	now func() time.Time
+}
+
+type key struct {
+	count int64
+	// logged is when the last line was logged, suppressed how many events
+	// weren't since
+	logged     time.Time
+	suppressed int64
+}
+
+// New returns a Limiter logging a key at most once per interval, every event
+// is logged when interval is 0.
+func New(interval time.Duration) *Limiter {
+	return &Limiter{
+		interval: interval,
+		keys:     make(map[string]*key),
+		now:      time.Now,
+	}
+}
+
+// Allow counts an event of k and reports if it should be logged, with how
+// many events of k were suppressed since the last one that was.
+func (l *Limiter) Allow(k string) (suppressed int64, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, found := l.keys[k]
+	if !found {
+		e = &key{}
+		l.keys[k] = e
+	}
+	e.count++
+
+	now := l.now()
+	if found && now.Sub(e.logged) < l.interval {
+		e.suppressed++
+		return 0, false
+	}
+	suppressed, e.suppressed, e.logged = e.suppressed, 0, now
+	return suppressed, true
+}
+
+// Warn logs msg as a warning if an event of k is allowed.
+func (l *Limiter) Warn(k, msg string, kv ...any) {
+	l.log(log.WarnLevel, k, msg, kv)
+}
+
+// Error logs msg as an error if an event of k is allowed.
+func (l *Limiter) Error(k, msg string, kv ...any) {
+	l.log(log.ErrorLevel, k, msg, kv)
+}
+
+func (l *Limiter) log(level log.Level, k, msg string, kv []any) {
+	suppressed, ok := l.Allow(k)
+	if !ok {
+		return
+	}
+	if suppressed > 0 {
+		kv = append(kv, "suppressed", suppressed)
+	}
+	log.Log(level, msg, kv...)
+}
+
+// Counts returns how many events of each key there were, logged or not.
+func (l *Limiter) Counts() map[string]int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	counts := make(map[string]int64, len(l.keys))
+	for k, e := range l.keys {
+		counts[k] = e.count
+	}
+	return counts
+}
+
+// Warn logs msg as a warning with Default, see Limiter.Warn.
+func Warn(k, msg string, kv ...any) {
+	Default.Warn(k, msg, kv...)
+}
+
+// Error logs msg as an error with Default, see Limiter.Error.
+func Error(k, msg string, kv ...any) {
+	Default.Error(k, msg, kv...)
+}
+
+// Counts returns the counts of Default, see Limiter.Counts.
+func Counts() map[string]int64 {
+	return Default.Counts()
+}
@@ -0,0 +1,40 @@
+package logpolicy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := New(time.Minute)
+	l.now = func() time.Time { return now }
+
+	suppressed, ok := l.Allow("a")
+	require.True(t, ok)
+	require.Zero(t, suppressed)
+
+	// the key is limited, other keys aren't
+	_, ok = l.Allow("a")
+	require.False(t, ok)
+	_, ok = l.Allow("a")
+	require.False(t, ok)
+	_, ok = l.Allow("b")
+	require.True(t, ok)
+
+	now = now.Add(time.Minute)
+	suppressed, ok = l.Allow("a")
+	require.True(t, ok)
+	require.Equal(t, int64(2), suppressed)
+
+	require.Equal(t, map[string]int64{"a": 4, "b": 1}, l.Counts())
+
+	// without an interval every event is logged
+	l = New(0)
+	for range 3 {
+		_, ok = l.Allow("a")
+		require.True(t, ok)
+	}
+}
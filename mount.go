@@ -0,0 +1,145 @@
+package webtea
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"sync"
+)
+
+// gotty only knows how to serve a single app from the root of its own
+// http.Server. To serve several apps from one listener every gotty server is
+// run on an in memory listener and mounted behind a reverse proxy that strips
+// the mount path.
+
+type remoteAddrKey struct{}
+
+type remoteAddr string
+
+func (a remoteAddr) Network() string { return "tcp" }
+func (a remoteAddr) String() string  { return string(a) }
+
+// pipeConn preserves the address of the original client so tailscale WhoIs
+// lookups made by a server.Factory still work behind the proxy.
+type pipeConn struct {
+	net.Conn
+	local, remote net.Addr
+}
+
+func (c pipeConn) LocalAddr() net.Addr  { return c.local }
+func (c pipeConn) RemoteAddr() net.Addr { return c.remote }
+
+type pipeListener struct {
+	addr  net.Addr
+	conns chan net.Conn
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newPipeListener(addr net.Addr) *pipeListener {
+	return &pipeListener{
+		addr:  addr,
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case <-l.done:
+		return nil, net.ErrClosed
+	case c := <-l.conns:
+		return c, nil
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return l.addr
+}
+
+func (l *pipeListener) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	var raddr net.Addr = l.addr
+	if s, ok := ctx.Value(remoteAddrKey{}).(string); ok {
+		raddr = remoteAddr(s)
+	}
+
+	client, srv := net.Pipe()
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-l.done:
+		return nil, net.ErrClosed
+	case l.conns <- pipeConn{srv, l.addr, raddr}:
+		return client, nil
+	}
+}
+
+// cleanMountPath returns path with a leading and trailing slash so it can be
+// used as a http.ServeMux subtree pattern.
+func cleanMountPath(path string) string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return "/"
+	}
+	return "/" + path + "/"
+}
+
+// mountMux is a http.ServeMux that reports a pattern registered twice, e.g.
+// by two mounts that clean to the same path, instead of panicking.
+type mountMux struct {
+	*http.ServeMux
+	patterns map[string]bool
+	err      error
+}
+
+func newMountMux() *mountMux {
+	return &mountMux{
+		ServeMux: http.NewServeMux(),
+		patterns: make(map[string]bool),
+	}
+}
+
+func (m *mountMux) Handle(pattern string, h http.Handler) {
+	if m.patterns[pattern] {
+		if m.err == nil {
+			m.err = fmt.Errorf("%s is mounted more than once", pattern)
+		}
+		return
+	}
+	m.patterns[pattern] = true
+	m.ServeMux.Handle(pattern, h)
+}
+
+func (m *mountMux) HandleFunc(pattern string, h func(http.ResponseWriter, *http.Request)) {
+	m.Handle(pattern, http.HandlerFunc(h))
+}
+
+func mountHandler(prefix string, l *pipeListener) http.Handler {
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.Out.URL.Scheme = "http"
+			r.Out.URL.Host = r.In.Host
+			r.Out.URL.Path = "/" + strings.TrimPrefix(r.In.URL.Path, prefix)
+			r.Out.URL.RawPath = ""
+			r.Out.Host = r.In.Host
+		},
+		Transport: &http.Transport{
+			DialContext:       l.DialContext,
+			DisableKeepAlives: true,
+		},
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), remoteAddrKey{}, r.RemoteAddr)
+		proxy.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
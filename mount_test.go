@@ -0,0 +1,59 @@
+package webtea
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMountHandler(t *testing.T) {
+	mux := newMountMux()
+	for _, name := range []string{"chat", "blokfall"} {
+		pl := newPipeListener(&net.TCPAddr{})
+		defer pl.Close()
+
+		// the backend stands in for the gotty server of a factory
+		backend := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "%s %s %s", name, r.URL.RequestURI(), r.RemoteAddr)
+		})}
+		go backend.Serve(pl)
+		defer backend.Close()
+
+		mux.Handle(cleanMountPath(name), mountHandler(cleanMountPath(name), pl))
+	}
+	require.NoError(t, mux.err)
+
+	clientAddr := make(chan string, 1)
+	front := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientAddr <- r.RemoteAddr
+		mux.ServeHTTP(w, r)
+	}))
+	defer front.Close()
+
+	get := func(path string) string {
+		resp, err := http.Get(front.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	body := get("/chat/js/app.js?v=1")
+	require.Equal(t, "chat /js/app.js?v=1 "+<-clientAddr, body)
+
+	body = get("/blokfall/")
+	require.Equal(t, "blokfall / "+<-clientAddr, body)
+}
+
+func TestMountMuxDuplicate(t *testing.T) {
+	mux := newMountMux()
+	mux.Handle(cleanMountPath("/chat"), http.NotFoundHandler())
+	mux.Handle(cleanMountPath("chat/"), http.NotFoundHandler())
+	require.ErrorContains(t, mux.err, "/chat/ is mounted more than once")
+}
@@ -0,0 +1,135 @@
+package mpty
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ghthor/webtea/logpolicy"
+	"github.com/ghthor/webtea/mpty/mptymsg"
+	"github.com/golang-cz/ringbuf"
+)
+
+// Backpressure is what happens to a client that falls more than MaxBehind
+// messages behind the newest, see WithBackpressure.
+type Backpressure int
+
+const (
+	// BackpressureDisconnect ends the client with the error of the ring, its
+	// model receives the error in a batch
+	BackpressureDisconnect Backpressure = iota
+	// BackpressureDropOldest skips the client ahead to the newest message,
+	// the recorded messages it missed are reported with a GapMsg
+	BackpressureDropOldest
+	// BackpressureResync skips the client ahead and replays the recorded
+	// messages it missed, like a resuming client
+	BackpressureResync
+)
+
+// WithBackpressure sets what happens to the clients that fall too far
+// behind, BackpressureDisconnect by default.
+func WithBackpressure(b Backpressure) Option {
+	return func(o *options) {
+		o.backpressure = b
+	}
+}
+
+type (
+	// fellBehind ends a batch instead of the error of the ring when the
+	// client is subscribed again, see Backpressure
+	fellBehind struct {
+		err error
+	}
+
+	// resubscribedMsg is sent once the client that fell behind is
+	// subscribed to the ring of its room again
+	resubscribedMsg struct {
+		resp        subResp
+		unsubscribe context.CancelFunc
+	}
+)
+
+// behind returns the error ending a read of the ring, wrapped in a
+// fellBehind when the client fell behind and isn't disconnected for it. The
+// other errors, e.g. the ring closing, always end the client.
+func (b Backpressure) behind(err error) tea.Msg {
+	if b == BackpressureDisconnect || !errors.Is(err, ringbuf.ErrSubscriberTooSlow) {
+		return err
+	}
+	return fellBehind{err}
+}
+
+// resubscribe subscribes the client that fell behind to the ring of its room
+// again, the batch that ended with a fellBehind was its last read of the old
+// subscription.
+func (m *ClientMain) resubscribe(msg fellBehind) tea.Cmd {
+	logpolicy.Warn("mpty.behind", "client fell behind", "client", m.Id(), "after", m.lastSeq, "error", msg.err)
+	m.unsubscribe()
+
+	ctx, room := m.ctx, m.Input
+	req := subReq{id: m.Id(), latest: m.backpressure == BackpressureDropOldest}
+	if m.backpressure == BackpressureResync {
+		req.after = m.lastSeq
+	}
+	return func() tea.Msg {
+		subCtx, unsubscribe := context.WithCancel(ctx)
+		respCh := make(chan subResp, 1)
+		req.ctx, req.resp = subCtx, respCh
+		select {
+		case <-ctx.Done():
+			unsubscribe()
+			return nil
+		case room <- req:
+		}
+
+		select {
+		case <-ctx.Done():
+			unsubscribe()
+			return nil
+		case resp := <-respCh:
+			return resubscribedMsg{resp: resp, unsubscribe: unsubscribe}
+		}
+	}
+}
+
+// resubscribed resumes reading the ring after the client fell behind. It is
+// told how many recorded messages it missed, or they are replayed.
+func (m *ClientMain) resubscribed(msg resubscribedMsg) tea.Cmd {
+	m.subscriber, m.unsubscribe = msg.resp.subscriber, msg.unsubscribe
+
+	if m.backpressure == BackpressureDropOldest {
+		if msg.resp.seq > m.lastSeq {
+			m.pending = append(m.pending,
+				GapMsg{After: m.lastSeq, Next: msg.resp.seq + 1},
+				ResumeMsg{ResumeToken(msg.resp.seq)},
+			)
+			m.lastSeq = msg.resp.seq
+		}
+		return m.ReadMsgsCmd()
+	}
+
+	// recorders that can't replay after a sequence number return the newest
+	// messages, those already applied are dropped
+	lastSeq := m.lastSeq
+	initial := slices.DeleteFunc(msg.resp.initialMsgs, func(msg mptymsg.Recordable) bool {
+		seq := mptymsg.SeqOf(msg)
+		return seq != 0 && seq <= lastSeq
+	})
+	initial = m.replayed(initial)
+	resync := m.lastSeq != lastSeq
+	lastSeq = m.lastSeq
+
+	return tea.Sequence(
+		func() tea.Msg {
+			return initial
+		},
+		func() tea.Msg {
+			if !resync {
+				return nil
+			}
+			return ResumeMsg{ResumeToken(lastSeq)}
+		},
+		m.ReadMsgsCmd(),
+	)
+}
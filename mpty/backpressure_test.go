@@ -0,0 +1,152 @@
+package mpty
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ghthor/webtea/mpty/mptymsg"
+	"github.com/golang-cz/ringbuf"
+	"github.com/stretchr/testify/require"
+)
+
+// echoModel broadcasts the testMsgs, like a room broadcasts its chat. ready
+// is closed once it has the ring.
+type echoModel struct {
+	ring  *ringbuf.RingBuffer[tea.Msg]
+	ready chan struct{}
+}
+
+func (m *echoModel) Init() tea.Cmd { return nil }
+func (m *echoModel) View() string  { return "" }
+
+func (m *echoModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case *ringbuf.RingBuffer[tea.Msg]:
+		m.ring = msg
+		close(m.ready)
+	case testMsg:
+		m.ring.Write(msg)
+	}
+	return m, nil
+}
+
+// overrun returns a client of a Program whose ring is 20 messages and lets
+// clients fall at most 2 behind. The client has read the first message.
+func overrun(t *testing.T, b Backpressure) (*ClientMain, *testClient, Program) {
+	t.Helper()
+	echo := &echoModel{ready: make(chan struct{})}
+	p := startProgram(t, echo, mptymsg.NewMemory(100),
+		WithRingSize(20), WithMaxBehind(2), WithBackpressure(b))
+	<-echo.ready
+
+	ctx, unsubscribe := context.WithCancel(t.Context())
+	resp := make(chan subResp, 1)
+	p.Send <- subReq{ctx: ctx, id: "test", resp: resp}
+	sub := <-resp
+
+	c := &testClient{}
+	m := newTestClientMain(c, sub.seq)
+	m.Input, m.ctx, m.backpressure = p.Send, t.Context(), b
+	m.subscriber, m.unsubscribe = sub.subscriber, unsubscribe
+
+	send(t, p, testMsg{Value: "1"})
+	m.Update(m.ReadMsgsCmd()())
+	require.Equal(t, uint64(1), m.lastSeq)
+	c.batches = nil
+	return m, c, p
+}
+
+// sendN sends the testMsgs from to n.
+func sendN(t *testing.T, p Program, from, n int) {
+	t.Helper()
+	for i := from; i <= n; i++ {
+		send(t, p, testMsg{Value: fmt.Sprint(i)})
+	}
+}
+
+// withoutTicks returns the batches without the ticks of Main.
+func withoutTicks(batches [][]tea.Msg) [][]tea.Msg {
+	for i, msgs := range batches {
+		batches[i] = slices.DeleteFunc(msgs, func(msg tea.Msg) bool {
+			_, ok := msg.(time.Time)
+			return ok
+		})
+	}
+	return batches
+}
+
+// sequenced returns the commands of a tea.Sequence.
+func sequenced(t *testing.T, cmd tea.Cmd) []tea.Cmd {
+	t.Helper()
+	v := reflect.ValueOf(cmd())
+	require.Equal(t, reflect.Slice, v.Kind())
+	cmds := make([]tea.Cmd, v.Len())
+	for i := range cmds {
+		cmds[i] = v.Index(i).Interface().(tea.Cmd)
+	}
+	return cmds
+}
+
+func TestBehind(t *testing.T) {
+	for _, b := range []Backpressure{BackpressureDisconnect, BackpressureDropOldest, BackpressureResync} {
+		require.Equal(t, ringbuf.ErrRingBufferClosed, b.behind(ringbuf.ErrRingBufferClosed), "a closed ring ends the client")
+		require.Equal(t, context.Canceled, b.behind(context.Canceled))
+	}
+	err := fmt.Errorf("fell behind: %w", ringbuf.ErrSubscriberTooSlow)
+	require.Equal(t, err, BackpressureDisconnect.behind(err))
+	require.Equal(t, fellBehind{err}, BackpressureDropOldest.behind(err))
+	require.Equal(t, fellBehind{err}, BackpressureResync.behind(err))
+}
+
+func TestBackpressureDisconnect(t *testing.T) {
+	m, c, p := overrun(t, BackpressureDisconnect)
+	sendN(t, p, 2, 10)
+
+	m.Update(m.ReadMsgsCmd()())
+	batches := withoutTicks(c.batches)
+	require.Len(t, batches, 1)
+	require.Len(t, batches[0], 1)
+	err, ok := batches[0][0].(error)
+	require.True(t, ok, "the client receives the error of the ring to end with")
+	require.ErrorIs(t, err, ringbuf.ErrSubscriberTooSlow)
+}
+
+func TestBackpressureDropOldest(t *testing.T) {
+	m, c, p := overrun(t, BackpressureDropOldest)
+	sendN(t, p, 2, 10)
+
+	_, resubscribe := m.Update(m.ReadMsgsCmd()())
+	_, read := m.Update(resubscribe())
+	m.Update(read())
+
+	require.Equal(t, [][]tea.Msg{
+		{},
+		{GapMsg{After: 1, Next: 11}, ResumeMsg{ResumeToken(10)}},
+	}, withoutTicks(c.batches), "the client skips ahead to the newest message")
+	require.Equal(t, uint64(10), m.lastSeq)
+}
+
+func TestBackpressureResync(t *testing.T) {
+	m, c, p := overrun(t, BackpressureResync)
+	sendN(t, p, 2, 10)
+
+	_, resubscribe := m.Update(m.ReadMsgsCmd()())
+	_, cmd := m.Update(resubscribe())
+	require.Equal(t, [][]tea.Msg{{}}, withoutTicks(c.batches))
+
+	// the last command reads the ring again
+	cmds := sequenced(t, cmd)
+	require.Len(t, cmds, 3)
+	var want []mptymsg.Recordable
+	for i := 2; i <= 10; i++ {
+		want = append(want, testMsg{Value: fmt.Sprint(i), id: int64(i), seq: uint64(i)})
+	}
+	require.Equal(t, want, cmds[0](), "the messages missed are replayed")
+	require.Equal(t, ResumeMsg{ResumeToken(10)}, cmds[1]())
+	require.Equal(t, uint64(10), m.lastSeq)
+}
@@ -1,6 +1,8 @@
 package mpty
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"net"
 	"strings"
@@ -9,6 +11,9 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/ssh"
+	"github.com/ghthor/webtea/i18n"
+	"github.com/ghthor/webtea/roles"
+	"github.com/muesli/termenv"
 	"tailscale.com/client/tailscale/apitype"
 )
 
@@ -28,36 +33,191 @@ type ClientInfoModel struct {
 	Height int
 	Time   time.Time
 
-	Sess Session
-	Who  *apitype.WhoIsResponse
+	Sess      Session
+	SessionId string
+	Who       *apitype.WhoIsResponse
+
+	transport string
+
+	// Access is the role and capabilities of Who
+	Access roles.Access
+
+	// Env is the environment sent by ssh clients, e.g. LANG and NO_COLOR
+	Env []string
+
+	// Caps are the probed capabilities of the terminal of ssh clients
+	Caps TermCaps
+	sync *SyncOutput
+
+	// confirmer approves the privileged actions of the client
+	confirmer Confirmer
+
+	// ColorProfile is the colors the terminal supports, the styles of
+	// Renderer degrade to it
+	ColorProfile termenv.Profile
+	renderer     *lipgloss.Renderer
+
+	// Locale is detected from the LANG environment of ssh sessions
+	Locale i18n.Locale
+}
+
+// NewSessionId returns a random id used to tell apart multiple connections
+// from the same identity. Unlike the remote address it carries no meaning
+// about where the connection came from.
+func NewSessionId() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
 }
 
 func NewClientInfoModelFromSsh(pty ssh.Pty, sess Session, who *apitype.WhoIsResponse) *ClientInfoModel {
+	var env []string
+	if s, ok := sess.(interface{ Environ() []string }); ok {
+		env = s.Environ()
+	}
+	var caps TermCaps
+	if s, ok := sess.(interface{ Context() ssh.Context }); ok {
+		caps = TermCapsOf(s.Context())
+	}
+	var syncOut *SyncOutput
+	if caps.SyncOutput {
+		syncOut = &SyncOutput{}
+	}
+	profile := DetectColorProfile(pty.Term, env, caps)
+
 	return &ClientInfoModel{
 		Term:   pty.Term,
 		Width:  pty.Window.Width,
 		Height: pty.Window.Height,
 		Time:   time.Now(),
 
-		Sess: sess,
-		Who:  who,
+		Sess:      sess,
+		SessionId: NewSessionId(),
+		Who:       who,
+		transport: "ssh",
+		Access:    roles.DefaultPolicy().Access(who),
+
+		Env:    env,
+		Caps:   caps,
+		Locale: i18n.Detect(env),
+
+		sync:         syncOut,
+		ColorProfile: profile,
+		renderer:     newRenderer(profile),
 	}
 }
 
-func NewClientInfoModelFromWebtty(sess Session, who *apitype.WhoIsResponse) *ClientInfoModel {
+// ColsParam and RowsParam are the web terminal arguments a browser passes the
+// size of its terminal in, so the first render fits before the terminal is
+// resized. The web terminal only receives arguments with
+// TerminalOptions.PermitArguments.
+const (
+	ColsParam = "cols"
+	RowsParam = "rows"
+)
+
+// NewClientInfoModelFromWebtty is the info of a web terminal of the given
+// size, a zero size is 80x40 until the terminal is resized.
+func NewClientInfoModelFromWebtty(size tea.WindowSizeMsg, sess Session, who *apitype.WhoIsResponse) *ClientInfoModel {
+	if size.Width <= 0 || size.Height <= 0 {
+		size = tea.WindowSizeMsg{Width: 80, Height: 40}
+	}
 	return &ClientInfoModel{
 		Term:   "webtty",
-		Width:  80,
-		Height: 40,
+		Width:  size.Width,
+		Height: size.Height,
 		Time:   time.Now(),
 
-		Sess: sess,
-		Who:  who,
+		Sess:      sess,
+		SessionId: NewSessionId(),
+		Who:       who,
+		transport: "web",
+		Access:    roles.DefaultPolicy().Access(who),
+
+		Locale: i18n.Default,
+
+		// the browser terminal renders every color
+		ColorProfile: termenv.TrueColor,
+		renderer:     newRenderer(termenv.TrueColor),
 	}
 }
 
+// NewClientInfoModelFromRPC is the info of a frontend connected over rpc,
+// which has no terminal.
+func NewClientInfoModelFromRPC(sess Session, who *apitype.WhoIsResponse) *ClientInfoModel {
+	return &ClientInfoModel{
+		Term: "rpc",
+		Time: time.Now(),
+
+		Sess:      sess,
+		SessionId: NewSessionId(),
+		Who:       who,
+		transport: "rpc",
+		Access:    roles.DefaultPolicy().Access(who),
+
+		Locale:       i18n.Default,
+		ColorProfile: termenv.Ascii,
+		renderer:     newRenderer(termenv.Ascii),
+	}
+}
+
+// Identity is the stable identifier of the connected user. Tailscale login
+// names are unique within a tailnet and don't change between connections.
+func (m *ClientInfoModel) Identity() string {
+	return m.Who.UserProfile.LoginName
+}
+
+// Device is the name of the tailnet device of the client, without the
+// tailnet, it is empty when it isn't known.
+func (m *ClientInfoModel) Device() string {
+	if m.Who == nil || m.Who.Node == nil {
+		return ""
+	}
+	device, _, _ := strings.Cut(m.Who.Node.Name, ".")
+	return device
+}
+
+// RemoteAddr is the address the client connected from. It should only be
+// shown to clients that can roles.CanViewAddresses.
+func (m *ClientInfoModel) RemoteAddr() net.Addr {
+	return m.Sess.RemoteAddr()
+}
+
+// Transport is how the client is connected, ssh, web or rpc.
+func (m *ClientInfoModel) Transport() string {
+	return m.transport
+}
+
+// SyncOutput is set when the terminal supports synchronized output, the
+// model turns it on while it redraws rapidly.
+func (m *ClientInfoModel) SyncOutput() *SyncOutput {
+	return m.sync
+}
+
+// Confirmer approves the privileged actions of the client, it is nil when
+// they don't need to be confirmed.
+func (m *ClientInfoModel) Confirmer() Confirmer {
+	return m.confirmer
+}
+
+// SetConfirmer is called by the server of the session when privileged
+// actions must be confirmed.
+func (m *ClientInfoModel) SetConfirmer(c Confirmer) {
+	m.confirmer = c
+}
+
+// Renderer renders styles in the colors of the terminal, see
+// lipgloss.Style.Renderer. A model that wasn't made by one of the
+// constructors uses the default renderer.
+func (m *ClientInfoModel) Renderer() *lipgloss.Renderer {
+	if m.renderer == nil {
+		return lipgloss.DefaultRenderer()
+	}
+	return m.renderer
+}
+
 func (m *ClientInfoModel) Id() ClientId {
-	return ClientId(m.Who.UserProfile.LoginName + " " + m.Sess.RemoteAddr().String())
+	return NewClientId(m.Identity(), m.SessionId)
 }
 
 func (m *ClientInfoModel) Init() tea.Cmd {
@@ -86,10 +246,14 @@ func (m *ClientInfoModel) View() string {
 	b := &m.b
 	b.Reset()
 	fmt.Fprintf(b, "  who: %s\n", m.Who.UserProfile.LoginName)
-	fmt.Fprintf(b, "raddr: %s\n", m.Sess.RemoteAddr().String())
+	if m.Access.Can(roles.CanViewAddresses) {
+		fmt.Fprintf(b, "raddr: %s (%s)\n", m.RemoteAddr(), m.SessionId)
+	} else {
+		fmt.Fprintf(b, " sess: %s\n", m.SessionId)
+	}
 	fmt.Fprintf(b, " term: %s\n", m.Term)
 	fmt.Fprintf(b, " size: (%d,%d)\n", m.Width, m.Height)
-	fmt.Fprintf(b, " time: %s\n", Bold.Render(m.Time.Format(time.RFC1123)))
+	fmt.Fprintf(b, " time: %s\n", Bold.Renderer(m.Renderer()).Render(m.Time.Format(time.RFC1123)))
 
 	return b.String()
 }
@@ -0,0 +1,40 @@
+package mpty
+
+import (
+	"io"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// DetectColorProfile returns the colors supported by the terminal of an ssh
+// client, from its TERM and COLORTERM and the probed caps. NO_COLOR is left
+// to the model, it usually still wants bold and underlined text.
+func DetectColorProfile(term string, env []string, caps TermCaps) termenv.Profile {
+	var colorTerm string
+	for _, kv := range env {
+		if v, ok := strings.CutPrefix(kv, "COLORTERM="); ok {
+			colorTerm = strings.ToLower(v)
+		}
+	}
+
+	switch {
+	case term == "" || term == "dumb":
+		return termenv.Ascii
+	case caps.TrueColor, colorTerm == "truecolor", colorTerm == "24bit",
+		strings.HasSuffix(term, "-direct"),
+		term == "xterm-kitty", term == "wezterm", term == "alacritty", term == "xterm-ghostty":
+		return termenv.TrueColor
+	case strings.Contains(term, "256color"):
+		return termenv.ANSI256
+	}
+	return termenv.ANSI
+}
+
+// newRenderer returns a renderer of styles for a terminal with profile.
+func newRenderer(profile termenv.Profile) *lipgloss.Renderer {
+	r := lipgloss.NewRenderer(io.Discard)
+	r.SetColorProfile(profile)
+	return r
+}
@@ -0,0 +1,15 @@
+package mpty
+
+import "context"
+
+// Confirmer asks the user at the terminal to approve a privileged action, a
+// second factor that a terminal left logged in isn't enough for. Confirm
+// returns nil once the user approved action and may block until they do.
+type Confirmer interface {
+	Confirm(ctx context.Context, action string) error
+}
+
+// ConfirmFunc is a Confirmer function.
+type ConfirmFunc func(ctx context.Context, action string) error
+
+func (f ConfirmFunc) Confirm(ctx context.Context, action string) error { return f(ctx, action) }
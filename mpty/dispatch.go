@@ -0,0 +1,81 @@
+package mpty
+
+import (
+	"reflect"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Dispatcher routes messages to the handlers registered for their type with
+// Handle, so models and plugins add handlers instead of growing a type
+// switch. The handlers of the concrete type of a message run first and then
+// fall through to the handlers of interfaces it implements, e.g. error, even
+// those registered before them. Every matching handler runs, the handlers of
+// the concrete type and those of the interfaces each in the order they were
+// registered.
+type Dispatcher struct {
+	concrete   map[reflect.Type][]func(tea.Msg) tea.Cmd
+	interfaces []interfaceHandler
+
+	// Unhandled is called with messages no handler matched
+	Unhandled func(tea.Msg) tea.Cmd
+}
+
+type interfaceHandler struct {
+	t reflect.Type
+	h func(tea.Msg) tea.Cmd
+}
+
+// Handle registers h for messages of type T. T may be an interface, which
+// matches every message implementing it.
+func Handle[T any](d *Dispatcher, h func(T) tea.Cmd) {
+	t := reflect.TypeFor[T]()
+	fn := func(msg tea.Msg) tea.Cmd {
+		return h(msg.(T))
+	}
+
+	if t.Kind() == reflect.Interface {
+		d.interfaces = append(d.interfaces, interfaceHandler{t, fn})
+		return
+	}
+	if d.concrete == nil {
+		d.concrete = make(map[reflect.Type][]func(tea.Msg) tea.Cmd)
+	}
+	d.concrete[t] = append(d.concrete[t], fn)
+}
+
+// Dispatch runs the handlers of msg.
+func (d *Dispatcher) Dispatch(msg tea.Msg) tea.Cmd {
+	t := reflect.TypeOf(msg)
+	if t == nil {
+		return nil
+	}
+
+	var (
+		cmds    []tea.Cmd
+		handled bool
+	)
+	for _, h := range d.concrete[t] {
+		cmds = append(cmds, h(msg))
+		handled = true
+	}
+	for _, ih := range d.interfaces {
+		if t.Implements(ih.t) {
+			cmds = append(cmds, ih.h(msg))
+			handled = true
+		}
+	}
+	if !handled && d.Unhandled != nil {
+		return d.Unhandled(msg)
+	}
+	return tea.Batch(cmds...)
+}
+
+// DispatchAll dispatches each of msgs in order, e.g. a broadcast batch.
+func (d *Dispatcher) DispatchAll(msgs []tea.Msg) tea.Cmd {
+	cmds := make([]tea.Cmd, 0, len(msgs))
+	for _, msg := range msgs {
+		cmds = append(cmds, d.Dispatch(msg))
+	}
+	return tea.Batch(cmds...)
+}
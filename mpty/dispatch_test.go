@@ -0,0 +1,56 @@
+package mpty
+
+import (
+	"errors"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/require"
+)
+
+type dispatchErr struct{}
+
+func (dispatchErr) Error() string { return "dispatch" }
+
+func TestDispatcher(t *testing.T) {
+	var (
+		d   Dispatcher
+		ran []string
+	)
+	record := func(name string) tea.Cmd {
+		ran = append(ran, name)
+		return nil
+	}
+
+	Handle(&d, func(error) tea.Cmd { return record("error") })
+	Handle(&d, func(dispatchErr) tea.Cmd { return record("dispatchErr 1") })
+	Handle(&d, func(interface{ Error() string }) tea.Cmd { return record("Error()") })
+	Handle(&d, func(dispatchErr) tea.Cmd { return record("dispatchErr 2") })
+	Handle(&d, func(ClientId) tea.Cmd { return record("ClientId") })
+	d.Unhandled = func(msg tea.Msg) tea.Cmd { return record("unhandled") }
+
+	t.Run("concrete then interfaces", func(t *testing.T) {
+		ran = nil
+		d.Dispatch(dispatchErr{})
+		require.Equal(t, []string{"dispatchErr 1", "dispatchErr 2", "error", "Error()"}, ran)
+	})
+
+	t.Run("interfaces only", func(t *testing.T) {
+		ran = nil
+		d.Dispatch(errors.New("other"))
+		require.Equal(t, []string{"error", "Error()"}, ran)
+	})
+
+	t.Run("unhandled", func(t *testing.T) {
+		ran = nil
+		d.Dispatch(ClientConnectMsg("a"))
+		d.Dispatch(nil)
+		require.Equal(t, []string{"unhandled"}, ran)
+	})
+
+	t.Run("all in order", func(t *testing.T) {
+		ran = nil
+		d.DispatchAll([]tea.Msg{ClientId("a"), dispatchErr{}, 1})
+		require.Equal(t, []string{"ClientId", "dispatchErr 1", "dispatchErr 2", "error", "Error()", "unhandled"}, ran)
+	})
+}
@@ -0,0 +1,91 @@
+package mpty
+
+import (
+	"reflect"
+	"slices"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ghthor/webtea/mpty/mptymsg"
+)
+
+// Interest reports if a client wants a broadcast message, see Interested.
+type Interest func(tea.Msg) bool
+
+// Interested is implemented by ClientModels that only want some of the
+// broadcast messages, e.g. a chat that never draws the games. The Interest
+// is read once as the client subscribes, the messages it doesn't want are
+// dropped as they are read off the ring so they never wake the client. The
+// time.Time ticks are always delivered, they are the clock of the client.
+type Interested interface {
+	Interest() Interest
+}
+
+func interest(m ClientModel) Interest {
+	if i, ok := m.(Interested); ok {
+		return i.Interest()
+	}
+	return nil
+}
+
+// Types returns an Interest in the messages of the same type as msgs, e.g.
+// Types(ClientConnectMsg(""), ClientDisconnectMsg("")).
+func Types(msgs ...tea.Msg) Interest {
+	types := make(map[reflect.Type]struct{}, len(msgs))
+	for _, msg := range msgs {
+		types[reflect.TypeOf(msg)] = struct{}{}
+	}
+	return func(msg tea.Msg) bool {
+		_, ok := types[reflect.TypeOf(msg)]
+		return ok
+	}
+}
+
+// skipped stands in for a Sequenced message the client doesn't want, the
+// sequence stage advances past it like it was delivered and it is dropped
+// before the other stages.
+type skipped struct {
+	mptymsg.Sequenced
+}
+
+// want returns msg when the client wants it, a skipped when it is a
+// Sequenced message it doesn't want and nil otherwise.
+func (m *ClientMain) want(msg tea.Msg) tea.Msg {
+	if m.interest == nil {
+		return msg
+	}
+	if _, ok := msg.(time.Time); ok || m.interest(msg) {
+		return msg
+	}
+	if rec, ok := msg.(mptymsg.Sequenced); ok && rec.Seq() > 0 {
+		return skipped{rec}
+	}
+	return nil
+}
+
+// wanted removes the recorded messages the client doesn't want from msgs.
+func (m *ClientMain) wanted(msgs []mptymsg.Recordable) []mptymsg.Recordable {
+	if m.interest == nil {
+		return msgs
+	}
+	return slices.DeleteFunc(msgs, func(msg mptymsg.Recordable) bool {
+		return !m.interest(msg)
+	})
+}
+
+// replayed advances the sequence of the client past the recorded msgs
+// replayed to it, those it doesn't want included, and returns the ones it wants.
+func (m *ClientMain) replayed(msgs []mptymsg.Recordable) []mptymsg.Recordable {
+	for _, msg := range msgs {
+		m.lastSeq = max(m.lastSeq, mptymsg.SeqOf(msg))
+	}
+	return m.wanted(msgs)
+}
+
+// dropSkipped is the stage after the sequence stage, see skipped.
+func dropSkipped(_ ClientModel, msgs []tea.Msg) []tea.Msg {
+	return slices.DeleteFunc(msgs, func(msg tea.Msg) bool {
+		_, ok := msg.(skipped)
+		return ok
+	})
+}
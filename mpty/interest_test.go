@@ -0,0 +1,104 @@
+package mpty
+
+import (
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ghthor/webtea/mpty/mptymsg"
+	"github.com/stretchr/testify/require"
+)
+
+// testClient keeps the batches it was updated with.
+type testClient struct {
+	interest Interest
+	batches  [][]tea.Msg
+}
+
+var _ Interested = &testClient{}
+
+func (m *testClient) Init() tea.Cmd                           { return nil }
+func (m *testClient) Update(msg tea.Msg) (tea.Model, tea.Cmd) { return m.UpdateClient(msg) }
+func (m *testClient) View() string                            { return "" }
+func (m *testClient) Id() ClientId                            { return "test" }
+func (m *testClient) Err() error                              { return nil }
+func (m *testClient) Interest() Interest                      { return m.interest }
+
+func (m *testClient) UpdateClient(msg tea.Msg) (ClientModel, tea.Cmd) {
+	if msgs, ok := msg.([]tea.Msg); ok {
+		m.batches = append(m.batches, msgs)
+	}
+	return m, nil
+}
+
+// newTestClientMain returns the ClientMain of c after the recorded message
+// lastSeq, with only the stages of the sequence.
+func newTestClientMain(c *testClient, lastSeq uint64) *ClientMain {
+	m := &ClientMain{ClientModel: c, interest: interest(c), lastSeq: lastSeq}
+	m.stages = []Stage{m.sequence, dropSkipped}
+	return m
+}
+
+// read passes msgs to m like they were read off the ring.
+func (m *ClientMain) read(msgs ...tea.Msg) {
+	var batch []tea.Msg
+	for _, msg := range msgs {
+		if msg = m.want(msg); msg != nil {
+			batch = append(batch, msg)
+		}
+	}
+	m.Update(batch)
+}
+
+func TestTypes(t *testing.T) {
+	i := Types(ClientConnectMsg(""), testMsg{})
+	require.True(t, i(ClientConnectMsg("a")))
+	require.True(t, i(testMsg{Value: "a", seq: 1}))
+	require.False(t, i(ClientDisconnectMsg("a")))
+	require.False(t, i("a"))
+}
+
+func TestInterest(t *testing.T) {
+	at := time.Now()
+	c := &testClient{interest: Types(ClientConnectMsg(""))}
+	m := newTestClientMain(c, 0)
+
+	m.read(testMsg{Value: "a", seq: 1}, ClientConnectMsg("a"), at, ClientDisconnectMsg("a"))
+	require.Equal(t, [][]tea.Msg{
+		{ClientConnectMsg("a"), at, ResumeMsg{ResumeToken(1)}},
+	}, c.batches, "only the messages wanted and the ticks are delivered")
+}
+
+func TestInterestSequence(t *testing.T) {
+	c := &testClient{interest: func(msg tea.Msg) bool {
+		m, ok := msg.(testMsg)
+		return ok && m.Value != "skip"
+	}}
+	m := newTestClientMain(c, 1)
+
+	m.read(testMsg{Value: "skip", seq: 2}, testMsg{Value: "skip", seq: 3})
+	m.read(testMsg{Value: "a", seq: 4})
+	require.Equal(t, [][]tea.Msg{
+		{ResumeMsg{ResumeToken(3)}},
+		{testMsg{Value: "a", seq: 4}, ResumeMsg{ResumeToken(4)}},
+	}, c.batches, "the sequence advances past the skipped messages without a GapMsg")
+	require.Equal(t, uint64(4), m.lastSeq)
+}
+
+func TestReplayed(t *testing.T) {
+	c := &testClient{interest: Types(ClientConnectMsg(""))}
+	m := newTestClientMain(c, 0)
+
+	msgs := m.replayed([]mptymsg.Recordable{
+		testMsg{Value: "a", seq: 1},
+		testMsg{Value: "b", seq: 2},
+	})
+	require.Empty(t, msgs, "the recorded messages the client doesn't want aren't replayed")
+	require.Equal(t, uint64(2), m.lastSeq, "the sequence advances past them")
+
+	c.interest = nil
+	m = newTestClientMain(c, 0)
+	msgs = m.replayed([]mptymsg.Recordable{testMsg{Value: "a", seq: 1}})
+	require.Equal(t, []mptymsg.Recordable{testMsg{Value: "a", seq: 1}}, msgs)
+	require.Equal(t, uint64(1), m.lastSeq)
+}
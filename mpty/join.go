@@ -0,0 +1,89 @@
+package mpty
+
+import (
+	"context"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+	"github.com/ghthor/webtea/roles"
+)
+
+// PasswordEnv is the ssh environment variable, and PasswordParam the web
+// terminal argument, a client passes the password of a protected room in.
+// Like the resume token, the web terminal needs PermitArguments for it.
+const (
+	PasswordEnv   = "WEBTEA_PASSWORD"
+	PasswordParam = "password"
+)
+
+// JoinReq is a client asking to join the Program, see Admitter.
+type JoinReq struct {
+	Id ClientId
+	// Access is the role of the client, when its model reports one
+	Access   roles.Access
+	Password string
+}
+
+// Admitter is implemented by the models of a Program that restrict who may
+// join, e.g. invite only rooms. Admit is called by the Main program before a
+// client is subscribed, a refused client is shown the error and ended.
+type Admitter interface {
+	Admit(JoinReq) error
+}
+
+// Verifier is implemented by the models of a Program with checks of a
+// JoinReq too slow for the Main program, e.g. comparing a password hash.
+// Verify is called by the Main program and the func it returns, when not
+// nil, runs in a command. A client it fails is refused with the error,
+// otherwise Admit is called once it returns.
+type Verifier interface {
+	Verify(JoinReq) func() error
+}
+
+// verified runs verify and sends req back to the Main program once it
+// passes.
+func verified(req subReq, verify func() error) tea.Cmd {
+	return func() tea.Msg {
+		if err := verify(); err != nil {
+			log.Info("refused", "id", req.id, "error", err)
+			req.resp <- subResp{err: err}
+			return nil
+		}
+		req.verified = true
+		return req
+	}
+}
+
+type passwordKey struct{}
+
+// WithPassword returns a ctx for NewClientProgram that joins with password.
+func WithPassword(ctx context.Context, password string) context.Context {
+	if password == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, passwordKey{}, password)
+}
+
+func passwordOf(ctx context.Context) string {
+	password, _ := ctx.Value(passwordKey{}).(string)
+	return password
+}
+
+func joinReq(ctx context.Context, m ClientModel) JoinReq {
+	req := JoinReq{Id: m.Id(), Password: passwordOf(ctx)}
+	if a, ok := m.(interface{ Access() roles.Access }); ok {
+		req.Access = a.Access()
+	}
+	return req
+}
+
+// refused is the program of a client an Admitter refused, it prints why and
+// exits. Err is why, for clients without a terminal to print to.
+type refused struct {
+	err error
+}
+
+func (m refused) Init() tea.Cmd                       { return tea.Quit }
+func (m refused) Update(tea.Msg) (tea.Model, tea.Cmd) { return m, nil }
+func (m refused) View() string                        { return "refused: " + m.err.Error() + "\n" }
+func (m refused) Err() error                          { return m.err }
@@ -0,0 +1,108 @@
+package mpty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+)
+
+// ErrMainPanic is the error a Program exits with when its model panicked.
+var ErrMainPanic = errors.New("mpty: main panicked")
+
+// mailboxSize bounds the messages waiting for Main, senders block once it is
+// full
+const mailboxSize = 1024
+
+// loop is the event loop of Main. Main never renders or reads a terminal, so
+// unlike a tea.Program the loop only reads the mailbox and calls Update with
+// each message. Commands run in their own goroutine and send their message
+// to the mailbox, a tea.Batch runs each of its commands. tea.Sequence isn't
+// supported, Main has no use for it.
+type loop struct {
+	model   tea.Model
+	mailbox chan tea.Msg
+
+	// cancel ends run, with ErrMainPanic when a command panicked
+	cancel context.CancelCauseFunc
+}
+
+func newLoop(m tea.Model) *loop {
+	return &loop{model: m, mailbox: make(chan tea.Msg, mailboxSize)}
+}
+
+// run calls Init then Update with every message of the mailbox until ctx is
+// done or a command returns tea.Quit. Once it returns Update is never called
+// again, the messages of commands still running are dropped.
+func (l *loop) run(ctx context.Context) (err error) {
+	ctx, l.cancel = context.WithCancelCause(ctx)
+	defer l.cancel(nil)
+	defer func() {
+		if r := recover(); r != nil {
+			err = panicked(r)
+		}
+	}()
+
+	l.exec(ctx, l.model.Init())
+	for {
+		select {
+		case <-ctx.Done():
+			if err := context.Cause(ctx); errors.Is(err, ErrMainPanic) {
+				return err
+			}
+			return nil
+		case msg := <-l.mailbox:
+			switch msg := msg.(type) {
+			case nil:
+			case tea.QuitMsg:
+				return nil
+			case tea.BatchMsg:
+				for _, cmd := range msg {
+					l.exec(ctx, cmd)
+				}
+			default:
+				var cmd tea.Cmd
+				l.model, cmd = l.model.Update(msg)
+				l.exec(ctx, cmd)
+			}
+		}
+	}
+}
+
+// exec runs cmd and sends its message to the mailbox unless ctx is done by
+// then.
+func (l *loop) exec(ctx context.Context, cmd tea.Cmd) {
+	if cmd == nil {
+		return
+	}
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				l.cancel(panicked(r))
+			}
+		}()
+		msg := cmd()
+		if batch, ok := msg.(tea.BatchMsg); ok {
+			for _, cmd := range batch {
+				l.exec(ctx, cmd)
+			}
+			return
+		}
+		if msg == nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+		case l.mailbox <- msg:
+		}
+	}()
+}
+
+// panicked logs the panic r of Main and returns it as an ErrMainPanic.
+func panicked(r any) error {
+	log.Error("main panicked", "panic", r, "stack", string(debug.Stack()))
+	return fmt.Errorf("%w: %v", ErrMainPanic, r)
+}
@@ -0,0 +1,145 @@
+package mpty
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ghthor/webtea/mpty/mptymsg"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+// countModel counts its updates, it may be read while the loop runs.
+type countModel struct {
+	init    tea.Cmd
+	update  func(tea.Msg) tea.Cmd
+	updates atomic.Int64
+}
+
+func (m *countModel) Init() tea.Cmd { return m.init }
+
+func (m *countModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	m.updates.Add(1)
+	if m.update != nil {
+		return m, m.update(msg)
+	}
+	return m, nil
+}
+
+func (m *countModel) View() string { return "" }
+
+func TestLoopQuitDrains(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	grp, grpCtx := errgroup.WithContext(ctx)
+	m := &testModel{}
+	p, err := NewProgram(grpCtx, cancel, m, mptymsg.NewMemory(10))
+	require.NoError(t, err)
+	require.NoError(t, p.StartIn(ctx, grp))
+
+	for i := range 100 {
+		p.Send <- i
+	}
+	p.Quit()
+	require.NoError(t, grp.Wait())
+
+	var got []tea.Msg
+	for _, msg := range m.received() {
+		if i, ok := msg.(int); ok {
+			got = append(got, i)
+		}
+	}
+	require.Len(t, got, 100, "every message sent before Quit is handled")
+	require.Equal(t, 99, got[99])
+}
+
+func TestLoopPanic(t *testing.T) {
+	t.Run("update", func(t *testing.T) {
+		l := newLoop(&countModel{update: func(tea.Msg) tea.Cmd {
+			panic("update")
+		}})
+		l.mailbox <- "boom"
+		require.ErrorIs(t, l.run(t.Context()), ErrMainPanic)
+	})
+
+	t.Run("command", func(t *testing.T) {
+		l := newLoop(&countModel{init: func() tea.Msg {
+			panic("command")
+		}})
+		require.ErrorIs(t, l.run(t.Context()), ErrMainPanic)
+	})
+}
+
+func TestLoopBatch(t *testing.T) {
+	got := make(chan tea.Msg, 3)
+	m := &countModel{
+		init: tea.Batch(
+			func() tea.Msg { return 1 },
+			tea.Batch(
+				func() tea.Msg { return 2 },
+				func() tea.Msg { return 3 },
+			),
+		),
+		update: func(msg tea.Msg) tea.Cmd {
+			got <- msg
+			return nil
+		},
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	l := newLoop(m)
+	done := make(chan error, 1)
+	go func() { done <- l.run(ctx) }()
+
+	var msgs []tea.Msg
+	for range 3 {
+		select {
+		case msg := <-got:
+			msgs = append(msgs, msg)
+		case <-time.After(time.Second):
+			t.Fatalf("only %v of the batch were handled", msgs)
+		}
+	}
+	require.ElementsMatch(t, []tea.Msg{1, 2, 3}, msgs, "each command of a batch is run, nested batches too")
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestLoopNoUpdateAfterRun(t *testing.T) {
+	release := make(chan struct{})
+	m := &countModel{init: func() tea.Msg {
+		<-release
+		return "late"
+	}}
+	l := newLoop(m)
+	l.mailbox <- tea.QuitMsg{}
+	require.NoError(t, l.run(t.Context()))
+
+	close(release)
+	time.Sleep(10 * time.Millisecond)
+	require.Zero(t, m.updates.Load(), "the message of a command still running is dropped")
+}
+
+func BenchmarkProgramSend(b *testing.B) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	grp, grpCtx := errgroup.WithContext(ctx)
+	m := &countModel{}
+	p, err := NewProgram(grpCtx, cancel, m, mptymsg.NewMemory(10))
+	require.NoError(b, err)
+	require.NoError(b, p.StartIn(ctx, grp))
+
+	b.ResetTimer()
+	for i := range b.N {
+		p.Send <- i
+	}
+	p.Quit()
+	require.NoError(b, grp.Wait())
+	b.StopTimer()
+
+	require.GreaterOrEqual(b, m.updates.Load(), int64(b.N))
+	cancel(nil)
+}
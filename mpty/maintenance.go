@@ -0,0 +1,18 @@
+package mpty
+
+import "time"
+
+type (
+	// SessionExpiringMsg is sent to a client program ahead of its session
+	// reaching the maximum duration.
+	SessionExpiringMsg struct {
+		At time.Time
+	}
+
+	// MaintenanceMsg is broadcast to every client ahead of a scheduled
+	// shutdown of the server.
+	MaintenanceMsg struct {
+		At     time.Time
+		Reason string
+	}
+)
@@ -0,0 +1,104 @@
+package mpty
+
+import (
+	"runtime/metrics"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	// memoryCheckInterval is how often Main compares the memory in use to
+	// the budget
+	memoryCheckInterval = 5 * time.Second
+	// memoryMaxLevel is the most times history is shed, further pressure is
+	// only logged
+	memoryMaxLevel = 8
+	// minBehind is the smallest lookback the broadcast ring is shrunk to,
+	// unless a tenth of the ring is more
+	minBehind = 100
+)
+
+// WithMemoryBudget sheds history whenever the memory held by the Go runtime,
+// an estimate of the resident size of the process, exceeds budget bytes.
+// Every time it's exceeded the lookback of new broadcast subscribers is
+// halved, a MemoryPressureMsg is broadcast so clients shrink the history they
+// keep, and a warning is logged for the operators. The broadcast ring itself
+// keeps its size. A budget of 0 disables it.
+func WithMemoryBudget(budget uint64) Option {
+	return func(o *options) {
+		o.memoryBudget = budget
+	}
+}
+
+// MemoryPressureMsg is broadcast every time the process exceeds its memory
+// budget, see WithMemoryBudget. Level counts how many times it has, the
+// higher it is the less history clients should keep, see Keep.
+type MemoryPressureMsg struct {
+	At           time.Time
+	Used, Budget uint64
+	Level        int
+}
+
+// Keep returns how many of n items a client should keep, n is halved for
+// every level down to at least floor.
+func (msg MemoryPressureMsg) Keep(n, floor int) int {
+	for range msg.Level {
+		if n/2 < floor {
+			return min(n, floor)
+		}
+		n /= 2
+	}
+	return n
+}
+
+// memoryInUse returns the memory mapped by the Go runtime that hasn't been
+// returned to the OS.
+func memoryInUse() uint64 {
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	metrics.Read(samples)
+	for _, s := range samples {
+		if s.Value.Kind() != metrics.KindUint64 {
+			return 0
+		}
+	}
+	return samples[0].Value.Uint64() - samples[1].Value.Uint64()
+}
+
+// checkMemory sheds history if the budget is exceeded, at most every
+// memoryCheckInterval.
+func (m *Main) checkMemory(now time.Time) {
+	if m.memoryBudget == 0 || now.Sub(m.memoryCheckedAt) < memoryCheckInterval {
+		return
+	}
+	m.memoryCheckedAt = now
+
+	used := memoryInUse()
+	if used <= m.memoryBudget {
+		return
+	}
+	if m.pressure.Load() >= memoryMaxLevel {
+		log.Debug("memory budget exceeded", "used", used, "budget", m.memoryBudget)
+		return
+	}
+
+	level := int(m.pressure.Add(1))
+	// the ring refuses subscribers that may fall less than a tenth of its
+	// size behind
+	floor := max(min(minBehind, m.maxBehind), int(m.broadcaster.Size()/10))
+	m.maxBehind = max(floor, m.maxBehind/2)
+	m.startBehind = min(m.startBehind, m.maxBehind)
+	log.Warn("memory budget exceeded, shedding history",
+		"used", used, "budget", m.memoryBudget,
+		"level", level, "max_behind", m.maxBehind,
+	)
+	m.broadcaster.Write(MemoryPressureMsg{
+		At:     now,
+		Used:   used,
+		Budget: m.memoryBudget,
+		Level:  level,
+	})
+}
@@ -0,0 +1,30 @@
+package mpty
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ghthor/webtea/mpty/mptymsg"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryPressureSubscribe(t *testing.T) {
+	p := startProgram(t, &testModel{}, mptymsg.NewMemory(10), WithMemoryBudget(1))
+
+	now := time.Now()
+	for range memoryMaxLevel + 1 {
+		now = now.Add(memoryCheckInterval)
+		send(t, p, now)
+	}
+	require.EqualValues(t, memoryMaxLevel, p.pressure.Load())
+
+	resp := make(chan subResp, 1)
+	p.Send <- subReq{ctx: t.Context(), id: "a", resp: resp}
+	select {
+	case r := <-resp:
+		require.NoError(t, r.err)
+		require.NotNil(t, r.subscriber)
+	case <-time.After(time.Second):
+		t.Fatal("not subscribed at the highest memory pressure")
+	}
+}
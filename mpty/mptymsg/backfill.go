@@ -0,0 +1,145 @@
+//go:build !nosqlite
+
+package mptymsg
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Report lists the integrity issues of the recorded messages.
+type Report struct {
+	// Messages is the number of recorded messages
+	Messages int
+	// OutOfOrder is the number of messages with a sequence number, or a
+	// timestamp when neither has one, before the message saved ahead of them
+	OutOfOrder int
+	// Undecodable are messages that can't be decoded, usually because their
+	// type isn't registered
+	Undecodable []Issue
+	// Renumbered is set when Repair has put the ids in order
+	Renumbered bool
+}
+
+// Issue is a problem with the message Id.
+type Issue struct {
+	Id  int64
+	Err string
+}
+
+type row struct {
+	id  int64
+	seq uint64
+	ts  time.Time
+	msg string
+}
+
+// compare orders rows by seq, messages recorded before sequence numbers have
+// a 0 seq and are ordered by timestamp.
+func (rw row) compare(o row) int {
+	return cmp.Or(cmp.Compare(rw.seq, o.seq), rw.ts.Compare(o.ts), cmp.Compare(rw.id, o.id))
+}
+
+func (r *SqliteRecorder) rows() ([]row, error) {
+	rows, err := r.db.QueryContext(r.ctx, `SELECT id, seq, ts, msg FROM msgs ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("msgs query error: %w", err)
+	}
+	defer rows.Close()
+
+	var all []row
+	for rows.Next() {
+		var rw row
+		if err := rows.Scan(&rw.id, &rw.seq, &rw.ts, &rw.msg); err != nil {
+			return nil, fmt.Errorf("rows scan error: %w", err)
+		}
+		all = append(all, rw)
+	}
+	return all, rows.Err()
+}
+
+func check(rows []row) Report {
+	rep := Report{Messages: len(rows)}
+	for i, rw := range rows {
+		if i > 0 && rw.compare(rows[i-1]) < 0 {
+			rep.OutOfOrder++
+		}
+		if _, err := JsonUnmarshal([]byte(rw.msg)); err != nil {
+			rep.Undecodable = append(rep.Undecodable, Issue{rw.id, err.Error()})
+		}
+	}
+	return rep
+}
+
+// Check scans every recorded message and reports the issues found without
+// modifying anything.
+func (r *SqliteRecorder) Check() (Report, error) {
+	rows, err := r.rows()
+	if err != nil {
+		return Report{}, err
+	}
+	return check(rows), nil
+}
+
+// Repair renumbers the messages so their ids are in sequence order, since
+// Read orders messages by sequence but projections replay them by id.
+// Undecodable messages are reported but kept. The checkpoints of every
+// projection are deleted because the ids they refer to change, they are
+// rebuilt by the next Project or Rebuild, so Repair must be called before
+// any projection is registered.
+func (r *SqliteRecorder) Repair() (Report, error) {
+	if len(r.projections) > 0 {
+		return Report{}, errors.New("repair: projections are already registered")
+	}
+
+	rows, err := r.rows()
+	if err != nil {
+		return Report{}, err
+	}
+	rep := check(rows)
+	if rep.OutOfOrder == 0 {
+		return rep, nil
+	}
+
+	slices.SortStableFunc(rows, row.compare)
+
+	tx, err := r.db.BeginTx(r.ctx, nil)
+	if err != nil {
+		return rep, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(r.ctx, `DELETE FROM msgs`); err != nil {
+		return rep, fmt.Errorf("error clearing messages: %w", err)
+	}
+	for i, rw := range rows {
+		_, err := tx.ExecContext(r.ctx, `INSERT INTO msgs(id, seq, ts, msg) VALUES (?, ?, ?, ?)`, i+1, rw.seq, rw.ts, rw.msg)
+		if err != nil {
+			return rep, fmt.Errorf("error renumbering message %d: %w", rw.id, err)
+		}
+	}
+	if _, err := tx.ExecContext(r.ctx, `DELETE FROM kv WHERE bucket = ?`, projectionBucket); err != nil {
+		return rep, fmt.Errorf("error deleting projection checkpoints: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return rep, err
+	}
+
+	rep.Renumbered = true
+	return rep, nil
+}
+
+// Rebuild discards the checkpoints of ps and replays the whole history
+// through them. The projections must be new, they are registered like with
+// Project.
+func (r *SqliteRecorder) Rebuild(ps ...Projection) error {
+	for _, p := range ps {
+		if err := r.Delete(projectionBucket, p.Name()); err != nil {
+			return err
+		}
+	}
+	return r.Project(ps...)
+}
@@ -0,0 +1,76 @@
+package mptymsg
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Projection folds the recorded messages, in the order they were saved, into
+// a materialized view that can be queried without scanning the history.
+// Projections are registered with a recorder which applies every message as
+// it is saved and checkpoints the state so only the messages saved after the
+// checkpoint are replayed on startup.
+type Projection interface {
+	// Name identifies the checkpoint of the projection, it must be unique
+	Name() string
+
+	// Apply folds msg into the state. It must be deterministic since
+	// messages after the last checkpoint are applied again on startup.
+	Apply(msg Recordable)
+
+	MarshalState() ([]byte, error)
+	UnmarshalState([]byte) error
+}
+
+// Reducer folds msg into state and returns the new state.
+type Reducer[S any] func(state S, msg Recordable) S
+
+// Table is a Projection of a json encodable state S maintained by a Reducer.
+type Table[S any] struct {
+	name   string
+	reduce Reducer[S]
+
+	mu    sync.RWMutex
+	state S
+}
+
+var _ Projection = &Table[struct{}]{}
+
+// NewTable returns a projection starting from the state init.
+func NewTable[S any](name string, init S, reduce Reducer[S]) *Table[S] {
+	return &Table[S]{
+		name:   name,
+		reduce: reduce,
+		state:  init,
+	}
+}
+
+func (t *Table[S]) Name() string {
+	return t.name
+}
+
+func (t *Table[S]) Apply(msg Recordable) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.state = t.reduce(t.state, msg)
+}
+
+// Read calls fn with the current state, which must not be modified or kept
+// after fn returns.
+func (t *Table[S]) Read(fn func(S)) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	fn(t.state)
+}
+
+func (t *Table[S]) MarshalState() ([]byte, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return json.Marshal(t.state)
+}
+
+func (t *Table[S]) UnmarshalState(b []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return json.Unmarshal(b, &t.state)
+}
@@ -0,0 +1,115 @@
+//go:build !nosqlite
+
+package mptymsg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	projectionBucket = "mptymsg.projections"
+
+	// checkpointEvery is how many messages are applied to a projection
+	// between checkpoints
+	checkpointEvery = 100
+)
+
+type projected struct {
+	Projection
+
+	// pos is the id of the last message applied
+	pos     int64
+	applied int
+}
+
+type checkpoint struct {
+	Pos   int64
+	State json.RawMessage
+}
+
+// Project registers projections that are updated by every saved message. Each
+// projection is loaded from its checkpoint and caught up with the messages
+// saved since. Project must be called before any message is saved.
+func (r *SqliteRecorder) Project(ps ...Projection) error {
+	for _, p := range ps {
+		proj := &projected{Projection: p}
+
+		var c checkpoint
+		found, err := r.Get(projectionBucket, p.Name(), &c)
+		if err != nil {
+			return err
+		}
+		if found {
+			if err := p.UnmarshalState(c.State); err != nil {
+				return fmt.Errorf("projection %s: error loading checkpoint: %w", p.Name(), err)
+			}
+			proj.pos = c.Pos
+		}
+
+		if err := r.replay(proj); err != nil {
+			return fmt.Errorf("projection %s: %w", p.Name(), err)
+		}
+		r.projections = append(r.projections, proj)
+	}
+	return r.checkpoint(r.ctx)
+}
+
+// replay applies the messages saved after the position of p. Messages of
+// types that aren't registered are skipped.
+func (r *SqliteRecorder) replay(p *projected) error {
+	rows, err := r.db.QueryContext(r.ctx, `SELECT id, msg FROM msgs WHERE id > ? ORDER BY id`, p.pos)
+	if err != nil {
+		return fmt.Errorf("msgs query error: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id     int64
+			rawMsg string
+		)
+		if err := rows.Scan(&id, &rawMsg); err != nil {
+			return fmt.Errorf("rows scan error: %w", err)
+		}
+		p.pos = id
+
+		msg, err := JsonUnmarshal([]byte(rawMsg))
+		if err != nil {
+			continue
+		}
+		p.Apply(msg.SetId(id))
+	}
+	return rows.Err()
+}
+
+func (r *SqliteRecorder) project(id int64, msg Recordable) {
+	for _, p := range r.projections {
+		p.Apply(msg)
+		p.pos = id
+		p.applied++
+		if p.applied%checkpointEvery == 0 {
+			// a failed checkpoint only means more to replay on startup
+			r.save(r.ctx, p)
+		}
+	}
+}
+
+// checkpoint saves the state of every projection
+func (r *SqliteRecorder) checkpoint(ctx context.Context) error {
+	var errs []error
+	for _, p := range r.projections {
+		errs = append(errs, r.save(ctx, p))
+	}
+	return errors.Join(errs...)
+}
+
+func (r *SqliteRecorder) save(ctx context.Context, p *projected) error {
+	state, err := p.MarshalState()
+	if err != nil {
+		return fmt.Errorf("projection %s: error encoding state: %w", p.Name(), err)
+	}
+	return r.put(ctx, projectionBucket, p.Name(), checkpoint{Pos: p.pos, State: state})
+}
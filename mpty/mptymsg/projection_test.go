@@ -0,0 +1,125 @@
+//go:build !nosqlite
+
+package mptymsg
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func countValues(state map[string]int, msg Recordable) map[string]int {
+	if m, ok := msg.(exampleMsg); ok {
+		state[m.Value]++
+	}
+	return state
+}
+
+func TestProjection(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "msgs.db")
+
+	r, err := NewSqlite(ctx, path)
+	require.NoError(t, err)
+
+	// saved before the projection exists and replayed by Project
+	_, err = r.Save(exampleMsg{At: time.Now(), Value: "a"})
+	require.NoError(t, err)
+
+	counts := NewTable("counts", map[string]int{}, countValues)
+	require.NoError(t, r.Project(counts))
+
+	_, err = r.Save(exampleMsg{At: time.Now(), Value: "a"})
+	require.NoError(t, err)
+	_, err = r.Save(exampleMsg{At: time.Now(), Value: "b"})
+	require.NoError(t, err)
+
+	counts.Read(func(s map[string]int) {
+		require.Equal(t, map[string]int{"a": 2, "b": 1}, s)
+	})
+	require.NoError(t, r.Close())
+
+	// reopening loads the checkpoint instead of replaying every message
+	r, err = NewSqlite(ctx, path)
+	require.NoError(t, err)
+	defer r.Close()
+
+	counts = NewTable("counts", map[string]int{}, countValues)
+	require.NoError(t, r.Project(counts))
+	_, err = r.Save(exampleMsg{At: time.Now(), Value: "b"})
+	require.NoError(t, err)
+
+	counts.Read(func(s map[string]int) {
+		require.Equal(t, map[string]int{"a": 2, "b": 2}, s)
+	})
+}
+
+func TestCloseCheckpoint(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	path := filepath.Join(t.TempDir(), "msgs.db")
+
+	r, err := NewSqlite(ctx, path)
+	require.NoError(t, err)
+	counts := NewTable("counts", map[string]int{}, countValues)
+	require.NoError(t, r.Project(counts))
+	_, err = r.Save(exampleMsg{At: time.Now(), Value: "a"})
+	require.NoError(t, err)
+
+	// the recorder is closed after the context of the process is cancelled
+	cancel()
+	require.NoError(t, r.Close())
+
+	r, err = NewSqlite(context.Background(), path)
+	require.NoError(t, err)
+	defer r.Close()
+
+	var c checkpoint
+	found, err := r.Get(projectionBucket, "counts", &c)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, int64(1), c.Pos)
+}
+
+func TestRepair(t *testing.T) {
+	ctx := context.Background()
+	r, err := NewSqlite(ctx, filepath.Join(t.TempDir(), "msgs.db"))
+	require.NoError(t, err)
+	defer r.Close()
+
+	now := time.Now()
+	for _, msg := range []exampleMsg{
+		{At: now, Value: "second"},
+		{At: now.Add(-time.Minute), Value: "first"},
+		{At: now.Add(time.Minute), Value: "third"},
+	} {
+		_, err = r.Save(msg)
+		require.NoError(t, err)
+	}
+	_, err = r.db.Exec(`INSERT INTO msgs(ts, msg) VALUES (?, ?)`, now, `{"Type":"unknown","Payload":{}}`)
+	require.NoError(t, err)
+
+	rep, err := r.Check()
+	require.NoError(t, err)
+	require.Equal(t, 4, rep.Messages)
+	require.Equal(t, 2, rep.OutOfOrder)
+	require.Len(t, rep.Undecodable, 1)
+
+	rep, err = r.Repair()
+	require.NoError(t, err)
+	require.True(t, rep.Renumbered)
+
+	rep, err = r.Check()
+	require.NoError(t, err)
+	require.Zero(t, rep.OutOfOrder)
+
+	order := NewTable("order", []string{}, func(s []string, msg Recordable) []string {
+		return append(s, msg.(exampleMsg).Value)
+	})
+	require.NoError(t, r.Rebuild(order))
+	order.Read(func(s []string) {
+		require.Equal(t, []string{"first", "second", "third"}, s)
+	})
+}
@@ -1,3 +1,7 @@
+// Package mptymsg records the messages of a mpty Program. The SqliteRecorder
+// keeps them in a sqlite database, builds with the nosqlite tag leave it, and
+// modernc.org/sqlite, out for a smaller binary, e.g. for ARM targets, and can
+// use the MemoryRecorder.
 package mptymsg
 
 import (
@@ -18,6 +22,18 @@ type Recordable interface {
 	SetId(int64) Recordable
 }
 
+// Sequenced is implemented by Recordable messages that carry the sequence
+// number assigned by the mpty Program as they are broadcast. Sequence numbers
+// totally order the messages, unlike timestamps which may be equal or go
+// backwards with the wall clock, and let subscribers detect messages they
+// missed.
+type Sequenced interface {
+	Recordable
+
+	Seq() uint64
+	SetSeq(uint64) Recordable
+}
+
 var decoders = make(map[string]func(data []byte) (Recordable, error))
 
 func Register[T Recordable](t T) {
@@ -35,11 +51,13 @@ func Register[T Recordable](t T) {
 
 type Envelope struct {
 	Type    string
+	Seq     uint64 `json:",omitempty"`
 	Payload json.RawMessage
 }
 
 type EnvelopeEncode struct {
 	Type    string
+	Seq     uint64 `json:",omitempty"`
 	Payload any
 }
 
@@ -48,10 +66,20 @@ type EnvelopeEncode struct {
 func JsonMarshal[T Recordable](t T) ([]byte, error) {
 	return json.Marshal(EnvelopeEncode{
 		Type:    t.TypeName(),
+		Seq:     SeqOf(t),
 		Payload: t,
 	})
 }
 
+// SeqOf returns the sequence number of msg, 0 when it isn't Sequenced or
+// hasn't been assigned one.
+func SeqOf(msg Recordable) uint64 {
+	if s, ok := msg.(Sequenced); ok {
+		return s.Seq()
+	}
+	return 0
+}
+
 // JsonUnmarshal will decode a Recordable message from json bytes. You must
 // Register(t) any types at least once before attempting to decode them.
 func JsonUnmarshal(data []byte) (Recordable, error) {
@@ -66,5 +94,12 @@ func JsonUnmarshal(data []byte) (Recordable, error) {
 		return nil, fmt.Errorf("unregistered mptymsg type: %s", e.Type)
 	}
 
-	return d(e.Payload)
+	msg, err := d(e.Payload)
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := msg.(Sequenced); ok && e.Seq > 0 {
+		msg = s.SetSeq(e.Seq)
+	}
+	return msg, nil
 }
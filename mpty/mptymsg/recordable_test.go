@@ -2,6 +2,7 @@ package mptymsg
 
 import (
 	"fmt"
+	"strconv"
 	"testing"
 	"time"
 
@@ -11,9 +12,11 @@ import (
 type exampleMsg struct {
 	At    time.Time
 	Value string
+
+	seq uint64
 }
 
-var _ Recordable = exampleMsg{}
+var _ Sequenced = exampleMsg{}
 
 func (m exampleMsg) TypeName() string {
 	return fmt.Sprintf("%T", m)
@@ -27,6 +30,15 @@ func (m exampleMsg) SetId(int64) Recordable {
 	return m
 }
 
+func (m exampleMsg) Seq() uint64 {
+	return m.seq
+}
+
+func (m exampleMsg) SetSeq(seq uint64) Recordable {
+	m.seq = seq
+	return m
+}
+
 func init() {
 	Register(exampleMsg{})
 }
@@ -50,3 +62,78 @@ func TestRecordable(t *testing.T) {
 	)
 	require.Equal(t, "testing", gotT.Value)
 }
+
+func TestSequence(t *testing.T) {
+	data, err := JsonMarshal(exampleMsg{At: time.Unix(1, 0), Value: "testing", seq: 7})
+	require.NoError(t, err)
+	got, err := JsonUnmarshal(data)
+	require.NoError(t, err)
+	require.Equal(t, uint64(7), SeqOf(got))
+}
+
+// testRecorder checks the ordering of the messages recorded by r.
+func testRecorder(t *testing.T, r interface {
+	Save(Recordable) (Recordable, error)
+	Read(int) ([]Recordable, error)
+	ReadAfter(uint64, int) ([]Recordable, error)
+	LastSeq() (uint64, error)
+	Prune(time.Time) (int64, error)
+}) {
+	// the wall clock went backwards between the messages
+	now := time.Now()
+	for _, msg := range []exampleMsg{
+		{At: now, Value: "first", seq: 1},
+		{At: now.Add(-time.Minute), Value: "second", seq: 2},
+		{At: now.Add(-time.Minute), Value: "third", seq: 3},
+	} {
+		_, err := r.Save(msg)
+		require.NoError(t, err)
+	}
+
+	msgs, err := r.Read(2)
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	require.Equal(t, "second", msgs[0].(exampleMsg).Value)
+	require.Equal(t, "third", msgs[1].(exampleMsg).Value)
+
+	seq, err := r.LastSeq()
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), seq)
+
+	// a client resuming after the first message is replayed the rest
+	msgs, err = r.ReadAfter(1, 10)
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	require.Equal(t, uint64(2), SeqOf(msgs[0]))
+	require.Equal(t, uint64(3), SeqOf(msgs[1]))
+
+	pruned, err := r.Prune(now.Add(-time.Second))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), pruned)
+
+	msgs, err = r.Read(10)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, "first", msgs[0].(exampleMsg).Value)
+}
+
+func TestMemoryRecorder(t *testing.T) {
+	testRecorder(t, NewMemory(10))
+
+	r := NewMemory(2)
+	for i := range 3 {
+		_, err := r.Save(exampleMsg{Value: strconv.Itoa(i), seq: uint64(i + 1)})
+		require.NoError(t, err)
+	}
+	msgs, err := r.Read(10)
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	require.Equal(t, "1", msgs[0].(exampleMsg).Value)
+
+	var v string
+	require.NoError(t, r.Put("b", "k", "v"))
+	ok, err := r.Get("b", "k", &v)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "v", v)
+}
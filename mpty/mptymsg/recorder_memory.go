@@ -0,0 +1,135 @@
+package mptymsg
+
+import (
+	"encoding/json"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MemoryRecorder keeps the most recent messages, and the values of the
+// Store, in memory. Nothing outlives the process. Unlike the SqliteRecorder
+// it is part of builds with the nosqlite tag.
+type MemoryRecorder struct {
+	mu   sync.Mutex
+	size int
+	// msgs are the messages in the order they were saved
+	msgs    []Recordable
+	lastId  int64
+	lastSeq uint64
+
+	kv map[string]map[string]json.RawMessage
+}
+
+// NewMemory returns a recorder keeping the most recent size messages.
+func NewMemory(size int) *MemoryRecorder {
+	return &MemoryRecorder{
+		size: size,
+		msgs: make([]Recordable, 0, size),
+		kv:   make(map[string]map[string]json.RawMessage),
+	}
+}
+
+// Backend names the recorder for server metadata.
+func (r *MemoryRecorder) Backend() string { return "memory" }
+
+func (r *MemoryRecorder) Save(msg Recordable) (Recordable, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastId++
+	msg = msg.SetId(r.lastId)
+	r.lastSeq = max(r.lastSeq, SeqOf(msg))
+	if len(r.msgs) == r.size {
+		r.msgs = slices.Delete(r.msgs, 0, 1)
+	}
+	if r.size > 0 {
+		r.msgs = append(r.msgs, msg)
+	}
+	return msg, nil
+}
+
+// Read returns the newest n messages, oldest first.
+func (r *MemoryRecorder) Read(n int) ([]Recordable, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.msgs[max(0, len(r.msgs)-n):]), nil
+}
+
+// ReadAfter returns up to n messages with a sequence number after seq, oldest
+// first, to replay the messages a resuming client missed.
+func (r *MemoryRecorder) ReadAfter(seq uint64, n int) ([]Recordable, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var msgs []Recordable
+	for _, msg := range r.msgs {
+		if len(msgs) == n {
+			break
+		}
+		if SeqOf(msg) > seq {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs, nil
+}
+
+// LastSeq returns the highest sequence number recorded.
+func (r *MemoryRecorder) LastSeq() (uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lastSeq, nil
+}
+
+// Prune deletes the messages recorded before t.
+func (r *MemoryRecorder) Prune(before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := len(r.msgs)
+	r.msgs = slices.DeleteFunc(r.msgs, func(msg Recordable) bool {
+		return msg.Ts().Before(before)
+	})
+	return int64(n - len(r.msgs)), nil
+}
+
+var _ Store = &MemoryRecorder{}
+
+func (r *MemoryRecorder) Put(bucket, key string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.kv[bucket] == nil {
+		r.kv[bucket] = make(map[string]json.RawMessage)
+	}
+	r.kv[bucket][key] = b
+	return nil
+}
+
+func (r *MemoryRecorder) Get(bucket, key string, v any) (bool, error) {
+	r.mu.Lock()
+	b, ok := r.kv[bucket][key]
+	r.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(b, v)
+}
+
+func (r *MemoryRecorder) Delete(bucket, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.kv[bucket], key)
+	return nil
+}
+
+func (r *MemoryRecorder) List(bucket string) (map[string]json.RawMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	values := make(map[string]json.RawMessage, len(r.kv[bucket]))
+	for key, b := range r.kv[bucket] {
+		values[key] = b
+	}
+	return values, nil
+}
@@ -1,8 +1,12 @@
+//go:build !nosqlite
+
 package mptymsg
 
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"slices"
 	"time"
@@ -13,6 +17,8 @@ import (
 type SqliteRecorder struct {
 	ctx context.Context
 	db  *sql.DB
+
+	projections []*projected
 }
 
 func NewSqlite(ctx context.Context, filename string) (*SqliteRecorder, error) {
@@ -32,14 +38,55 @@ func NewSqlite(ctx context.Context, filename string) (*SqliteRecorder, error) {
 		return nil, fmt.Errorf("error initializing sqlite table: %w", err)
 	}
 
+	// seq was added after the table, messages recorded before have a 0 seq
+	// and are ordered by id
+	var hasSeq bool
+	err = db.QueryRow(`SELECT COUNT(*) > 0 FROM pragma_table_info('msgs') WHERE name = 'seq'`).Scan(&hasSeq)
+	if err != nil {
+		return nil, fmt.Errorf("error inspecting sqlite table: %w", err)
+	}
+	if !hasSeq {
+		_, err = db.Exec(`ALTER TABLE msgs ADD COLUMN seq INTEGER NOT NULL DEFAULT 0`)
+		if err != nil {
+			return nil, fmt.Errorf("error adding seq column: %w", err)
+		}
+	}
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS msgs_seq ON msgs(seq)`)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing seq index: %w", err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS kv (
+			bucket TEXT NOT NULL,
+			key TEXT NOT NULL,
+			value JSON NOT NULL CHECK (json_valid(value)),
+			PRIMARY KEY (bucket, key)
+		);
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing sqlite kv table: %w", err)
+	}
+
 	return &SqliteRecorder{
 		ctx: ctx,
 		db:  db,
 	}, nil
 }
 
+// Backend names the recorder for server metadata.
+func (r *SqliteRecorder) Backend() string { return "sqlite" }
+
+// closeTimeout bounds the final checkpoint written by Close
+const closeTimeout = 5 * time.Second
+
+// Close checkpoints the projections and closes the database. The checkpoint
+// isn't bound to the context of the recorder since that is usually already
+// done when the recorder is closed.
 func (r *SqliteRecorder) Close() error {
-	return r.db.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	return errors.Join(r.checkpoint(ctx), r.db.Close())
 }
 
 func (r *SqliteRecorder) Save(msg Recordable) (Recordable, error) {
@@ -53,7 +100,7 @@ func (r *SqliteRecorder) Save(msg Recordable) (Recordable, error) {
 		ts = time.Now()
 	}
 
-	res, err := r.db.ExecContext(r.ctx, `INSERT INTO msgs(ts, msg) VALUES (?, ?)`, ts, string(b))
+	res, err := r.db.ExecContext(r.ctx, `INSERT INTO msgs(ts, seq, msg) VALUES (?, ?, ?)`, ts, SeqOf(msg), string(b))
 	if err != nil {
 		return nil, fmt.Errorf("error saving message: %w", err)
 	}
@@ -62,20 +109,36 @@ func (r *SqliteRecorder) Save(msg Recordable) (Recordable, error) {
 		return nil, fmt.Errorf("error reading last insert id: %w", err)
 	}
 
-	return msg.SetId(id), nil
+	msg = msg.SetId(id)
+	r.project(id, msg)
+	return msg, nil
 }
 
 func (r *SqliteRecorder) Read(n int) ([]Recordable, error) {
 	rows, err := r.db.QueryContext(r.ctx, `
 SELECT id, msg
 FROM msgs
-ORDER BY ts DESC, id DESC
+ORDER BY seq DESC, id DESC
 LIMIT ?
 `, n)
 	if err != nil {
 		return nil, fmt.Errorf("msgs query error: %w", err)
 	}
 
+	msgs, err := scanMsgs(rows, n)
+	if err != nil {
+		return nil, err
+	}
+
+	// the newest n were selected, return them oldest first
+	slices.Reverse(msgs)
+
+	return msgs, nil
+}
+
+// scanMsgs decodes the id and msg of each row.
+func scanMsgs(rows *sql.Rows, n int) ([]Recordable, error) {
+	var err error
 	msgs := make([]Recordable, 0, n)
 	for rows.Next() {
 		var (
@@ -105,9 +168,108 @@ LIMIT ?
 	if rows.Err() != nil {
 		return nil, fmt.Errorf("rows unexpected error: %w", rows.Err())
 	}
+	return msgs, nil
+}
 
-	// TODO: maybe need to reverse msgs?
-	slices.Reverse(msgs)
+// ReadAfter returns up to n messages with a sequence number after seq, oldest
+// first, to replay the messages a resuming client missed.
+func (r *SqliteRecorder) ReadAfter(seq uint64, n int) ([]Recordable, error) {
+	rows, err := r.db.QueryContext(r.ctx, `
+SELECT id, msg
+FROM msgs
+WHERE seq > ?
+ORDER BY seq, id
+LIMIT ?
+`, seq, n)
+	if err != nil {
+		return nil, fmt.Errorf("msgs query error: %w", err)
+	}
+	return scanMsgs(rows, n)
+}
 
-	return msgs, nil
+// LastSeq returns the highest sequence number recorded, so a new Program
+// continues the sequence.
+func (r *SqliteRecorder) LastSeq() (uint64, error) {
+	var seq uint64
+	err := r.db.QueryRowContext(r.ctx, `SELECT COALESCE(MAX(seq), 0) FROM msgs`).Scan(&seq)
+	if err != nil {
+		return 0, fmt.Errorf("error reading last seq: %w", err)
+	}
+	return seq, nil
+}
+
+// Prune deletes the messages recorded before t, e.g. to enforce a history
+// retention. Projections keep what they have already applied.
+func (r *SqliteRecorder) Prune(before time.Time) (int64, error) {
+	res, err := r.db.ExecContext(r.ctx, `DELETE FROM msgs WHERE ts < ?`, before)
+	if err != nil {
+		return 0, fmt.Errorf("error pruning messages: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+var _ Store = &SqliteRecorder{}
+
+func (r *SqliteRecorder) Put(bucket, key string, v any) error {
+	return r.put(r.ctx, bucket, key, v)
+}
+
+func (r *SqliteRecorder) put(ctx context.Context, bucket, key string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error marshaling %s/%s: %w", bucket, key, err)
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+INSERT INTO kv(bucket, key, value) VALUES (?, ?, ?)
+ON CONFLICT(bucket, key) DO UPDATE SET value = excluded.value
+`, bucket, key, string(b))
+	if err != nil {
+		return fmt.Errorf("error saving %s/%s: %w", bucket, key, err)
+	}
+	return nil
+}
+
+func (r *SqliteRecorder) Get(bucket, key string, v any) (bool, error) {
+	var raw string
+	err := r.db.QueryRowContext(r.ctx, `SELECT value FROM kv WHERE bucket = ? AND key = ?`, bucket, key).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error loading %s/%s: %w", bucket, key, err)
+	}
+	if err = json.Unmarshal([]byte(raw), v); err != nil {
+		return false, fmt.Errorf("error decoding %s/%s: %w", bucket, key, err)
+	}
+	return true, nil
+}
+
+func (r *SqliteRecorder) Delete(bucket, key string) error {
+	_, err := r.db.ExecContext(r.ctx, `DELETE FROM kv WHERE bucket = ? AND key = ?`, bucket, key)
+	if err != nil {
+		return fmt.Errorf("error deleting %s/%s: %w", bucket, key, err)
+	}
+	return nil
+}
+
+func (r *SqliteRecorder) List(bucket string) (map[string]json.RawMessage, error) {
+	rows, err := r.db.QueryContext(r.ctx, `SELECT key, value FROM kv WHERE bucket = ?`, bucket)
+	if err != nil {
+		return nil, fmt.Errorf("kv query error: %w", err)
+	}
+	defer rows.Close()
+
+	values := make(map[string]json.RawMessage)
+	for rows.Next() {
+		var key, raw string
+		if err = rows.Scan(&key, &raw); err != nil {
+			return nil, fmt.Errorf("kv scan error: %w", err)
+		}
+		values[key] = json.RawMessage(raw)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("kv rows unexpected error: %w", rows.Err())
+	}
+	return values, nil
 }
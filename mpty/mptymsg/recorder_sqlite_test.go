@@ -0,0 +1,23 @@
+//go:build !nosqlite
+
+package mptymsg
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSqliteRecorder(t *testing.T) {
+	r, err := NewSqlite(context.Background(), filepath.Join(t.TempDir(), "msgs.db"))
+	require.NoError(t, err)
+	defer r.Close()
+
+	testRecorder(t, r)
+
+	rep, err := r.Check()
+	require.NoError(t, err)
+	require.Zero(t, rep.OutOfOrder)
+}
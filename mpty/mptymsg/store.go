@@ -0,0 +1,15 @@
+package mptymsg
+
+import "encoding/json"
+
+// Store is a small key/value store for state that must outlive the recent
+// message history, e.g. user profiles and preferences. Values are encoded as
+// json and grouped into buckets.
+type Store interface {
+	Put(bucket, key string, v any) error
+	// Get decodes the value at bucket/key into v. It returns false if there
+	// is no value stored.
+	Get(bucket, key string, v any) (bool, error)
+	Delete(bucket, key string) error
+	List(bucket string) (map[string]json.RawMessage, error)
+}
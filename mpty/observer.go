@@ -0,0 +1,70 @@
+package mpty
+
+import (
+	"context"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/golang-cz/ringbuf"
+)
+
+// Observer follows the broadcast like a client program and keeps its model
+// up to date so it can be rendered on demand, e.g. for previews of the room.
+// It isn't a session, no connect or disconnect is announced for it and it
+// never sends input.
+type Observer struct {
+	mu    sync.Mutex
+	model ClientModel
+}
+
+// Observe subscribes m to the broadcast until ctx is done. The init messages
+// are applied first, typically a tea.WindowSizeMsg. Commands returned by m are
+// not run so it must render from the broadcast messages alone.
+func (p Program) Observe(ctx context.Context, m ClientModel, init ...tea.Msg) (*Observer, error) {
+	respCh := make(chan subResp, 1)
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case p.Send <- subReq{ctx: ctx, id: m.Id(), resp: respCh}:
+	}
+
+	var resp subResp
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case resp = <-respCh:
+	}
+
+	o := &Observer{model: m}
+	m.Init()
+	for _, msg := range init {
+		o.update(msg)
+	}
+	o.update(resp.initialMsgs)
+
+	go o.follow(resp.subscriber)
+	return o, nil
+}
+
+func (o *Observer) follow(sub *ringbuf.Subscriber[tea.Msg]) {
+	for {
+		msg, err := sub.Next()
+		if err != nil {
+			return
+		}
+		o.update([]tea.Msg{msg})
+	}
+}
+
+func (o *Observer) update(msg tea.Msg) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.model, _ = o.model.UpdateClient(msg)
+}
+
+// View renders the current state of the observed model.
+func (o *Observer) View() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.model.View()
+}
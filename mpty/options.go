@@ -0,0 +1,49 @@
+package mpty
+
+import tea "github.com/charmbracelet/bubbletea"
+
+// ProgramOptions returns the options to add to the program of a client, e.g.
+// mouse support for web terminals, from what its model knows about the
+// client.
+type ProgramOptions func(ClientModel) []tea.ProgramOption
+
+// WithProgramOptions adds the options returned by fn to the program of every
+// client, after the options of the Program.
+func WithProgramOptions(fn ProgramOptions) Option {
+	return func(o *options) {
+		o.programOptions = append(o.programOptions, fn)
+	}
+}
+
+// AltScreener is implemented by ClientModels that decide if their program
+// runs in the alternate screen, which it does by default.
+type AltScreener interface {
+	AltScreen() bool
+}
+
+// AltScreen is false for dumb terminals, which have no alternate screen.
+func (m *ClientInfoModel) AltScreen() bool {
+	return m.Term != "dumb"
+}
+
+// MinWidth is the narrowest terminal a full screen model is laid out in, see
+// Dumb.
+const MinWidth = 40
+
+// Dumb reports if the terminal can't be relied on to draw a full screen
+// model: it is "dumb", unknown or narrower than MinWidth. Such clients are
+// better served by a model printing plain lines.
+func (m *ClientInfoModel) Dumb() bool {
+	switch m.Term {
+	case "", "dumb", "unknown":
+		return true
+	}
+	return m.Width < MinWidth
+}
+
+// TitleVarser is implemented by ClientModels with variables for the title of
+// their terminal, e.g. the room or the game being played. TitleVars is called
+// outside of the program of the client and must be safe for concurrent use.
+type TitleVarser interface {
+	TitleVars() map[string]any
+}
@@ -0,0 +1,76 @@
+package mpty
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+)
+
+// ErrClientPanic is the error of a client whose model panicked. Only the
+// program of that client ends, the Program and its other clients carry on.
+var ErrClientPanic = errors.New("client panicked")
+
+// crashView replaces the view of a client whose model panicked
+const crashView = "Sorry, your session crashed and was disconnected. Reconnect to continue.\n"
+
+// crashed logs the panic r of the model with the id of the client.
+func (m *ClientMain) crashed(r any) {
+	m.crash = fmt.Errorf("%w: %v", ErrClientPanic, r)
+	log.Error("client panicked", "id", m.Id(), "panic", r, "stack", string(debug.Stack()))
+}
+
+// recoverInit is deferred by Init, the client never connected so it is only
+// removed from the sessions.
+func (m *ClientMain) recoverInit(cmd *tea.Cmd) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	m.crashed(r)
+	m.sessions.remove(m.Id())
+	m.unsubscribe()
+	*cmd = tea.Quit
+}
+
+// recoverUpdate is deferred by Update, the program quits and disconnects
+// like it would if the client had left.
+func (m *ClientMain) recoverUpdate(model *tea.Model, cmd *tea.Cmd) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	m.crashed(r)
+	*model, *cmd = m, tea.Quit
+}
+
+// recoverView is deferred by View, the program is told to quit from outside
+// the event loop it is called by.
+func (m *ClientMain) recoverView(view *string) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	m.crashed(r)
+	*view = crashView
+	go m.program.Quit()
+}
+
+func (m *ClientMain) View() (view string) {
+	if m.crash != nil {
+		return crashView
+	}
+	defer m.recoverView(&view)
+	return m.ClientModel.View()
+}
+
+// Err is ErrClientPanic if the model panicked, otherwise the error of the
+// model.
+func (m *ClientMain) Err() error {
+	if m.crash != nil {
+		return m.crash
+	}
+	return m.ClientModel.Err()
+}
@@ -3,26 +3,107 @@ package mpty
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/log"
+	"github.com/ghthor/webtea/logpolicy"
 	"github.com/ghthor/webtea/mpty/mptymsg"
+	"github.com/ghthor/webtea/tracing"
 	"github.com/golang-cz/ringbuf"
 	"golang.org/x/sync/errgroup"
 )
 
-const (
-	broadcastRingSz    = 10000
-	broadcastLookback  = 7000
-	broadcaseMaxBehind = 9000
-)
+// broadcastRingSz is the default size of the broadcast ring, clients fall
+// behind by 90% of its size by default, see WithMaxBehind
+const broadcastRingSz = 10000
+
+// MinRingSize is the broadcast ring size the ring must be larger than.
+const MinRingSize = 10
+
+// ValidateRing reports if a ring of size with clients starting startBehind
+// and at most maxBehind messages behind is within the limits of the ring:
+// maxBehind must be between a tenth and 90% of size, and startBehind at most
+// maxBehind.
+func ValidateRing(size, startBehind, maxBehind int) error {
+	switch {
+	case size <= MinRingSize:
+		return fmt.Errorf("ring size %d must be more than %d", size, MinRingSize)
+	case maxBehind < size/10 || maxBehind > size*9/10:
+		return fmt.Errorf("max behind %d must be between %d and %d, a tenth and 90%% of the ring size", maxBehind, size/10, size*9/10)
+	case startBehind < 0 || startBehind > maxBehind:
+		return fmt.Errorf("start behind %d must be between 0 and max behind %d", startBehind, maxBehind)
+	}
+	return nil
+}
+
+// Option configures a Program.
+type Option func(*options)
+
+type options struct {
+	ringSize, startBehind, maxBehind int
+	memoryBudget                     uint64
+	backpressure                     Backpressure
+
+	stages         []Stage
+	programOptions []ProgramOptions
+	rooms          *Rooms
+	watchdog       *watchdog
+	slow           *slowHandlers
+}
+
+// WithRingSize sizes the broadcast ring buffer, 10000 messages by default. A
+// bigger ring tolerates slower clients for more memory, it must be more than
+// MinRingSize.
+func WithRingSize(size int) Option {
+	return func(o *options) {
+		o.ringSize = size
+	}
+}
+
+// WithStartBehind starts new clients n messages behind the newest, so they
+// see the recent broadcasts, none by default.
+func WithStartBehind(n int) Option {
+	return func(o *options) {
+		o.startBehind = n
+	}
+}
+
+// WithMaxBehind skips clients ahead once they fall more than n messages
+// behind the newest, 90% of the ring size by default. It must be between a
+// tenth and 90% of the ring size.
+func WithMaxBehind(n int) Option {
+	return func(o *options) {
+		o.maxBehind = n
+	}
+}
 
 type Input chan<- tea.Msg
 
+// ClientId identifies a single connected client program. It is composed of
+// a stable identity, which is the same every time a user connects, and a
+// session id that is unique to each connection.
 type ClientId string
 
+func NewClientId(identity, session string) ClientId {
+	return ClientId(identity + " " + session)
+}
+
+// Identity returns the stable identity component of the id.
+func (id ClientId) Identity() string {
+	identity, _, _ := strings.Cut(string(id), " ")
+	return identity
+}
+
+// Session returns the per-connection component of the id.
+func (id ClientId) Session() string {
+	_, sess, _ := strings.Cut(string(id), " ")
+	return sess
+}
+
 type ClientModel interface {
 	tea.Model
 
@@ -42,40 +123,105 @@ type Program struct {
 	cancel  context.CancelCauseFunc
 	started chan struct{}
 
-	// TODO: implement our own minimal tea compatible event loop since this
-	// program does not interact with a PTY/TTY at any point
-	*tea.Program
+	// loop runs Main, the program never interacts with a PTY/TTY so it
+	// isn't a tea.Program
+	loop *loop
 
-	// Send is for a non-blocking many-to-one for clients to communicate with
-	// the mpty Program since the tea.Program.Send() API is unfortunately
-	// blocking
+	// Send is the many-to-one mailbox of Main for clients to communicate
+	// with the Program, it blocks once the mailbox is full
 	Send Input
-	recv <-chan tea.Msg
 
 	broadcast *ringbuf.RingBuffer[tea.Msg]
+
+	sessions  *sessions
+	startedAt time.Time
+	// pressure is the level of the last MemoryPressureMsg
+	pressure *atomic.Int32
+
+	// stages run on the batches of every client, see WithStages
+	stages []Stage
+	// programOptions are added to the program of every client, see
+	// WithProgramOptions
+	programOptions []ProgramOptions
+	// rooms the clients may move to, see WithRooms
+	rooms *Rooms
+	// watchdog is nil unless WithWatchdog
+	watchdog *watchdog
+	// slow is nil unless WithSlowHandler
+	slow *slowHandlers
+	// backpressure is what happens to clients that fall behind, see
+	// WithBackpressure
+	backpressure Backpressure
 }
 
 type (
 	ClientConnectMsg    ClientId
 	ClientDisconnectMsg ClientId
 
+	// GapMsg is delivered to a client ahead of the message with sequence
+	// number Next when the messages after After never reached it, e.g.
+	// because it fell too far behind the broadcast ring.
+	GapMsg struct {
+		After, Next uint64
+	}
+
 	subReq struct {
 		ctx  context.Context
 		id   ClientId
 		resp chan<- subResp
+
+		// after is the last sequence number applied by a resuming client
+		after uint64
+		// join is checked by an Admitter, it is nil for observers which
+		// never join
+		join *JoinReq
+		// verified is set once a Verifier accepted join
+		verified bool
+		// latest subscribes at the newest message without replaying the
+		// recorded messages, see BackpressureDropOldest
+		latest bool
 	}
 	subResp struct {
 		initialMsgs []mptymsg.Recordable
 		subscriber  *ringbuf.Subscriber[tea.Msg]
+		// seq is the sequence number of the last Sequenced message when
+		// the client subscribed
+		seq uint64
+		// err is why an Admitter refused the client
+		err error
 	}
 )
 
+// Refuser is implemented by the models of a Program that refuse some of the
+// Recordable messages sent to it, e.g. a chat in slow mode. Refuse is called
+// by the Main program before the message is numbered and recorded, a refused
+// message is neither and never reaches Update. The model tells the sender
+// why itself.
+type Refuser interface {
+	Refuse(mptymsg.Recordable) bool
+}
+
 type Main struct {
 	broadcaster *ringbuf.RingBuffer[tea.Msg]
+	startBehind int
+	maxBehind   int
 	recorder    Recorder
 	started     chan struct{}
 	cmds        []tea.Cmd
 
+	// seq is the sequence number of the last Sequenced message
+	seq uint64
+	// handedOff stops recording and sequencing once the process taking
+	// over loads seq from the recorder, see Program.Handoff
+	handedOff bool
+
+	memoryBudget    uint64
+	memoryCheckedAt time.Time
+	pressure        *atomic.Int32
+
+	watchdog *watchdog
+	slow     *slowHandlers
+
 	tea.Model
 }
 
@@ -84,16 +230,33 @@ func (m *Main) Init() tea.Cmd {
 	if m.cmds == nil {
 		m.cmds = make([]tea.Cmd, 0, 1)
 	}
+	cmd := m.Model.Init()
+	if err := m.restore(); err != nil {
+		log.Warn("failed to restore snapshot", "error", err)
+	}
+	if r, ok := m.recorder.(interface{ LastSeq() (uint64, error) }); ok {
+		seq, err := r.LastSeq()
+		if err != nil {
+			log.Warn("failed to load the last sequence number", "error", err)
+		}
+		m.seq = seq
+	}
 	return tea.Batch(
 		func() tea.Msg {
 			return m.broadcaster
 		},
 		tea.Every(time.Second, func(t time.Time) tea.Msg { return t }),
-		m.Model.Init(),
+		cmd,
 	)
 }
 
 func (m *Main) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if m.slow != nil {
+		defer func(start time.Time) {
+			m.slow.observe(msg, time.Since(start))
+		}(time.Now())
+	}
+
 	var (
 		cmd  tea.Cmd
 		cmds = m.cmds[:0]
@@ -101,6 +264,19 @@ func (m *Main) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch rec := msg.(type) {
 	case mptymsg.Recordable:
+		if r, ok := m.Model.(Refuser); ok && r.Refuse(rec) {
+			return m, nil
+		}
+		if m.handedOff {
+			break
+		}
+		// the sequence is assigned even if recording fails so subscribers
+		// still see every message in order
+		if s, ok := rec.(mptymsg.Sequenced); ok {
+			m.seq++
+			rec = s.SetSeq(m.seq)
+			msg = rec
+		}
 		var err error
 		rec, err = m.recorder.Save(rec)
 		if err != nil {
@@ -112,28 +288,67 @@ func (m *Main) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case subReq:
+		if v, ok := m.Model.(Verifier); ok && msg.join != nil && !msg.verified {
+			if verify := v.Verify(*msg.join); verify != nil {
+				return m, verified(msg, verify)
+			}
+		}
+		if a, ok := m.Model.(Admitter); ok && msg.join != nil {
+			if err := a.Admit(*msg.join); err != nil {
+				log.Info("refused", "id", msg.id, "error", err)
+				msg.resp <- subResp{err: err}
+				return m, nil
+			}
+		}
+
 		// TODO: configurable default read len
-		init, err := m.recorder.Read(100)
+		var (
+			init        []mptymsg.Recordable
+			err         error
+			startBehind = m.startBehind
+		)
+		switch r, ok := m.recorder.(replayer); {
+		case msg.latest:
+			startBehind = 0
+		case ok && msg.after > 0:
+			init, err = r.ReadAfter(msg.after, resumeReadLen)
+		default:
+			init, err = m.recorder.Read(100)
+		}
 		if err != nil {
 			log.Warn("failed to load recorded messages", "error", err)
 		}
 
 		sub := m.broadcaster.Subscribe(msg.ctx, &ringbuf.SubscribeOpts{
 			Name:        string(msg.id),
-			StartBehind: 0,
-			MaxBehind:   broadcaseMaxBehind,
+			StartBehind: uint64(startBehind),
+			MaxBehind:   uint64(m.maxBehind),
 		})
+		seq := m.seq
 		return m, func() tea.Msg {
 			select {
 			case <-msg.ctx.Done():
 			case msg.resp <- subResp{
 				initialMsgs: init,
 				subscriber:  sub,
+				seq:         seq,
 			}:
 			}
 			return nil
 		}
 
+	case snapshotReq:
+		switch {
+		case msg.discard:
+			m.handedOff = false
+			msg.resp <- m.discardSnapshot()
+		case msg.handoff:
+			msg.resp <- m.handoff()
+		default:
+			msg.resp <- m.snapshot()
+		}
+		return m, nil
+
 	case ClientConnectMsg:
 		log.Info("connected", "id", msg)
 		m.broadcaster.Write(msg)
@@ -142,15 +357,25 @@ func (m *Main) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		log.Info("disconnected", "id", msg)
 		m.broadcaster.Write(msg)
 
+	case MaintenanceMsg:
+		log.Info("maintenance", "at", msg.At, "reason", msg.Reason)
+		m.broadcaster.Write(msg)
+
+	case AnnounceMsg:
+		log.Info("announce", "msg", msg.Str)
+		m.broadcaster.Write(msg)
+
 	case time.Time:
-		// These ticks are important for periodically waking any subscribers
-		// that may need to exit but are completely caught up and sitting on
-		// the wake condition. Becuase of this race, if the subscriber is
-		// waiting and the broadcast channel is quiet the tea.Program can never
-		// exit. These ticks ensure that any tea.Program will get to exit when
-		// it has a running command that is stuck on a subscriber holding the
+		// These ticks are the clock of the models and periodically wake any
+		// subscribers that may need to exit but are completely caught up and
+		// sitting on the wake condition. If the subscriber is waiting and the
+		// broadcast channel is quiet the tea.Program of a client can never
+		// exit. These ticks ensure that it will get to exit when it has a
+		// running command that is stuck on a subscriber holding the
 		// ringbuffer mutex
 		m.broadcaster.Write(msg)
+		m.watchdog.beat()
+		m.checkMemory(msg)
 		cmds = append(cmds, tea.Every(time.Second, func(t time.Time) tea.Msg { return t }))
 	}
 
@@ -159,65 +384,85 @@ func (m *Main) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
-func NewProgram(ctx context.Context, cancel context.CancelCauseFunc, m tea.Model, r Recorder) Program {
-	broadcaster := ringbuf.New[tea.Msg](broadcastRingSz)
+// NewProgram returns the Program running m, the error reports options out
+// of the limits of the broadcast ring, see ValidateRing.
+func NewProgram(ctx context.Context, cancel context.CancelCauseFunc, m tea.Model, r Recorder, opts ...Option) (Program, error) {
+	o := options{
+		ringSize: broadcastRingSz,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.maxBehind <= 0 {
+		o.maxBehind = o.ringSize * 9 / 10
+	}
+	if err := ValidateRing(o.ringSize, o.startBehind, o.maxBehind); err != nil {
+		return Program{}, err
+	}
+
+	broadcaster := ringbuf.New[tea.Msg](uint64(o.ringSize))
 	started := make(chan struct{})
+	pressure := &atomic.Int32{}
 
-	p := tea.NewProgram(
+	l := newLoop(
 		&Main{
 			broadcaster: broadcaster,
+			startBehind: o.startBehind,
+			maxBehind:   o.maxBehind,
 			recorder:    r,
 			started:     started,
 			Model:       m,
+
+			memoryBudget: o.memoryBudget,
+			pressure:     pressure,
+
+			watchdog: o.watchdog,
+			slow:     o.slow,
 		},
-		tea.WithContext(ctx),
-		tea.WithoutSignals(),
-		tea.WithoutRenderer(),
-		tea.WithInput(nil),
 	)
 
-	input := make(chan tea.Msg)
-
 	return Program{
 		ctx:     ctx,
 		cancel:  cancel,
-		Program: p,
+		loop:    l,
 		started: started,
-		Send:    input,
-		recv:    input,
+		Send:    l.mailbox,
 
 		broadcast: broadcaster,
-	}
+
+		sessions:  newSessions(),
+		startedAt: time.Now(),
+		pressure:  pressure,
+
+		stages:         o.stages,
+		programOptions: o.programOptions,
+		rooms:          o.rooms,
+		watchdog:       o.watchdog,
+		slow:           o.slow,
+		backpressure:   o.backpressure,
+	}, nil
 }
 
 func (p Program) StartIn(ctx context.Context, grp *errgroup.Group) error {
+	exited := make(chan struct{})
 	grp.Go(func() error {
-		_, serr := p.Program.Run()
-		if serr != nil && !errors.Is(serr, context.Canceled) {
-			p.cancel(serr)
-			return serr
-		}
+		defer close(exited)
+		err := p.loop.run(p.ctx)
 
 		// Send one last pulse on the the ringbuffer unblock any subscribers
 		p.broadcast.Write(ctx.Err())
 
-		return nil
-	})
-	// Start a many to one input reader and wrap the unfortunate blocking Send() API
-	// provided by tea.Program
-	grp.Go(func() error {
-		done := p.ctx.Done()
-		recv := p.recv
-		for {
-			select {
-			case <-done:
-				return nil
-			case m := <-recv:
-				p.Program.Send(m)
-			}
+		if err != nil {
+			p.cancel(err)
 		}
+		return err
 	})
-
+	if p.watchdog != nil {
+		grp.Go(func() error {
+			p.watchdog.watch(exited, p.cancel)
+			return nil
+		})
+	}
 	select {
 	case <-ctx.Done():
 		return p.ctx.Err()
@@ -226,6 +471,15 @@ func (p Program) StartIn(ctx context.Context, grp *errgroup.Group) error {
 	}
 }
 
+// Quit stops Main once the messages sent before are handled, unless its
+// context is done first.
+func (p Program) Quit() {
+	select {
+	case <-p.ctx.Done():
+	case p.Send <- tea.QuitMsg{}:
+	}
+}
+
 type NewClientProgram func(context.Context, ClientModel, ...tea.ProgramOption) *tea.Program
 
 type ClientMain struct {
@@ -235,6 +489,31 @@ type ClientMain struct {
 	initialMsgs []mptymsg.Recordable
 	subscriber  *ringbuf.Subscriber[tea.Msg]
 	msgs        []tea.Msg
+	// readAt is when the first message of msgs was read
+	readAt time.Time
+	// lastSeq is the sequence number of the last Sequenced message delivered
+	lastSeq uint64
+	// stages process each batch before UpdateClient
+	stages []Stage
+
+	sessions *sessions
+	session  *session
+
+	ctx context.Context
+	// unsubscribe ends the subscription to the ring of the room
+	unsubscribe context.CancelFunc
+	rooms       *Rooms
+	// joining is the room the client is moving to, see JoinRoomMsg
+	joining *roomSubscribedMsg
+	// backpressure is what happens when the client falls behind and
+	// pending are delivered with the next batch, see Backpressure
+	backpressure Backpressure
+	pending      []tea.Msg
+	// interest drops the broadcast messages the client doesn't want, nil
+	// delivers all of them, see Interested
+	interest Interest
+	// crash is set once the model panicked, see ErrClientPanic
+	crash error
 
 	// The tea.Program does not have safe way to wait for it to exit until
 	// AFTER it has started running. So to schedule disconnect messages when
@@ -243,7 +522,8 @@ type ClientMain struct {
 	program *tea.Program
 }
 
-func (m *ClientMain) Init() tea.Cmd {
+func (m *ClientMain) Init() (cmd tea.Cmd) {
+	defer m.recoverInit(&cmd)
 	if m.msgs == nil {
 		m.msgs = make([]tea.Msg, 0, 100)
 	}
@@ -262,6 +542,7 @@ func (m *ClientMain) Init() tea.Cmd {
 			m.Input <- ClientConnectMsg(id)
 			return tea.Cmd(func() tea.Msg {
 				m.program.Wait()
+				m.sessions.remove(id)
 				m.Input <- ClientDisconnectMsg(id)
 				return nil
 			})
@@ -269,23 +550,65 @@ func (m *ClientMain) Init() tea.Cmd {
 		func() tea.Msg {
 			msgs := m.initialMsgs
 			m.initialMsgs = nil
-			return msgs
+			return m.replayed(msgs)
+		},
+		func() tea.Msg {
+			if m.lastSeq == 0 {
+				return nil
+			}
+			return ResumeMsg{ResumeToken(m.lastSeq)}
 		},
 		m.ReadMsgsCmd(),
 	)
 }
 
-func (m *ClientMain) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	var (
-		cmd  tea.Cmd
-		cmds []tea.Cmd
-	)
+func (m *ClientMain) Update(msg tea.Msg) (model tea.Model, cmd tea.Cmd) {
+	if m.crash != nil {
+		return m, nil
+	}
+	defer m.recoverUpdate(&model, &cmd)
+
+	var cmds []tea.Cmd
 	switch msg := msg.(type) {
 	case tea.Cmd:
 		return m, msg
 
+	case JoinRoomMsg:
+		return m, m.joinRoom(msg.Room)
+	case roomSubscribedMsg:
+		m.subscribed(msg)
+		return m, nil
+	case resubscribedMsg:
+		return m, m.resubscribed(msg)
+
 	case []tea.Msg:
-		cmds = append(cmds, m.ReadMsgsCmd())
+		if m.joining != nil {
+			// the batch is from the room being left
+			return m, m.switchRoom()
+		}
+		msgs, read := msg, m.ReadMsgsCmd
+		if n := len(msgs); n > 0 {
+			if behind, ok := msgs[n-1].(fellBehind); ok {
+				msgs = msgs[:n-1]
+				read = func() tea.Cmd { return m.resubscribe(behind) }
+			}
+		}
+		for _, stage := range m.stages {
+			msgs = stage(m.ClientModel, msgs)
+		}
+		cmds = append(cmds, read())
+
+		// the span covers reading the batch from the ring until the client
+		// has updated with it
+		_, span := tracing.StartAt(context.Background(), "mpty.broadcast.batch", m.readAt,
+			tracing.String("client", string(m.Id())),
+			tracing.Int("size", len(msg)),
+		)
+		defer span.End()
+
+		m.ClientModel, cmd = m.ClientModel.UpdateClient(msgs)
+		cmds = append(cmds, cmd)
+		return m, tea.Batch(cmds...)
 	}
 
 	m.ClientModel, cmd = m.ClientModel.UpdateClient(msg)
@@ -293,9 +616,68 @@ func (m *ClientMain) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
+// sequence drops the Sequenced messages that were already delivered, i.e.
+// the recorded messages the subscriber started behind, and inserts a GapMsg
+// where messages are missing. A ResumeMsg ends the batch when it advanced
+// the sequence.
+func (m *ClientMain) sequence(_ ClientModel, msgs []tea.Msg) []tea.Msg {
+	var (
+		out     []tea.Msg
+		lastSeq = m.lastSeq
+	)
+	for i, msg := range msgs {
+		rec, ok := msg.(mptymsg.Sequenced)
+		if !ok || rec.Seq() == 0 || rec.Seq() == m.lastSeq+1 || m.lastSeq == 0 {
+			if ok && rec.Seq() > 0 {
+				m.lastSeq = rec.Seq()
+			}
+			if out != nil {
+				out = append(out, msg)
+			}
+			continue
+		}
+
+		// the batch is copied on the first message that isn't delivered as is
+		if out == nil {
+			out = append(make([]tea.Msg, 0, len(msgs)+2), msgs[:i]...)
+		}
+		if rec.Seq() <= m.lastSeq {
+			continue
+		}
+		logpolicy.Warn("mpty.missed", "missed messages", "client", m.Id(), "after", m.lastSeq, "next", rec.Seq())
+		out = append(out, GapMsg{After: m.lastSeq, Next: rec.Seq()}, msg)
+		m.lastSeq = rec.Seq()
+	}
+	if m.lastSeq == lastSeq {
+		if out == nil {
+			return msgs
+		}
+		return out
+	}
+	if out == nil {
+		out = append(make([]tea.Msg, 0, len(msgs)+1), msgs...)
+	}
+	return append(out, ResumeMsg{ResumeToken(m.lastSeq)})
+}
+
+// setStages sets the stages of the client in the room p.
+func (m *ClientMain) setStages(p Program) {
+	// the latency is stamped and the sequence checked before any other
+	// stage can drop messages
+	m.stages = append(m.stages[:0], m.session.latency, m.sequence, dropSkipped)
+	m.stages = append(m.stages, p.stages...)
+	if s, ok := m.ClientModel.(Stager); ok {
+		m.stages = append(m.stages, s.Stages()...)
+	}
+}
+
 func (m *ClientMain) ReadMsgsCmd() tea.Cmd {
-	read := m.subscriber
-	m.msgs = m.msgs[:0]
+	read, backpressure := m.subscriber, m.backpressure
+	m.msgs = append(m.msgs[:0], m.pending...)
+	if len(m.pending) > 0 {
+		m.readAt = time.Now()
+		m.pending = m.pending[:0]
+	}
 
 	return func() tea.Msg {
 		start := time.Now()
@@ -312,8 +694,16 @@ func (m *ClientMain) ReadMsgsCmd() tea.Cmd {
 			}
 
 			msg, err := read.Next()
+			if err == nil {
+				if msg = m.want(msg); msg == nil {
+					continue
+				}
+			}
+			if len(m.msgs) == 0 {
+				m.readAt = time.Now()
+			}
 			if err != nil {
-				m.msgs = append(m.msgs, err)
+				m.msgs = append(m.msgs, backpressure.behind(err))
 				return m.msgs
 			}
 			m.msgs = append(m.msgs, msg)
@@ -326,27 +716,58 @@ func (p Program) NewClientProgram() NewClientProgram {
 		opts = append(opts,
 			tea.WithContext(ctx),
 			tea.WithoutSignalHandler(),
-			tea.WithAltScreen(),
 		)
 
+		after := resumeAfter(ctx)
+		join := joinReq(ctx, m)
 		respCh := make(chan subResp, 1)
+		// the subscription ends before the program when it moves to another
+		// room
+		subCtx, unsubscribe := context.WithCancel(ctx)
 		select {
 		case <-ctx.Done():
+			unsubscribe()
 			return nil
-		case p.Send <- subReq{ctx, m.Id(), respCh}:
+		case p.Send <- subReq{ctx: subCtx, id: m.Id(), resp: respCh, after: after, join: &join}:
 		}
 
 		var resp subResp
 		select {
 		case <-ctx.Done():
+			unsubscribe()
 			return nil
 		case resp = <-respCh:
 		}
+		if resp.err != nil {
+			unsubscribe()
+			return tea.NewProgram(refused{resp.err}, opts...)
+		}
+		if a, ok := m.(AltScreener); !ok || a.AltScreen() {
+			opts = append(opts, tea.WithAltScreen())
+		}
+		for _, fn := range p.programOptions {
+			opts = append(opts, fn(m)...)
+		}
 
-		main := &ClientMain{p.Send, m, resp.initialMsgs, resp.subscriber, nil, nil}
-		p := tea.NewProgram(main, opts...)
-		main.program = p
-		return p
+		main := &ClientMain{
+			Input:        p.Send,
+			ClientModel:  m,
+			initialMsgs:  resp.initialMsgs,
+			subscriber:   resp.subscriber,
+			sessions:     p.sessions,
+			lastSeq:      after,
+			backpressure: p.backpressure,
+			interest:     interest(m),
+
+			ctx:         ctx,
+			unsubscribe: unsubscribe,
+			rooms:       p.rooms,
+		}
+		prog := tea.NewProgram(main, opts...)
+		main.program = prog
+		main.session = p.sessions.add(m, prog)
+		main.setStages(p)
+		return prog
 	}
 
 }
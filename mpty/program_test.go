@@ -0,0 +1,192 @@
+package mpty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ghthor/webtea/mpty/mptymsg"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+// testMsg is a Sequenced message recorded by the Program.
+type testMsg struct {
+	Value string
+
+	id  int64
+	seq uint64
+}
+
+var _ mptymsg.Sequenced = testMsg{}
+
+func (m testMsg) TypeName() string                  { return fmt.Sprintf("%T", m) }
+func (m testMsg) Ts() time.Time                     { return time.Time{} }
+func (m testMsg) SetId(id int64) mptymsg.Recordable { m.id = id; return m }
+func (m testMsg) Seq() uint64                       { return m.seq }
+func (m testMsg) SetSeq(seq uint64) mptymsg.Recordable {
+	m.seq = seq
+	return m
+}
+
+// testModel keeps the messages Main passed to it, Main keeps updating it
+// with its ticks while the test reads them.
+type testModel struct {
+	mu   sync.Mutex
+	msgs []tea.Msg
+}
+
+func (m *testModel) Init() tea.Cmd { return nil }
+
+func (m *testModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.msgs = append(m.msgs, msg)
+	return m, nil
+}
+
+// received returns the messages passed to m so far.
+func (m *testModel) received() []tea.Msg {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.msgs)
+}
+
+func (m *testModel) View() string { return "" }
+
+// startProgram starts a Program of m, it is stopped when the test ends.
+func startProgram(t *testing.T, m tea.Model, r Recorder, opts ...Option) Program {
+	t.Helper()
+	ctx, cancel := context.WithCancelCause(context.Background())
+	grp, grpCtx := errgroup.WithContext(ctx)
+	p, err := NewProgram(grpCtx, cancel, m, r, opts...)
+	require.NoError(t, err)
+	require.NoError(t, p.StartIn(ctx, grp))
+	t.Cleanup(func() {
+		cancel(nil)
+		grp.Wait()
+	})
+	return p
+}
+
+// send sends msgs to Main and returns once they are handled.
+func send(t *testing.T, p Program, msgs ...tea.Msg) {
+	t.Helper()
+	for _, msg := range msgs {
+		p.Send <- msg
+	}
+	// the snapshot is requested through the mailbox after msgs
+	require.NoError(t, p.Snapshot(context.Background()))
+}
+
+// refuser refuses the testMsgs with Value "refused".
+type refuser struct {
+	testModel
+}
+
+func (m *refuser) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	m.testModel.Update(msg)
+	return m, nil
+}
+
+func (m *refuser) Refuse(rec mptymsg.Recordable) bool {
+	msg, ok := rec.(testMsg)
+	return ok && msg.Value == "refused"
+}
+
+func TestRefuser(t *testing.T) {
+	r := mptymsg.NewMemory(10)
+	m := &refuser{}
+	p := startProgram(t, m, r)
+
+	send(t, p, testMsg{Value: "a"}, testMsg{Value: "refused"}, testMsg{Value: "b"})
+
+	msgs, err := r.Read(10)
+	require.NoError(t, err)
+	require.Equal(t, []mptymsg.Recordable{
+		testMsg{Value: "a", id: 1, seq: 1},
+		testMsg{Value: "b", id: 2, seq: 2},
+	}, msgs, "a refused message is neither numbered nor recorded")
+	require.NotContains(t, m.received(), testMsg{Value: "refused"})
+}
+
+// verifier refuses the clients joining without the password "secret".
+type verifier struct {
+	testModel
+	admitted []ClientId
+}
+
+func (m *verifier) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	m.testModel.Update(msg)
+	return m, nil
+}
+
+func (m *verifier) Verify(req JoinReq) func() error {
+	return func() error {
+		if req.Password != "secret" {
+			return errors.New("wrong password")
+		}
+		return nil
+	}
+}
+
+func (m *verifier) Admit(req JoinReq) error {
+	m.admitted = append(m.admitted, req.Id)
+	return nil
+}
+
+func TestVerifier(t *testing.T) {
+	m := &verifier{}
+	p := startProgram(t, m, mptymsg.NewMemory(10))
+
+	join := func(id ClientId, password string) error {
+		resp := make(chan subResp, 1)
+		p.Send <- subReq{ctx: t.Context(), id: id, resp: resp, join: &JoinReq{Id: id, Password: password}}
+		return (<-resp).err
+	}
+	require.EqualError(t, join("a", "guess"), "wrong password")
+	require.NoError(t, join("b", "secret"))
+
+	send(t, p)
+	require.Equal(t, []ClientId{"b"}, m.admitted, "Admit is called once verified")
+}
+
+func TestNewProgramRing(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(t.Context())
+	defer cancel(nil)
+	newProgram := func(opts ...Option) (*Main, error) {
+		p, err := NewProgram(ctx, cancel, &testModel{}, mptymsg.NewMemory(0), opts...)
+		if err != nil {
+			return nil, err
+		}
+		return p.loop.model.(*Main), nil
+	}
+
+	m, err := newProgram()
+	require.NoError(t, err)
+	require.EqualValues(t, broadcastRingSz, m.broadcaster.Size())
+	require.Equal(t, 0, m.startBehind)
+	require.Equal(t, broadcastRingSz*9/10, m.maxBehind)
+
+	m, err = newProgram(WithRingSize(100), WithStartBehind(20))
+	require.NoError(t, err)
+	require.EqualValues(t, 100, m.broadcaster.Size())
+	require.Equal(t, 20, m.startBehind)
+	require.Equal(t, 90, m.maxBehind, "max behind defaults to 90% of the ring")
+
+	for _, opts := range [][]Option{
+		{WithRingSize(MinRingSize)},
+		{WithRingSize(100), WithMaxBehind(91)},
+		{WithRingSize(100), WithMaxBehind(9)},
+		{WithRingSize(100), WithMaxBehind(50), WithStartBehind(51)},
+		{WithRingSize(100), WithStartBehind(-1)},
+	} {
+		_, err := newProgram(opts...)
+		require.Error(t, err)
+	}
+}
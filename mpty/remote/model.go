@@ -0,0 +1,192 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/mpty/mptymsg"
+)
+
+// inputLen is how many messages the client may send before it blocks on the
+// calls to Room.Send
+const inputLen = 16
+
+type (
+	// LeftMsg is returned to the embedding program once the room has been
+	// left, e.g. the client was kicked or the connection was lost. Err is nil
+	// if the server ended the client.
+	LeftMsg struct {
+		Err error
+	}
+
+	// msg is a message of the Model to, a program may embed more than one
+	msg struct {
+		to  *Model
+		msg any
+	}
+	// batchMsg is the messages of a call to Room.Next
+	batchMsg struct {
+		msgs   []tea.Msg
+		resume string
+	}
+	// sentMsg is the result of a call to Room.Send
+	sentMsg struct {
+		typeName string
+		err      error
+	}
+	leftMsg struct {
+		err error
+	}
+)
+
+// Model is a ClientModel joined to a room over rpc, embedded in a program
+// that isn't run by mpty. Like a client of a Program it is sent its
+// mpty.Input, then batches of the messages broadcast to the room.
+type Model struct {
+	mpty.ClientModel
+
+	ctx  context.Context
+	conn *Conn
+
+	input  chan tea.Msg
+	resume string
+	left   bool
+	err    error
+}
+
+var _ tea.Model = &Model{}
+
+// New runs m in the room joined by conn, see Conn.Join. The room is left when
+// ctx is done.
+func New(ctx context.Context, conn *Conn, m mpty.ClientModel) *Model {
+	return &Model{
+		ClientModel: m,
+
+		ctx:   ctx,
+		conn:  conn,
+		input: make(chan tea.Msg, inputLen),
+	}
+}
+
+func (m *Model) Init() tea.Cmd {
+	return tea.Batch(
+		m.ClientModel.Init(),
+		func() tea.Msg {
+			return msg{m, mpty.Input(m.input)}
+		},
+		m.next(),
+		m.forward(),
+	)
+}
+
+func (m *Model) Update(teaMsg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	wrapped, ok := teaMsg.(msg)
+	if !ok {
+		m.ClientModel, cmd = m.ClientModel.UpdateClient(teaMsg)
+		return m, cmd
+	}
+	if wrapped.to != m {
+		return m, nil
+	}
+
+	switch msg := wrapped.msg.(type) {
+	case mpty.Input:
+		m.ClientModel, cmd = m.ClientModel.UpdateClient(msg)
+		return m, cmd
+
+	case batchMsg:
+		msgs := msg.msgs
+		if len(msgs) == 0 && msg.resume == m.resume {
+			return m, m.next()
+		}
+		if msg.resume != "" && msg.resume != m.resume {
+			m.resume = msg.resume
+			msgs = append(msgs, mpty.ResumeMsg{Token: msg.resume})
+		}
+		m.ClientModel, cmd = m.ClientModel.UpdateClient(msgs)
+		return m, tea.Batch(cmd, m.next())
+
+	case sentMsg:
+		if msg.err != nil {
+			log.Warn("remote send", "type", msg.typeName, "error", msg.err)
+		}
+		return m, m.forward()
+
+	case leftMsg:
+		if m.left {
+			return m, nil
+		}
+		m.left, m.err = true, msg.err
+		return m, func() tea.Msg { return LeftMsg{Err: msg.err} }
+	}
+	return m, nil
+}
+
+// Err is why the room was left, if the connection failed.
+func (m *Model) Err() error {
+	if m.err != nil {
+		return m.err
+	}
+	return m.ClientModel.Err()
+}
+
+// Left reports if the room has been left.
+func (m *Model) Left() bool {
+	return m.left
+}
+
+// Resume is the token to join with after reconnecting, see JoinArgs.
+func (m *Model) Resume() string {
+	return m.resume
+}
+
+// next reads the messages broadcast since the last call. A ResumeMsg ends the
+// batch when the resume token has changed.
+func (m *Model) next() tea.Cmd {
+	return func() tea.Msg {
+		reply, err := m.conn.Next(m.ctx, MaxWait)
+		switch {
+		case err != nil:
+			return msg{m, leftMsg{err}}
+		case reply.Left:
+			return msg{m, leftMsg{}}
+		}
+
+		batch := batchMsg{msgs: make([]tea.Msg, 0, len(reply.Msgs)+1), resume: reply.Resume}
+		for _, b := range reply.Msgs {
+			rec, err := mptymsg.JsonUnmarshal(b)
+			if err != nil {
+				log.Warn("remote decode", "error", err)
+				continue
+			}
+			batch.msgs = append(batch.msgs, rec)
+		}
+		return msg{m, batch}
+	}
+}
+
+// forward sends the next message the client sends to its Input. Only
+// recorded messages can be sent over rpc, the others are dropped.
+func (m *Model) forward() tea.Cmd {
+	return func() tea.Msg {
+		for {
+			var in tea.Msg
+			select {
+			case <-m.ctx.Done():
+				return nil
+			case in = <-m.input:
+			}
+
+			rec, ok := in.(mptymsg.Recordable)
+			if !ok {
+				log.Debug("remote send dropped", "type", fmt.Sprintf("%T", in))
+				continue
+			}
+			return msg{m, sentMsg{rec.TypeName(), m.conn.Send(m.ctx, rec)}}
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Package remote embeds a room in a bubbletea program of its own. A Model
+// runs a ClientModel, e.g. the chat client, joined to a room served over rpc
+// by tstea.RPCServer instead of a Program of the same process:
+//
+//	conn, err := remote.Dial(ctx, "tcp", "webtea.example.ts.net:2224")
+//	id, err := conn.Join(ctx, remote.JoinArgs{})
+//	room := remote.New(ctx, conn, chat.NewClient(ctx, remote.Info(id)))
+//
+// The embedding program routes its messages to the Update of the room, and
+// draws its View where it likes. Only the recorded messages of the room are
+// delivered, and only the recorded messages the server accepts are sent.
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"time"
+
+	"github.com/ghthor/webtea/i18n"
+	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/mpty/mptymsg"
+	"tailscale.com/client/tailscale/apitype"
+	"tailscale.com/tailcfg"
+)
+
+// MaxWait bounds how long Room.Next waits for messages
+const MaxWait = 30 * time.Second
+
+// The arguments and replies of the Room service, see tstea.RPCServer.
+type (
+	JoinArgs struct {
+		// Password of a protected room
+		Password string
+		// Resume is the token of a previous connection, only the messages it
+		// missed are replayed
+		Resume string
+	}
+	JoinReply struct {
+		Id string
+	}
+
+	NextArgs struct {
+		// Wait is how long to wait for a message, at most MaxWait
+		Wait time.Duration
+	}
+	NextReply struct {
+		// Msgs are mptymsg envelopes, see mptymsg.JsonUnmarshal
+		Msgs []json.RawMessage
+		// Resume is the token to join with after a reconnect
+		Resume string
+		// Left is set once the frontend has been ended, e.g. kicked
+		Left bool
+	}
+
+	SendArgs struct {
+		// Msg is an mptymsg envelope
+		Msg json.RawMessage
+	}
+	SendReply struct{}
+)
+
+// Conn is a connection to the Room service of a server.
+type Conn struct {
+	c *rpc.Client
+}
+
+// NewConn calls the Room service over conn with the JSON-RPC codec.
+func NewConn(conn io.ReadWriteCloser) *Conn {
+	return &Conn{c: jsonrpc.NewClient(conn)}
+}
+
+// Dial connects to the Room service at address.
+func Dial(ctx context.Context, network, address string) (*Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(conn), nil
+}
+
+func (c *Conn) Close() error {
+	return c.c.Close()
+}
+
+// call abandons the call when ctx is done, the reply is discarded once it
+// arrives.
+func (c *Conn) call(ctx context.Context, method string, args, reply any) error {
+	call := c.c.Go(method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-call.Done:
+		return call.Error
+	}
+}
+
+// Join joins the room and returns the id the server knows the client by.
+func (c *Conn) Join(ctx context.Context, args JoinArgs) (mpty.ClientId, error) {
+	var reply JoinReply
+	if err := c.call(ctx, "Room.Join", args, &reply); err != nil {
+		return "", err
+	}
+	return mpty.ClientId(reply.Id), nil
+}
+
+// Next waits up to wait for the messages broadcast since the last call.
+func (c *Conn) Next(ctx context.Context, wait time.Duration) (NextReply, error) {
+	var reply NextReply
+	err := c.call(ctx, "Room.Next", NextArgs{Wait: wait}, &reply)
+	return reply, err
+}
+
+// Send sends msg to the room, the server decides if it is accepted.
+func (c *Conn) Send(ctx context.Context, msg mptymsg.Recordable) error {
+	b, err := mptymsg.JsonMarshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.call(ctx, "Room.Send", SendArgs{Msg: b}, &SendReply{})
+}
+
+// Info returns the info of the client the server knows as id, for the
+// ClientModel of a Model. Its styles are rendered for the terminal of the
+// embedding program, and it has no access of its own, the server checks what
+// the client may do.
+func Info(id mpty.ClientId) *mpty.ClientInfoModel {
+	return &mpty.ClientInfoModel{
+		Term: os.Getenv("TERM"),
+		Time: time.Now(),
+
+		SessionId: id.Session(),
+		Who:       &apitype.WhoIsResponse{UserProfile: &tailcfg.UserProfile{LoginName: id.Identity()}},
+
+		Locale: i18n.Detect(os.Environ()),
+	}
+}
@@ -0,0 +1,116 @@
+package remote
+
+import (
+	"encoding/json"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/mpty/mptymsg"
+	"github.com/stretchr/testify/require"
+)
+
+type remoteTestMsg struct {
+	At  time.Time
+	Str string
+}
+
+func init() {
+	mptymsg.Register(remoteTestMsg{})
+}
+
+func (m remoteTestMsg) TypeName() string               { return "remote.remoteTestMsg" }
+func (m remoteTestMsg) Ts() time.Time                  { return m.At }
+func (m remoteTestMsg) SetId(int64) mptymsg.Recordable { return m }
+
+// testRoom is the Room service of a server
+type testRoom struct {
+	next chan NextReply
+	sent chan json.RawMessage
+}
+
+func (r *testRoom) Join(args JoinArgs, reply *JoinReply) error {
+	reply.Id = "alice@example.com s1"
+	return nil
+}
+
+func (r *testRoom) Next(args NextArgs, reply *NextReply) error {
+	*reply = <-r.next
+	return nil
+}
+
+func (r *testRoom) Send(args SendArgs, reply *SendReply) error {
+	r.sent <- args.Msg
+	return nil
+}
+
+type testClient struct {
+	*mpty.ClientInfoModel
+	input mpty.Input
+	msgs  []tea.Msg
+}
+
+func (c *testClient) Update(msg tea.Msg) (tea.Model, tea.Cmd) { return c.UpdateClient(msg) }
+func (c *testClient) View() string                            { return "" }
+func (c *testClient) Err() error                              { return nil }
+
+func (c *testClient) UpdateClient(msg tea.Msg) (mpty.ClientModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case mpty.Input:
+		c.input = msg
+	case []tea.Msg:
+		c.msgs = append(c.msgs, msg...)
+	}
+	return c, nil
+}
+
+func TestModel(t *testing.T) {
+	room := &testRoom{next: make(chan NextReply, 1), sent: make(chan json.RawMessage, 1)}
+	srv := rpc.NewServer()
+	require.NoError(t, srv.RegisterName("Room", room))
+	client, server := net.Pipe()
+	go srv.ServeCodec(jsonrpc.NewServerCodec(server))
+
+	conn := NewConn(client)
+	defer conn.Close()
+	id, err := conn.Join(t.Context(), JoinArgs{})
+	require.NoError(t, err)
+	require.Equal(t, mpty.ClientId("alice@example.com s1"), id)
+	require.Equal(t, id, Info(id).Id())
+
+	c := &testClient{ClientInfoModel: Info(id)}
+	m := New(t.Context(), conn, c)
+	cmds := m.Init()().(tea.BatchMsg)
+	require.Len(t, cmds, 3)
+	m.Update(cmds[0]())
+	require.NotNil(t, c.input)
+
+	// the messages of another room are left alone
+	other := New(t.Context(), conn, &testClient{})
+	m.Update(msg{other, batchMsg{msgs: []tea.Msg{remoteTestMsg{Str: "other"}}}})
+	require.Empty(t, c.msgs)
+
+	b, err := mptymsg.JsonMarshal(remoteTestMsg{Str: "live"})
+	require.NoError(t, err)
+	room.next <- NextReply{Msgs: []json.RawMessage{b}, Resume: "1"}
+	m.Update(cmds[1]())
+	require.Equal(t, []tea.Msg{remoteTestMsg{Str: "live"}, mpty.ResumeMsg{Token: "1"}}, c.msgs)
+	require.Equal(t, "1", m.Resume())
+
+	c.input <- mpty.ClientConnectMsg(id)
+	c.input <- remoteTestMsg{Str: "sent"}
+	require.Equal(t, msg{m, sentMsg{typeName: "remote.remoteTestMsg"}}, cmds[2]())
+	sent, err := mptymsg.JsonUnmarshal(<-room.sent)
+	require.NoError(t, err)
+	require.Equal(t, remoteTestMsg{Str: "sent"}, sent)
+
+	room.next <- NextReply{Left: true}
+	_, cmd := m.Update(m.next()())
+	require.True(t, m.Left())
+	require.NoError(t, m.Err())
+	require.Equal(t, LeftMsg{}, cmd())
+}
@@ -0,0 +1,67 @@
+package mpty
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/ghthor/webtea/mpty/mptymsg"
+)
+
+// ResumeEnv is the ssh environment variable, and ResumeParam the web
+// terminal argument, a reconnecting client passes its resume token in. The
+// web terminal only receives arguments with TerminalOptions.PermitArguments.
+const (
+	ResumeEnv   = "WEBTEA_RESUME"
+	ResumeParam = "resume"
+)
+
+// TabParam is the web terminal argument a browser tab passes its id in, so a
+// reloaded tab is reattached to its program, see tstea.WithReattach.
+const TabParam = "tab"
+
+// resumeReadLen is the most recorded messages replayed to a resuming client,
+// anything missed before them is reported with a GapMsg.
+const resumeReadLen = 1000
+
+// ResumeMsg is delivered to a client after the recorded messages, and with
+// every batch of messages that advanced its sequence. Reconnecting with Token
+// replays only the messages the client missed.
+type ResumeMsg struct {
+	Token string
+}
+
+// ResumeToken returns the token for a client that has applied every message
+// up to seq.
+func ResumeToken(seq uint64) string {
+	return strconv.FormatUint(seq, 36)
+}
+
+// ParseResumeToken returns the last sequence number applied by the client
+// that was given token.
+func ParseResumeToken(token string) (uint64, error) {
+	seq, err := strconv.ParseUint(token, 36, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid resume token: %q", token)
+	}
+	return seq, nil
+}
+
+type resumeKey struct{}
+
+// WithResume returns a ctx for NewClientProgram that resumes the client
+// after the sequence number seq.
+func WithResume(ctx context.Context, seq uint64) context.Context {
+	return context.WithValue(ctx, resumeKey{}, seq)
+}
+
+func resumeAfter(ctx context.Context) uint64 {
+	seq, _ := ctx.Value(resumeKey{}).(uint64)
+	return seq
+}
+
+// replayer is implemented by Recorders that can read the messages after a
+// sequence number, e.g. mptymsg.SqliteRecorder and mptymsg.MemoryRecorder.
+type replayer interface {
+	ReadAfter(seq uint64, n int) ([]mptymsg.Recordable, error)
+}
@@ -0,0 +1,190 @@
+package mpty
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Rooms are Programs a client can move between without reconnecting, each
+// with its own model, broadcast ring and history. Every Program added is
+// created WithRooms, so its clients can leave it.
+type Rooms struct {
+	mu sync.RWMutex
+	m  map[string]Program
+}
+
+func NewRooms() *Rooms {
+	return &Rooms{m: make(map[string]Program)}
+}
+
+// WithRooms lets the clients of the Program move to the rooms of r with a
+// JoinRoomMsg.
+func WithRooms(r *Rooms) Option {
+	return func(o *options) {
+		o.rooms = r
+	}
+}
+
+// Add makes p joinable as name.
+func (r *Rooms) Add(name string, p Program) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.m[name] = p
+}
+
+// Get returns the room called name.
+func (r *Rooms) Get(name string) (Program, bool) {
+	if r == nil {
+		return Program{}, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.m[name]
+	return p, ok
+}
+
+// Names returns the name of every room, sorted.
+func (r *Rooms) Names() []string {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Sorted(maps.Keys(r.m))
+}
+
+// ErrUnknownRoom refuses a JoinRoomMsg for a room that wasn't added.
+var ErrUnknownRoom = errors.New("no such room")
+
+type (
+	// JoinRoomMsg moves the client to Room, a ClientModel returns it from a
+	// command. The client leaves its room, as if it had disconnected, and
+	// joins Room within the same tea.Program. Room's Admitter decides if it
+	// may.
+	JoinRoomMsg struct {
+		Room string
+	}
+
+	// RoomJoinedMsg is sent to the client once it has joined Room, ahead of
+	// the Input of Room and its recorded history.
+	RoomJoinedMsg struct {
+		Room string
+	}
+
+	// RoomJoinErr is sent to the client when it couldn't join Room, it stays
+	// in its room.
+	RoomJoinErr struct {
+		Room string
+		Err  error
+	}
+
+	// roomSubscribedMsg is sent once the client has been admitted to room
+	// and subscribed to its ring
+	roomSubscribedMsg struct {
+		name        string
+		room        Program
+		resp        subResp
+		unsubscribe context.CancelFunc
+	}
+)
+
+// joinRoom subscribes the client to the ring of the room called name.
+func (m *ClientMain) joinRoom(name string) tea.Cmd {
+	room, ok := m.rooms.Get(name)
+	if !ok {
+		return func() tea.Msg { return RoomJoinErr{Room: name, Err: ErrUnknownRoom} }
+	}
+
+	ctx, id, join := m.ctx, m.Id(), joinReq(m.ctx, m.ClientModel)
+	return func() tea.Msg {
+		subCtx, unsubscribe := context.WithCancel(ctx)
+		respCh := make(chan subResp, 1)
+		select {
+		case <-ctx.Done():
+			unsubscribe()
+			return nil
+		case room.Send <- subReq{ctx: subCtx, id: id, resp: respCh, join: &join}:
+		}
+
+		select {
+		case <-ctx.Done():
+			unsubscribe()
+			return nil
+		case resp := <-respCh:
+			if resp.err != nil {
+				unsubscribe()
+				return RoomJoinErr{Room: name, Err: resp.err}
+			}
+			return roomSubscribedMsg{name: name, room: room, resp: resp, unsubscribe: unsubscribe}
+		}
+	}
+}
+
+// subscribed ends the subscription to the ring of the current room. The
+// switch to the new room waits for the last batch of the old ring, only one
+// reader of the rings may be running.
+func (m *ClientMain) subscribed(msg roomSubscribedMsg) {
+	if m.joining != nil {
+		// a room joined earlier was never switched to
+		m.joining.unsubscribe()
+	} else {
+		m.unsubscribe()
+	}
+	m.joining = &msg
+}
+
+// switchRoom moves the client to the room it is joining, once the reader of
+// the old ring has returned.
+func (m *ClientMain) switchRoom() tea.Cmd {
+	j := m.joining
+	m.joining = nil
+
+	id, left := m.Id(), m.Input
+	m.sessions.remove(id)
+
+	m.Input, m.sessions = j.room.Send, j.room.sessions
+	m.subscriber, m.unsubscribe = j.resp.subscriber, j.unsubscribe
+	m.rooms = j.room.rooms
+	m.backpressure = j.room.backpressure
+	m.session = m.sessions.add(m.ClientModel, m.program)
+	m.setStages(j.room)
+
+	// sequence numbers are numbered by each room
+	m.lastSeq = 0
+	initial := m.replayed(j.resp.initialMsgs)
+	ctx, joined, lastSeq := m.ctx, m.Input, m.lastSeq
+
+	return tea.Sequence(
+		func() tea.Msg {
+			for _, send := range []struct {
+				to  Input
+				msg tea.Msg
+			}{{left, ClientDisconnectMsg(id)}, {joined, ClientConnectMsg(id)}} {
+				select {
+				case <-ctx.Done():
+					return nil
+				case send.to <- send.msg:
+				}
+			}
+			return RoomJoinedMsg{Room: j.name}
+		},
+		func() tea.Msg {
+			return joined
+		},
+		func() tea.Msg {
+			return initial
+		},
+		func() tea.Msg {
+			if lastSeq == 0 {
+				return nil
+			}
+			return ResumeMsg{ResumeToken(lastSeq)}
+		},
+		m.ReadMsgsCmd(),
+	)
+}
@@ -0,0 +1,198 @@
+package mpty
+
+import (
+	"cmp"
+	"net"
+	"net/netip"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// SessionInfo describes a connected client program for operators.
+type SessionInfo struct {
+	Id        ClientId  `json:"id"`
+	Transport string    `json:"transport"`
+	Connected time.Time `json:"connected"`
+
+	// Addr is the remote address of the client, it isn't encoded since it
+	// may only be shown to those who can roles.CanViewAddresses
+	Addr string `json:"-"`
+
+	// Lag is how long the last broadcast tick took to reach the client
+	Lag time.Duration `json:"lag"`
+}
+
+// Stats summarizes the state of the Program.
+type Stats struct {
+	Started     time.Time `json:"started"`
+	Sessions    int       `json:"sessions"`
+	Identities  int       `json:"identities"`
+	Subscribers int64     `json:"subscribers"`
+
+	RingSize uint64 `json:"ring_size"`
+	// MemoryPressure is how many times history was shed to stay within the
+	// memory budget, see WithMemoryBudget
+	MemoryPressure int `json:"memory_pressure"`
+	// Stalled reports if the update loop is stalled right now and Stalls how
+	// many times it has, see WithWatchdog
+	Stalled bool  `json:"stalled"`
+	Stalls  int64 `json:"stalls"`
+	// SlowHandlers are the messages Main was slow to handle by their type,
+	// see WithSlowHandler
+	SlowHandlers map[string]SlowHandler `json:"slow_handlers,omitempty"`
+}
+
+// AnnounceMsg is broadcast to every client on behalf of an operator.
+type AnnounceMsg struct {
+	At  time.Time
+	Str string
+}
+
+// PeerMsg is sent to Main when a peer of the network of the server comes
+// online or goes offline, e.g. by tshelper.WatchPeers, for the model to
+// announce it.
+type PeerMsg struct {
+	At     time.Time
+	Name   string
+	Online bool
+}
+
+// Peer is a peer of the network of the server, see tshelper.WatchPeers.
+type Peer struct {
+	Name   string
+	Online bool
+}
+
+// AddrsMsg is sent to Main when the addresses of the server on its network
+// change.
+type AddrsMsg struct {
+	At    time.Time
+	Addrs []netip.Addr
+}
+
+type session struct {
+	info    SessionInfo
+	program *tea.Program
+	lag     atomic.Int64
+}
+
+type sessions struct {
+	mu sync.Mutex
+	m  map[ClientId]*session
+}
+
+func newSessions() *sessions {
+	return &sessions{m: make(map[ClientId]*session)}
+}
+
+func (s *sessions) add(m ClientModel, p *tea.Program) *session {
+	transport := "unknown"
+	if t, ok := m.(interface{ Transport() string }); ok {
+		transport = t.Transport()
+	}
+	var addr string
+	if a, ok := m.(interface{ RemoteAddr() net.Addr }); ok {
+		addr = a.RemoteAddr().String()
+	}
+
+	sess := &session{
+		info: SessionInfo{
+			Id:        m.Id(),
+			Transport: transport,
+			Connected: time.Now(),
+			Addr:      addr,
+		},
+		program: p,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m[sess.info.Id] = sess
+	return sess
+}
+
+func (s *sessions) remove(id ClientId) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.m, id)
+}
+
+// latency is a Stage recording the lag of the newest broadcast tick in msgs
+func (s *session) latency(_ ClientModel, msgs []tea.Msg) []tea.Msg {
+	for _, msg := range slices.Backward(msgs) {
+		if t, ok := msg.(time.Time); ok {
+			s.lag.Store(int64(time.Since(t)))
+			break
+		}
+	}
+	return msgs
+}
+
+// Sessions returns every connected client program, oldest first.
+func (p Program) Sessions() []SessionInfo {
+	p.sessions.mu.Lock()
+	defer p.sessions.mu.Unlock()
+
+	infos := make([]SessionInfo, 0, len(p.sessions.m))
+	for _, s := range p.sessions.m {
+		info := s.info
+		info.Lag = time.Duration(s.lag.Load())
+		infos = append(infos, info)
+	}
+	slices.SortFunc(infos, func(a, b SessionInfo) int {
+		return cmp.Or(a.Connected.Compare(b.Connected), cmp.Compare(a.Id, b.Id))
+	})
+	return infos
+}
+
+// Kick ends every session whose id or identity matches and returns how many
+// were ended.
+func (p Program) Kick(idOrIdentity string) int {
+	p.sessions.mu.Lock()
+	defer p.sessions.mu.Unlock()
+
+	n := 0
+	for id, s := range p.sessions.m {
+		if string(id) == idOrIdentity || id.Identity() == idOrIdentity {
+			s.program.Quit()
+			n++
+		}
+	}
+	return n
+}
+
+// Announce broadcasts str to every client.
+func (p Program) Announce(str string) {
+	select {
+	case <-p.ctx.Done():
+	case p.Send <- AnnounceMsg{At: time.Now(), Str: str}:
+	}
+}
+
+func (p Program) Stats() Stats {
+	p.sessions.mu.Lock()
+	defer p.sessions.mu.Unlock()
+
+	identities := make(map[string]struct{}, len(p.sessions.m))
+	for id := range p.sessions.m {
+		identities[id.Identity()] = struct{}{}
+	}
+	s := Stats{
+		Started:     p.startedAt,
+		Sessions:    len(p.sessions.m),
+		Identities:  len(identities),
+		Subscribers: p.broadcast.NumSubscribers(),
+		RingSize:    p.broadcast.Size(),
+
+		MemoryPressure: int(p.pressure.Load()),
+		SlowHandlers:   p.slow.stats(),
+	}
+	if p.watchdog != nil {
+		s.Stalled, s.Stalls = p.watchdog.stalled.Load(), p.watchdog.stalls.Load()
+	}
+	return s
+}
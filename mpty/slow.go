@@ -0,0 +1,119 @@
+package mpty
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ghthor/webtea/logpolicy"
+)
+
+// SlowBuckets are the upper bounds of the buckets of a SlowHandler, the last
+// bucket counts the messages that took longer.
+var SlowBuckets = []time.Duration{
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// WithSlowHandler logs every message Main takes longer than threshold to
+// handle, with its type and the client it came from, and counts them by type
+// in Stats. A threshold of 0 disables it.
+func WithSlowHandler(threshold time.Duration) Option {
+	return func(o *options) {
+		o.slow = nil
+		if threshold > 0 {
+			o.slow = &slowHandlers{threshold: threshold, types: make(map[string]*SlowHandler)}
+		}
+	}
+}
+
+// SlowHandler is a histogram of how long Main took to handle the messages of
+// a type that were slower than the threshold, see WithSlowHandler.
+type SlowHandler struct {
+	Count int64         `json:"count"`
+	Max   time.Duration `json:"max"`
+	// Buckets count the messages by SlowBuckets
+	Buckets []int64 `json:"buckets"`
+}
+
+type slowHandlers struct {
+	threshold time.Duration
+
+	mu    sync.Mutex
+	types map[string]*SlowHandler
+}
+
+// observe records how long Main took to handle msg, if it was slow.
+func (s *slowHandlers) observe(msg tea.Msg, took time.Duration) {
+	if s == nil || took < s.threshold {
+		return
+	}
+	typ := fmt.Sprintf("%T", msg)
+	logpolicy.Warn("mpty.slow."+typ, "slow message", "type", typ, "client", originOf(msg), "took", took)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, ok := s.types[typ]
+	if !ok {
+		h = &SlowHandler{Buckets: make([]int64, len(SlowBuckets)+1)}
+		s.types[typ] = h
+	}
+	h.Count++
+	h.Max = max(h.Max, took)
+	i := 0
+	for i < len(SlowBuckets) && took > SlowBuckets[i] {
+		i++
+	}
+	h.Buckets[i]++
+}
+
+// stats returns a copy of the histograms by message type.
+func (s *slowHandlers) stats() map[string]SlowHandler {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make(map[string]SlowHandler, len(s.types))
+	for typ, h := range s.types {
+		c := *h
+		c.Buckets = append([]int64(nil), h.Buckets...)
+		stats[typ] = c
+	}
+	return stats
+}
+
+var clientIdType = reflect.TypeFor[ClientId]()
+
+// originOf returns the client msg came from, if it is known. Besides the
+// messages of mpty, a struct with a ClientId Requestor or Id field, like the
+// requests of the chat, is from that client.
+func originOf(msg tea.Msg) ClientId {
+	switch msg := msg.(type) {
+	case ClientConnectMsg:
+		return ClientId(msg)
+	case ClientDisconnectMsg:
+		return ClientId(msg)
+	case subReq:
+		return msg.id
+	}
+
+	v := reflect.ValueOf(msg)
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	for _, name := range []string{"Requestor", "Id"} {
+		if f := v.FieldByName(name); f.IsValid() && f.Type() == clientIdType {
+			return ClientId(f.String())
+		}
+	}
+	return ""
+}
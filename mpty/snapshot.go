@@ -0,0 +1,133 @@
+package mpty
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/ghthor/webtea/mpty/mptymsg"
+)
+
+// Snapshotter is implemented by models with state, beyond the recorded
+// messages, that should survive a restart of the process. The snapshot is
+// saved when Program.Snapshot is called and restored once by the next
+// process, after the model's Init.
+type Snapshotter interface {
+	// Snapshot returns the state to save, it is encoded as json
+	Snapshot() (any, error)
+	Restore(json.RawMessage) error
+}
+
+const (
+	snapshotBucket = "mpty.snapshot"
+	snapshotKey    = "main"
+)
+
+// ErrNoSnapshotStore is returned by Program.Snapshot when the Recorder isn't
+// also a mptymsg.Store.
+var ErrNoSnapshotStore = errors.New("recorder can't store snapshots")
+
+type snapshotReq struct {
+	// discard deletes the saved snapshot instead
+	discard bool
+	// handoff stops recording once the snapshot is saved
+	handoff bool
+	resp    chan<- error
+}
+
+// Snapshot saves the state of the model in the Recorder, which must also be a
+// mptymsg.Store. Models that aren't a Snapshotter have nothing to save.
+func (p Program) Snapshot(ctx context.Context) error {
+	return p.snapshotReq(ctx, snapshotReq{})
+}
+
+// Handoff saves the snapshot like Snapshot, then stops recording and
+// numbering messages so the process taking over, which loads the last
+// sequence number from the same Recorder, never records one twice. The
+// messages of this process are still broadcast to its clients while they
+// drain, without a sequence number their resume tokens stay at the last one
+// recorded. ErrNoSnapshotStore is returned once recording stopped.
+func (p Program) Handoff(ctx context.Context) error {
+	return p.snapshotReq(ctx, snapshotReq{handoff: true})
+}
+
+// DiscardSnapshot deletes the snapshot saved by Snapshot, e.g. when the
+// process that should have restored it failed to start, so it isn't restored
+// by an unrelated later start. Recording resumes after a Handoff, the
+// messages broadcast since aren't recorded.
+func (p Program) DiscardSnapshot(ctx context.Context) error {
+	return p.snapshotReq(ctx, snapshotReq{discard: true})
+}
+
+func (p Program) snapshotReq(ctx context.Context, req snapshotReq) error {
+	resp := make(chan error, 1)
+	req.resp = resp
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case p.Send <- req:
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-resp:
+		return err
+	}
+}
+
+func (m *Main) snapshot() error {
+	s, ok := m.Model.(Snapshotter)
+	if !ok {
+		return nil
+	}
+	store, ok := m.recorder.(mptymsg.Store)
+	if !ok {
+		return ErrNoSnapshotStore
+	}
+
+	v, err := s.Snapshot()
+	if err != nil {
+		return err
+	}
+	return store.Put(snapshotBucket, snapshotKey, v)
+}
+
+// handoff saves the snapshot and stops recording, unless saving it failed
+// and the handoff won't happen.
+func (m *Main) handoff() error {
+	err := m.snapshot()
+	m.handedOff = err == nil || errors.Is(err, ErrNoSnapshotStore)
+	return err
+}
+
+func (m *Main) discardSnapshot() error {
+	store, ok := m.recorder.(mptymsg.Store)
+	if !ok {
+		return nil
+	}
+	return store.Delete(snapshotBucket, snapshotKey)
+}
+
+// restore applies the snapshot left by a previous process and deletes it, so
+// it's never restored twice.
+func (m *Main) restore() error {
+	s, ok := m.Model.(Snapshotter)
+	if !ok {
+		return nil
+	}
+	store, ok := m.recorder.(mptymsg.Store)
+	if !ok {
+		return nil
+	}
+
+	var raw json.RawMessage
+	found, err := store.Get(snapshotBucket, snapshotKey, &raw)
+	if err != nil || !found {
+		return err
+	}
+	if err := store.Delete(snapshotBucket, snapshotKey); err != nil {
+		return err
+	}
+	return s.Restore(raw)
+}
@@ -0,0 +1,36 @@
+package mpty
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ghthor/webtea/mpty/mptymsg"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandoffStopsRecording(t *testing.T) {
+	r := mptymsg.NewMemory(10)
+	p := startProgram(t, &testModel{}, r)
+	ctx := context.Background()
+
+	send(t, p, testMsg{Value: "before"})
+	require.NoError(t, p.Handoff(ctx))
+	send(t, p, testMsg{Value: "after"})
+
+	seq, err := r.LastSeq()
+	require.NoError(t, err)
+	require.EqualValues(t, 1, seq, "the process taking over numbers the messages after it")
+	msgs, err := r.Read(10)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, "before", msgs[0].(testMsg).Value)
+
+	// a failed handoff records again, after the last sequence number
+	require.NoError(t, p.DiscardSnapshot(ctx))
+	send(t, p, testMsg{Value: "discarded"})
+
+	msgs, err = r.Read(10)
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	require.Equal(t, testMsg{Value: "discarded", id: 2, seq: 2}, msgs[1])
+}
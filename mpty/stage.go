@@ -0,0 +1,71 @@
+package mpty
+
+import (
+	"sync/atomic"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Stage processes each batch of broadcast messages a client receives before
+// its model is updated with it, e.g. to filter, rewrite or measure them, and
+// returns the batch to pass on. Stages may modify msgs but must not keep it,
+// its array is reused for the next batch.
+type Stage func(m ClientModel, msgs []tea.Msg) []tea.Msg
+
+// Stager is implemented by ClientModels with stages of their own, e.g. an
+// ignore list. They run after the stages of the Program.
+type Stager interface {
+	Stages() []Stage
+}
+
+// WithStages runs stages, in order, on the batches of every client.
+func WithStages(stages ...Stage) Option {
+	return func(o *options) {
+		o.stages = append(o.stages, stages...)
+	}
+}
+
+// Filter returns a Stage dropping the messages keep returns false for.
+func Filter(keep func(ClientModel, tea.Msg) bool) Stage {
+	return func(m ClientModel, msgs []tea.Msg) []tea.Msg {
+		out := msgs[:0]
+		for _, msg := range msgs {
+			if keep(m, msg) {
+				out = append(out, msg)
+			}
+		}
+		return out
+	}
+}
+
+// Map returns a Stage replacing every message, in place, with the result of
+// fn.
+func Map(fn func(ClientModel, tea.Msg) tea.Msg) Stage {
+	return func(m ClientModel, msgs []tea.Msg) []tea.Msg {
+		for i, msg := range msgs {
+			msgs[i] = fn(m, msg)
+		}
+		return msgs
+	}
+}
+
+// BatchMetrics counts the batches delivered to clients, see Stage.
+type BatchMetrics struct {
+	Batches, Msgs, MaxSize atomic.Int64
+}
+
+// Stage returns a Stage counting the batches it sees.
+func (b *BatchMetrics) Stage() Stage {
+	return func(_ ClientModel, msgs []tea.Msg) []tea.Msg {
+		n := int64(len(msgs))
+		b.Batches.Add(1)
+		b.Msgs.Add(n)
+		for {
+			max := b.MaxSize.Load()
+			if n <= max || b.MaxSize.CompareAndSwap(max, n) {
+				break
+			}
+		}
+		return msgs
+	}
+}
@@ -0,0 +1,287 @@
+// Package teav2 runs models written against bubbletea v2 under mpty, whose
+// programs are bubbletea v1 programs. The messages a v2 model receives are
+// converted from v1, e.g. KeyMsg to KeyPressMsg, and the messages of its
+// commands back to v1, e.g. QuitMsg, Batch and Sequence.
+//
+// The batches broadcast by mpty arrive as a []tea.Msg of bubbletea v1, which
+// is what mpty.Dispatcher handles, and the mpty.Input as is.
+//
+//	client := teav2.Client(newV2Client(ctx, info))
+package teav2
+
+import (
+	"reflect"
+	"strings"
+	"unicode/utf8"
+
+	tea "charm.land/bubbletea/v2"
+	teav1 "github.com/charmbracelet/bubbletea"
+	"github.com/ghthor/webtea/mpty"
+)
+
+// Model adapts a bubbletea v2 model to v1, e.g. the server model of a
+// mpty.Program.
+//
+// Of the fields of the tea.View only Content is rendered. Changes to its
+// WindowTitle, AltScreen and MouseMode are applied with the v1 commands, the
+// rest of them, e.g. Cursor, have no v1 equivalent.
+func Model(m tea.Model) teav1.Model {
+	return &model{Model: m}
+}
+
+type model struct {
+	tea.Model
+
+	// view is rendered by Update, so the changes to it are applied with
+	// the commands of the update, applied is the view they were last
+	// applied for
+	view     tea.View
+	applied  tea.View
+	rendered bool
+}
+
+func (m *model) Init() teav1.Cmd {
+	return fromCmd(m.Model.Init())
+}
+
+func (m *model) Update(msg teav1.Msg) (teav1.Model, teav1.Cmd) {
+	return m, m.update(msg)
+}
+
+func (m *model) update(msg teav1.Msg) teav1.Cmd {
+	var cmd tea.Cmd
+	m.Model, cmd = m.Model.Update(toMsg(msg))
+	return teav1.Batch(fromCmd(cmd), m.render())
+}
+
+// render renders the view and returns the commands that apply the changes to
+// it.
+func (m *model) render() teav1.Cmd {
+	m.view, m.rendered = m.Model.View(), true
+	last := m.applied
+	m.applied = m.view
+
+	var cmds []teav1.Cmd
+	if m.view.WindowTitle != last.WindowTitle {
+		cmds = append(cmds, teav1.SetWindowTitle(m.view.WindowTitle))
+	}
+	if m.view.AltScreen != last.AltScreen {
+		if m.view.AltScreen {
+			cmds = append(cmds, teav1.EnterAltScreen)
+		} else {
+			cmds = append(cmds, teav1.ExitAltScreen)
+		}
+	}
+	if m.view.MouseMode != last.MouseMode {
+		switch m.view.MouseMode {
+		case tea.MouseModeCellMotion:
+			cmds = append(cmds, teav1.EnableMouseCellMotion)
+		case tea.MouseModeAllMotion:
+			cmds = append(cmds, teav1.EnableMouseAllMotion)
+		default:
+			cmds = append(cmds, teav1.DisableMouse)
+		}
+	}
+	return teav1.Batch(cmds...)
+}
+
+func (m *model) View() string {
+	if !m.rendered {
+		m.view, m.rendered = m.Model.View(), true
+	}
+	return m.view.Content
+}
+
+// ClientModel is a mpty.ClientModel written against bubbletea v2.
+type ClientModel interface {
+	tea.Model
+
+	Id() mpty.ClientId
+	Err() error
+}
+
+// Client adapts a bubbletea v2 client model to a mpty.ClientModel, see
+// Model.
+func Client(m ClientModel) mpty.ClientModel {
+	return &client{model: model{Model: m}}
+}
+
+type client struct {
+	model
+}
+
+func (c *client) Update(msg teav1.Msg) (teav1.Model, teav1.Cmd) {
+	return c, c.update(msg)
+}
+
+func (c *client) UpdateClient(msg teav1.Msg) (mpty.ClientModel, teav1.Cmd) {
+	return c, c.update(msg)
+}
+
+func (c *client) Id() mpty.ClientId {
+	return c.Model.(ClientModel).Id()
+}
+
+func (c *client) Err() error {
+	return c.Model.(ClientModel).Err()
+}
+
+// toMsg converts the v1 messages that changed in v2.
+func toMsg(msg teav1.Msg) tea.Msg {
+	switch msg := msg.(type) {
+	case teav1.KeyMsg:
+		if msg.Paste {
+			return tea.PasteMsg{Content: string(msg.Runes)}
+		}
+		return tea.KeyPressMsg(toKey(msg))
+	case teav1.MouseMsg:
+		return toMouse(msg)
+	case teav1.WindowSizeMsg:
+		return tea.WindowSizeMsg{Width: msg.Width, Height: msg.Height}
+	case teav1.FocusMsg:
+		return tea.FocusMsg{}
+	case teav1.BlurMsg:
+		return tea.BlurMsg{}
+	case teav1.QuitMsg:
+		return tea.QuitMsg{}
+	case teav1.InterruptMsg:
+		return tea.InterruptMsg{}
+	}
+	return msg
+}
+
+// keyCodes are the v2 codes of the keys v1 names
+var keyCodes = map[string]rune{
+	"enter":     tea.KeyEnter,
+	"tab":       tea.KeyTab,
+	"backspace": tea.KeyBackspace,
+	"esc":       tea.KeyEscape,
+	" ":         tea.KeySpace,
+	"up":        tea.KeyUp,
+	"down":      tea.KeyDown,
+	"left":      tea.KeyLeft,
+	"right":     tea.KeyRight,
+	"home":      tea.KeyHome,
+	"end":       tea.KeyEnd,
+	"pgup":      tea.KeyPgUp,
+	"pgdown":    tea.KeyPgDown,
+	"delete":    tea.KeyDelete,
+	"insert":    tea.KeyInsert,
+	"f1":        tea.KeyF1,
+	"f2":        tea.KeyF2,
+	"f3":        tea.KeyF3,
+	"f4":        tea.KeyF4,
+	"f5":        tea.KeyF5,
+	"f6":        tea.KeyF6,
+	"f7":        tea.KeyF7,
+	"f8":        tea.KeyF8,
+	"f9":        tea.KeyF9,
+	"f10":       tea.KeyF10,
+	"f11":       tea.KeyF11,
+	"f12":       tea.KeyF12,
+}
+
+// keyMods are the v2 modifiers of the prefixes of v1 key names
+var keyMods = map[string]tea.KeyMod{
+	"ctrl":  tea.ModCtrl,
+	"alt":   tea.ModAlt,
+	"shift": tea.ModShift,
+}
+
+// toKey converts a v1 key by its name, e.g. "ctrl+shift+up".
+func toKey(msg teav1.KeyMsg) tea.Key {
+	if msg.Type == teav1.KeyRunes && len(msg.Runes) > 0 {
+		k := tea.Key{Code: msg.Runes[0], Text: string(msg.Runes)}
+		if msg.Alt {
+			k.Mod, k.Text = tea.ModAlt, ""
+		}
+		return k
+	}
+
+	var k tea.Key
+	name := msg.String()
+	for {
+		prefix, rest, ok := strings.Cut(name, "+")
+		mod, isMod := keyMods[prefix]
+		if !ok || !isMod || rest == "" {
+			break
+		}
+		k.Mod |= mod
+		name = rest
+	}
+	if code, ok := keyCodes[name]; ok {
+		k.Code = code
+	} else if r, size := utf8.DecodeRuneInString(name); size == len(name) {
+		k.Code = r
+	}
+	if k.Code == tea.KeySpace && k.Mod == 0 {
+		k.Text = " "
+	}
+	return k
+}
+
+func toMouse(msg teav1.MouseMsg) tea.Msg {
+	m := tea.Mouse{X: msg.X, Y: msg.Y, Button: tea.MouseButton(msg.Button)}
+	if msg.Ctrl {
+		m.Mod |= tea.ModCtrl
+	}
+	if msg.Alt {
+		m.Mod |= tea.ModAlt
+	}
+	if msg.Shift {
+		m.Mod |= tea.ModShift
+	}
+	switch {
+	case teav1.MouseEvent(msg).IsWheel():
+		return tea.MouseWheelMsg(m)
+	case msg.Action == teav1.MouseActionRelease:
+		return tea.MouseReleaseMsg(m)
+	case msg.Action == teav1.MouseActionMotion:
+		return tea.MouseMotionMsg(m)
+	default:
+		return tea.MouseClickMsg(m)
+	}
+}
+
+var cmdsType = reflect.TypeFor[[]tea.Cmd]()
+
+// fromCmd converts the messages of a v2 command to v1.
+func fromCmd(cmd tea.Cmd) teav1.Cmd {
+	if cmd == nil {
+		return nil
+	}
+	return func() teav1.Msg {
+		return fromMsg(cmd())
+	}
+}
+
+func fromMsg(msg tea.Msg) teav1.Msg {
+	switch msg := msg.(type) {
+	case nil:
+		return nil
+	case tea.QuitMsg:
+		return teav1.QuitMsg{}
+	case tea.InterruptMsg:
+		return teav1.InterruptMsg{}
+	case tea.BatchMsg:
+		return teav1.BatchMsg(fromCmds(msg))
+	}
+
+	// tea.Sequence returns an unexported []tea.Cmd
+	if v := reflect.ValueOf(msg); v.Kind() == reflect.Slice && v.Type().ConvertibleTo(cmdsType) {
+		seq := teav1.Sequence(fromCmds(v.Convert(cmdsType).Interface().([]tea.Cmd))...)
+		if seq == nil {
+			return nil
+		}
+		return seq()
+	}
+	return msg
+}
+
+func fromCmds(cmds []tea.Cmd) []teav1.Cmd {
+	converted := make([]teav1.Cmd, len(cmds))
+	for i, cmd := range cmds {
+		converted[i] = fromCmd(cmd)
+	}
+	return converted
+}
@@ -0,0 +1,66 @@
+package teav2
+
+import (
+	"reflect"
+	"testing"
+
+	tea "charm.land/bubbletea/v2"
+	teav1 "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToKey(t *testing.T) {
+	for _, c := range []struct {
+		v1 teav1.KeyMsg
+		v2 string
+	}{
+		{teav1.KeyMsg{Type: teav1.KeyRunes, Runes: []rune("a")}, "a"},
+		{teav1.KeyMsg{Type: teav1.KeyRunes, Runes: []rune("a"), Alt: true}, "alt+a"},
+		{teav1.KeyMsg{Type: teav1.KeyEnter}, "enter"},
+		{teav1.KeyMsg{Type: teav1.KeySpace, Runes: []rune(" ")}, "space"},
+		{teav1.KeyMsg{Type: teav1.KeyCtrlC}, "ctrl+c"},
+		{teav1.KeyMsg{Type: teav1.KeyShiftTab}, "shift+tab"},
+		{teav1.KeyMsg{Type: teav1.KeyCtrlShiftUp}, "ctrl+shift+up"},
+		{teav1.KeyMsg{Type: teav1.KeyF5}, "f5"},
+	} {
+		require.Equal(t, c.v2, tea.KeyPressMsg(toKey(c.v1)).String(), c.v1.String())
+	}
+
+	require.Equal(t, tea.PasteMsg{Content: "hi"}, toMsg(teav1.KeyMsg{Type: teav1.KeyRunes, Runes: []rune("hi"), Paste: true}))
+}
+
+type counter struct {
+	n int
+}
+
+func (c counter) Init() tea.Cmd { return nil }
+
+func (c counter) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if k, ok := msg.(tea.KeyPressMsg); ok && k.String() == "q" {
+		return c, tea.Sequence(func() tea.Msg { return "done" }, tea.Quit)
+	}
+	c.n++
+	return c, nil
+}
+
+func (c counter) View() tea.View {
+	v := tea.NewView("count")
+	v.WindowTitle = "counter"
+	return v
+}
+
+func TestModel(t *testing.T) {
+	m := Model(counter{})
+	_, cmd := m.Update(teav1.WindowSizeMsg{Width: 80, Height: 24})
+	require.Equal(t, "count", m.View())
+	require.Equal(t, teav1.SetWindowTitle("counter")(), cmd())
+
+	_, cmd = m.Update(teav1.KeyMsg{Type: teav1.KeyRunes, Runes: []rune("q")})
+	// the v1 sequence is unexported
+	seq := reflect.ValueOf(cmd())
+	require.Equal(t, reflect.Slice, seq.Kind())
+	cmds := seq.Convert(reflect.TypeFor[[]teav1.Cmd]()).Interface().([]teav1.Cmd)
+	require.Len(t, cmds, 2)
+	require.Equal(t, "done", cmds[0]())
+	require.Equal(t, teav1.QuitMsg{}, cmds[1]())
+}
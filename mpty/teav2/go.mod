@@ -0,0 +1,17 @@
+module github.com/ghthor/webtea/mpty/teav2
+
+go 1.25.3
+
+require (
+	charm.land/bubbletea/v2 v2.0.2
+	github.com/charmbracelet/bubbletea v1.3.10
+	github.com/ghthor/webtea v0.0.0
+	github.com/stretchr/testify v1.11.1
+)
+
+// bubbletea v2 requires an x/ansi the cellbuf of bubbletea v1 only builds
+// against from v0.0.15
+require github.com/charmbracelet/x/cellbuf v0.0.15 // indirect
+
+// the mpty package is developed alongside this module
+replace github.com/ghthor/webtea => ../..
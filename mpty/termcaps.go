@@ -0,0 +1,117 @@
+package mpty
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync/atomic"
+
+	"github.com/charmbracelet/ssh"
+)
+
+// TermCaps are the capabilities the terminal of an ssh session answered to
+// when it was probed, see tstea.WithTermProbe. The zero value is a terminal
+// that wasn't probed, or didn't answer.
+type TermCaps struct {
+	// Probed is set once the terminal answered the probe
+	Probed bool
+
+	// Attributes are the primary device attributes (DA1), e.g. 4 for sixel
+	// graphics
+	Attributes []int
+	// Terminal and Version are the secondary device attributes (DA2)
+	Terminal, Version int
+
+	// KittyKeyboard is set when the terminal supports the progressive
+	// enhancements of the kitty keyboard protocol, KittyFlags are the ones
+	// enabled
+	KittyKeyboard bool
+	KittyFlags    int
+
+	// SyncOutput is set when the terminal supports synchronized output, mode
+	// 2026
+	SyncOutput bool
+	TrueColor  bool
+}
+
+type termCapsKey struct{}
+
+// SetTermCaps stores the caps of the terminal of an ssh session in its ctx,
+// they are picked up by NewClientInfoModelFromSsh.
+func SetTermCaps(ctx ssh.Context, caps TermCaps) {
+	ctx.SetValue(termCapsKey{}, caps)
+}
+
+// TermCapsOf returns the caps stored in ctx by SetTermCaps.
+func TermCapsOf(ctx context.Context) TermCaps {
+	caps, _ := ctx.Value(termCapsKey{}).(TermCaps)
+	return caps
+}
+
+// beginSync and endSync bracket a frame in synchronized output, mode 2026
+const (
+	beginSync = "\x1b[?2026h"
+	endSync   = "\x1b[?2026l"
+)
+
+// SyncOutput wraps the frames written to a terminal in synchronized output
+// while it is on, so terminals that support it draw each frame at once
+// instead of tearing, e.g. during the rapid redraws of a game. The renderer
+// writes every frame with a single Write. A nil SyncOutput is always off.
+type SyncOutput struct {
+	on atomic.Bool
+}
+
+// Set turns synchronized output on or off.
+func (s *SyncOutput) Set(on bool) {
+	if s != nil {
+		s.on.Store(on)
+	}
+}
+
+// On reports if synchronized output is on.
+func (s *SyncOutput) On() bool {
+	return s != nil && s.on.Load()
+}
+
+// Writer returns w with the frames synchronized while s is on. Terminals
+// are kept as *os.File, the program needs their file descriptor.
+func (s *SyncOutput) Writer(w io.Writer) io.Writer {
+	if f, ok := w.(*os.File); ok {
+		return syncFile{f, s}
+	}
+	return syncWriter{w, s}
+}
+
+type syncWriter struct {
+	io.Writer
+	s *SyncOutput
+}
+
+func (w syncWriter) Write(p []byte) (int, error) {
+	return w.s.write(w.Writer, p)
+}
+
+type syncFile struct {
+	*os.File
+	s *SyncOutput
+}
+
+func (w syncFile) Write(p []byte) (int, error) {
+	return w.s.write(w.File, p)
+}
+
+func (s *SyncOutput) write(w io.Writer, p []byte) (int, error) {
+	if !s.On() || len(p) == 0 {
+		return w.Write(p)
+	}
+	frame := make([]byte, 0, len(beginSync)+len(p)+len(endSync))
+	frame = append(frame, beginSync...)
+	frame = append(frame, p...)
+	frame = append(frame, endSync...)
+	n, err := w.Write(frame)
+	if err != nil {
+		return min(max(0, n-len(beginSync)), len(p)), err
+	}
+	return len(p), nil
+}
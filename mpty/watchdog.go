@@ -0,0 +1,94 @@
+package mpty
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// ErrStalled is the cause the context of a Program is cancelled with when its
+// update loop stalls, see WithWatchdog.
+var ErrStalled = errors.New("mpty: update loop stalled")
+
+// WithWatchdog watches that the update loop of the Program keeps handling
+// its ticks. Once it hasn't for timeout, e.g. because a model blocks in
+// Update, the stacks of every goroutine are logged and Stats reports the
+// Program as stalled until the loop catches up. With cancel the context of
+// the Program is also cancelled with ErrStalled, so whatever runs under it
+// shuts down instead of silently stalling with it. Main ticks every second,
+// timeout should be a few seconds at least. A timeout of 0 disables it.
+func WithWatchdog(timeout time.Duration, cancel bool) Option {
+	return func(o *options) {
+		o.watchdog = nil
+		if timeout > 0 {
+			o.watchdog = &watchdog{timeout: timeout, cancel: cancel}
+		}
+	}
+}
+
+type watchdog struct {
+	timeout time.Duration
+	cancel  bool
+
+	// tick is when Main last handled a tick, in unix nanoseconds
+	tick    atomic.Int64
+	stalled atomic.Bool
+	stalls  atomic.Int64
+}
+
+// beat is called by Main for every tick it handles.
+func (w *watchdog) beat() {
+	if w != nil {
+		w.tick.Store(time.Now().UnixNano())
+	}
+}
+
+// watch checks the ticks of Main until it has exited, a stalled Main may
+// never.
+func (w *watchdog) watch(exited <-chan struct{}, cancel context.CancelCauseFunc) {
+	w.beat()
+	t := time.NewTicker(w.timeout / 4)
+	defer t.Stop()
+	for {
+		select {
+		case <-exited:
+			return
+		case now := <-t.C:
+			w.check(now, cancel)
+		}
+	}
+}
+
+func (w *watchdog) check(now time.Time, cancel context.CancelCauseFunc) {
+	last := time.Unix(0, w.tick.Load())
+	if now.Sub(last) < w.timeout {
+		if w.stalled.CompareAndSwap(true, false) {
+			log.Warn("update loop recovered", "stalled_for", now.Sub(last))
+		}
+		return
+	}
+	if !w.stalled.CompareAndSwap(false, true) {
+		return
+	}
+	w.stalls.Add(1)
+	log.Error("update loop stalled", "last_tick", last, "cancel", w.cancel, "stacks", string(stacks()))
+	if w.cancel {
+		cancel(ErrStalled)
+	}
+}
+
+// stacks returns the stacks of every goroutine.
+func stacks() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
@@ -0,0 +1,82 @@
+package webtea
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// WithOrigins only accepts websocket connections from pages served by one of
+// hosts and stops other sites from framing the terminal. A host without a
+// port matches any port. By default gotty only accepts same origin requests.
+func WithOrigins(hosts ...string) HTTPOption {
+	return func(c *httpConfig) {
+		if len(hosts) == 0 {
+			return
+		}
+		c.gotty.WSOrigin = originPattern(hosts)
+		c.frameGuard = true
+	}
+}
+
+// WithAuthToken requires the websocket to authenticate with token before a
+// terminal is started. An empty token generates a random one. The token is
+// handed to the page through auth_token.js, which is only served to same
+// origin requests.
+func WithAuthToken(token string) HTTPOption {
+	return func(c *httpConfig) {
+		if token == "" {
+			token = rand.Text()
+		}
+		c.gotty.Credential = token
+		c.gotty.EnableBasicAuth = false
+		c.authToken = true
+	}
+}
+
+func originPattern(hosts []string) string {
+	alts := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		host, port, err := net.SplitHostPort(h)
+		if err != nil {
+			host, port = strings.Trim(h, "[]"), ""
+		}
+
+		alt := regexp.QuoteMeta(host)
+		if strings.Contains(host, ":") {
+			alt = `\[` + alt + `\]`
+		}
+		if port != "" {
+			alt += ":" + regexp.QuoteMeta(port)
+		} else {
+			alt += `(:\d+)?`
+		}
+		alts = append(alts, alt)
+	}
+	return fmt.Sprintf(`^https?://(%s)$`, strings.Join(alts, "|"))
+}
+
+// sameOrigin rejects requests browsers report as coming from another site,
+// so a page on another origin can't load the auth token as a script.
+func sameOrigin(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if site := r.Header.Get("Sec-Fetch-Site"); site != "" && site != "same-origin" {
+			http.Error(w, "cross origin request", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Cross-Origin-Resource-Policy", "same-origin")
+		h.ServeHTTP(w, r)
+	})
+}
+
+// noFraming stops the terminal from being embedded by other sites
+func noFraming(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", "frame-ancestors 'self'")
+		w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,28 @@
+package webtea
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestOriginPattern(t *testing.T) {
+	re := regexp.MustCompile(originPattern([]string{"webtea", "webtea.tail1234.ts.net", "100.64.0.1:8080", "fd7a::1"}))
+
+	for origin, want := range map[string]bool{
+		"http://webtea":                     true,
+		"http://webtea:28080":               true,
+		"https://webtea.tail1234.ts.net":    true,
+		"http://100.64.0.1:8080":            true,
+		"http://100.64.0.1:9090":            false,
+		"http://[fd7a::1]:28080":            true,
+		"http://webtea.evil.example":        false,
+		"http://webteaxtail1234.ts.net":     false,
+		"http://evil.example/http://webtea": false,
+		"":                                  false,
+		"null":                              false,
+	} {
+		if got := re.MatchString(origin); got != want {
+			t.Errorf("%q matched %v, want %v", origin, got, want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package webtea
+
+import (
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// RateLimit is a token bucket refilled with Rate tokens per second up to
+// Burst. A zero Rate disables the limit.
+type RateLimit struct {
+	Rate  float64 `yaml:"rate" toml:"rate"`
+	Burst int     `yaml:"burst" toml:"burst"`
+}
+
+func (l RateLimit) valid() bool {
+	return l.Rate == 0 || (l.Rate > 0 && l.Burst >= 1)
+}
+
+// bucket is a RateLimit in use, the zero bucket is full.
+type bucket struct {
+	tokens float64
+	at     time.Time
+}
+
+// take refills b for the time since it was last used and takes a token if
+// there is one.
+func (b *bucket) take(l RateLimit, now time.Time) bool {
+	b.tokens = min(float64(l.Burst), b.tokens+now.Sub(b.at).Seconds()*l.Rate)
+	b.at = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// full reports if b would be refilled to the burst by now, so forgetting it
+// doesn't change the limit.
+func (b *bucket) full(l RateLimit, now time.Time) bool {
+	return b.tokens+now.Sub(b.at).Seconds()*l.Rate >= float64(l.Burst)
+}
+
+// rateLimitSweep is how often buckets of addresses that have stopped
+// connecting are forgotten.
+const rateLimitSweep = time.Minute
+
+// RateLimiter limits how fast new connections are accepted, both in total and
+// per source IP, so a misbehaving client can't start hundreds of programs a
+// second. The limits can be replaced with SetLimits while listeners are using
+// it.
+type RateLimiter struct {
+	mu sync.Mutex
+
+	global, perIP RateLimit
+
+	total bucket
+	addrs map[netip.Addr]*bucket
+	swept time.Time
+
+	now func() time.Time
+}
+
+func NewRateLimiter(global, perIP RateLimit) *RateLimiter {
+	l := &RateLimiter{
+		addrs: make(map[netip.Addr]*bucket),
+		now:   time.Now,
+	}
+	l.SetLimits(global, perIP)
+	return l
+}
+
+// SetLimits replaces the limits, every bucket starts out full again.
+func (l *RateLimiter) SetLimits(global, perIP RateLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.global, l.perIP = global, perIP
+	l.total = bucket{}
+	clear(l.addrs)
+}
+
+// Allow takes a token for a connection from addr. Addresses that aren't IPs,
+// like unix sockets, only count towards the global limit.
+func (l *RateLimiter) Allow(addr net.Addr) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.swept) > rateLimitSweep {
+		for ip, b := range l.addrs {
+			if b.full(l.perIP, now) {
+				delete(l.addrs, ip)
+			}
+		}
+		l.swept = now
+	}
+
+	if l.perIP.Rate > 0 {
+		if ap, err := netip.ParseAddrPort(addr.String()); err == nil {
+			ip := ap.Addr().Unmap()
+			b, ok := l.addrs[ip]
+			if !ok {
+				b = &bucket{}
+				l.addrs[ip] = b
+			}
+			if !b.take(l.perIP, now) {
+				return false
+			}
+		}
+	}
+	if l.global.Rate > 0 && !l.total.take(l.global, now) {
+		return false
+	}
+	return true
+}
+
+type rateLimitListener struct {
+	net.Listener
+	limiter *RateLimiter
+}
+
+// RateLimitListener closes connections over the limits of rl as soon as they
+// are accepted, before any session, pty or program is created for them. The
+// same limiter should be used for the ssh and http listeners so the global
+// limit applies across them.
+func RateLimitListener(l net.Listener, rl *RateLimiter) net.Listener {
+	return &rateLimitListener{l, rl}
+}
+
+func (l *rateLimitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.limiter.Allow(conn.RemoteAddr()) {
+			return conn, nil
+		}
+		log.Warn("connection rejected by rate limit", "raddr", conn.RemoteAddr(), "laddr", conn.LocalAddr())
+		conn.Close()
+	}
+}
+
+// Unwrap returns the rate limited listener.
+func (l *rateLimitListener) Unwrap() net.Listener {
+	return l.Listener
+}
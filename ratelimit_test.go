@@ -0,0 +1,44 @@
+package webtea
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	tcp := func(s string) net.Addr {
+		addr, err := net.ResolveTCPAddr("tcp", s)
+		require.NoError(t, err)
+		return addr
+	}
+	a, b := tcp("100.64.0.1:1000"), tcp("100.64.0.2:1000")
+
+	now := time.Now()
+	l := NewRateLimiter(RateLimit{Rate: 10, Burst: 3}, RateLimit{Rate: 1, Burst: 2})
+	l.now = func() time.Time { return now }
+
+	require.True(t, l.Allow(a))
+	require.True(t, l.Allow(tcp("100.64.0.1:1001")), "the port doesn't matter")
+	require.False(t, l.Allow(a), "per ip burst is used up")
+	require.True(t, l.Allow(b))
+	require.False(t, l.Allow(b), "global burst is used up")
+
+	now = now.Add(time.Second)
+	require.True(t, l.Allow(a), "a token per second is refilled")
+	require.False(t, l.Allow(a))
+
+	// unix sockets only count towards the global limit
+	l.SetLimits(RateLimit{}, RateLimit{Rate: 1, Burst: 1})
+	unix := &net.UnixAddr{Name: "@", Net: "unix"}
+	require.True(t, l.Allow(unix))
+	require.True(t, l.Allow(unix))
+
+	// idle addresses are forgotten
+	require.True(t, l.Allow(a))
+	now = now.Add(2 * rateLimitSweep)
+	require.True(t, l.Allow(b))
+	require.NotContains(t, l.addrs, tcp("100.64.0.1:0").(*net.TCPAddr).AddrPort().Addr())
+}
@@ -0,0 +1,94 @@
+package roles
+
+import (
+	"errors"
+	"fmt"
+
+	"tailscale.com/tailcfg"
+)
+
+// Config is the file representation of a Policy, e.g. in yaml
+//
+//	roles:
+//	  default: user
+//	  logins:
+//	    alice@example.com: admin
+//	  tags:
+//	    tag:ops: moderator
+//	  grants:
+//	    guest: []
+//	    user: []
+//	  tag_grants:
+//	    tag:arcade: [start_game]
+//	  app_capability: example.com/cap/webtea
+type Config struct {
+	Default string            `yaml:"default" toml:"default"`
+	Logins  map[string]string `yaml:"logins" toml:"logins"`
+	Tags    map[string]string `yaml:"tags" toml:"tags"`
+
+	// Grants replace the DefaultGrants of each role listed
+	Grants map[string][]Capability `yaml:"grants" toml:"grants"`
+
+	// TagGrants and AppCapability add capabilities by node tag and
+	// tailscale ACL grant, see Policy
+	TagGrants     map[string][]Capability `yaml:"tag_grants" toml:"tag_grants"`
+	AppCapability string                  `yaml:"app_capability" toml:"app_capability"`
+}
+
+func (c Config) Policy() (Policy, error) {
+	p := DefaultPolicy()
+	var errs []error
+
+	if c.Default != "" {
+		r, err := Parse(c.Default)
+		errs = append(errs, err)
+		p.Default = r
+	}
+
+	p.Logins = make(map[string]Role, len(c.Logins))
+	for login, name := range c.Logins {
+		r, err := Parse(name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("logins.%s: %w", login, err))
+			continue
+		}
+		p.Logins[login] = r
+	}
+
+	p.Tags = make(map[string]Role, len(c.Tags))
+	for tag, name := range c.Tags {
+		r, err := Parse(name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("tags.%s: %w", tag, err))
+			continue
+		}
+		p.Tags[tag] = r
+	}
+
+	for name, caps := range c.Grants {
+		r, err := Parse(name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("grants: %w", err))
+			continue
+		}
+		for _, c := range caps {
+			if !c.Valid() {
+				errs = append(errs, fmt.Errorf("grants.%s: unknown capability: %s", name, c))
+			}
+		}
+		p.Grants[r] = caps
+	}
+
+	p.TagGrants = make(map[string][]Capability, len(c.TagGrants))
+	for tag, caps := range c.TagGrants {
+		for _, c := range caps {
+			if !c.Valid() {
+				errs = append(errs, fmt.Errorf("tag_grants.%s: unknown capability: %s", tag, c))
+			}
+		}
+		p.TagGrants[tag] = caps
+	}
+	p.AppCapability = tailcfg.PeerCapability(c.AppCapability)
+
+	return p, errors.Join(errs...)
+}
@@ -0,0 +1,220 @@
+// Package roles is the role and capability model shared by the chat command
+// palette, moderation, the operator APIs and privacy controls.
+//
+// Every identity is assigned a single Role and each Role is granted a set of
+// Capabilities. Code should check for a Capability rather than a Role so the
+// grants can be changed by configuration.
+package roles
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"tailscale.com/client/tailscale/apitype"
+	"tailscale.com/tailcfg"
+)
+
+type Role int
+
+const (
+	Guest Role = iota
+	User
+	Moderator
+	Admin
+)
+
+var roleNames = []string{"guest", "user", "moderator", "admin"}
+
+func (r Role) String() string {
+	if r >= 0 && int(r) < len(roleNames) {
+		return roleNames[r]
+	}
+	return fmt.Sprintf("Role(%d)", int(r))
+}
+
+func Parse(s string) (Role, error) {
+	i := slices.Index(roleNames, strings.ToLower(s))
+	if i < 0 {
+		return Guest, fmt.Errorf("unknown role: %s", s)
+	}
+	return Role(i), nil
+}
+
+type Capability string
+
+const (
+	CanBroadcast     Capability = "broadcast"
+	CanKick          Capability = "kick"
+	CanStartGame     Capability = "start_game"
+	CanViewAddresses Capability = "view_addresses"
+
+	// CanConfigureRoom grants editing the room topic, MOTD and limits
+	CanConfigureRoom Capability = "configure_room"
+
+	// CanOperate grants access to the operator endpoints like the status API
+	CanOperate Capability = "operate"
+
+	// CanApproveGuests grants approving the first message of guests, which
+	// promotes them to users for their session
+	CanApproveGuests Capability = "approve_guests"
+)
+
+// GuestTag is the node tag of the identities made up for peers that couldn't
+// be identified, they are always a Guest.
+const GuestTag = "tag:webtea-guest"
+
+var capabilities = []Capability{CanBroadcast, CanKick, CanStartGame, CanViewAddresses, CanConfigureRoom, CanOperate, CanApproveGuests}
+
+// Valid reports if c is one of the capabilities defined by this package.
+func (c Capability) Valid() bool {
+	return slices.Contains(capabilities, c)
+}
+
+// Policy assigns roles to tailscale identities and capabilities to roles.
+type Policy struct {
+	// Default is the role of identities not matched by Logins or Tags
+	Default Role
+
+	// Logins and Tags assign roles by tailscale login name and node tag. The
+	// highest role matched wins.
+	Logins map[string]Role
+	Tags   map[string]Role
+
+	Grants map[Role][]Capability
+
+	// TagGrants are capabilities of the nodes with a tag on top of the
+	// grants of their role, e.g. to only let tag:arcade nodes start games.
+	TagGrants map[string][]Capability
+
+	// AppCapability is a tailscale ACL app capability whose values grant
+	// capabilities, e.g. with the ACL grant
+	//
+	//	"app": {"example.com/cap/webtea": [{"capabilities": ["kick"]}]}
+	AppCapability tailcfg.PeerCapability
+}
+
+// appCapValue is a value of the AppCapability of a Policy
+type appCapValue struct {
+	Capabilities []Capability `json:"capabilities"`
+}
+
+// DefaultGrants gives each role the capabilities of the roles below it.
+func DefaultGrants() map[Role][]Capability {
+	user := []Capability{CanStartGame}
+	moderator := append(slices.Clone(user), CanBroadcast, CanKick, CanConfigureRoom, CanApproveGuests)
+	admin := append(slices.Clone(moderator), CanViewAddresses, CanOperate)
+	return map[Role][]Capability{
+		User:      user,
+		Moderator: moderator,
+		Admin:     admin,
+	}
+}
+
+func DefaultPolicy() Policy {
+	return Policy{
+		Default: User,
+		Grants:  DefaultGrants(),
+	}
+}
+
+// RoleOf returns the role of who, an unknown identity is a Guest.
+func (p Policy) RoleOf(who *apitype.WhoIsResponse) Role {
+	if who == nil || who.UserProfile == nil {
+		return Guest
+	}
+	if who.Node != nil && slices.Contains(who.Node.Tags, GuestTag) {
+		return Guest
+	}
+
+	role := p.Default
+	matched := false
+	match := func(r Role) {
+		if !matched || r > role {
+			role = r
+		}
+		matched = true
+	}
+
+	if r, ok := p.Logins[who.UserProfile.LoginName]; ok {
+		match(r)
+	}
+	if who.Node != nil {
+		for _, tag := range who.Node.Tags {
+			if r, ok := p.Tags[tag]; ok {
+				match(r)
+			}
+		}
+	}
+	return role
+}
+
+func (p Policy) Can(r Role, c Capability) bool {
+	return slices.Contains(p.Grants[r], c)
+}
+
+// Access resolves the role and capabilities of who.
+func (p Policy) Access(who *apitype.WhoIsResponse) Access {
+	r := p.RoleOf(who)
+	a := Access{Role: r, caps: slices.Clone(p.Grants[r])}
+	if who == nil {
+		return a
+	}
+
+	if who.Node != nil {
+		a.Tags = slices.Clone(who.Node.Tags)
+		for _, tag := range a.Tags {
+			a.caps = append(a.caps, p.TagGrants[tag]...)
+		}
+	}
+	if p.AppCapability != "" {
+		for _, raw := range who.CapMap[p.AppCapability] {
+			var v appCapValue
+			if err := json.Unmarshal([]byte(raw), &v); err != nil {
+				continue
+			}
+			a.caps = append(a.caps, slices.DeleteFunc(v.Capabilities, func(c Capability) bool {
+				return !c.Valid()
+			})...)
+		}
+	}
+	slices.Sort(a.caps)
+	a.caps = slices.Compact(a.caps)
+	return a
+}
+
+// Promote returns a with the role r, it keeps the capabilities it had and
+// gains those of r and of its tags, e.g. for a guest approved by a moderator.
+func (p Policy) Promote(a Access, r Role) Access {
+	a.Role = r
+	a.caps = append(slices.Clone(a.caps), p.Grants[r]...)
+	for _, tag := range a.Tags {
+		a.caps = append(a.caps, p.TagGrants[tag]...)
+	}
+	slices.Sort(a.caps)
+	a.caps = slices.Compact(a.caps)
+	return a
+}
+
+// Access is the resolved role and capabilities of a single identity.
+type Access struct {
+	Role Role
+	// Tags are the tailscale tags of the node
+	Tags []string
+	caps []Capability
+}
+
+func (a Access) Can(c Capability) bool {
+	return slices.Contains(a.caps, c)
+}
+
+// HasTag reports if the node has the tailscale tag.
+func (a Access) HasTag(tag string) bool {
+	return slices.Contains(a.Tags, tag)
+}
+
+// Capabilities returns every capability granted, sorted.
+func (a Access) Capabilities() []Capability {
+	return slices.Clone(a.caps)
+}
@@ -0,0 +1,74 @@
+package roles
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"tailscale.com/client/tailscale/apitype"
+	"tailscale.com/tailcfg"
+)
+
+func whois(login string, tags ...string) *apitype.WhoIsResponse {
+	return &apitype.WhoIsResponse{
+		Node:        &tailcfg.Node{Tags: tags},
+		UserProfile: &tailcfg.UserProfile{LoginName: login},
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	p, err := Config{
+		Default: "guest",
+		Logins:  map[string]string{"alice@example.com": "admin", "bob@example.com": "user"},
+		Tags:    map[string]string{"tag:ops": "moderator"},
+		Grants:  map[string][]Capability{"guest": {CanStartGame}},
+	}.Policy()
+	require.NoError(t, err)
+
+	require.Equal(t, Guest, p.RoleOf(nil))
+	require.Equal(t, Guest, p.RoleOf(whois("eve@example.com")))
+	require.Equal(t, Admin, p.RoleOf(whois("alice@example.com", "tag:ops")))
+	require.Equal(t, Moderator, p.RoleOf(whois("bob@example.com", "tag:ops")))
+	require.Equal(t, Guest, p.RoleOf(whois("alice@example.com", GuestTag)))
+
+	require.True(t, p.Access(whois("eve@example.com")).Can(CanStartGame))
+	require.False(t, p.Access(whois("bob@example.com")).Can(CanKick))
+	require.False(t, p.Access(whois("bob@example.com")).Can(CanConfigureRoom))
+	require.True(t, p.Access(whois("alice@example.com")).Can(CanOperate))
+
+	guest := p.Access(whois("eve@example.com", GuestTag))
+	require.Equal(t, Guest, guest.Role)
+	user := p.Promote(guest, User)
+	require.Equal(t, User, user.Role)
+	require.True(t, user.Can(CanStartGame), "the capabilities of the guest are kept")
+	require.True(t, DefaultPolicy().Promote(guest, Moderator).Can(CanApproveGuests))
+
+	_, err = Config{Logins: map[string]string{"x": "root"}}.Policy()
+	require.ErrorContains(t, err, "unknown role: root")
+
+	_, err = Config{Grants: map[string][]Capability{"user": {"start_games"}}}.Policy()
+	require.ErrorContains(t, err, "unknown capability: start_games")
+}
+
+func TestTagAndAppGrants(t *testing.T) {
+	p, err := Config{
+		Grants:        map[string][]Capability{"user": {}},
+		TagGrants:     map[string][]Capability{"tag:arcade": {CanStartGame}},
+		AppCapability: "example.com/cap/webtea",
+	}.Policy()
+	require.NoError(t, err)
+
+	require.False(t, p.Access(whois("bob@example.com")).Can(CanStartGame))
+
+	arcade := p.Access(whois("bob@example.com", "tag:arcade"))
+	require.True(t, arcade.Can(CanStartGame))
+	require.True(t, arcade.HasTag("tag:arcade"))
+
+	who := whois("bob@example.com")
+	who.CapMap = tailcfg.PeerCapMap{
+		"example.com/cap/webtea": {`{"capabilities": ["kick", "fly"]}`, `not json`},
+	}
+	require.Equal(t, []Capability{CanKick}, p.Access(who).Capabilities())
+
+	_, err = Config{TagGrants: map[string][]Capability{"tag:x": {"fly"}}}.Policy()
+	require.ErrorContains(t, err, "tag_grants.tag:x: unknown capability: fly")
+}
@@ -0,0 +1,257 @@
+package webtea
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+	"github.com/ghthor/gotty/v2/server"
+	"github.com/ghthor/webtea/mpty"
+	"golang.org/x/sync/errgroup"
+)
+
+// Runner starts any goroutines it needs in grp and should return once they
+// are running. It follows the signature of RunSSH and RunHTTP.
+type Runner func(ctx context.Context, grp *errgroup.Group, cancel context.CancelCauseFunc) error
+
+// Server owns the listeners, the mpty Program and any other Runners of a
+// webtea app so they are started together and shut down in order.
+type Server struct {
+	hostname string
+
+	program *mpty.Program
+
+	sshL net.Listener
+	ssh  *ssh.Server
+
+	httpL    net.Listener
+	mounts   map[string]server.Factory
+	httpOpts []HTTPOption
+	http     *http.Server
+
+	runners []Runner
+
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	grp    *errgroup.Group
+}
+
+type ServerOption func(*Server) error
+
+// WithProgram starts p with the server and stops it last on shutdown.
+func WithProgram(p mpty.Program) ServerOption {
+	return func(s *Server) error {
+		s.program = &p
+		return nil
+	}
+}
+
+func WithSSH(l net.Listener, srv *ssh.Server) ServerOption {
+	return func(s *Server) error {
+		s.sshL, s.ssh = l, srv
+		return nil
+	}
+}
+
+// WithHTTP serves fact on l, see RunHTTP.
+func WithHTTP(l net.Listener, fact server.Factory, opts ...HTTPOption) ServerOption {
+	return WithHTTPMux(l, map[string]server.Factory{"/": fact}, opts...)
+}
+
+// WithHTTPMux serves each of the mounts on l, see RunHTTPMux.
+func WithHTTPMux(l net.Listener, mounts map[string]server.Factory, opts ...HTTPOption) ServerOption {
+	return func(s *Server) error {
+		s.httpL, s.mounts, s.httpOpts = l, mounts, opts
+		return nil
+	}
+}
+
+// WithHostname sets the hostname shown in the browser title.
+func WithHostname(hostname string) ServerOption {
+	return func(s *Server) error {
+		s.hostname = hostname
+		return nil
+	}
+}
+
+// WithRunner starts r after the listeners, e.g. an admin console.
+func WithRunner(r Runner) ServerOption {
+	return func(s *Server) error {
+		s.runners = append(s.runners, r)
+		return nil
+	}
+}
+
+func NewServer(opts ...ServerOption) (*Server, error) {
+	s := &Server{hostname: "webtea"}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+	if s.ssh == nil && s.httpL == nil {
+		return nil, errors.New("webtea server requires an ssh or http listener")
+	}
+	return s, nil
+}
+
+// Start runs everything owned by the server. The Program should have been
+// created with ctx, or a child of it.
+func (s *Server) Start(ctx context.Context) error {
+	if s.grp != nil {
+		return errors.New("webtea server already started")
+	}
+
+	s.ctx, s.cancel = context.WithCancelCause(ctx)
+	grp, grpCtx := errgroup.WithContext(s.ctx)
+	s.grp = grp
+
+	if s.program != nil {
+		if err := s.program.StartIn(grpCtx, grp); err != nil {
+			return fmt.Errorf("could not start main program: %w", err)
+		}
+	}
+
+	var err error
+	if s.ssh != nil {
+		err = errors.Join(err, RunSSH(grpCtx, grp, s.cancel, s.sshL, s.ssh))
+	}
+	if s.httpL != nil {
+		opts := append(s.httpOpts[:len(s.httpOpts):len(s.httpOpts)], func(c *httpConfig) {
+			c.served = func(srv *http.Server) { s.http = srv }
+		})
+		err = errors.Join(err, RunHTTPMux(grpCtx, grp, s.cancel, s.httpL, s.mounts, s.hostname, opts...))
+	}
+	for _, r := range s.runners {
+		err = errors.Join(err, r(grpCtx, grp, s.cancel))
+	}
+	if err != nil {
+		s.cancel(err)
+	}
+	return err
+}
+
+// Handoff snapshots the Program, which stops recording, and starts a new
+// process serving the ssh and http listeners, passed with the names "ssh"
+// and "http", see Handoff. Call Shutdown once it returns to drain the
+// sessions of this process. The snapshot is discarded and recording resumes
+// if the new process fails to start.
+func (s *Server) Handoff(ctx context.Context) (*os.Process, error) {
+	if s.program != nil {
+		err := s.program.Handoff(ctx)
+		if err != nil && !errors.Is(err, mpty.ErrNoSnapshotStore) {
+			return nil, fmt.Errorf("could not snapshot main program: %w", err)
+		}
+	}
+
+	listeners := make(map[string]net.Listener, 2)
+	if s.sshL != nil {
+		listeners["ssh"] = s.sshL
+	}
+	if s.httpL != nil {
+		listeners["http"] = s.httpL
+	}
+	proc, err := Handoff(ctx, listeners)
+	if err != nil && s.program != nil {
+		// ctx may be why the handoff failed
+		discardCtx, cancel := context.WithTimeout(context.Background(), discardTimeout)
+		defer cancel()
+		if derr := s.program.DiscardSnapshot(discardCtx); derr != nil {
+			log.Warn("could not discard snapshot", "error", derr)
+		}
+	}
+	return proc, err
+}
+
+// discardTimeout bounds discarding the snapshot of a failed handoff
+const discardTimeout = 5 * time.Second
+
+// Done is closed when the ctx given to Start is done or any part of the
+// server failed, see Err.
+func (s *Server) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// Err returns the error the server failed with, if any.
+func (s *Server) Err() error {
+	if err := context.Cause(s.ctx); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
+
+// drainPoll is how often Shutdown checks if the web sessions have ended
+const drainPoll = 100 * time.Millisecond
+
+// Shutdown stops the server in order. The ssh and http listeners stop
+// accepting and their sessions are given until ctx is done to end before they
+// are closed, then the runners and finally the Program are stopped.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.grp == nil {
+		return nil
+	}
+
+	var errs []error
+	if s.ssh != nil {
+		log.Info("Stopping SSH server")
+		err := s.ssh.Shutdown(ctx)
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = s.ssh.Close()
+		}
+		if err != nil && !errors.Is(err, ssh.ErrServerClosed) {
+			errs = append(errs, fmt.Errorf("ssh shutdown: %w", err))
+		}
+	}
+	if s.http != nil {
+		log.Info("Stopping HTTP server")
+		// web sessions are hijacked websockets which http.Server.Shutdown
+		// doesn't wait for, so they are drained through the Program
+		err := s.http.Shutdown(ctx)
+		if err == nil {
+			s.drainWeb(ctx)
+		} else if !errors.Is(err, context.DeadlineExceeded) {
+			errs = append(errs, fmt.Errorf("http shutdown: %w", err))
+		}
+	}
+
+	s.cancel(nil)
+	if s.program != nil {
+		s.program.Quit()
+	}
+
+	if err := s.grp.Wait(); err != nil && !errors.Is(err, context.Canceled) {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// drainWeb waits until the Program has no web sessions left or ctx is done.
+func (s *Server) drainWeb(ctx context.Context) {
+	if s.program == nil {
+		return
+	}
+	t := time.NewTicker(drainPoll)
+	defer t.Stop()
+	for {
+		web := 0
+		for _, sess := range s.program.Sessions() {
+			if sess.Transport == "web" {
+				web++
+			}
+		}
+		if web == 0 {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
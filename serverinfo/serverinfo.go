@@ -0,0 +1,132 @@
+// Package serverinfo describes the running server: what it was built from,
+// how long it has been up and how many clients it serves. The version and
+// commit are injected when building:
+//
+//	go build -ldflags "-X github.com/ghthor/webtea/serverinfo.Version=v1.2.0 -X github.com/ghthor/webtea/serverinfo.Commit=$(git rev-parse HEAD)"
+//
+// Without them they are read from the build info of the binary.
+package serverinfo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/ghthor/webtea/mpty"
+)
+
+// Version and Commit of the build, set with -ldflags -X.
+var (
+	Version string
+	Commit  string
+)
+
+// Info is the metadata of a server, the fields of a running server are zero
+// for an Info of the Build alone.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	GoVersion string `json:"go_version"`
+
+	Started  time.Time     `json:"started,omitzero"`
+	Uptime   time.Duration `json:"uptime,omitempty"`
+	Clients  int           `json:"clients"`
+	Recorder string        `json:"recorder,omitempty"`
+}
+
+var readBuild = sync.OnceValues(func() (version, commit string) {
+	version, commit = Version, Commit
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return version, commit
+	}
+	if version == "" && bi.Main.Version != "(devel)" {
+		version = bi.Main.Version
+	}
+	for _, s := range bi.Settings {
+		if s.Key == "vcs.revision" && commit == "" {
+			commit = s.Value
+		}
+	}
+	return version, commit
+})
+
+// Build returns the Info of the binary alone.
+func Build() Info {
+	version, commit := readBuild()
+	if version == "" {
+		version = "devel"
+	}
+	return Info{
+		Version:   version,
+		Commit:    commit,
+		GoVersion: runtime.Version(),
+	}
+}
+
+// Backend names the backend of a recorder, from its Backend method when it
+// has one.
+func Backend(r mpty.Recorder) string {
+	switch r := r.(type) {
+	case nil:
+		return "none"
+	case interface{ Backend() string }:
+		return r.Backend()
+	default:
+		return fmt.Sprintf("%T", r)
+	}
+}
+
+// Server is a running server, usually the mpty.Program and its recorder.
+type Server struct {
+	program  interface{ Stats() mpty.Stats }
+	recorder string
+}
+
+// New describes the server running p with recorder r.
+func New(p interface{ Stats() mpty.Stats }, r mpty.Recorder) *Server {
+	return &Server{program: p, recorder: Backend(r)}
+}
+
+// Info returns the metadata of the server now.
+func (s *Server) Info() Info {
+	info := Build()
+	stats := s.program.Stats()
+	info.Started = stats.Started
+	info.Uptime = time.Since(stats.Started).Round(time.Second)
+	info.Clients = stats.Sessions
+	info.Recorder = s.recorder
+	return info
+}
+
+// HealthHandler answers GET requests with the status of the server, its build
+// and uptime, for load balancers and monitoring. It doesn't count the clients,
+// it can be served without authentication.
+func (s *Server) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		info := s.Info()
+		health := struct {
+			Status    string        `json:"status"`
+			Version   string        `json:"version"`
+			Commit    string        `json:"commit"`
+			GoVersion string        `json:"go_version"`
+			Uptime    time.Duration `json:"uptime"`
+		}{"ok", info.Version, info.Commit, info.GoVersion, info.Uptime}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(health); err != nil {
+			log.Warn("health response", "error", err)
+		}
+	})
+}
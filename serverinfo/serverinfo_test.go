@@ -0,0 +1,51 @@
+package serverinfo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/mpty/mptymsg"
+	"github.com/stretchr/testify/require"
+)
+
+type stats mpty.Stats
+
+func (s stats) Stats() mpty.Stats { return mpty.Stats(s) }
+
+func TestServer(t *testing.T) {
+	started := time.Now().Add(-time.Hour)
+	s := New(stats{Started: started, Sessions: 3}, mptymsg.NewMemory(8))
+
+	info := s.Info()
+	require.Equal(t, runtime.Version(), info.GoVersion)
+	require.NotEmpty(t, info.Version)
+	require.Equal(t, started, info.Started)
+	require.GreaterOrEqual(t, info.Uptime, time.Hour)
+	require.Equal(t, 3, info.Clients)
+	require.Equal(t, "memory", info.Recorder)
+
+	require.Equal(t, "none", Backend(nil))
+}
+
+func TestHealthHandler(t *testing.T) {
+	s := New(stats{Started: time.Now(), Sessions: 3}, nil)
+
+	w := httptest.NewRecorder()
+	s.HealthHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var health map[string]any
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&health))
+	require.Equal(t, "ok", health["status"])
+	require.Equal(t, runtime.Version(), health["go_version"])
+	require.NotContains(t, health, "clients")
+
+	w = httptest.NewRecorder()
+	s.HealthHandler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/healthz", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
@@ -0,0 +1,87 @@
+package webtea
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// SystemdUnits are the unit files of a socket activated service: a socket
+// unit for each listener and the service they start. Each socket is passed
+// to the service by name, see Activation.Take, and stays open while the
+// service restarts.
+type SystemdUnits struct {
+	// Name of the service, e.g. webtea-chat
+	Name        string
+	Description string
+	// ExecStart is the command line of the service
+	ExecStart string
+	// User the service runs as, root when empty
+	User string
+	// Sockets are the ListenStream= addresses of the sockets by the name they
+	// are passed as, e.g. "ssh": "100.64.0.1:2222"
+	Sockets map[string]string
+}
+
+// Files returns the contents of the unit files by file name.
+func (u SystemdUnits) Files() (map[string]string, error) {
+	if u.Name == "" || u.ExecStart == "" {
+		return nil, errors.New("systemd units need a name and a command")
+	}
+	if len(u.Sockets) == 0 {
+		return nil, errors.New("systemd units need at least one socket")
+	}
+	desc := u.Description
+	if desc == "" {
+		desc = u.Name
+	}
+
+	var (
+		files   = make(map[string]string, len(u.Sockets)+1)
+		sockets = make([]string, 0, len(u.Sockets))
+		service = u.Name + ".service"
+	)
+	for _, name := range slices.Sorted(maps.Keys(u.Sockets)) {
+		socket := fmt.Sprintf("%s-%s.socket", u.Name, name)
+		sockets = append(sockets, socket)
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "[Unit]\nDescription=%s %s socket\nPartOf=%s\n\n", desc, name, service)
+		fmt.Fprintf(&b, "[Socket]\nListenStream=%s\nFileDescriptorName=%s\nService=%s\n", u.Sockets[name], name, service)
+		// the tailnet address of the host may only be assigned after boot
+		b.WriteString("FreeBind=true\n\n")
+		b.WriteString("[Install]\nWantedBy=sockets.target\n")
+		files[socket] = b.String()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Unit]\nDescription=%s\n", desc)
+	b.WriteString("Wants=network-online.target\nAfter=network-online.target\n")
+	fmt.Fprintf(&b, "Requires=%s\nAfter=%[1]s\n\n", strings.Join(sockets, " "))
+	fmt.Fprintf(&b, "[Service]\nExecStart=%s\nSockets=%s\n", u.ExecStart, strings.Join(sockets, " "))
+	if u.User != "" {
+		fmt.Fprintf(&b, "User=%s\n", u.User)
+	}
+	b.WriteString("Restart=on-failure\n\n")
+	b.WriteString("[Install]\nWantedBy=multi-user.target\n")
+	files[service] = b.String()
+	return files, nil
+}
+
+// Write writes the unit files to dir, e.g. /etc/systemd/system.
+func (u SystemdUnits) Write(dir string) error {
+	files, err := u.Files()
+	if err != nil {
+		return err
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package webtea
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSystemdUnits(t *testing.T) {
+	u := SystemdUnits{
+		Name:      "webtea-chat",
+		ExecStart: "/usr/local/bin/webtea-chat -config /etc/webtea.yaml",
+		User:      "webtea",
+		Sockets:   map[string]string{"ssh": "2222", "http": "8080"},
+	}
+	files, err := u.Files()
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+
+	require.Contains(t, files["webtea-chat-ssh.socket"], "ListenStream=2222\nFileDescriptorName=ssh\nService=webtea-chat.service\n")
+	require.Contains(t, files["webtea-chat-http.socket"], "FileDescriptorName=http\n")
+
+	service := files["webtea-chat.service"]
+	require.Contains(t, service, "Sockets=webtea-chat-http.socket webtea-chat-ssh.socket\n")
+	require.Contains(t, service, "ExecStart=/usr/local/bin/webtea-chat -config /etc/webtea.yaml\n")
+	require.Contains(t, service, "User=webtea\n")
+
+	dir := t.TempDir()
+	require.NoError(t, u.Write(dir))
+	b, err := os.ReadFile(filepath.Join(dir, "webtea-chat.service"))
+	require.NoError(t, err)
+	require.Equal(t, service, string(b))
+
+	_, err = SystemdUnits{Name: "webtea", ExecStart: "webtea"}.Files()
+	require.ErrorContains(t, err, "at least one socket")
+}
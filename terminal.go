@@ -0,0 +1,97 @@
+package webtea
+
+import (
+	"time"
+
+	"github.com/ghthor/gotty/v2/server"
+)
+
+// TerminalOptions are the behaviors of the web terminal that applications
+// commonly tune. They are a typed view over the gotty server.Options, use
+// WithGottyOptions for anything not covered here.
+type TerminalOptions struct {
+	// TitleFormat is a text/template for the browser title rendered with
+	// TitleVariables, and the variables of the client model if it is a
+	// mpty.TitleVarser. It defaults to "{{ .hostname }}"
+	TitleFormat    string
+	TitleVariables map[string]any
+
+	// PermitWrite forwards browser input to the program
+	PermitWrite bool
+
+	// PermitArguments allows ?arg= query parameters to be passed to the
+	// server.Factory
+	PermitArguments bool
+
+	// WSOrigin is a regular expression matching the origins websockets are
+	// accepted from. Only same origin requests are accepted when empty.
+	WSOrigin string
+
+	// ConnectTimeout stops serving the terminal when no client has been
+	// connected for this long, 0 serves forever
+	ConnectTimeout time.Duration
+
+	// MaxConnections is the maximum number of concurrent websockets, 0 is
+	// unlimited
+	MaxConnections int
+
+	// Reconnect is how long the browser waits before reconnecting a closed
+	// websocket, 0 disables reconnecting
+	Reconnect time.Duration
+
+	// IndexFile overrides the index.html served to browsers
+	IndexFile string
+
+	// Term is the browser terminal emulator, xterm or hterm
+	Term string
+
+	// Width and Height fix the terminal size, 0 resizes to the browser
+	Width, Height int
+}
+
+// WithTerminal applies fn to the TerminalOptions of the web terminal.
+func WithTerminal(fn func(*TerminalOptions)) HTTPOption {
+	return func(c *httpConfig) {
+		o := terminalOptions(c.gotty)
+		fn(&o)
+		o.apply(c.gotty)
+	}
+}
+
+func terminalOptions(g *server.Options) TerminalOptions {
+	o := TerminalOptions{
+		TitleFormat:     g.TitleFormat,
+		TitleVariables:  g.TitleVariables,
+		PermitWrite:     g.PermitWrite,
+		PermitArguments: g.PermitArguments,
+		WSOrigin:        g.WSOrigin,
+		ConnectTimeout:  time.Duration(g.Timeout) * time.Second,
+		MaxConnections:  g.MaxConnection,
+		IndexFile:       g.IndexFile,
+		Term:            g.Term,
+		Width:           g.Width,
+		Height:          g.Height,
+	}
+	if g.EnableReconnect {
+		o.Reconnect = time.Duration(g.ReconnectTime) * time.Second
+	}
+	return o
+}
+
+func (o TerminalOptions) apply(g *server.Options) {
+	g.TitleFormat = o.TitleFormat
+	g.TitleVariables = o.TitleVariables
+	g.PermitWrite = o.PermitWrite
+	g.PermitArguments = o.PermitArguments
+	g.WSOrigin = o.WSOrigin
+	g.Timeout = int(o.ConnectTimeout / time.Second)
+	g.MaxConnection = o.MaxConnections
+	g.IndexFile = o.IndexFile
+	g.Term = o.Term
+	g.Width, g.Height = o.Width, o.Height
+
+	g.EnableReconnect = o.Reconnect > 0
+	if g.EnableReconnect {
+		g.ReconnectTime = max(1, int(o.Reconnect/time.Second))
+	}
+}
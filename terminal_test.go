@@ -0,0 +1,33 @@
+package webtea
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithTerminal(t *testing.T) {
+	g, err := newGottyOptions("host")
+	require.NoError(t, err)
+
+	cfg := &httpConfig{gotty: g}
+	WithPermitWrite(false)(cfg)
+	WithTerminal(func(o *TerminalOptions) {
+		require.False(t, o.PermitWrite, "sees options applied before it")
+		require.Equal(t, "{{ .hostname }}", o.TitleFormat)
+
+		o.TitleFormat = "chat@{{ .hostname }}"
+		o.MaxConnections = 10
+		o.Reconnect = 5 * time.Second
+		o.WSOrigin = `^https://example\.com$`
+	})(cfg)
+
+	require.False(t, g.PermitWrite)
+	require.Equal(t, "chat@{{ .hostname }}", g.TitleFormat)
+	require.Equal(t, 10, g.MaxConnection)
+	require.True(t, g.EnableReconnect)
+	require.Equal(t, 5, g.ReconnectTime)
+	require.Equal(t, `^https://example\.com$`, g.WSOrigin)
+	require.Equal(t, "xterm", g.Term)
+}
@@ -0,0 +1,12 @@
+module github.com/ghthor/webtea/tracing/oteltracing
+
+go 1.25.3
+
+require (
+	github.com/ghthor/webtea v0.0.0
+	go.opentelemetry.io/otel v1.38.0
+	go.opentelemetry.io/otel/trace v1.38.0
+)
+
+// the tracing package is developed alongside this module
+replace github.com/ghthor/webtea => ../..
@@ -0,0 +1,69 @@
+// Package oteltracing exports the spans of the tracing hooks with
+// OpenTelemetry. It is a separate module so webtea itself doesn't depend on
+// OpenTelemetry, e.g.
+//
+//	tracing.SetTracer(oteltracing.New(otel.Tracer("webtea")))
+package oteltracing
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ghthor/webtea/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type tracer struct {
+	trace.Tracer
+}
+
+// New adapts t to a tracing.Tracer.
+func New(t trace.Tracer) tracing.Tracer {
+	return tracer{t}
+}
+
+func (t tracer) Start(ctx context.Context, name string, start time.Time, attrs ...tracing.Attr) (context.Context, tracing.Span) {
+	opts := []trace.SpanStartOption{trace.WithAttributes(otelAttrs(attrs)...)}
+	if !start.IsZero() {
+		opts = append(opts, trace.WithTimestamp(start))
+	}
+	ctx, s := t.Tracer.Start(ctx, name, opts...)
+	return ctx, span{s}
+}
+
+type span struct {
+	trace.Span
+}
+
+func (s span) SetAttributes(attrs ...tracing.Attr) {
+	s.Span.SetAttributes(otelAttrs(attrs)...)
+}
+
+func (s span) RecordError(err error) {
+	s.Span.RecordError(err)
+	s.Span.SetStatus(codes.Error, err.Error())
+}
+
+func (s span) End() {
+	s.Span.End()
+}
+
+func otelAttrs(attrs []tracing.Attr) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		switch v := a.Value.(type) {
+		case string:
+			kvs = append(kvs, attribute.String(a.Key, v))
+		case int:
+			kvs = append(kvs, attribute.Int(a.Key, v))
+		case time.Duration:
+			kvs = append(kvs, attribute.Int64(a.Key+"_ms", v.Milliseconds()))
+		default:
+			kvs = append(kvs, attribute.String(a.Key, fmt.Sprint(v)))
+		}
+	}
+	return kvs
+}
@@ -0,0 +1,79 @@
+// Package tracing has optional hooks for tracing the connection lifecycle and
+// the delivery of broadcast messages. It doesn't depend on OpenTelemetry, the
+// spans are exported by setting a Tracer, e.g. the adapter in the separate
+// oteltracing module:
+//
+//	tracing.SetTracer(oteltracing.New(otel.Tracer("webtea")))
+//
+// No spans are created until a Tracer is set.
+package tracing
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Attr is an attribute of a span.
+type Attr struct {
+	Key   string
+	Value any
+}
+
+func String(key, value string) Attr { return Attr{key, value} }
+
+func Int(key string, value int) Attr { return Attr{key, value} }
+
+func Duration(key string, value time.Duration) Attr { return Attr{key, value} }
+
+type Span interface {
+	SetAttributes(...Attr)
+	RecordError(error)
+	End()
+}
+
+type Tracer interface {
+	// Start begins a span that is a child of any span in ctx. A zero start
+	// begins the span now.
+	Start(ctx context.Context, name string, start time.Time, attrs ...Attr) (context.Context, Span)
+}
+
+type tracerBox struct{ Tracer }
+
+var tracer atomic.Pointer[tracerBox]
+
+// SetTracer sets the Tracer used by every hook, nil disables tracing.
+func SetTracer(t Tracer) {
+	if t == nil {
+		tracer.Store(nil)
+		return
+	}
+	tracer.Store(&tracerBox{t})
+}
+
+// Enabled reports if a Tracer is set, so hooks can skip collecting
+// attributes that are expensive to compute.
+func Enabled() bool {
+	return tracer.Load() != nil
+}
+
+// Start begins a span now.
+func Start(ctx context.Context, name string, attrs ...Attr) (context.Context, Span) {
+	return StartAt(ctx, name, time.Time{}, attrs...)
+}
+
+// StartAt begins a span at start, for spans of work that began before the
+// hook could observe it.
+func StartAt(ctx context.Context, name string, start time.Time, attrs ...Attr) (context.Context, Span) {
+	t := tracer.Load()
+	if t == nil {
+		return ctx, noopSpan{}
+	}
+	return t.Start(ctx, name, start, attrs...)
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...Attr) {}
+func (noopSpan) RecordError(error)     {}
+func (noopSpan) End()                  {}
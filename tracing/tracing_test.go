@@ -0,0 +1,45 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type recordedSpan struct {
+	name  string
+	start time.Time
+	ended bool
+}
+
+func (s *recordedSpan) SetAttributes(...Attr) {}
+func (s *recordedSpan) RecordError(error)     {}
+func (s *recordedSpan) End()                  { s.ended = true }
+
+type recorder struct{ spans []*recordedSpan }
+
+func (r *recorder) Start(ctx context.Context, name string, start time.Time, attrs ...Attr) (context.Context, Span) {
+	s := &recordedSpan{name: name, start: start}
+	r.spans = append(r.spans, s)
+	return ctx, s
+}
+
+func TestSetTracer(t *testing.T) {
+	if Enabled() {
+		t.Fatal("enabled without a tracer")
+	}
+	_, span := Start(context.Background(), "noop")
+	span.End()
+
+	r := &recorder{}
+	SetTracer(r)
+	defer SetTracer(nil)
+
+	start := time.Now().Add(-time.Second)
+	_, span = StartAt(context.Background(), "accepted", start)
+	span.End()
+
+	if len(r.spans) != 1 || r.spans[0].name != "accepted" || !r.spans[0].start.Equal(start) || !r.spans[0].ended {
+		t.Errorf("unexpected spans %+v", r.spans)
+	}
+}
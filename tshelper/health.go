@@ -0,0 +1,102 @@
+package tshelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"tailscale.com/ipn/ipnstate"
+)
+
+const (
+	// DefaultHealthInterval is how often WatchHealth checks a healthy device
+	DefaultHealthInterval = 30 * time.Second
+	// minHealthRetry is the first retry of WatchHealth once the device is
+	// unhealthy, it doubles up to the interval
+	minHealthRetry = time.Second
+)
+
+// Health is the connectivity of the device to its tailnet.
+type Health struct {
+	Up bool
+	// State is the backend state of tailscale, e.g. Running or NeedsLogin
+	State string
+	// KeyExpiry is when the node key of the device expires, zero when it
+	// doesn't
+	KeyExpiry time.Time
+	// Err is why the device is down
+	Err error
+}
+
+var (
+	ErrNotRunning = errors.New("tailscale isn't running")
+	ErrKeyExpired = errors.New("the node key of the device expired, log in again")
+)
+
+// WatchHealth checks the connection of the device to its tailnet every
+// interval, DefaultHealthInterval when 0, until ctx is done. fn is called
+// whenever the device goes down or comes back up, e.g. because it lost its
+// connection or its node key expired, so the server can refuse sessions it
+// can't identify instead of failing them. The device is assumed up when
+// WatchHealth starts, like after WaitForTailscaleIP. While it is down it is
+// checked again with backoff, starting after a second.
+func (s *ListenerSet) WatchHealth(ctx context.Context, interval time.Duration, fn func(Health)) {
+	if interval <= 0 {
+		interval = DefaultHealthInterval
+	}
+	var (
+		up    = true
+		retry = minHealthRetry
+		t     = time.NewTimer(interval)
+	)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		st, err := s.Client.StatusWithoutPeers(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		h := healthOf(st, err, time.Now())
+		if h.Up != up {
+			up = h.Up
+			fn(h)
+		}
+
+		if h.Up {
+			retry = minHealthRetry
+			t.Reset(interval)
+			continue
+		}
+		log.Debug("tailscale unhealthy", "state", h.State, "error", h.Err, "retry", retry)
+		t.Reset(retry)
+		retry = min(retry*2, interval)
+	}
+}
+
+// healthOf returns the health of the device from its status at now.
+func healthOf(st *ipnstate.Status, err error, now time.Time) Health {
+	if err != nil {
+		return Health{Err: fmt.Errorf("tailscale status: %w", err)}
+	}
+	h := Health{State: st.BackendState}
+	if st.Self != nil && st.Self.KeyExpiry != nil {
+		h.KeyExpiry = *st.Self.KeyExpiry
+	}
+	switch {
+	case st.BackendState != "Running":
+		h.Err = fmt.Errorf("%w: %s", ErrNotRunning, st.BackendState)
+	case !h.KeyExpiry.IsZero() && !now.Before(h.KeyExpiry):
+		h.Err = ErrKeyExpired
+	default:
+		h.Up = true
+	}
+	return h
+}
@@ -0,0 +1,119 @@
+package tshelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"tailscale.com/tsnet"
+)
+
+// ErrHostnameTaken is returned by NewListenerSet when another device of the
+// tailnet already has the hostname, see OnCollision.
+var ErrHostnameTaken = errors.New("hostname is taken by another device of the tailnet")
+
+// CollisionPolicy is what NewListenerSet does when another device of the
+// tailnet already has the hostname, e.g. a second instance of the server.
+type CollisionPolicy int
+
+const (
+	// CollisionIgnore keeps the name tailscale gives the device, the
+	// hostname with a number appended, without waiting for it
+	CollisionIgnore CollisionPolicy = iota
+	// CollisionSuffix renames the device hostname-2, hostname-3 and so on
+	// until one is free
+	CollisionSuffix
+	// CollisionFail closes the device and fails with ErrHostnameTaken
+	CollisionFail
+)
+
+const (
+	// maxSuffix is the last suffix CollisionSuffix tries
+	maxSuffix = 16
+	// hostnameTimeout bounds the wait for the name the tailnet gives the
+	// device, it includes logging in
+	hostnameTimeout = 2 * time.Minute
+)
+
+// OnCollision detects another device of the tailnet with the hostname as the
+// device starts, and handles it with p. The device must be logged in for its
+// name to be known, NewListenerSet waits for it unless p is
+// CollisionIgnore.
+func OnCollision(p CollisionPolicy) Option {
+	return func(o *options) {
+		o.collision = p
+	}
+}
+
+// start starts the device as hostname, renamed or closed when the hostname
+// is taken as the policy of o says.
+func (s *ListenerSet) start(hostname string, o options) error {
+	name := hostname
+	for n := 1; ; n++ {
+		s.ts = &tsnet.Server{Hostname: name, Ephemeral: o.ephemeral, Dir: o.stateDir}
+		if err := s.ts.Start(); err != nil {
+			return fmt.Errorf("tsnet.Server failed to start: %w", err)
+		}
+		var err error
+		s.Client, err = s.ts.LocalClient()
+		if err != nil {
+			return errors.Join(
+				fmt.Errorf("failed to create tsnet LocalClient(): %w", err),
+				s.ts.Close(),
+			)
+		}
+		if o.collision == CollisionIgnore {
+			return nil
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), hostnameTimeout)
+		dnsName, err := s.waitDNSName(ctx)
+		cancel()
+		if err != nil {
+			return errors.Join(fmt.Errorf("failed to get the name of the device: %w", err), s.ts.Close())
+		}
+		if !renamed(name, dnsName) {
+			if name != hostname {
+				log.Warn("tailscale hostname taken, renamed", "hostname", hostname, "name", name)
+			}
+			return nil
+		}
+
+		err = fmt.Errorf("%w: %s was named %s", ErrHostnameTaken, name, dnsName)
+		if o.collision == CollisionFail || n == maxSuffix {
+			return errors.Join(err, s.ts.Close())
+		}
+		log.Info("tailscale hostname taken, trying another", "name", name, "dns", dnsName)
+		if err := s.ts.Close(); err != nil {
+			return err
+		}
+		name = fmt.Sprintf("%s-%d", hostname, n+1)
+	}
+}
+
+// waitDNSName returns the MagicDNS name of the device once it is running.
+func (s *ListenerSet) waitDNSName(ctx context.Context) (string, error) {
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+	for {
+		st, err := s.Client.StatusWithoutPeers(ctx)
+		if err == nil && st.BackendState == "Running" && st.Self != nil && st.Self.DNSName != "" {
+			return strings.TrimSuffix(st.Self.DNSName, "."), nil
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// renamed reports if the tailnet named the device other than hostname,
+// tailscale appends a number to a hostname that is taken.
+func renamed(hostname, dnsName string) bool {
+	label, _, _ := strings.Cut(dnsName, ".")
+	return !strings.EqualFold(label, hostname)
+}
@@ -0,0 +1,63 @@
+package tshelper
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// ConnStats counts the connections of a listener, Active are the accepted
+// connections not closed yet.
+type ConnStats struct {
+	Accepted int64 `json:"accepted"`
+	Active   int64 `json:"active"`
+	Closed   int64 `json:"closed"`
+}
+
+// ConnCounter is a set of listeners counting their connections by name, for
+// a metrics endpoint to scrape.
+type ConnCounter interface {
+	ConnStats() map[string]ConnStats
+}
+
+var _ ConnCounter = (*ListenerSet)(nil)
+
+// countListener counts the connections it accepts until they are closed.
+type countListener struct {
+	net.Listener
+	accepted, closed atomic.Int64
+}
+
+func (l *countListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	l.accepted.Add(1)
+	return &countConn{Conn: conn, l: l}, nil
+}
+
+// Unwrap returns the counted listener.
+func (l *countListener) Unwrap() net.Listener {
+	return l.Listener
+}
+
+func (l *countListener) stats() ConnStats {
+	// closed is loaded first so Active is never negative
+	closed := l.closed.Load()
+	accepted := l.accepted.Load()
+	return ConnStats{Accepted: accepted, Active: accepted - closed, Closed: closed}
+}
+
+// countConn is counted as closed the first time it is closed.
+type countConn struct {
+	net.Conn
+	l    *countListener
+	once sync.Once
+}
+
+func (c *countConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() { c.l.closed.Add(1) })
+	return err
+}
@@ -0,0 +1,133 @@
+package tshelper
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+	"github.com/ghthor/webtea/mpty"
+	"tailscale.com/ipn/ipnstate"
+)
+
+// DefaultPeersInterval is how often WatchPeers checks the tailnet
+const DefaultPeersInterval = 10 * time.Second
+
+// WatchPeers checks the tailnet every interval, DefaultPeersInterval when 0,
+// until ctx is done, keeping Peers up to date. It sends an mpty.PeerMsg to
+// send for every peer that came online or went offline and an mpty.AddrsMsg
+// when the addresses of the device changed, usually to the Main program of a
+// server so it can announce them. The peers already online as it starts
+// aren't sent, and nothing is when send is nil.
+func (s *ListenerSet) WatchPeers(ctx context.Context, interval time.Duration, send mpty.Input) {
+	if interval <= 0 {
+		interval = DefaultPeersInterval
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	var prev *tailnet
+	for {
+		st, err := s.Client.Status(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Debug("tailscale status", "error", err)
+		} else {
+			next := tailnetOf(st)
+			s.setPeers(next.peers)
+			if prev != nil && send != nil {
+				for _, msg := range prev.changes(next, time.Now()) {
+					select {
+					case <-ctx.Done():
+						return
+					case send <- msg:
+					}
+				}
+			}
+			prev = &next
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Peers returns the peers of the tailnet in the order of their names, as
+// WatchPeers last saw them. It is empty until WatchPeers runs.
+func (s *ListenerSet) Peers() []mpty.Peer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.peers)
+}
+
+func (s *ListenerSet) setPeers(peers []mpty.Peer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.peers = peers
+}
+
+// tailnet is what WatchPeers compares of two statuses.
+type tailnet struct {
+	addrs []netip.Addr
+	// online are the names of the peers online by their stable id
+	online map[string]string
+	peers  []mpty.Peer
+}
+
+func tailnetOf(st *ipnstate.Status) tailnet {
+	t := tailnet{online: make(map[string]string)}
+	if st.Self != nil {
+		t.addrs = slices.Clone(st.Self.TailscaleIPs)
+	}
+	for _, p := range st.Peer {
+		name := peerName(p)
+		if p.Online {
+			t.online[string(p.ID)] = name
+		}
+		t.peers = append(t.peers, mpty.Peer{Name: name, Online: p.Online})
+	}
+	slices.SortFunc(t.peers, func(a, b mpty.Peer) int { return strings.Compare(a.Name, b.Name) })
+	return t
+}
+
+// peerName is the MagicDNS name of p without the tailnet, its hostname when
+// it has none.
+func peerName(p *ipnstate.PeerStatus) string {
+	if name, _, _ := strings.Cut(p.DNSName, "."); name != "" {
+		return name
+	}
+	return p.HostName
+}
+
+// changes returns the messages of what changed from t to next, the peers in
+// the order of their names.
+func (t tailnet) changes(next tailnet, now time.Time) []tea.Msg {
+	var msgs []tea.Msg
+	if !slices.Equal(t.addrs, next.addrs) {
+		msgs = append(msgs, mpty.AddrsMsg{At: now, Addrs: next.addrs})
+	}
+	var peers []mpty.PeerMsg
+	for id, name := range next.online {
+		if _, ok := t.online[id]; !ok {
+			peers = append(peers, mpty.PeerMsg{At: now, Name: name, Online: true})
+		}
+	}
+	for id, name := range t.online {
+		if _, ok := next.online[id]; !ok {
+			peers = append(peers, mpty.PeerMsg{At: now, Name: name})
+		}
+	}
+	slices.SortFunc(peers, func(a, b mpty.PeerMsg) int { return strings.Compare(a.Name, b.Name) })
+	for _, p := range peers {
+		msgs = append(msgs, p)
+	}
+	return msgs
+}
@@ -0,0 +1,286 @@
+package tshelper
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/ghthor/webtea/mpty"
+	"tailscale.com/client/local"
+	"tailscale.com/tsnet"
+)
+
+// ListenerSet is a tailscale device listening on ports of its tailnet by
+// name, e.g. for metrics, gRPC or more http ports next to ssh and http.
+type ListenerSet struct {
+	ts *tsnet.Server
+
+	Client *local.Client
+
+	mu        sync.Mutex
+	listeners map[string]net.Listener
+	// counts count the connections of each listener, see ConnStats
+	counts map[string]*countListener
+	// peers are the peers of the tailnet, see WatchPeers
+	peers []mpty.Peer
+
+	// close is what Close does with the device, see OnClose
+	close CloseMode
+}
+
+// options are the options of a device
+type options struct {
+	ephemeral bool
+	close     CloseMode
+	stateDir  string
+	collision CollisionPolicy
+	// listeners are opened by the caller rather than on the tailnet
+	listeners map[string]net.Listener
+}
+
+// Option configures the device of NewListenerSet and NewListeners.
+type Option func(*options)
+
+// Ephemeral registers the device as ephemeral, for CI runs and short lived
+// servers. Tailscale removes ephemeral devices once they are offline, Close
+// logs the device out so it is removed right away rather than left as a
+// stale device of the tailnet.
+func Ephemeral() Option {
+	return func(o *options) {
+		o.ephemeral = true
+	}
+}
+
+// CloseMode is what Close does with the device once its listeners are
+// closed.
+type CloseMode int
+
+const (
+	// CloseKeep leaves the device logged in, it is back on the tailnet as
+	// the same device on the next start
+	CloseKeep CloseMode = iota
+	// CloseLogout logs the device out of the tailnet, the next start logs in
+	// again as a new device
+	CloseLogout
+	// CloseDelete logs the device out and deletes its state directory, see
+	// WithStateDir
+	CloseDelete
+)
+
+// OnClose sets what Close does with the device, an Ephemeral device is
+// always logged out.
+func OnClose(mode CloseMode) Option {
+	return func(o *options) {
+		o.close = mode
+	}
+}
+
+// WithStateDir keeps the state of the device, its keys and login, in dir
+// rather than the default of tsnet.
+func WithStateDir(dir string) Option {
+	return func(o *options) {
+		o.stateDir = dir
+	}
+}
+
+// WithListener serves name on a listener the caller opened, e.g. a socket
+// inherited from systemd, instead of listening on the tailnet. It is ignored
+// when l is nil.
+func WithListener(name string, l net.Listener) Option {
+	return func(o *options) {
+		if l != nil {
+			o.listeners[name] = l
+		}
+	}
+}
+
+// logoutTimeout bounds the logout of the device by Close
+const logoutTimeout = 5 * time.Second
+
+// NewListenerSet starts the device hostname and listens on each of ports of
+// the tailnet by name. A hostname taken by another device is handled as
+// OnCollision says.
+func NewListenerSet(hostname string, ports map[string]int, opts ...Option) (*ListenerSet, error) {
+	o := options{listeners: make(map[string]net.Listener)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.close == CloseDelete && o.stateDir == "" {
+		return nil, errors.New("deleting the state of the device on close needs WithStateDir")
+	}
+	if o.ephemeral {
+		o.close = max(o.close, CloseLogout)
+	}
+
+	s := &ListenerSet{
+		listeners: make(map[string]net.Listener, len(ports)),
+		counts:    make(map[string]*countListener, len(ports)),
+		close:     o.close,
+	}
+	if err := s.start(hostname, o); err != nil {
+		return nil, err
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(ports)) {
+		if l, ok := o.listeners[name]; ok {
+			s.add(name, func() (net.Listener, error) { return l, nil })
+			continue
+		}
+		if _, err := s.Listen(name, ports[name]); err != nil {
+			return nil, errors.Join(err, s.Close())
+		}
+	}
+
+	return s, nil
+}
+
+// Listener returns the listener named name, nil when there is none.
+func (s *ListenerSet) Listener(name string) net.Listener {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listeners[name]
+}
+
+// Names returns the names of the listeners in order.
+func (s *ListenerSet) Names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Sorted(maps.Keys(s.listeners))
+}
+
+// ConnStats returns the connections of each listener by name, the
+// connections of ListenTLS are counted before the handshake.
+func (s *ListenerSet) ConnStats() map[string]ConnStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make(map[string]ConnStats, len(s.counts))
+	for name, l := range s.counts {
+		stats[name] = l.stats()
+	}
+	return stats
+}
+
+// Listen listens on port of the tailnet as name.
+func (s *ListenerSet) Listen(name string, port int) (net.Listener, error) {
+	return s.add(name, func() (net.Listener, error) {
+		return s.ts.Listen("tcp", net.JoinHostPort("", fmt.Sprint(port)))
+	})
+}
+
+// ListenFunnel starts Funnel as name, serving port to the internet over
+// Tailscale Funnel. Funnel only serves ports 443, 8443 and 10000 and
+// terminates TLS with the certificate of the device. Connections have the
+// public address of the peer, which WhoIs can't identify, see tstea.Funnel.
+// They are counted once the tls connection is accepted, so the requests served
+// on it don't have http.Request.TLS set.
+func (s *ListenerSet) ListenFunnel(name string, port int) (net.Listener, error) {
+	return s.add(name, func() (net.Listener, error) {
+		return s.ts.ListenFunnel("tcp", net.JoinHostPort("", fmt.Sprint(port)), tsnet.FunnelOnly())
+	})
+}
+
+// ListenTLS listens on port of the tailnet for https as name, TLS is
+// terminated with the certificate tailscale issues to the device. The tailnet
+// must have HTTPS certificates enabled, the certificate is fetched on the
+// first connection.
+func (s *ListenerSet) ListenTLS(name string, port int) (net.Listener, error) {
+	l, err := s.Listen(name, port)
+	if err != nil {
+		return nil, err
+	}
+	// like tsnet.Server.ListenTLS, with the connections counted beneath tls
+	l = tls.NewListener(l, &tls.Config{GetCertificate: s.Client.GetCertificate})
+	s.mu.Lock()
+	s.listeners[name] = l
+	s.mu.Unlock()
+	return l, nil
+}
+
+// add starts the listener name with listen, its connections are counted.
+func (s *ListenerSet) add(name string, listen func() (net.Listener, error)) (net.Listener, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.listeners[name]; ok {
+		return nil, fmt.Errorf("%s listener already exists", name)
+	}
+	l, err := listen()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start %s listener: %w", name, err)
+	}
+	c := &countListener{Listener: l}
+	s.listeners[name], s.counts[name] = c, c
+	return c, nil
+}
+
+// DNSName returns the MagicDNS name of the device without the trailing dot.
+func (s *ListenerSet) DNSName(ctx context.Context) (string, error) {
+	st, err := s.Client.StatusWithoutPeers(ctx)
+	if err != nil {
+		return "", err
+	}
+	if st.Self == nil {
+		return "", errors.New("tailscale status has no self node")
+	}
+	return strings.TrimSuffix(st.Self.DNSName, "."), nil
+}
+
+func (s *ListenerSet) WaitForTailscaleIP(ctx context.Context) (v4, v6 netip.Addr, err error) {
+	var (
+		t    = time.NewTicker(time.Second)
+		done = ctx.Done()
+	)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-done:
+			return v4, v6, ctx.Err()
+
+		case <-t.C:
+			v4, v6 = s.ts.TailscaleIPs()
+			if v4.IsValid() {
+				return v4, v6, nil
+			}
+			log.Info("Waiting for tailscale IP")
+		}
+	}
+}
+
+// Close closes the listeners, the ones already closed by their servers are
+// ignored, and the device. The device is logged out first and its state
+// deleted last as OnClose says.
+func (s *ListenerSet) Close() error {
+	if s == nil {
+		return nil
+	}
+	errs := make([]error, 0, len(s.listeners)+2)
+	for _, l := range s.listeners {
+		if err := l.Close(); !errors.Is(err, net.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+	if s.close >= CloseLogout && s.Client != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), logoutTimeout)
+		if err := s.Client.Logout(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to log out device: %w", err))
+		}
+		cancel()
+	}
+	errs = append(errs, s.ts.Close())
+	if s.close == CloseDelete {
+		if err := os.RemoveAll(s.ts.Dir); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete device state: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -1,96 +1,66 @@
 package tshelper
 
-import (
-	"context"
-	"errors"
-	"fmt"
-	"net"
-	"net/netip"
-	"time"
-
-	"github.com/charmbracelet/log"
-	"tailscale.com/client/local"
-	"tailscale.com/tsnet"
-)
+import "net"
 
+// Listeners is a device listening for ssh and http on its tailnet, and
+// optionally for funnel, https and rpc, see ListenerSet for other ports.
 type Listeners struct {
-	ts *tsnet.Server
+	*ListenerSet
 
 	Ssh, Http net.Listener
 
-	Client *local.Client
-}
-
-func NewListeners(hostname string, sshPort, httpPort int) (Listeners, error) {
-	l := Listeners{}
-	l.ts = new(tsnet.Server)
-	l.ts.Hostname = hostname
+	// Funnel is the public https listener, see ListenFunnel
+	Funnel net.Listener
 
-	err := l.ts.Start()
-	if err != nil {
-		return l, fmt.Errorf("tsnet.Server failed to start: %w", err)
-	}
+	// TLS is the tailnet https listener, see ListenTLS
+	TLS net.Listener
 
-	l.Ssh, err = l.ts.Listen("tcp", net.JoinHostPort("", fmt.Sprint(sshPort)))
-	if err != nil {
-		return l, errors.Join(
-			fmt.Errorf("failed to start ssh listener: %w", err),
-			l.Close(),
-		)
-	}
+	// RPC is the tailnet listener of the rpc frontends, see ListenRPC
+	RPC net.Listener
+}
 
-	l.Http, err = l.ts.Listen("tcp", net.JoinHostPort("", fmt.Sprint(httpPort)))
-	if err != nil {
-		return l, errors.Join(
-			fmt.Errorf("failed to start http listener: %w", err),
-			l.Close(),
-		)
+// WithListeners serves ssh and http on listeners the caller opened, e.g.
+// sockets inherited from systemd, instead of listening on the tailnet. A nil
+// listener is still opened on the tailnet.
+func WithListeners(ssh, http net.Listener) Option {
+	return func(o *options) {
+		WithListener("ssh", ssh)(o)
+		WithListener("http", http)(o)
 	}
+}
 
-	l.Client, err = l.ts.LocalClient()
+func NewListeners(hostname string, sshPort, httpPort int, opts ...Option) (Listeners, error) {
+	s, err := NewListenerSet(hostname, map[string]int{"ssh": sshPort, "http": httpPort}, opts...)
 	if err != nil {
-		return l, errors.Join(
-			fmt.Errorf("failed to create tsnet LocalClient(): %w", err),
-			l.Close(),
-		)
+		return Listeners{}, err
 	}
-
-	return l, nil
+	return Listeners{
+		ListenerSet: s,
+		Ssh:         s.Listener("ssh"),
+		Http:        s.Listener("http"),
+	}, nil
 }
 
-func (l Listeners) WaitForTailscaleIP(ctx context.Context) (v4, v6 netip.Addr, err error) {
-	var (
-		t    = time.NewTicker(time.Second)
-		done = ctx.Done()
-	)
-	defer t.Stop()
-
-	for {
-		select {
-		case <-done:
-			return v4, v6, ctx.Err()
-
-		case <-t.C:
-			v4, v6 = l.ts.TailscaleIPs()
-			if v4.IsValid() {
-				return v4, v6, nil
-			}
-			log.Info("Waiting for tailscale IP")
-		}
-	}
+// ListenFunnel starts Funnel, serving port to the internet over Tailscale
+// Funnel, see ListenerSet.ListenFunnel.
+func (l *Listeners) ListenFunnel(port int) error {
+	var err error
+	l.Funnel, err = l.ListenerSet.ListenFunnel("funnel", port)
+	return err
 }
 
-func (l Listeners) Close() error {
-	errs := make([]error, 0, 3)
-	if l.Ssh != nil {
-		errs = append(errs, l.Ssh.Close())
-	}
-	if l.Http != nil {
-		errs = append(errs, l.Http.Close())
-	}
-	if l.ts != nil {
-		errs = append(errs, l.ts.Close())
-	}
+// ListenTLS listens on port of the tailnet for https, see
+// ListenerSet.ListenTLS.
+func (l *Listeners) ListenTLS(port int) error {
+	var err error
+	l.TLS, err = l.ListenerSet.ListenTLS("tls", port)
+	return err
+}
 
-	return errors.Join(errs...)
+// ListenRPC listens on port of the tailnet for frontends, see
+// tstea.RPCServer.
+func (l *Listeners) ListenRPC(port int) error {
+	var err error
+	l.RPC, err = l.ListenerSet.Listen("rpc", port)
+	return err
 }
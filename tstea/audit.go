@@ -0,0 +1,300 @@
+package tstea
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/mpty/mptymsg"
+	"tailscale.com/client/tailscale/apitype"
+)
+
+func init() {
+	mptymsg.Register(AuditEvent{})
+}
+
+// The kinds of AuditEvent
+const (
+	AuditStart = "start"
+	AuditEnd   = "end"
+	// AuditConfirm is a privileged action the session was asked to confirm,
+	// see WithAgentConfirm
+	AuditConfirm = "confirm"
+)
+
+// AuditEvent is a session starting or ending, the trail of who used a shared
+// server and for how long. Only the end of a session has its Duration and the
+// bytes it read and wrote, sessions without a terminal don't count them.
+type AuditEvent struct {
+	Kind       string        `json:"kind"`
+	At         time.Time     `json:"at"`
+	Id         mpty.ClientId `json:"id"`
+	Login      string        `json:"login"`
+	Node       string        `json:"node,omitempty"`
+	Transport  string        `json:"transport"`
+	RemoteAddr string        `json:"remote_addr"`
+
+	Duration time.Duration `json:"duration,omitempty"`
+	BytesIn  int64         `json:"bytes_in,omitempty"`
+	BytesOut int64         `json:"bytes_out,omitempty"`
+
+	// Action is the privileged action of a confirm event, Error why it
+	// wasn't confirmed
+	Action string `json:"action,omitempty"`
+	Error  string `json:"error,omitempty"`
+
+	recId int64
+}
+
+var _ mptymsg.Recordable = AuditEvent{}
+
+func (e AuditEvent) TypeName() string { return "tstea.Audit" }
+func (e AuditEvent) Ts() time.Time    { return e.At }
+
+func (e AuditEvent) SetId(id int64) mptymsg.Recordable {
+	e.recId = id
+	return e
+}
+
+// AuditSink receives the events of every session. Audit is called by the
+// session as it starts and ends, it must not block for long.
+type AuditSink interface {
+	Audit(AuditEvent)
+}
+
+// AuditFunc is an AuditSink function.
+type AuditFunc func(AuditEvent)
+
+func (f AuditFunc) Audit(e AuditEvent) { f(e) }
+
+// WithAudit reports the start and end of every ssh, web terminal and rpc
+// session to sinks.
+func WithAudit(sinks ...AuditSink) Option {
+	return func(c *config) {
+		c.audit = append(c.audit, sinks...)
+	}
+}
+
+// AuditLog logs the events to l, the default logger when l is nil.
+func AuditLog(l *log.Logger) AuditSink {
+	if l == nil {
+		l = log.Default()
+	}
+	return AuditFunc(func(e AuditEvent) {
+		kv := []any{
+			"id", e.Id,
+			"login", e.Login,
+			"transport", e.Transport,
+			"raddr", e.RemoteAddr,
+		}
+		switch e.Kind {
+		case AuditEnd:
+			kv = append(kv, "duration", e.Duration, "in", e.BytesIn, "out", e.BytesOut)
+		case AuditConfirm:
+			kv = append(kv, "action", e.Action)
+			if e.Error != "" {
+				kv = append(kv, "error", e.Error)
+			}
+		}
+		l.Info("audit session "+e.Kind, kv...)
+	})
+}
+
+// AuditRecorder saves the events to r. The history of the recorder of a
+// Program is replayed to its clients, r should be a recorder of its own.
+func AuditRecorder(r mpty.Recorder) AuditSink {
+	return AuditFunc(func(e AuditEvent) {
+		if _, err := r.Save(e); err != nil {
+			log.Warn("audit record", "id", e.Id, "error", err)
+		}
+	})
+}
+
+// auditWebhookQueue is how many events are kept for the webhook while it is
+// slow, more are dropped
+const auditWebhookQueue = 256
+
+// AuditWebhook posts every event as JSON to url, in the order they happened,
+// until ctx is done. Events are dropped, and logged, while the webhook is too
+// far behind or failing.
+func AuditWebhook(ctx context.Context, url string, client *http.Client) AuditSink {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	events := make(chan AuditEvent, auditWebhookQueue)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e := <-events:
+				if err := postAudit(ctx, client, url, e); err != nil {
+					log.Warn("audit webhook", "id", e.Id, "kind", e.Kind, "error", err)
+				}
+			}
+		}
+	}()
+	return AuditFunc(func(e AuditEvent) {
+		select {
+		case events <- e:
+		default:
+			log.Warn("audit webhook behind, event dropped", "id", e.Id, "kind", e.Kind)
+		}
+	})
+}
+
+func postAudit(ctx context.Context, client *http.Client, url string, e AuditEvent) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// auditSession counts the bytes of a session until it ends.
+type auditSession struct {
+	sinks []AuditSink
+	start AuditEvent
+	in    atomic.Int64
+	out   atomic.Int64
+	ended atomic.Bool
+}
+
+// auditStart reports the start of the session of m, it is nil without sinks.
+func (c config) auditStart(m mpty.ClientModel, who *apitype.WhoIsResponse, transport string, raddr net.Addr) *auditSession {
+	if len(c.audit) == 0 || m == nil {
+		return nil
+	}
+	a := &auditSession{
+		sinks: c.audit,
+		start: AuditEvent{
+			Kind:       AuditStart,
+			At:         time.Now(),
+			Id:         m.Id(),
+			Login:      who.UserProfile.LoginName,
+			Transport:  transport,
+			RemoteAddr: raddr.String(),
+		},
+	}
+	if who.Node != nil {
+		a.start.Node = who.Node.Name
+	}
+	for _, s := range a.sinks {
+		s.Audit(a.start)
+	}
+	return a
+}
+
+// end reports the end of the session, only once.
+func (a *auditSession) end() {
+	if a == nil || a.ended.Swap(true) {
+		return
+	}
+	e := a.start
+	e.Kind, e.At = AuditEnd, time.Now()
+	e.Duration = e.At.Sub(a.start.At)
+	e.BytesIn, e.BytesOut = a.in.Load(), a.out.Load()
+	for _, s := range a.sinks {
+		s.Audit(e)
+	}
+}
+
+// confirm reports that the session was asked to confirm action, err is why it
+// wasn't.
+func (a *auditSession) confirm(action string, err error) {
+	if a == nil {
+		return
+	}
+	e := a.start
+	e.Kind, e.At, e.Action = AuditConfirm, time.Now(), action
+	if err != nil {
+		e.Error = err.Error()
+	}
+	for _, s := range a.sinks {
+		s.Audit(e)
+	}
+}
+
+// input returns r counted as the input of the session.
+func (a *auditSession) input(r io.Reader) io.Reader {
+	if a == nil {
+		return r
+	}
+	if f, ok := r.(ttyFile); ok {
+		return auditFile{f, a}
+	}
+	return auditReader{r, a}
+}
+
+// output returns w counted as the output of the session.
+func (a *auditSession) output(w io.Writer) io.Writer {
+	if a == nil {
+		return w
+	}
+	if f, ok := w.(ttyFile); ok {
+		return auditFile{f, a}
+	}
+	return auditWriter{w, a}
+}
+
+type auditReader struct {
+	io.Reader
+	a *auditSession
+}
+
+func (r auditReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.a.in.Add(int64(n))
+	return n, err
+}
+
+type auditWriter struct {
+	io.Writer
+	a *auditSession
+}
+
+func (w auditWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.a.out.Add(int64(n))
+	return n, err
+}
+
+// auditFile counts both directions of a terminal, the program needs its file
+// descriptor to detect the terminal.
+type auditFile struct {
+	ttyFile
+	a *auditSession
+}
+
+func (f auditFile) Read(p []byte) (int, error) {
+	n, err := f.ttyFile.Read(p)
+	f.a.in.Add(int64(n))
+	return n, err
+}
+
+func (f auditFile) Write(p []byte) (int, error) {
+	n, err := f.ttyFile.Write(p)
+	f.a.out.Add(int64(n))
+	return n, err
+}
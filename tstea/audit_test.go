@@ -0,0 +1,77 @@
+package tstea
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/ghthor/webtea/mpty"
+	"github.com/stretchr/testify/require"
+	"tailscale.com/client/tailscale/apitype"
+	"tailscale.com/tailcfg"
+)
+
+func TestAuditSession(t *testing.T) {
+	var events []AuditEvent
+	cfg := newConfig([]Option{WithAudit(AuditFunc(func(e AuditEvent) {
+		events = append(events, e)
+	}))})
+
+	who := &apitype.WhoIsResponse{UserProfile: &tailcfg.UserProfile{LoginName: "alice@example.com"}}
+	m := newRPCClient(mpty.NewClientInfoModelFromRPC(nil, who))
+	raddr := &net.TCPAddr{IP: net.IPv4(100, 64, 0, 1), Port: 1234}
+
+	a := cfg.auditStart(m, who, "ssh", raddr)
+	require.Len(t, events, 1)
+	require.Equal(t, AuditStart, events[0].Kind)
+	require.Equal(t, m.Id(), events[0].Id)
+	require.Equal(t, "alice@example.com", events[0].Login)
+	require.Equal(t, "100.64.0.1:1234", events[0].RemoteAddr)
+
+	_, err := io.ReadAll(a.input(strings.NewReader("hello")))
+	require.NoError(t, err)
+	_, err = a.output(io.Discard).Write([]byte("hi"))
+	require.NoError(t, err)
+
+	a.end()
+	a.end()
+	require.Len(t, events, 2)
+	end := events[1]
+	require.Equal(t, AuditEnd, end.Kind)
+	require.Equal(t, int64(5), end.BytesIn)
+	require.Equal(t, int64(2), end.BytesOut)
+	require.Equal(t, end.At.Sub(events[0].At), end.Duration)
+
+	require.Nil(t, config{}.auditStart(m, who, "ssh", raddr))
+}
+
+func TestAuditWebhook(t *testing.T) {
+	posted := make(chan AuditEvent, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e AuditEvent
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&e))
+		posted <- e
+	}))
+	defer srv.Close()
+
+	sink := AuditWebhook(t.Context(), srv.URL, srv.Client())
+	sink.Audit(AuditEvent{Kind: AuditEnd, Id: "alice@example.com s1", Duration: time.Minute})
+	e := <-posted
+	require.Equal(t, AuditEnd, e.Kind)
+	require.Equal(t, mpty.ClientId("alice@example.com s1"), e.Id)
+	require.Equal(t, time.Minute, e.Duration)
+}
+
+func TestAuditLog(t *testing.T) {
+	var b bytes.Buffer
+	AuditLog(log.New(&b)).Audit(AuditEvent{Kind: AuditStart, Login: "alice@example.com"})
+	require.Contains(t, b.String(), "audit session start")
+	require.Contains(t, b.String(), "alice@example.com")
+}
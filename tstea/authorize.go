@@ -0,0 +1,52 @@
+package tstea
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/ghthor/webtea/roles"
+	"tailscale.com/client/tailscale/apitype"
+)
+
+// Authorizer decides if who may start a session once its identity is
+// resolved. The error is shown to the refused peer.
+type Authorizer func(ctx context.Context, who *apitype.WhoIsResponse) error
+
+// WithAuthorizer refuses the sessions auth returns an error for. Features
+// within a session are restricted with the capabilities of a roles.Policy.
+func WithAuthorizer(auth Authorizer) Option {
+	return func(c *config) {
+		c.authorize = auth
+	}
+}
+
+// RequireTags only authorizes nodes with one of the tailscale tags.
+func RequireTags(tags ...string) Authorizer {
+	return func(_ context.Context, who *apitype.WhoIsResponse) error {
+		if who.Node != nil && slices.ContainsFunc(who.Node.Tags, func(tag string) bool {
+			return slices.Contains(tags, tag)
+		}) {
+			return nil
+		}
+		return fmt.Errorf("not authorized: the node needs one of the tags %v", tags)
+	}
+}
+
+// RequireCapability only authorizes identities granted c by policy.
+func RequireCapability(policy roles.Policy, c roles.Capability) Authorizer {
+	return func(_ context.Context, who *apitype.WhoIsResponse) error {
+		if policy.Access(who).Can(c) {
+			return nil
+		}
+		return fmt.Errorf("not authorized: %s is required", c)
+	}
+}
+
+// authorizeWho runs the Authorizer, if any
+func (c config) authorizeWho(ctx context.Context, who *apitype.WhoIsResponse) error {
+	if c.authorize == nil {
+		return nil
+	}
+	return c.authorize(ctx, who)
+}
@@ -0,0 +1,30 @@
+package tstea
+
+import (
+	"testing"
+
+	"github.com/ghthor/webtea/roles"
+	"github.com/stretchr/testify/require"
+	"tailscale.com/client/tailscale/apitype"
+	"tailscale.com/tailcfg"
+)
+
+func TestAuthorizer(t *testing.T) {
+	who := func(tags ...string) *apitype.WhoIsResponse {
+		return &apitype.WhoIsResponse{
+			Node:        &tailcfg.Node{Tags: tags},
+			UserProfile: &tailcfg.UserProfile{LoginName: "alice@example.com"},
+		}
+	}
+
+	tags := RequireTags("tag:ops", "tag:arcade")
+	require.NoError(t, tags(t.Context(), who("tag:arcade")))
+	require.ErrorContains(t, tags(t.Context(), who("tag:web")), "not authorized")
+	require.Error(t, tags(t.Context(), &apitype.WhoIsResponse{UserProfile: &tailcfg.UserProfile{}}))
+
+	policy := roles.DefaultPolicy()
+	policy.TagGrants = map[string][]roles.Capability{"tag:ops": {roles.CanOperate}}
+	operate := RequireCapability(policy, roles.CanOperate)
+	require.NoError(t, operate(t.Context(), who("tag:ops")))
+	require.ErrorContains(t, operate(t.Context(), who()), "operate is required")
+}
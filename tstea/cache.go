@@ -0,0 +1,161 @@
+package tstea
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ghthor/webtea/logpolicy"
+	"github.com/ghthor/webtea/mpty"
+	"tailscale.com/client/tailscale/apitype"
+)
+
+// refreshTimeout bounds the background WhoIs of a stale entry
+const refreshTimeout = 10 * time.Second
+
+// IdentityCache remembers the profiles resolved by an Identity by remote
+// address, so reconnect storms don't each wait on a WhoIs of the local API.
+// Entries are fresh for the ttl and then served stale for up to stale while
+// they are refreshed in the background. Errors are never cached.
+//
+// Only cache identities that resolve by the remote address alone, e.g.
+// Tailscale, since the session isn't part of the key.
+type IdentityCache struct {
+	id         Identity
+	size       int
+	ttl, stale time.Duration
+	now        func() time.Time
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+	pending map[string]*pendingWhoIs
+}
+
+type cacheEntry struct {
+	addr       string
+	who        *apitype.WhoIsResponse
+	resolved   time.Time
+	refreshing bool
+}
+
+// pendingWhoIs is a resolve that concurrent misses of the same address wait
+// on instead of each resolving.
+type pendingWhoIs struct {
+	done chan struct{}
+	who  *apitype.WhoIsResponse
+	err  error
+}
+
+// NewIdentityCache caches up to size profiles resolved by id. A ttl of 0
+// disables the cache.
+func NewIdentityCache(id Identity, size int, ttl, stale time.Duration) *IdentityCache {
+	return &IdentityCache{
+		id:      id,
+		size:    max(1, size),
+		ttl:     ttl,
+		stale:   stale,
+		now:     time.Now,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+		pending: make(map[string]*pendingWhoIs),
+	}
+}
+
+var _ Identity = &IdentityCache{}
+
+func (c *IdentityCache) Resolve(ctx context.Context, remoteAddr string, sess mpty.Session) (*apitype.WhoIsResponse, error) {
+	if c.ttl <= 0 {
+		return c.id.Resolve(ctx, remoteAddr, sess)
+	}
+
+	c.mu.Lock()
+	if el, ok := c.entries[remoteAddr]; ok {
+		e := el.Value.(*cacheEntry)
+		age := c.now().Sub(e.resolved)
+		if age < c.ttl+c.stale {
+			c.lru.MoveToFront(el)
+			if age >= c.ttl && !e.refreshing {
+				e.refreshing = true
+				go c.refresh(context.WithoutCancel(ctx), remoteAddr, sess)
+			}
+			c.mu.Unlock()
+			return e.who, nil
+		}
+	}
+
+	if p, ok := c.pending[remoteAddr]; ok {
+		c.mu.Unlock()
+		select {
+		case <-p.done:
+			return p.who, p.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	p := &pendingWhoIs{done: make(chan struct{})}
+	c.pending[remoteAddr] = p
+	c.mu.Unlock()
+
+	p.who, p.err = c.id.Resolve(ctx, remoteAddr, sess)
+
+	c.mu.Lock()
+	delete(c.pending, remoteAddr)
+	if p.err == nil {
+		c.store(remoteAddr, p.who)
+	}
+	c.mu.Unlock()
+	close(p.done)
+	return p.who, p.err
+}
+
+func (c *IdentityCache) refresh(ctx context.Context, remoteAddr string, sess mpty.Session) {
+	ctx, cancel := context.WithTimeout(ctx, refreshTimeout)
+	defer cancel()
+	who, err := c.id.Resolve(ctx, remoteAddr, sess)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		logpolicy.Warn("tstea.identity_refresh", "failed to refresh identity", "addr", remoteAddr, "error", err)
+		if el, ok := c.entries[remoteAddr]; ok {
+			el.Value.(*cacheEntry).refreshing = false
+		}
+		return
+	}
+	c.store(remoteAddr, who)
+}
+
+// store must be called with mu held
+func (c *IdentityCache) store(addr string, who *apitype.WhoIsResponse) {
+	if el, ok := c.entries[addr]; ok {
+		el.Value = &cacheEntry{addr: addr, who: who, resolved: c.now()}
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[addr] = c.lru.PushFront(&cacheEntry{addr: addr, who: who, resolved: c.now()})
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).addr)
+	}
+}
+
+// Forget drops the cached profile of remoteAddr, e.g. after its node logged
+// out.
+func (c *IdentityCache) Forget(remoteAddr string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[remoteAddr]; ok {
+		c.lru.Remove(el)
+		delete(c.entries, remoteAddr)
+	}
+}
+
+// Len returns the number of cached profiles.
+func (c *IdentityCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
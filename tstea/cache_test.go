@@ -0,0 +1,96 @@
+package tstea
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ghthor/webtea/mpty"
+	"github.com/stretchr/testify/require"
+	"tailscale.com/client/tailscale/apitype"
+	"tailscale.com/tailcfg"
+)
+
+func TestIdentityCache(t *testing.T) {
+	var (
+		calls   atomic.Int32
+		fail    atomic.Bool
+		refresh = make(chan struct{}, 1)
+	)
+	id := IdentityFunc(func(ctx context.Context, remoteAddr string, _ mpty.Session) (*apitype.WhoIsResponse, error) {
+		n := calls.Add(1)
+		defer func() {
+			select {
+			case refresh <- struct{}{}:
+			default:
+			}
+		}()
+		if fail.Load() {
+			return nil, errors.New("whois failed")
+		}
+		return &apitype.WhoIsResponse{
+			UserProfile: &tailcfg.UserProfile{LoginName: remoteAddr, DisplayName: strconv.Itoa(int(n))},
+		}, nil
+	})
+
+	var now atomic.Int64
+	c := NewIdentityCache(id, 2, time.Minute, time.Minute)
+	c.now = func() time.Time { return time.Unix(now.Load(), 0) }
+
+	resolve := func(addr string) (string, error) {
+		who, err := c.Resolve(t.Context(), addr, nil)
+		if err != nil {
+			return "", err
+		}
+		return who.UserProfile.DisplayName, nil
+	}
+
+	got, err := resolve("a:1")
+	require.NoError(t, err)
+	require.Equal(t, "1", got)
+	<-refresh
+
+	// fresh entries are served from the cache
+	got, err = resolve("a:1")
+	require.NoError(t, err)
+	require.Equal(t, "1", got)
+	require.Equal(t, int32(1), calls.Load())
+
+	// stale entries are served while they are refreshed
+	now.Add(90)
+	got, err = resolve("a:1")
+	require.NoError(t, err)
+	require.Equal(t, "1", got)
+	<-refresh
+	require.Eventually(t, func() bool {
+		got, _ := resolve("a:1")
+		return got == "2"
+	}, time.Second, time.Millisecond)
+
+	// expired entries are resolved again and errors aren't cached
+	now.Add(180)
+	fail.Store(true)
+	_, err = resolve("a:1")
+	require.Error(t, err)
+	<-refresh
+	fail.Store(false)
+	got, err = resolve("a:1")
+	require.NoError(t, err)
+	require.Equal(t, "4", got)
+	<-refresh
+
+	// the least recently used entry is evicted
+	_, err = resolve("b:1")
+	require.NoError(t, err)
+	<-refresh
+	_, err = resolve("c:1")
+	require.NoError(t, err)
+	<-refresh
+	require.Equal(t, 2, c.Len())
+	got, err = resolve("a:1")
+	require.NoError(t, err)
+	require.Equal(t, "7", got)
+}
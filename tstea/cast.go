@@ -0,0 +1,201 @@
+package tstea
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+	"github.com/ghthor/webtea/mpty"
+)
+
+// WithCastRecording records what every session is shown to an asciicast v2
+// file in dir, named after its ClientId, so moderators can replay it with
+// asciinema. Recording failures are logged and never end the session.
+func WithCastRecording(dir string) Option {
+	return func(c *config) {
+		c.castDir = dir
+	}
+}
+
+// castSize is the terminal size recorded for web terminals that didn't pass
+// their size, they are only resized once the program runs.
+const (
+	castWidth  = 80
+	castHeight = 24
+)
+
+// castHeader is the first line of an asciicast v2 file
+type castHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// castWriter writes the output events of a session to an asciicast file.
+type castWriter struct {
+	mu    sync.Mutex
+	f     *os.File
+	w     *bufio.Writer
+	start time.Time
+	// partial is the start of a utf-8 sequence split across writes
+	partial []byte
+	err     error
+}
+
+// recordOptions appends the output that records the program of m to opts,
+// when recording is enabled. The cast is closed once ctx is done.
+func (c config) recordOptions(ctx context.Context, m mpty.ClientModel, width, height int, term string, out io.Writer, opts []tea.ProgramOption) []tea.ProgramOption {
+	if c.castDir == "" || m == nil {
+		return opts
+	}
+	cast, err := newCastWriter(c.castDir, m.Id(), width, height, term)
+	if err != nil {
+		log.Warn("failed to record session", "id", m.Id(), "error", err)
+		return opts
+	}
+	context.AfterFunc(ctx, func() {
+		if err := cast.Close(); err != nil {
+			log.Warn("failed to close session recording", "id", m.Id(), "error", err)
+		}
+	})
+	return append(opts, tea.WithOutput(recordOutput(out, cast)))
+}
+
+// castFileName returns a file name for the cast of id
+func castFileName(id mpty.ClientId) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', strings.ContainsRune("@.-", r):
+			return r
+		}
+		return '_'
+	}, string(id))
+	return name + ".cast"
+}
+
+func newCastWriter(dir string, id mpty.ClientId, width, height int, term string) (*castWriter, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, castFileName(id)), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &castWriter{f: f, w: bufio.NewWriter(f), start: time.Now()}
+	h := castHeader{
+		Version:   2,
+		Width:     width,
+		Height:    height,
+		Timestamp: c.start.Unix(),
+		Title:     string(id),
+	}
+	if term != "" {
+		h.Env = map[string]string{"TERM": term}
+	}
+	if err := json.NewEncoder(c.w).Encode(h); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// output records p as an output event.
+func (c *castWriter) output(p []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil || c.f == nil {
+		return
+	}
+
+	data := append(c.partial, p...)
+	c.partial = nil
+	// hold back a trailing incomplete rune so it isn't encoded as U+FFFD
+	for i := len(data) - 1; i >= max(0, len(data)-utf8.UTFMax+1); i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				c.partial = append([]byte(nil), data[i:]...)
+				data = data[:i]
+			}
+			break
+		}
+	}
+	if len(data) == 0 {
+		return
+	}
+
+	event, err := json.Marshal([]any{time.Since(c.start).Seconds(), "o", string(data)})
+	if err == nil {
+		_, err = fmt.Fprintf(c.w, "%s\n", event)
+	}
+	if err != nil {
+		c.err = err
+		log.Warn("session recording failed", "file", c.f.Name(), "error", err)
+	}
+}
+
+func (c *castWriter) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.f == nil {
+		return nil
+	}
+	err := c.w.Flush()
+	if cerr := c.f.Close(); err == nil {
+		err = cerr
+	}
+	c.f = nil
+	return err
+}
+
+// castOutput tees what the program writes to w into the cast.
+type castOutput struct {
+	io.Writer
+	cast *castWriter
+}
+
+func (o castOutput) Write(p []byte) (int, error) {
+	n, err := o.Writer.Write(p)
+	o.cast.output(p[:n])
+	return n, err
+}
+
+// ttyFile is a terminal, e.g. the *os.File of a pty
+type ttyFile interface {
+	io.ReadWriteCloser
+	Fd() uintptr
+}
+
+// castFile is castOutput for terminals, the program needs the file
+// descriptor of its output to detect the terminal.
+type castFile struct {
+	ttyFile
+	cast *castWriter
+}
+
+func (o castFile) Write(p []byte) (int, error) {
+	n, err := o.ttyFile.Write(p)
+	o.cast.output(p[:n])
+	return n, err
+}
+
+// recordOutput returns the output of a program recorded into cast.
+func recordOutput(w io.Writer, cast *castWriter) io.Writer {
+	if f, ok := w.(ttyFile); ok {
+		return castFile{f, cast}
+	}
+	return castOutput{w, cast}
+}
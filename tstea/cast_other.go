@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !freebsd && !dragonfly && !netbsd && !openbsd && !solaris
+
+package tstea
+
+import (
+	"io"
+
+	"github.com/charmbracelet/ssh"
+)
+
+// sshOutput returns the output wish gives the program of s.
+func sshOutput(s ssh.Session) io.Writer {
+	return s
+}
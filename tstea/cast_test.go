@@ -0,0 +1,55 @@
+package tstea
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ghthor/webtea/mpty"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCastWriter(t *testing.T) {
+	dir := t.TempDir()
+	id := mpty.NewClientId("alice@example.com", "a1/b2")
+	cast, err := newCastWriter(dir, id, 100, 30, "xterm")
+	require.NoError(t, err)
+
+	var screen bytes.Buffer
+	out := recordOutput(&screen, cast)
+	_, err = out.Write([]byte("h\xc3"))
+	require.NoError(t, err)
+	_, err = out.Write([]byte("\xa9llo\x1b[0m"))
+	require.NoError(t, err)
+	require.NoError(t, cast.Close())
+	require.Equal(t, "héllo\x1b[0m", screen.String())
+
+	_, err = newCastWriter(dir, id, 100, 30, "xterm")
+	require.Error(t, err, "recordings are never overwritten")
+
+	f, err := os.Open(filepath.Join(dir, castFileName(id)))
+	require.NoError(t, err)
+	defer f.Close()
+	lines := bufio.NewScanner(f)
+
+	require.True(t, lines.Scan())
+	var h castHeader
+	require.NoError(t, json.Unmarshal(lines.Bytes(), &h))
+	require.Equal(t, 2, h.Version)
+	require.Equal(t, 100, h.Width)
+	require.Equal(t, string(id), h.Title)
+	require.Equal(t, "xterm", h.Env["TERM"])
+
+	var data string
+	for lines.Scan() {
+		var event []any
+		require.NoError(t, json.Unmarshal(lines.Bytes(), &event))
+		require.Len(t, event, 3)
+		require.Equal(t, "o", event[1])
+		data += event[2].(string)
+	}
+	require.Equal(t, "héllo\x1b[0m", data)
+}
@@ -0,0 +1,17 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+
+package tstea
+
+import (
+	"io"
+
+	"github.com/charmbracelet/ssh"
+)
+
+// sshOutput returns the output wish gives the program of s.
+func sshOutput(s ssh.Session) io.Writer {
+	if pty, _, ok := s.Pty(); ok && !s.EmulatedPty() && pty.Slave != nil {
+		return pty.Slave
+	}
+	return s
+}
@@ -0,0 +1,123 @@
+package tstea
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/ghthor/webtea/mpty"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// agentChannel is the channel type of a forwarded ssh agent
+const agentChannel = "auth-agent@openssh.com"
+
+// confirmNamespace prefixes the data signed to confirm an action, so the
+// signature can't be mistaken for one of an ssh login
+const confirmNamespace = "webtea-confirm-v1\x00"
+
+var (
+	// ErrNoAgent is returned when a privileged action must be confirmed but
+	// the ssh session didn't forward an agent
+	ErrNoAgent = errors.New("confirm with a forwarded ssh agent, reconnect with ssh -A")
+	// ErrNoConfirmKey is returned when none of the keys of the agent are
+	// authorized for the login of the session
+	ErrNoConfirmKey = errors.New("the ssh agent has no key authorized for your login")
+	// ErrConfirmUnavailable is returned by sessions that can't confirm
+	// privileged actions, like web terminals
+	ErrConfirmUnavailable = errors.New("privileged actions must be confirmed from an ssh session with a forwarded agent")
+)
+
+// WithAgentConfirm requires a fresh signature of the forwarded ssh agent for
+// the privileged actions of ssh sessions, e.g. to kick users, so a hijacked
+// idle terminal isn't enough to take them. The agent must sign with one of
+// keys authorized for the login of the session. Web terminals can't confirm
+// and are refused. Each confirmation is reported to the audit sinks. Nothing
+// needs to be confirmed when keys is nil.
+//
+// The confirmer is given to models with a SetConfirmer(mpty.Confirmer)
+// method, like mpty.ClientInfoModel.
+func WithAgentConfirm(keys *AuthorizedKeys) Option {
+	return func(c *config) {
+		c.confirmKeys = keys
+	}
+}
+
+// setConfirmer gives m the confirmer of its session when privileged actions
+// must be confirmed.
+func (c config) setConfirmer(m mpty.ClientModel, confirm mpty.Confirmer) {
+	if c.confirmKeys == nil || m == nil {
+		return
+	}
+	if s, ok := m.(interface{ SetConfirmer(mpty.Confirmer) }); ok {
+		s.SetConfirmer(confirm)
+	}
+}
+
+// refuseConfirm refuses every action of a session that can't confirm them.
+func refuseConfirm(audit *auditSession) mpty.Confirmer {
+	return mpty.ConfirmFunc(func(ctx context.Context, action string) error {
+		audit.confirm(action, ErrConfirmUnavailable)
+		return ErrConfirmUnavailable
+	})
+}
+
+// agentConfirmer confirms actions with the agent forwarded by an ssh session.
+type agentConfirmer struct {
+	sess  ssh.Session
+	login string
+	keys  *AuthorizedKeys
+	audit *auditSession
+}
+
+func (c agentConfirmer) Confirm(ctx context.Context, action string) (err error) {
+	defer func() { c.audit.confirm(action, err) }()
+
+	if !ssh.AgentRequested(c.sess) {
+		return ErrNoAgent
+	}
+	conn, ok := c.sess.Context().Value(ssh.ContextKeyConn).(gossh.Conn)
+	if !ok {
+		return ErrNoAgent
+	}
+	ch, reqs, err := conn.OpenChannel(agentChannel, nil)
+	if err != nil {
+		return fmt.Errorf("could not reach the ssh agent: %w", err)
+	}
+	go gossh.DiscardRequests(reqs)
+	defer ch.Close()
+	// the agent may wait on the user, e.g. to touch a security key
+	stop := context.AfterFunc(ctx, func() { ch.Close() })
+	defer stop()
+
+	return signChallenge(agent.NewClient(ch), c.keys, c.login, action)
+}
+
+// signChallenge has the first key of a authorized for login sign a fresh
+// challenge for action, and verifies the signature.
+func signChallenge(a agent.ExtendedAgent, keys *AuthorizedKeys, login, action string) error {
+	list, err := a.List()
+	if err != nil {
+		return fmt.Errorf("could not list the keys of the ssh agent: %w", err)
+	}
+	for _, k := range list {
+		if !keys.authorizes(k, login) {
+			continue
+		}
+		data := fmt.Appendf(nil, "%s%s\x00%s", confirmNamespace, action, rand.Text())
+
+		var flags agent.SignatureFlags
+		if k.Type() == gossh.KeyAlgoRSA {
+			flags = agent.SignatureFlagRsaSha256
+		}
+		sig, err := a.SignWithFlags(k, data, flags)
+		if err != nil {
+			return fmt.Errorf("the ssh agent didn't sign: %w", err)
+		}
+		return k.Verify(data, sig)
+	}
+	return ErrNoConfirmKey
+}
@@ -0,0 +1,56 @@
+package tstea
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/ghthor/webtea/mpty"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"tailscale.com/client/tailscale/apitype"
+	"tailscale.com/tailcfg"
+)
+
+func TestSignChallenge(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	pub, err := gossh.NewPublicKey(priv.Public())
+	require.NoError(t, err)
+
+	keys, err := ParseAuthorizedKeys([]byte(strings.TrimSpace(string(gossh.MarshalAuthorizedKey(pub))) + " alice@example.com\n"))
+	require.NoError(t, err)
+
+	a := agent.NewKeyring().(agent.ExtendedAgent)
+	require.ErrorIs(t, signChallenge(a, keys, "alice@example.com", "kick bob"), ErrNoConfirmKey)
+
+	require.NoError(t, a.Add(agent.AddedKey{PrivateKey: priv}))
+	require.NoError(t, signChallenge(a, keys, "alice@example.com", "kick bob"))
+	require.ErrorIs(t, signChallenge(a, keys, "mallory@example.com", "kick bob"), ErrNoConfirmKey)
+}
+
+func TestConfirmAudit(t *testing.T) {
+	var events []AuditEvent
+	cfg := newConfig([]Option{
+		WithAudit(AuditFunc(func(e AuditEvent) { events = append(events, e) })),
+		WithAgentConfirm(&AuthorizedKeys{}),
+	})
+	who := &apitype.WhoIsResponse{UserProfile: &tailcfg.UserProfile{LoginName: "alice@example.com"}}
+	info := mpty.NewClientInfoModelFromRPC(nil, who)
+	m := newRPCClient(info)
+	a := cfg.auditStart(m, who, "web", &net.TCPAddr{IP: net.IPv4(100, 64, 0, 1), Port: 1234})
+
+	cfg.setConfirmer(m, refuseConfirm(a))
+	require.ErrorIs(t, info.Confirmer().Confirm(t.Context(), "kick bob"), ErrConfirmUnavailable)
+	require.Len(t, events, 2)
+	require.Equal(t, AuditConfirm, events[1].Kind)
+	require.Equal(t, "kick bob", events[1].Action)
+	require.Equal(t, ErrConfirmUnavailable.Error(), events[1].Error)
+
+	m = newRPCClient(mpty.NewClientInfoModelFromRPC(nil, who))
+	config{}.setConfirmer(m, refuseConfirm(a))
+	require.Nil(t, m.Confirmer())
+}
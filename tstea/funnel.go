@@ -0,0 +1,54 @@
+package tstea
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"slices"
+
+	"github.com/ghthor/webtea/mpty"
+	"tailscale.com/client/tailscale/apitype"
+)
+
+// ErrUnidentified is returned by Unidentified.
+var ErrUnidentified = errors.New("connection from outside of the tailnet can't be identified")
+
+// tailnetPrefixes are the address ranges tailscale assigns nodes from
+var tailnetPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("fd7a:115c:a1e0::/48"),
+}
+
+// IsTailnetAddr reports if remoteAddr, an ip:port, is a tailscale address.
+func IsTailnetAddr(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(tailnetPrefixes, func(p netip.Prefix) bool {
+		return p.Contains(addr.Unmap())
+	})
+}
+
+// Unidentified identifies nobody, e.g. to wrap with Guests so every peer is
+// a guest.
+var Unidentified Identity = IdentityFunc(func(context.Context, string, mpty.Session) (*apitype.WhoIsResponse, error) {
+	return nil, ErrUnidentified
+})
+
+// Funnel resolves peers with tailscale addresses with id and everyone else,
+// e.g. the public peers of Tailscale Funnel which WhoIs knows nothing about,
+// with public. Usually public is Guests(Unidentified) or an OIDC login.
+func Funnel(id, public Identity) Identity {
+	return IdentityFunc(func(ctx context.Context, remoteAddr string, sess mpty.Session) (*apitype.WhoIsResponse, error) {
+		if IsTailnetAddr(remoteAddr) {
+			return id.Resolve(ctx, remoteAddr, sess)
+		}
+		return public.Resolve(ctx, remoteAddr, sess)
+	})
+}
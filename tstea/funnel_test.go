@@ -0,0 +1,36 @@
+package tstea
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/roles"
+	"github.com/stretchr/testify/require"
+	"tailscale.com/client/tailscale/apitype"
+	"tailscale.com/tailcfg"
+)
+
+func TestFunnel(t *testing.T) {
+	require.True(t, IsTailnetAddr("100.101.102.103:22"))
+	require.True(t, IsTailnetAddr("[fd7a:115c:a1e0::1]:443"))
+	require.False(t, IsTailnetAddr("203.0.113.7:51000"))
+	require.False(t, IsTailnetAddr("not an addr"))
+
+	tailnet := IdentityFunc(func(_ context.Context, remoteAddr string, _ mpty.Session) (*apitype.WhoIsResponse, error) {
+		return &apitype.WhoIsResponse{UserProfile: &tailcfg.UserProfile{LoginName: "alice@example.com"}}, nil
+	})
+	id := Funnel(tailnet, Guests(Unidentified))
+
+	who, err := id.Resolve(t.Context(), "100.101.102.103:51000", nil)
+	require.NoError(t, err)
+	require.Equal(t, "alice@example.com", who.UserProfile.LoginName)
+
+	who, err = id.Resolve(t.Context(), "203.0.113.7:51000", nil)
+	require.NoError(t, err)
+	require.Equal(t, GuestName("203.0.113.7:51000"), who.UserProfile.LoginName)
+	require.Equal(t, roles.Guest, roles.DefaultPolicy().RoleOf(who))
+
+	_, err = Funnel(tailnet, Unidentified).Resolve(t.Context(), "203.0.113.7:51000", nil)
+	require.ErrorIs(t, err, ErrUnidentified)
+}
@@ -0,0 +1,65 @@
+package tstea
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net"
+
+	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/roles"
+	"tailscale.com/client/tailscale/apitype"
+	"tailscale.com/tailcfg"
+)
+
+var (
+	guestAdjectives = []string{
+		"amber", "brave", "calm", "dusty", "eager", "fuzzy", "gentle", "hazy",
+		"icy", "jolly", "keen", "lucky", "mellow", "nimble", "quiet", "rusty",
+	}
+	guestAnimals = []string{
+		"badger", "crane", "dingo", "egret", "ferret", "gecko", "heron", "ibis",
+		"jackal", "koala", "lemur", "marmot", "newt", "otter", "puffin", "quokka",
+	}
+)
+
+// Guests resolves peers with id, and peers id doesn't know, e.g. because they
+// are outside of the tailnet, as a guest instead of refusing the session. A
+// guest is named adjective-animal-NNN after its IP, so it keeps the name when
+// it reconnects, and always has the roles.Guest role.
+func Guests(id Identity) Identity {
+	return IdentityFunc(func(ctx context.Context, remoteAddr string, sess mpty.Session) (*apitype.WhoIsResponse, error) {
+		who, err := id.Resolve(ctx, remoteAddr, sess)
+		if err == nil {
+			return who, nil
+		}
+		name := GuestName(remoteAddr)
+		return &apitype.WhoIsResponse{
+			Node: &tailcfg.Node{
+				Name: name,
+				Tags: []string{roles.GuestTag},
+			},
+			UserProfile: &tailcfg.UserProfile{
+				LoginName:   name,
+				DisplayName: name,
+			},
+		}, nil
+	})
+}
+
+// GuestName returns the guest name of the IP of remoteAddr.
+func GuestName(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	h := fnv.New64a()
+	h.Write([]byte(host))
+	n := h.Sum64()
+
+	adjective := guestAdjectives[n%uint64(len(guestAdjectives))]
+	n /= uint64(len(guestAdjectives))
+	animal := guestAnimals[n%uint64(len(guestAnimals))]
+	n /= uint64(len(guestAnimals))
+	return fmt.Sprintf("%s-%s-%03d", adjective, animal, n%1000)
+}
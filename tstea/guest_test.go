@@ -0,0 +1,27 @@
+package tstea
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/roles"
+	"github.com/stretchr/testify/require"
+	"tailscale.com/client/tailscale/apitype"
+)
+
+func TestGuests(t *testing.T) {
+	unknown := IdentityFunc(func(context.Context, string, mpty.Session) (*apitype.WhoIsResponse, error) {
+		return nil, errors.New("not on the tailnet")
+	})
+
+	who, err := Guests(unknown).Resolve(context.Background(), "203.0.113.7:51000", nil)
+	require.NoError(t, err)
+	require.Regexp(t, `^[a-z]+-[a-z]+-\d{3}$`, who.UserProfile.LoginName)
+	require.Equal(t, roles.Guest, roles.DefaultPolicy().RoleOf(who))
+
+	// the name only depends on the IP so it's kept across reconnects
+	require.Equal(t, who.UserProfile.LoginName, GuestName("203.0.113.7:52000"))
+	require.NotEqual(t, who.UserProfile.LoginName, GuestName("203.0.113.8:51000"))
+}
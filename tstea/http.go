@@ -0,0 +1,36 @@
+package tstea
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/ghthor/webtea/logpolicy"
+	"github.com/ghthor/webtea/roles"
+)
+
+// AllowLogins returns a request filter that only allows requests from the
+// given login names. It's intended for guarding operator endpoints like
+// webtea.WithPprof.
+func AllowLogins(id Identity, logins ...string) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		who, err := id.Resolve(r.Context(), r.RemoteAddr, nil)
+		if err != nil {
+			logpolicy.Warn("tstea.http_identity", "http identity", "error", err, "raddr", r.RemoteAddr, "path", r.URL.Path)
+			return false
+		}
+		return slices.Contains(logins, who.UserProfile.LoginName)
+	}
+}
+
+// AllowCapability returns a request filter that only allows requests from
+// identities granted c by policy.
+func AllowCapability(id Identity, policy roles.Policy, c roles.Capability) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		who, err := id.Resolve(r.Context(), r.RemoteAddr, nil)
+		if err != nil {
+			logpolicy.Warn("tstea.http_identity", "http identity", "error", err, "raddr", r.RemoteAddr, "path", r.URL.Path)
+			return false
+		}
+		return policy.Access(who).Can(c)
+	}
+}
@@ -0,0 +1,35 @@
+package tstea
+
+import (
+	"context"
+
+	"github.com/ghthor/webtea/mpty"
+	"tailscale.com/client/local"
+	"tailscale.com/client/tailscale/apitype"
+)
+
+// Identity resolves who is on the other end of a connection. The profile is
+// a tailscale WhoIsResponse since the models and roles are built around it,
+// resolvers for deployments outside of a tailnet only need to fill in the
+// UserProfile.
+type Identity interface {
+	// Resolve returns the profile of remoteAddr, an ip:port. sess is the ssh
+	// session or websocket connection being started, and nil for plain http
+	// requests.
+	Resolve(ctx context.Context, remoteAddr string, sess mpty.Session) (*apitype.WhoIsResponse, error)
+}
+
+// IdentityFunc adapts a function to an Identity.
+type IdentityFunc func(ctx context.Context, remoteAddr string, sess mpty.Session) (*apitype.WhoIsResponse, error)
+
+func (f IdentityFunc) Resolve(ctx context.Context, remoteAddr string, sess mpty.Session) (*apitype.WhoIsResponse, error) {
+	return f(ctx, remoteAddr, sess)
+}
+
+// Tailscale resolves identities with the WhoIs of the tailscale node lc
+// belongs to.
+func Tailscale(lc *local.Client) Identity {
+	return IdentityFunc(func(ctx context.Context, remoteAddr string, _ mpty.Session) (*apitype.WhoIsResponse, error) {
+		return lc.WhoIs(ctx, remoteAddr)
+	})
+}
@@ -0,0 +1,67 @@
+package tstea
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// WithIdleTimeout ends sessions that haven't sent any key or mouse input for
+// timeout. Ending the program is what broadcasts the disconnect, so the
+// server model cleans up the presence of idle sessions like any other.
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.idleTimeout = timeout
+	}
+}
+
+type idleTracker struct {
+	timeout time.Duration
+	last    atomic.Int64
+}
+
+// newIdleTracker returns nil when idle timeouts are disabled
+func newIdleTracker(timeout time.Duration) *idleTracker {
+	if timeout <= 0 {
+		return nil
+	}
+	t := &idleTracker{timeout: timeout}
+	t.last.Store(time.Now().UnixNano())
+	return t
+}
+
+// options appends the filter that records input to opts
+func (t *idleTracker) options(opts []tea.ProgramOption) []tea.ProgramOption {
+	if t == nil {
+		return opts
+	}
+	return append(opts, tea.WithFilter(t.filter))
+}
+
+func (t *idleTracker) filter(_ tea.Model, msg tea.Msg) tea.Msg {
+	switch msg.(type) {
+	case tea.KeyMsg, tea.MouseMsg:
+		t.last.Store(time.Now().UnixNano())
+	}
+	return msg
+}
+
+func (t *idleTracker) enforce(ctx context.Context, prog *tea.Program) {
+	if t == nil {
+		return
+	}
+
+	go func() {
+		deadline := time.Unix(0, t.last.Load()).Add(t.timeout)
+		for sleepUntil(ctx, deadline) {
+			next := time.Unix(0, t.last.Load()).Add(t.timeout)
+			if !next.After(deadline) {
+				prog.Quit()
+				return
+			}
+			deadline = next
+		}
+	}()
+}
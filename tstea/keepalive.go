@@ -0,0 +1,137 @@
+package tstea
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+	"github.com/gorilla/websocket"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// Some clients vanish without tearing down their TCP connection. Without a
+// probe their sessions, and presence in any room, would live until the kernel
+// gives up on the connection which can take hours. Closing the connection of
+// a session that stops answering makes its program exit, which is how the
+// disconnect events are emitted.
+
+type keepalive struct {
+	interval, timeout time.Duration
+}
+
+// WithKeepalive probes every session each interval and closes those that
+// haven't answered within timeout. SSH sessions are sent a
+// keepalive@openssh.com request and web sessions a websocket ping.
+func WithKeepalive(interval, timeout time.Duration) Option {
+	return func(c *config) {
+		c.keepalive = keepalive{interval, timeout}
+	}
+}
+
+func (k keepalive) enabled() bool {
+	return k.interval > 0 && k.timeout > 0
+}
+
+var errKeepaliveTimeout = errors.New("keepalive timeout")
+
+// ErrHeartbeatMissed ends the program of a web terminal that stopped
+// answering pings.
+var ErrHeartbeatMissed = errors.New("web terminal stopped answering heartbeats")
+
+func (k keepalive) ssh(s ssh.Session) {
+	if !k.enabled() {
+		return
+	}
+	conn, ok := s.Context().Value(ssh.ContextKeyConn).(gossh.Conn)
+	if !ok {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(k.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.Context().Done():
+				return
+			case <-ticker.C:
+			}
+
+			if err := k.probe(s.Context(), conn); err != nil {
+				log.Warn("ssh keepalive failed, closing session", "error", err, "raddr", s.RemoteAddr())
+				conn.Close()
+				return
+			}
+		}
+	}()
+}
+
+// probe sends a global request the client must reply to. OpenSSH replies to
+// unknown requests with a failure which still proves the client is alive.
+func (k keepalive) probe(ctx context.Context, conn gossh.Conn) error {
+	reply := make(chan error, 1)
+	go func() {
+		_, _, err := conn.SendRequest("keepalive@openssh.com", true, nil)
+		reply <- err
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-reply:
+		return err
+	case <-time.After(k.timeout):
+		return errKeepaliveTimeout
+	}
+}
+
+// websocket extends the read deadline of conn whenever a pong is received.
+// The deadline expiring fails the pending read in gotty which closes the
+// session. A connection of a sleeping laptop doesn't fail however, pings are
+// buffered without an error, so a connection that hasn't answered a ping
+// within timeout is reaped: it is closed and reap is called to end its
+// program.
+func (k keepalive) websocket(ctx context.Context, conn *websocket.Conn, reap func(error)) {
+	if !k.enabled() {
+		return
+	}
+
+	var lastPong atomic.Int64
+	lastPong.Store(time.Now().UnixNano())
+	deadline := func() time.Time { return time.Now().Add(k.interval + k.timeout) }
+	conn.SetReadDeadline(deadline())
+	conn.SetPongHandler(func(string) error {
+		lastPong.Store(time.Now().UnixNano())
+		return conn.SetReadDeadline(deadline())
+	})
+
+	go func() {
+		ticker := time.NewTicker(k.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(k.timeout))
+			if err == nil && time.Since(time.Unix(0, lastPong.Load())) > k.interval+k.timeout {
+				err = ErrHeartbeatMissed
+			}
+			if err != nil {
+				log.Warn("websocket keepalive failed, closing session", "error", err, "raddr", conn.RemoteAddr())
+				conn.Close()
+				if reap != nil {
+					reap(err)
+				}
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,48 @@
+package tstea
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeepaliveReapsWebsocket(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reaped := make(chan error, 1)
+	k := keepalive{interval: 10 * time.Millisecond, timeout: 20 * time.Millisecond}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		k.websocket(ctx, conn, func(err error) { reaped <- err })
+		// gotty reads the connection, which handles the pongs
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	// a client that doesn't read never answers the pings, like a sleeping
+	// laptop
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	select {
+	case err := <-reaped:
+		require.ErrorIs(t, err, ErrHeartbeatMissed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the session wasn't reaped")
+	}
+}
@@ -0,0 +1,218 @@
+package tstea
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"sync"
+)
+
+var (
+	ErrServerFull      = errors.New("server full, try again later")
+	ErrTooManySessions = errors.New("too many sessions for your user, close one and try again")
+	ErrSessionReplaced = errors.New("session closed, your user opened too many sessions")
+)
+
+// LimitPolicy decides what happens to a new session of an identity that
+// already holds its maximum number of sessions.
+type LimitPolicy int
+
+const (
+	// RejectNew refuses the new session with ErrTooManySessions.
+	RejectNew LimitPolicy = iota
+	// CloseOldest ends the oldest session of the identity with
+	// ErrSessionReplaced to make room for the new one.
+	CloseOldest
+)
+
+// SessionLimiter caps the number of concurrent sessions in total and per
+// identity. A zero limit disables that check.
+type SessionLimiter struct {
+	mu sync.Mutex
+
+	max, maxPerIdentity int
+	policy              LimitPolicy
+
+	total       int
+	perIdentity map[string][]*limitedSession
+
+	refuse error
+}
+
+// limitedSession is a session holding a slot of the limiter
+type limitedSession struct {
+	// end closes the session, it is nil if the session can't be closed by
+	// the limiter
+	end func(error)
+}
+
+func NewSessionLimiter(max, maxPerIdentity int) *SessionLimiter {
+	return &SessionLimiter{
+		max:            max,
+		maxPerIdentity: maxPerIdentity,
+		perIdentity:    make(map[string][]*limitedSession),
+	}
+}
+
+// Acquire reserves a session for identity. The returned release func must be
+// called once the session has ended. With the CloseOldest policy end is
+// called to close the session when a newer session of identity needs its
+// slot, it may be nil if the session can't be closed.
+func (l *SessionLimiter) Acquire(identity string, end func(error)) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	l.mu.Lock()
+
+	if l.refuse != nil {
+		l.mu.Unlock()
+		return nil, l.refuse
+	}
+
+	var oldest *limitedSession
+	if l.maxPerIdentity > 0 && len(l.perIdentity[identity]) >= l.maxPerIdentity {
+		if l.policy == CloseOldest {
+			oldest = l.oldest(identity)
+		}
+		if oldest == nil {
+			l.mu.Unlock()
+			return nil, ErrTooManySessions
+		}
+	}
+	total := l.total
+	if oldest != nil {
+		total--
+	}
+	if l.max > 0 && total >= l.max {
+		l.mu.Unlock()
+		return nil, ErrServerFull
+	}
+
+	if oldest != nil {
+		l.remove(identity, oldest)
+	}
+	s := &limitedSession{end: end}
+	l.total++
+	l.perIdentity[identity] = append(l.perIdentity[identity], s)
+	l.mu.Unlock()
+
+	if oldest != nil {
+		// the slot was handed over already, the release of the old session
+		// won't free it again
+		oldest.end(ErrSessionReplaced)
+	}
+
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.remove(identity, s)
+	}, nil
+}
+
+// oldest returns the oldest session of identity that can be closed.
+func (l *SessionLimiter) oldest(identity string) *limitedSession {
+	for _, s := range l.perIdentity[identity] {
+		if s.end != nil {
+			return s
+		}
+	}
+	return nil
+}
+
+// remove frees the slot of s, if it still holds one.
+func (l *SessionLimiter) remove(identity string, s *limitedSession) {
+	sessions := l.perIdentity[identity]
+	i := slices.Index(sessions, s)
+	if i < 0 {
+		return
+	}
+	l.total--
+	sessions = slices.Delete(sessions, i, i+1)
+	if len(sessions) == 0 {
+		delete(l.perIdentity, identity)
+	} else {
+		l.perIdentity[identity] = sessions
+	}
+}
+
+// SetLimits changes the limits, e.g. after the configuration was reloaded.
+// Sessions over the new limits are not ended.
+func (l *SessionLimiter) SetLimits(max, maxPerIdentity int) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.max, l.maxPerIdentity = max, maxPerIdentity
+}
+
+// SetPolicy changes what happens to new sessions of an identity that is at
+// its limit, from the next Acquire on.
+func (l *SessionLimiter) SetPolicy(p LimitPolicy) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.policy = p
+}
+
+// Refuse rejects every new session with err, e.g. while the server is
+// draining for maintenance. Existing sessions are unaffected.
+func (l *SessionLimiter) Refuse(err error) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refuse = err
+}
+
+// Resume accepts new sessions again if they are refused with err, so a
+// refusal for another reason, like maintenance, is kept.
+func (l *SessionLimiter) Resume(err error) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.refuse == err {
+		l.refuse = nil
+	}
+}
+
+// Active returns the total number of sessions and the number of sessions
+// held by identity.
+func (l *SessionLimiter) Active(identity string) (total, forIdentity int) {
+	if l == nil {
+		return 0, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.total, len(l.perIdentity[identity])
+}
+
+// messageSlave is a server.Slave that writes a single message to the
+// browser and then closes. It is used to explain why a session was refused.
+type messageSlave struct {
+	msg []byte
+}
+
+func newMessageSlave(msg string) *messageSlave {
+	return &messageSlave{msg: []byte(msg + "\r\n")}
+}
+
+func (s *messageSlave) Read(p []byte) (int, error) {
+	if len(s.msg) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, s.msg)
+	s.msg = s.msg[n:]
+	return n, nil
+}
+
+func (s *messageSlave) Write(p []byte) (int, error)            { return len(p), nil }
+func (s *messageSlave) Close() error                           { return nil }
+func (s *messageSlave) WindowTitleVariables() map[string]any   { return map[string]any{} }
+func (s *messageSlave) ResizeTerminal(width, height int) error { return nil }
@@ -0,0 +1,172 @@
+package tstea
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionLimiter(t *testing.T) {
+	type step struct {
+		acquire string
+		release int // index of the step whose session is released
+		limits  []int
+		refuse  error
+		resume  error
+
+		err                error
+		total, forIdentity int
+	}
+	const none = -1
+
+	tests := []struct {
+		name       string
+		max, perId int
+		steps      []step
+	}{{
+		name: "total limit",
+		max:  2,
+		steps: []step{
+			{acquire: "a", release: none, total: 1, forIdentity: 1},
+			{acquire: "b", release: none, total: 2, forIdentity: 1},
+			{acquire: "c", release: none, err: ErrServerFull, total: 2},
+			{release: 0, total: 1},
+			{acquire: "c", release: none, total: 2, forIdentity: 1},
+		},
+	}, {
+		name:  "per identity limit",
+		perId: 1,
+		steps: []step{
+			{acquire: "a", release: none, total: 1, forIdentity: 1},
+			{acquire: "a", release: none, err: ErrTooManySessions, total: 1, forIdentity: 1},
+			{acquire: "b", release: none, total: 2, forIdentity: 1},
+		},
+	}, {
+		name:  "double release",
+		max:   2,
+		perId: 2,
+		steps: []step{
+			{acquire: "a", release: none, total: 1, forIdentity: 1},
+			{acquire: "a", release: none, total: 2, forIdentity: 2},
+			{release: 0, total: 1, forIdentity: 1},
+			{release: 0, total: 1, forIdentity: 1},
+			{acquire: "a", release: none, total: 2, forIdentity: 2},
+		},
+	}, {
+		name: "set limits",
+		max:  1,
+		steps: []step{
+			{acquire: "a", release: none, total: 1, forIdentity: 1},
+			{acquire: "b", release: none, err: ErrServerFull, total: 1},
+			{limits: []int{0, 1}, release: none, total: 1},
+			{acquire: "b", release: none, total: 2, forIdentity: 1},
+			{acquire: "b", release: none, err: ErrTooManySessions, total: 2, forIdentity: 1},
+		},
+	}, {
+		name: "refuse",
+		steps: []step{
+			{acquire: "a", release: none, total: 1, forIdentity: 1},
+			{refuse: ErrMaintenance, release: none, total: 1},
+			{acquire: "b", release: none, err: ErrMaintenance, total: 1},
+			{release: 0, total: 0},
+			{resume: ErrServerFull, release: none},
+			{acquire: "b", release: none, err: ErrMaintenance},
+			{resume: ErrMaintenance, release: none},
+			{acquire: "b", release: none, total: 1, forIdentity: 1},
+		},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewSessionLimiter(tt.max, tt.perId)
+			releases := make([]func(), len(tt.steps))
+			for i, s := range tt.steps {
+				identity := s.acquire
+				switch {
+				case s.acquire != "":
+					release, err := l.Acquire(s.acquire, nil)
+					require.True(t, errors.Is(err, s.err), "step %d: %v", i, err)
+					releases[i] = release
+				case s.limits != nil:
+					l.SetLimits(s.limits[0], s.limits[1])
+				case s.refuse != nil:
+					l.Refuse(s.refuse)
+				case s.resume != nil:
+					l.Resume(s.resume)
+				}
+				if s.release != none {
+					releases[s.release]()
+					identity = tt.steps[s.release].acquire
+				}
+
+				total, forIdentity := l.Active(identity)
+				require.Equal(t, s.total, total, "step %d total", i)
+				if identity != "" {
+					require.Equal(t, s.forIdentity, forIdentity, "step %d for identity", i)
+				}
+			}
+		})
+	}
+}
+
+func TestSessionLimiterNil(t *testing.T) {
+	var l *SessionLimiter
+	release, err := l.Acquire("a", nil)
+	require.NoError(t, err)
+	release()
+	l.SetLimits(1, 1)
+	l.SetPolicy(CloseOldest)
+	l.Refuse(ErrMaintenance)
+	l.Resume(ErrMaintenance)
+	total, forIdentity := l.Active("a")
+	require.Zero(t, total)
+	require.Zero(t, forIdentity)
+}
+
+func TestSessionLimiterCloseOldest(t *testing.T) {
+	l := NewSessionLimiter(3, 2)
+	l.SetPolicy(CloseOldest)
+
+	var ended []string
+	end := func(name string) func(error) {
+		return func(err error) {
+			require.ErrorIs(t, err, ErrSessionReplaced)
+			ended = append(ended, name)
+		}
+	}
+
+	release1, err := l.Acquire("a", end("a1"))
+	require.NoError(t, err)
+	_, err = l.Acquire("a", end("a2"))
+	require.NoError(t, err)
+	_, err = l.Acquire("a", end("a3"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"a1"}, ended)
+
+	total, forIdentity := l.Active("a")
+	require.Equal(t, 2, total)
+	require.Equal(t, 2, forIdentity)
+
+	// the replaced session releasing its slot doesn't free another one
+	release1()
+	total, _ = l.Active("a")
+	require.Equal(t, 2, total)
+
+	// replacing a session doesn't grow the total
+	_, err = l.Acquire("b", end("b1"))
+	require.NoError(t, err)
+	_, err = l.Acquire("a", end("a4"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"a1", "a2"}, ended)
+	_, err = l.Acquire("c", end("c1"))
+	require.ErrorIs(t, err, ErrServerFull)
+
+	// sessions that can't be closed are skipped
+	l = NewSessionLimiter(0, 1)
+	l.SetPolicy(CloseOldest)
+	_, err = l.Acquire("a", nil)
+	require.NoError(t, err)
+	_, err = l.Acquire("a", end("a2"))
+	require.ErrorIs(t, err, ErrTooManySessions)
+}
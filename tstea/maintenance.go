@@ -0,0 +1,82 @@
+package tstea
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/ghthor/webtea/mpty"
+)
+
+var ErrMaintenance = errors.New("server is going down for maintenance, try again later")
+
+// Maintenance schedules a graceful shutdown of the server at a fixed time.
+// Every client is warned ahead of time, new sessions are refused once
+// draining begins and the server is shut down at At.
+type Maintenance struct {
+	At     time.Time
+	Reason string
+
+	// Drain is how long before At new sessions are refused
+	Drain time.Duration
+
+	// Warnings are how long before At a mpty.MaintenanceMsg is broadcast,
+	// DefaultExpiryWarnings when empty.
+	Warnings []time.Duration
+
+	// Limiter must be the limiter given to the transports with
+	// WithSessionLimiter
+	Limiter *SessionLimiter
+
+	// Send is the input of the main mpty.Program
+	Send mpty.Input
+}
+
+// Run blocks until the maintenance window and then calls shutdown. It returns
+// early without calling shutdown if ctx is done first or At has already
+// passed, e.g. when a stale maintenance time is left in the configuration.
+func (m Maintenance) Run(ctx context.Context, shutdown context.CancelCauseFunc) error {
+	if m.At.IsZero() {
+		return nil
+	}
+	if !m.At.After(time.Now()) {
+		log.Warn("maintenance time has passed, ignoring it", "at", m.At)
+		return nil
+	}
+	warnings := m.Warnings
+	if len(warnings) == 0 {
+		warnings = DefaultExpiryWarnings
+	}
+
+	warn := warningTimes(m.At, warnings)
+	drain := m.At.Add(-m.Drain)
+	for len(warn) > 0 || !drain.IsZero() {
+		if !drain.IsZero() && (len(warn) == 0 || drain.Before(warn[0])) {
+			if !sleepUntil(ctx, drain) {
+				return ctx.Err()
+			}
+			log.Info("maintenance, refusing new sessions", "at", m.At)
+			m.Limiter.Refuse(ErrMaintenance)
+			drain = time.Time{}
+			continue
+		}
+
+		if !sleepUntil(ctx, warn[0]) {
+			return ctx.Err()
+		}
+		warn = warn[1:]
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case m.Send <- mpty.MaintenanceMsg{At: m.At, Reason: m.Reason}:
+		}
+	}
+
+	if !sleepUntil(ctx, m.At) {
+		return ctx.Err()
+	}
+	log.Info("maintenance, shutting down", "reason", m.Reason)
+	shutdown(nil)
+	return nil
+}
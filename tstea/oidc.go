@@ -0,0 +1,362 @@
+package tstea
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/ghthor/webtea/mpty"
+	"golang.org/x/oauth2"
+	"tailscale.com/client/tailscale/apitype"
+	"tailscale.com/tailcfg"
+)
+
+// DefaultOIDCSessionTTL is how long a login is valid for when the
+// OIDCConfig doesn't set one.
+const DefaultOIDCSessionTTL = 12 * time.Hour
+
+const (
+	oidcSessionCookie = "webtea_session"
+	oidcStateCookie   = "webtea_oidc_state"
+	oidcStateTTL      = 10 * time.Minute
+)
+
+// OIDCConfig is the OpenID Connect client the web terminal logs in with.
+type OIDCConfig struct {
+	// Issuer is the provider url the discovery document is served under
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is where the provider returns the browser to, its path is
+	// handled by the Middleware, e.g. https://chat.example.com/oidc/callback
+	RedirectURL string
+	// Scopes default to openid, profile and email
+	Scopes []string
+
+	// SessionKey signs the session cookies. A random key is used when it's
+	// empty, so logins don't survive a restart.
+	SessionKey []byte
+	SessionTTL time.Duration
+}
+
+// OIDC logs browsers in with an OpenID Connect provider before the web
+// terminal is served, for deployments outside of a tailnet. It's the
+// Identity of the web terminals it guards: the profile of the login is
+// resolved by the address of the request the websocket was upgraded from.
+type OIDC struct {
+	oauth    *oauth2.Config
+	userinfo string
+	issuer   *url.URL
+	callback string
+	secure   bool
+
+	key []byte
+	ttl time.Duration
+
+	mu    sync.Mutex
+	conns map[string]*oidcConn
+}
+
+type oidcConn struct {
+	who  *apitype.WhoIsResponse
+	reqs int
+}
+
+// oidcSession is the login stored in the session cookie
+type oidcSession struct {
+	Login   string `json:"l"`
+	Name    string `json:"n,omitempty"`
+	Picture string `json:"p,omitempty"`
+	Exp     int64  `json:"e"`
+}
+
+// oidcState is the login in progress stored in the state cookie
+type oidcState struct {
+	State    string `json:"s"`
+	Verifier string `json:"v"`
+	Return   string `json:"r"`
+	Exp      int64  `json:"e"`
+}
+
+// NewOIDC reads the discovery document of the issuer.
+func NewOIDC(ctx context.Context, cfg OIDCConfig) (*OIDC, error) {
+	issuer, err := url.Parse(cfg.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid oidc issuer: %w", err)
+	}
+	redirect, err := url.Parse(cfg.RedirectURL)
+	if err != nil || redirect.Path == "" {
+		return nil, fmt.Errorf("invalid oidc redirect url: %q", cfg.RedirectURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(cfg.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc discovery: %s", resp.Status)
+	}
+	var doc struct {
+		Issuer   string `json:"issuer"`
+		Auth     string `json:"authorization_endpoint"`
+		Token    string `json:"token_endpoint"`
+		UserInfo string `json:"userinfo_endpoint"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != strings.TrimSuffix(cfg.Issuer, "/") {
+		return nil, fmt.Errorf("oidc discovery: issuer %q doesn't match %q", doc.Issuer, cfg.Issuer)
+	}
+	if doc.UserInfo == "" {
+		return nil, errors.New("oidc discovery: provider has no userinfo endpoint")
+	}
+
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "profile", "email"}
+	}
+	key := cfg.SessionKey
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+	ttl := cfg.SessionTTL
+	if ttl <= 0 {
+		ttl = DefaultOIDCSessionTTL
+	}
+
+	return &OIDC{
+		oauth: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			Endpoint:     oauth2.Endpoint{AuthURL: doc.Auth, TokenURL: doc.Token},
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       scopes,
+		},
+		userinfo: doc.UserInfo,
+		issuer:   issuer,
+		callback: redirect.Path,
+		secure:   redirect.Scheme == "https",
+
+		key: key,
+		ttl: ttl,
+
+		conns: make(map[string]*oidcConn),
+	}, nil
+}
+
+// Middleware serves the login callback and only lets requests with a login
+// through, for use with webtea.WithMiddleware. Browsers navigating to a page
+// are sent to the provider to log in, everything else is refused.
+func (o *OIDC) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == o.callback {
+			o.serveCallback(w, r)
+			return
+		}
+
+		var s oidcSession
+		if c, err := r.Cookie(oidcSessionCookie); err == nil && o.open(c.Value, &s) && time.Now().Unix() < s.Exp {
+			release := o.track(r.RemoteAddr, &apitype.WhoIsResponse{
+				UserProfile: &tailcfg.UserProfile{
+					LoginName:     s.Login,
+					DisplayName:   s.Name,
+					ProfilePicURL: s.Picture,
+				},
+			})
+			defer release()
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Method != http.MethodGet || r.Header.Get("Upgrade") != "" {
+			http.Error(w, "login required", http.StatusUnauthorized)
+			return
+		}
+		o.login(w, r)
+	})
+}
+
+// Resolve returns the login of the request from remoteAddr.
+func (o *OIDC) Resolve(ctx context.Context, remoteAddr string, sess mpty.Session) (*apitype.WhoIsResponse, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if c, ok := o.conns[remoteAddr]; ok {
+		return c.who, nil
+	}
+	return nil, fmt.Errorf("no oidc login for %s", remoteAddr)
+}
+
+var _ Identity = &OIDC{}
+
+// track makes who the identity of remoteAddr until release is called. The
+// websocket relay holds the upgrade request open while the terminal runs.
+func (o *OIDC) track(remoteAddr string, who *apitype.WhoIsResponse) (release func()) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	c, ok := o.conns[remoteAddr]
+	if !ok {
+		c = &oidcConn{}
+		o.conns[remoteAddr] = c
+	}
+	c.who = who
+	c.reqs++
+	return func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		if c.reqs--; c.reqs == 0 {
+			delete(o.conns, remoteAddr)
+		}
+	}
+}
+
+func (o *OIDC) login(w http.ResponseWriter, r *http.Request) {
+	st := oidcState{
+		State:    rand.Text(),
+		Verifier: oauth2.GenerateVerifier(),
+		Return:   r.URL.RequestURI(),
+		Exp:      time.Now().Add(oidcStateTTL).Unix(),
+	}
+	http.SetCookie(w, o.cookie(oidcStateCookie, o.seal(st), oidcStateTTL))
+	http.Redirect(w, r, o.oauth.AuthCodeURL(st.State, oauth2.S256ChallengeOption(st.Verifier)), http.StatusFound)
+}
+
+func (o *OIDC) serveCallback(w http.ResponseWriter, r *http.Request) {
+	var st oidcState
+	c, err := r.Cookie(oidcStateCookie)
+	if err != nil || !o.open(c.Value, &st) || time.Now().Unix() >= st.Exp || r.FormValue("state") != st.State {
+		http.Error(w, "invalid login state", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, o.cookie(oidcStateCookie, "", -1))
+	if e := r.FormValue("error"); e != "" {
+		http.Error(w, "login failed: "+e, http.StatusForbidden)
+		return
+	}
+
+	s, err := o.exchange(r.Context(), r.FormValue("code"), st.Verifier)
+	if err != nil {
+		log.Warn("oidc login", "error", err, "raddr", r.RemoteAddr)
+		http.Error(w, "login failed", http.StatusForbidden)
+		return
+	}
+	log.Info("oidc login", "login", s.Login, "raddr", r.RemoteAddr)
+	http.SetCookie(w, o.cookie(oidcSessionCookie, o.seal(s), o.ttl))
+
+	ret := st.Return
+	if !strings.HasPrefix(ret, "/") || strings.HasPrefix(ret, "//") {
+		ret = "/"
+	}
+	http.Redirect(w, r, ret, http.StatusFound)
+}
+
+// exchange trades the code for a token and reads the profile from the
+// userinfo endpoint. The token comes straight from the provider over TLS, so
+// its signature doesn't need to be checked.
+func (o *OIDC) exchange(ctx context.Context, code, verifier string) (oidcSession, error) {
+	tok, err := o.oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return oidcSession{}, fmt.Errorf("token exchange: %w", err)
+	}
+	resp, err := o.oauth.Client(ctx, tok).Get(o.userinfo)
+	if err != nil {
+		return oidcSession{}, fmt.Errorf("userinfo: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return oidcSession{}, fmt.Errorf("userinfo: %s", resp.Status)
+	}
+
+	var info oidcUserInfo
+	if err = json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return oidcSession{}, fmt.Errorf("userinfo: %w", err)
+	}
+	if info.Sub == "" {
+		return oidcSession{}, errors.New("userinfo: missing sub")
+	}
+	return oidcSession{
+		Login:   info.login(o.issuer.Host),
+		Name:    info.Name,
+		Picture: info.Picture,
+		Exp:     time.Now().Add(o.ttl).Unix(),
+	}, nil
+}
+
+type oidcUserInfo struct {
+	Sub               string `json:"sub"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username"`
+	Name              string `json:"name"`
+	Picture           string `json:"picture"`
+}
+
+// login is the login name used for ClientIds and roles: the verified email,
+// otherwise the sub scoped to the issuer so it can't collide with the logins
+// of other providers. preferred_username isn't stable or unique, so it's
+// only shown as the name.
+func (u oidcUserInfo) login(issuerHost string) string {
+	if u.Email != "" && u.EmailVerified {
+		return u.Email
+	}
+	return u.Sub + "@" + issuerHost
+}
+
+func (o *OIDC) cookie(name, value string, ttl time.Duration) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   int(ttl.Seconds()),
+		Secure:   o.secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// seal encodes v and signs it with the session key.
+func (o *OIDC) seal(v any) string {
+	b, _ := json.Marshal(v)
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(o.sign(payload))
+}
+
+// open decodes a value from seal into v if its signature is valid.
+func (o *OIDC) open(sealed string, v any) bool {
+	payload, sig, ok := strings.Cut(sealed, ".")
+	if !ok {
+		return false
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, o.sign(payload)) {
+		return false
+	}
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(b, v) == nil
+}
+
+func (o *OIDC) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, o.key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
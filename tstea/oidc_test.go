@@ -0,0 +1,71 @@
+package tstea
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestOIDCMiddleware(t *testing.T) {
+	o := &OIDC{
+		oauth:    &oauth2.Config{Endpoint: oauth2.Endpoint{AuthURL: "https://idp.example.com/auth"}},
+		callback: "/oidc/callback",
+		key:      []byte("key"),
+		ttl:      time.Hour,
+		conns:    make(map[string]*oidcConn),
+	}
+
+	var login string
+	h := o.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		who, err := o.Resolve(r.Context(), r.RemoteAddr, nil)
+		require.NoError(t, err)
+		login = who.UserProfile.LoginName
+	}))
+
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	// pages redirect to the provider, websockets are refused
+	w := serve(httptest.NewRequest(http.MethodGet, "/chat/", nil))
+	require.Equal(t, http.StatusFound, w.Code)
+	require.Contains(t, w.Header().Get("Location"), "https://idp.example.com/auth")
+	require.Contains(t, w.Header().Get("Location"), "code_challenge=")
+
+	r := httptest.NewRequest(http.MethodGet, "/chat/ws", nil)
+	r.Header.Set("Upgrade", "websocket")
+	require.Equal(t, http.StatusUnauthorized, serve(r).Code)
+
+	session := oidcSession{Login: "alice@example.com", Exp: time.Now().Add(time.Hour).Unix()}
+	r = httptest.NewRequest(http.MethodGet, "/chat/ws", nil)
+	r.AddCookie(&http.Cookie{Name: oidcSessionCookie, Value: o.seal(session)})
+	require.Equal(t, http.StatusOK, serve(r).Code)
+	require.Equal(t, "alice@example.com", login)
+
+	// the login is forgotten once the request is done
+	_, err := o.Resolve(context.Background(), r.RemoteAddr, nil)
+	require.Error(t, err)
+
+	tampered := o.seal(session) + "x"
+	r = httptest.NewRequest(http.MethodGet, "/chat/", nil)
+	r.AddCookie(&http.Cookie{Name: oidcSessionCookie, Value: tampered})
+	require.Equal(t, http.StatusFound, serve(r).Code)
+
+	session.Exp = time.Now().Add(-time.Minute).Unix()
+	r = httptest.NewRequest(http.MethodGet, "/chat/", nil)
+	r.AddCookie(&http.Cookie{Name: oidcSessionCookie, Value: o.seal(session)})
+	require.Equal(t, http.StatusFound, serve(r).Code)
+}
+
+func TestOIDCLogin(t *testing.T) {
+	require.Equal(t, "alice@example.com", oidcUserInfo{Sub: "1", Email: "alice@example.com", EmailVerified: true}.login("idp.example.com"))
+	require.Equal(t, "1@idp.example.com", oidcUserInfo{Sub: "1", Email: "alice@example.com"}.login("idp.example.com"))
+	require.Equal(t, "1@idp.example.com", oidcUserInfo{Sub: "1", PreferredUsername: "alice"}.login("idp.example.com"))
+}
@@ -0,0 +1,63 @@
+package tstea
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ghthor/webtea/mpty"
+)
+
+// Option configures the session handling shared by WishMiddleware and
+// TeaTYFactory.
+type Option func(*config)
+
+type config struct {
+	limiter     *SessionLimiter
+	keepalive   keepalive
+	sessionCap  sessionCap
+	idleTimeout time.Duration
+	authorize   Authorizer
+	castDir     string
+	termProbe   time.Duration
+	reattach    time.Duration
+	progOpts    []mpty.ProgramOptions
+	audit       []AuditSink
+	confirmKeys *AuthorizedKeys
+}
+
+func newConfig(opts []Option) config {
+	c := config{}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// WithSessionLimiter rejects sessions once the limiter is full. The same
+// limiter should be given to both the ssh and http transports so the limits
+// apply across them.
+func WithSessionLimiter(l *SessionLimiter) Option {
+	return func(c *config) {
+		c.limiter = l
+	}
+}
+
+// WithProgramOptions adds the options returned by fn to the program of every
+// ssh and web terminal session, e.g. to enable the mouse for web terminals.
+// Use mpty.WithProgramOptions for options of every client of a Program.
+func WithProgramOptions(fn mpty.ProgramOptions) Option {
+	return func(c *config) {
+		c.progOpts = append(c.progOpts, fn)
+	}
+}
+
+// programOptions appends the options of the session of m to opts.
+func (c config) programOptions(m mpty.ClientModel, opts []tea.ProgramOption) []tea.ProgramOption {
+	if m == nil {
+		return opts
+	}
+	for _, fn := range c.progOpts {
+		opts = append(opts, fn(m)...)
+	}
+	return opts
+}
@@ -0,0 +1,96 @@
+package tstea
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// termPipe connects a web terminal to its program in memory, so web sessions
+// don't need a kernel pty. What the browser types is written to the pipe and
+// read by the program as its input, the screen the program writes is read
+// from the pipe and sent to the browser.
+//
+// Without a tty there is no line discipline, the keys reach the program as
+// they were typed like they would in raw mode, and the window size is sent to
+// the program as a tea.WindowSizeMsg.
+//
+// The output can be attached to a new terminal, e.g. when a browser tab
+// reconnects, while the program keeps running.
+type termPipe struct {
+	// in is the input of the program, out its output
+	inR *io.PipeReader
+	inW *io.PipeWriter
+
+	mu sync.Mutex
+	// outW is nil while no terminal is attached, the output is discarded
+	outW *io.PipeWriter
+}
+
+func newTermPipe() *termPipe {
+	p := &termPipe{}
+	p.inR, p.inW = io.Pipe()
+	return p
+}
+
+// attach returns a new output of the program, the previous output sees
+// io.EOF.
+func (p *termPipe) attach() io.Reader {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.outW != nil {
+		p.outW.Close()
+	}
+	r, w := io.Pipe()
+	p.outW = w
+	return r
+}
+
+// detach closes the output, the program keeps running and its output is
+// discarded until the next attach.
+func (p *termPipe) detach() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.outW != nil {
+		p.outW.Close()
+		p.outW = nil
+	}
+}
+
+// Write sends b to the input of the program.
+func (p *termPipe) Write(b []byte) (int, error) {
+	return p.inW.Write(b)
+}
+
+// Close ends the pipe, the program and the terminal see io.EOF.
+func (p *termPipe) Close() error {
+	p.detach()
+	return p.inW.Close()
+}
+
+// program is the end of the pipe given to the program with tea.WithInput and
+// tea.WithOutput.
+func (p *termPipe) program() io.ReadWriter {
+	return programEnd{p}
+}
+
+type programEnd struct {
+	p *termPipe
+}
+
+func (e programEnd) Read(b []byte) (int, error) { return e.p.inR.Read(b) }
+
+func (e programEnd) Write(b []byte) (int, error) {
+	e.p.mu.Lock()
+	w := e.p.outW
+	e.p.mu.Unlock()
+	if w == nil {
+		return len(b), nil
+	}
+	n, err := w.Write(b)
+	if errors.Is(err, io.ErrClosedPipe) {
+		// detached, or attached elsewhere, while writing
+		return len(b), nil
+	}
+	return n, err
+}
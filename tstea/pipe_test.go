@@ -0,0 +1,61 @@
+package tstea
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTermPipe(t *testing.T) {
+	p := newTermPipe()
+	prog := p.program()
+	out := p.attach()
+
+	go p.Write([]byte("q"))
+	b := make([]byte, 8)
+	n, err := prog.Read(b)
+	require.NoError(t, err)
+	require.Equal(t, "q", string(b[:n]))
+
+	go prog.Write([]byte("\x1b[2J"))
+	n, err = out.Read(b)
+	require.NoError(t, err)
+	require.Equal(t, "\x1b[2J", string(b[:n]))
+
+	require.NoError(t, p.Close())
+	_, err = prog.Read(b)
+	require.ErrorIs(t, err, io.EOF)
+	_, err = out.Read(b)
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestTermPipeReattach(t *testing.T) {
+	p := newTermPipe()
+	prog := p.program()
+	first := p.attach()
+
+	p.detach()
+	_, err := first.Read(make([]byte, 8))
+	require.ErrorIs(t, err, io.EOF)
+
+	// the output is discarded while detached
+	n, err := prog.Write([]byte("lost"))
+	require.NoError(t, err)
+	require.Equal(t, 4, n)
+
+	second := p.attach()
+	go prog.Write([]byte("frame"))
+	b := make([]byte, 8)
+	n, err = second.Read(b)
+	require.NoError(t, err)
+	require.Equal(t, "frame", string(b[:n]))
+
+	third := p.attach()
+	_, err = second.Read(b)
+	require.ErrorIs(t, err, io.EOF)
+	go prog.Write([]byte("next"))
+	n, err = third.Read(b)
+	require.NoError(t, err)
+	require.Equal(t, "next", string(b[:n]))
+}
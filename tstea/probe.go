@@ -0,0 +1,184 @@
+package tstea
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/ghthor/webtea/mpty"
+)
+
+// WithTermProbe asks the terminal of every ssh session for its capabilities
+// before the program starts, waiting at most timeout for the answers. The
+// caps are stored with mpty.SetTermCaps for the ClientInfoModel. Keys typed
+// while probing are passed on to the program.
+//
+// Only sessions with an emulated pty, the wish default, are probed.
+func WithTermProbe(timeout time.Duration) Option {
+	return func(c *config) {
+		c.termProbe = timeout
+	}
+}
+
+// probeSSH probes the terminal of s, when enabled, and returns the input the
+// program must read from instead of s.
+func (c config) probeSSH(s ssh.Session) io.Reader {
+	if c.termProbe <= 0 || !s.EmulatedPty() {
+		return nil
+	}
+	in := newProbedInput(s.Context(), s)
+	mpty.SetTermCaps(s.Context(), probeTerm(s.Context(), s, in, c.termProbe, s.Environ()))
+	return in
+}
+
+// termQueries ask for the kitty keyboard flags, the synchronized output mode,
+// the color kept after setting a truecolor foreground and the secondary
+// attributes. The primary attributes are asked for last, every terminal
+// answers them so their answer ends the probe.
+const termQueries = "\x1b[?u" +
+	"\x1b[?2026$p" +
+	"\x1b[38;2;1;2;3m\x1bP$qm\x1b\\\x1b[m" +
+	"\x1b[>c" +
+	"\x1b[c"
+
+var (
+	da1Reply    = regexp.MustCompile(`\x1b\[\?([0-9;]*)c`)
+	da2Reply    = regexp.MustCompile(`\x1b\[>([0-9;]*)c`)
+	kittyReply  = regexp.MustCompile(`\x1b\[\?([0-9]*)u`)
+	decrpmReply = regexp.MustCompile(`\x1b\[\?2026;([0-9])\$y`)
+	sgrReply    = regexp.MustCompile(`\x1bP([01])\$r([^\x1b]*)\x1b\\`)
+)
+
+// probeTerm writes the queries to w and reads the answers from in until the
+// primary attributes arrive or timeout.
+func probeTerm(ctx context.Context, w io.Writer, in *probedInput, timeout time.Duration, env []string) mpty.TermCaps {
+	var caps mpty.TermCaps
+	switch sshEnv(env, "COLORTERM") {
+	case "truecolor", "24bit":
+		caps.TrueColor = true
+	}
+
+	if _, err := io.WriteString(w, termQueries); err != nil {
+		return caps
+	}
+
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	for !caps.Probed {
+		select {
+		case <-ctx.Done():
+			return caps
+		case <-t.C:
+			return caps
+		case chunk, ok := <-in.chunks:
+			if !ok {
+				return caps
+			}
+			in.buf = parseTermCaps(append(in.buf, chunk...), &caps)
+		}
+	}
+	return caps
+}
+
+// parseTermCaps sets caps from the answers in b and returns the rest of b,
+// i.e. what was typed and any answer that hasn't fully arrived.
+func parseTermCaps(b []byte, caps *mpty.TermCaps) []byte {
+	b = replies(b, kittyReply, func(m [][]byte) {
+		caps.KittyKeyboard = true
+		caps.KittyFlags, _ = strconv.Atoi(string(m[1]))
+	})
+	b = replies(b, decrpmReply, func(m [][]byte) {
+		// 0 is an unknown mode, 1-4 are set or reset
+		caps.SyncOutput = m[1][0] != '0'
+	})
+	b = replies(b, sgrReply, func(m [][]byte) {
+		sgr := string(m[2])
+		if m[1][0] == '1' && (strings.Contains(sgr, "1:2:3") || strings.Contains(sgr, "1;2;3")) {
+			caps.TrueColor = true
+		}
+	})
+	b = replies(b, da2Reply, func(m [][]byte) {
+		attrs := params(m[1])
+		if len(attrs) > 1 {
+			caps.Terminal, caps.Version = attrs[0], attrs[1]
+		}
+	})
+	b = replies(b, da1Reply, func(m [][]byte) {
+		caps.Probed = true
+		caps.Attributes = params(m[1])
+	})
+	return b
+}
+
+// replies cuts the matches of re out of b, calling fn with the submatches of
+// each.
+func replies(b []byte, re *regexp.Regexp, fn func([][]byte)) []byte {
+	return re.ReplaceAllFunc(b, func(match []byte) []byte {
+		fn(re.FindSubmatch(match))
+		return nil
+	})
+}
+
+func params(b []byte) []int {
+	var ps []int
+	for p := range strings.SplitSeq(string(b), ";") {
+		if n, err := strconv.Atoi(p); err == nil {
+			ps = append(ps, n)
+		}
+	}
+	return ps
+}
+
+// probedInput is the input of a session that was probed. It is read by a
+// single goroutine, so the probe can give up waiting for answers without
+// leaving a read behind that would swallow the next keys.
+type probedInput struct {
+	chunks <-chan []byte
+	// err ended the reads, it is set before chunks is closed
+	err error
+	// buf is what was read but not yet consumed
+	buf []byte
+}
+
+func newProbedInput(ctx context.Context, r io.Reader) *probedInput {
+	chunks := make(chan []byte, 16)
+	in := &probedInput{chunks: chunks}
+	go func() {
+		defer close(chunks)
+		b := make([]byte, 1024)
+		for {
+			n, err := r.Read(b)
+			if n > 0 {
+				select {
+				case <-ctx.Done():
+					in.err = ctx.Err()
+					return
+				case chunks <- bytes.Clone(b[:n]):
+				}
+			}
+			if err != nil {
+				in.err = err
+				return
+			}
+		}
+	}()
+	return in
+}
+
+func (in *probedInput) Read(p []byte) (int, error) {
+	if len(in.buf) == 0 {
+		chunk, ok := <-in.chunks
+		if !ok {
+			return 0, in.err
+		}
+		in.buf = chunk
+	}
+	n := copy(p, in.buf)
+	in.buf = in.buf[n:]
+	return n, nil
+}
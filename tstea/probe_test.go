@@ -0,0 +1,59 @@
+package tstea
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ghthor/webtea/mpty"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTermCaps(t *testing.T) {
+	var caps mpty.TermCaps
+	rest := parseTermCaps([]byte("\x1b[?1u"+"q"+"\x1b[?2026;2$y"+"\x1bP1$r0;38:2::1:2:3m\x1b\\"+"\x1b[>41;390;0c"+"\x1b[?62;4"), &caps)
+	require.Equal(t, "q\x1b[?62;4", string(rest), "typed keys and partial answers are kept")
+	require.False(t, caps.Probed)
+	require.True(t, caps.KittyKeyboard)
+	require.Equal(t, 1, caps.KittyFlags)
+	require.True(t, caps.SyncOutput)
+	require.True(t, caps.TrueColor)
+	require.Equal(t, 41, caps.Terminal)
+	require.Equal(t, 390, caps.Version)
+
+	rest = parseTermCaps(append(rest, "c"...), &caps)
+	require.Equal(t, "q", string(rest))
+	require.True(t, caps.Probed)
+	require.Equal(t, []int{62, 4}, caps.Attributes)
+
+	caps = mpty.TermCaps{}
+	rest = parseTermCaps([]byte("\x1b[?2026;0$y\x1bP0$r\x1b\\\x1b[?1;2c"), &caps)
+	require.Empty(t, rest)
+	require.False(t, caps.SyncOutput)
+	require.False(t, caps.TrueColor)
+	require.False(t, caps.KittyKeyboard)
+	require.True(t, caps.Probed)
+}
+
+func TestProbeTerm(t *testing.T) {
+	r, w := io.Pipe()
+	in := newProbedInput(t.Context(), r)
+	var queries bytes.Buffer
+
+	go w.Write([]byte("\x1b[?62;22cab"))
+	caps := probeTerm(t.Context(), &queries, in, time.Second, []string{"COLORTERM=truecolor"})
+	require.Equal(t, termQueries, queries.String())
+	require.True(t, caps.Probed)
+	require.True(t, caps.TrueColor)
+
+	go w.Write([]byte("c"))
+	typed, err := io.ReadAll(io.LimitReader(in, 3))
+	require.NoError(t, err)
+	require.Equal(t, "abc", string(typed))
+
+	// terminals that don't answer are given up on
+	caps = probeTerm(t.Context(), io.Discard, in, 10*time.Millisecond, nil)
+	require.False(t, caps.Probed)
+	w.Close()
+}
@@ -4,66 +4,203 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
-	"time"
+	"io"
+	"net/url"
+	"slices"
+	"strconv"
 
-	"github.com/cenkalti/backoff/v5"
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/log"
 	"github.com/charmbracelet/ssh"
 	"github.com/charmbracelet/wish"
 	"github.com/charmbracelet/wish/bubbletea"
-	"github.com/creack/pty"
 	"github.com/ghthor/gotty/v2/server"
 	"github.com/ghthor/webtea/ctxhelp"
 	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/tracing"
 	"github.com/gorilla/websocket"
 	"github.com/muesli/termenv"
 	"golang.org/x/sync/errgroup"
-	"tailscale.com/client/local"
 	"tailscale.com/client/tailscale/apitype"
 )
 
 type NewSshModel func(context.Context, ssh.Pty, mpty.Session, *apitype.WhoIsResponse) mpty.ClientModel
-type NewHttpModel func(context.Context, mpty.Session, *apitype.WhoIsResponse) mpty.ClientModel
 
-func WishMiddleware(ctx context.Context, lc *local.Client, newModel NewSshModel, newProg mpty.NewClientProgram) wish.Middleware {
+// NewHttpModel returns the model of a web terminal.
+type NewHttpModel func(context.Context, WebTerminal, mpty.Session, *apitype.WhoIsResponse) mpty.ClientModel
+
+// WebTerminal is what the browser told about its terminal when it connected.
+type WebTerminal struct {
+	// Window is zero unless the browser passed mpty.ColsParam and
+	// mpty.RowsParam
+	Window tea.WindowSizeMsg
+	// Params are the other arguments of the terminal, e.g. ?room=dev to deep
+	// link into a room. The arguments used by tstea, like the password, are
+	// left out.
+	Params url.Values
+}
+
+// webParams are the arguments of the web terminal used by tstea
+var webParams = []string{
+	mpty.ResumeParam,
+	mpty.PasswordParam,
+	mpty.TabParam,
+	mpty.ColsParam,
+	mpty.RowsParam,
+}
+
+// newWebTerminal returns the terminal described by the arguments of the web
+// terminal.
+func newWebTerminal(params map[string][]string) WebTerminal {
+	t := WebTerminal{Window: webWindowSize(params), Params: url.Values{}}
+	for k, v := range params {
+		if !slices.Contains(webParams, k) {
+			t.Params[k] = slices.Clone(v)
+		}
+	}
+	return t
+}
+
+// WishMiddleware starts a program for every ssh session, once id has
+// resolved who the session belongs to.
+func WishMiddleware(ctx context.Context, id Identity, newModel NewSshModel, newProg mpty.NewClientProgram, opts ...Option) wish.Middleware {
+	cfg := newConfig(opts)
 	teaHandler := func(s ssh.Session) *tea.Program {
-		who, err := lc.WhoIs(s.Context(), s.RemoteAddr().String())
+		accepted := acceptedAt(s.Context())
+		spanCtx, span := tracing.StartAt(s.Context(), "ssh.session.start", accepted,
+			tracing.String("raddr", s.RemoteAddr().String()),
+		)
+		defer span.End()
+		if !accepted.IsZero() {
+			_, hs := tracing.StartAt(spanCtx, "ssh.handshake", accepted)
+			hs.End()
+		}
+
+		_, whoSpan := tracing.Start(spanCtx, "identity.resolve")
+		who, err := id.Resolve(s.Context(), s.RemoteAddr().String(), s)
+		whoSpan.End()
+		if err != nil {
+			span.RecordError(err)
+			wish.Fatalln(s, "identity error: ", err)
+			return nil
+		}
+
+		if err := cfg.authorizeWho(s.Context(), who); err != nil {
+			span.RecordError(err)
+			wish.Fatalln(s, err)
+			return nil
+		}
+
+		release, err := cfg.limiter.Acquire(who.UserProfile.LoginName, func(err error) {
+			wish.Fatalln(s, err)
+		})
 		if err != nil {
-			wish.Fatalln(s, "tailscale WhoIs error: ", err)
+			span.RecordError(err)
+			wish.Fatalln(s, err)
 			return nil
 		}
+		go func() {
+			<-s.Context().Done()
+			release()
+		}()
+		cfg.keepalive.ssh(s)
 
 		pty, _, active := s.Pty()
 		if !active {
 			wish.Fatalln(s, "no active terminal, skipping")
 			return nil
 		}
+		input := cfg.probeSSH(s)
 		var (
 			progCtx, _ = ctxhelp.Join(ctx, s.Context())
 			m          = newModel(progCtx, pty, s, who)
 		)
-		return newProg(progCtx, m, bubbletea.MakeOptions(s)...)
+		progCtx = withResume(progCtx, sshEnv(s.Environ(), mpty.ResumeEnv))
+		progCtx = mpty.WithPassword(progCtx, sshEnv(s.Environ(), mpty.PasswordEnv))
+		idle := newIdleTracker(cfg.idleTimeout)
+		_, progSpan := tracing.Start(spanCtx, "mpty.program.new")
+		progOpts, out := bubbletea.MakeOptions(s), sshOutput(s)
+		if input != nil {
+			progOpts = append(progOpts, tea.WithInput(input))
+		}
+		if sync := syncOutput(m); sync != nil {
+			out = sync.Writer(out)
+			progOpts = append(progOpts, tea.WithOutput(out))
+		}
+		audit := cfg.auditStart(m, who, "ssh", s.RemoteAddr())
+		if audit != nil {
+			if input == nil {
+				// the terminal of the session is both its input and output
+				input = sshOutput(s).(io.Reader)
+			}
+			input, out = audit.input(input), audit.output(out)
+			progOpts = append(progOpts, tea.WithInput(input), tea.WithOutput(out))
+			context.AfterFunc(s.Context(), audit.end)
+		}
+		cfg.setConfirmer(m, agentConfirmer{s, who.UserProfile.LoginName, cfg.confirmKeys, audit})
+		progOpts = cfg.recordOptions(progCtx, m, pty.Window.Width, pty.Window.Height, pty.Term, out, progOpts)
+		progOpts = cfg.programOptions(m, progOpts)
+		prog := newProg(progCtx, m, idle.options(progOpts)...)
+		progSpan.End()
+		if prog != nil {
+			cfg.sessionCap.enforce(progCtx, prog)
+			idle.enforce(progCtx, prog)
+		}
+		return prog
+	}
+	// the colors of each terminal are detected by the ClientInfoModel, wish
+	// must not force a profile on them
+	return bubbletea.MiddlewareWithProgramHandler(teaHandler, termenv.Ascii)
+}
+
+// syncOutput returns the synchronized output of the terminal of m, if it
+// supports it.
+func syncOutput(m mpty.ClientModel) *mpty.SyncOutput {
+	if s, ok := m.(interface{ SyncOutput() *mpty.SyncOutput }); ok {
+		return s.SyncOutput()
+	}
+	return nil
+}
+
+// maxWebSize bounds the size a browser may claim for its terminal
+const maxWebSize = 1000
+
+// webWindowSize returns the size of the terminal in the web terminal
+// arguments, or a zero size if it wasn't passed or isn't valid.
+func webWindowSize(params map[string][]string) tea.WindowSizeMsg {
+	cols, err := strconv.Atoi(param(params, mpty.ColsParam))
+	if err != nil || cols <= 0 || cols > maxWebSize {
+		return tea.WindowSizeMsg{}
 	}
-	return bubbletea.MiddlewareWithProgramHandler(teaHandler, termenv.ANSI256)
+	rows, err := strconv.Atoi(param(params, mpty.RowsParam))
+	if err != nil || rows <= 0 || rows > maxWebSize {
+		return tea.WindowSizeMsg{}
+	}
+	return tea.WindowSizeMsg{Width: cols, Height: rows}
 }
 
 type TeaTYFactory struct {
 	ctx context.Context
-	ts  *local.Client
+	id  Identity
 
 	newModel NewHttpModel
 	newProg  mpty.NewClientProgram
+
+	detached detachedSessions
+
+	config
 }
 
-func NewTeaTYFactory(ctx context.Context, ts *local.Client, newModel NewHttpModel, newProg mpty.NewClientProgram) *TeaTYFactory {
+// NewTeaTYFactory starts a program for every web terminal, once id has
+// resolved who the websocket belongs to.
+func NewTeaTYFactory(ctx context.Context, id Identity, newModel NewHttpModel, newProg mpty.NewClientProgram, opts ...Option) *TeaTYFactory {
 	return &TeaTYFactory{
 		ctx: ctx,
-		ts:  ts,
+		id:  id,
 
 		newModel: newModel,
 		newProg:  newProg,
+
+		config: newConfig(opts),
 	}
 }
 
@@ -74,120 +211,155 @@ func (*TeaTYFactory) Name() string { return "TeaTYFactory" }
 func (f *TeaTYFactory) New(ctx context.Context, params map[string][]string, conn *websocket.Conn) (server.Slave, error) {
 	ctx, cancel := ctxhelp.Join(f.ctx, ctx)
 
-	who, err := f.ts.WhoIs(ctx, conn.RemoteAddr().String())
+	// gotty creates the slave once the websocket is upgraded and has sent
+	// its init message
+	spanCtx, span := tracing.Start(ctx, "websocket.session.start",
+		tracing.String("raddr", conn.RemoteAddr().String()),
+	)
+	defer span.End()
+
+	_, whoSpan := tracing.Start(spanCtx, "identity.resolve")
+	who, err := f.id.Resolve(ctx, conn.RemoteAddr().String(), conn)
+	whoSpan.End()
 	if err != nil {
+		span.RecordError(err)
 		return nil, err
 	}
 
-	p, t, err := pty.Open()
+	if err := f.authorizeWho(ctx, who); err != nil {
+		span.RecordError(err)
+		cancel(err)
+		return newMessageSlave(err.Error()), nil
+	}
+
+	var key string
+	if f.reattach > 0 {
+		key = sessionKey(who.UserProfile.LoginName, param(params, mpty.TabParam))
+	}
+	if s := f.detached.take(key); s != nil {
+		if t := s.attach(conn); t != nil {
+			_, reattachSpan := tracing.Start(spanCtx, "websocket.reattach")
+			reattachSpan.End()
+			f.keepalive.websocket(ctx, conn, s.reaper(conn))
+			return t, nil
+		}
+	}
+
+	// a program that can be reattached outlives the websocket
+	progCtx, progCancel := ctx, cancel
+	if key != "" {
+		progCtx, progCancel = context.WithCancelCause(f.ctx)
+	}
+
+	release, err := f.limiter.Acquire(who.UserProfile.LoginName, progCancel)
 	if err != nil {
-		return nil, fmt.Errorf("failed to pty.Open(): %w", err)
+		progCancel(err)
+		cancel(err)
+		return newMessageSlave(err.Error()), nil
+	}
+	s := &webSession{
+		key:      key,
+		grace:    f.reattach,
+		detached: &f.detached,
+		pipe:     newTermPipe(),
+		grp:      &errgroup.Group{},
+		cancel:   progCancel,
 	}
+	t := s.attach(conn)
+	in := s.pipe.program()
 
-	m := f.newModel(ctx, conn, who)
-	prog := f.newProg(ctx, m,
-		tea.WithInput(t),
-		tea.WithOutput(t),
-	)
+	term := newWebTerminal(params)
+	m := f.newModel(progCtx, term, conn, who)
+	idle := newIdleTracker(f.idleTimeout)
+	_, progSpan := tracing.Start(spanCtx, "mpty.program.new")
+	resumeCtx := withResume(progCtx, param(params, mpty.ResumeParam))
+	resumeCtx = mpty.WithPassword(resumeCtx, param(params, mpty.PasswordParam))
+	castW, castH := castWidth, castHeight
+	if term.Window.Width > 0 {
+		castW, castH = term.Window.Width, term.Window.Height
+	}
+	audit := f.auditStart(m, who, "web", conn.RemoteAddr())
+	f.setConfirmer(m, refuseConfirm(audit))
+	out := audit.output(in)
+	progOpts := f.recordOptions(progCtx, m, castW, castH, "xterm-256color", out, []tea.ProgramOption{
+		tea.WithInput(audit.input(in)),
+		tea.WithOutput(out),
+	})
+	progOpts = f.programOptions(m, progOpts)
+	prog := f.newProg(resumeCtx, m, idle.options(progOpts)...)
+	progSpan.End()
 	if prog == nil {
-		t.Close()
-		p.Close()
-		conn.Close()
+		audit.end()
+		release()
+		s.end()
+		progCancel(nil)
 		return nil, fmt.Errorf("program initialization failed: %w", ctx.Err())
 	}
+	s.prog, s.model = prog, m
+
+	f.keepalive.websocket(ctx, conn, s.reaper(conn))
+	f.sessionCap.enforce(progCtx, prog)
+	idle.enforce(progCtx, prog)
 
-	grp, grpCtx := errgroup.WithContext(ctx)
-	grp.Go(func() error {
+	s.grp.Go(func() error {
 		defer func() {
-			t.Close()
-			p.Close()
-			conn.Close()
+			s.end()
+			f.detached.remove(s)
+			release()
+			audit.end()
 		}()
 
 		finalModel, err := prog.Run()
 		if err != nil && !errors.Is(err, context.Canceled) {
-			cancel(err)
+			progCancel(err)
 			return err
 		}
 
 		if clientModel, ok := finalModel.(mpty.ClientModel); ok && clientModel.Err() != nil {
-			cancel(clientModel.Err())
+			progCancel(clientModel.Err())
 		}
 
-		cancel(nil)
+		progCancel(nil)
 		return nil
 	})
 
-	return &TeaTYProgram{
-		ctx: grpCtx,
-		pty: p,
-		tty: t,
-
-		grp:     grp,
-		program: prog,
-	}, nil
+	return t, nil
 }
 
+// TeaTYProgram is a websocket attached to the program of a web terminal.
 type TeaTYProgram struct {
-	ctx context.Context
-
-	pty, tty *os.File
-
-	grp     *errgroup.Group
-	program *tea.Program
+	session *webSession
+	conn    *websocket.Conn
+	out     io.Reader
 }
 
 var _ server.Slave = &TeaTYProgram{}
 
 func (t *TeaTYProgram) Read(p []byte) (n int, err error) {
-	return t.pty.Read(p)
+	return t.out.Read(p)
 }
 
 func (t *TeaTYProgram) Write(p []byte) (n int, err error) {
-	return t.pty.Write(p)
+	return t.session.pipe.Write(p)
 }
 
 func (t *TeaTYProgram) Close() error {
-	t.tty.Close()
-	t.pty.Close()
-	t.program.Quit()
-	return t.grp.Wait()
+	return t.session.close(t)
 }
 
+// WindowTitleVariables returns the title variables of the model of the
+// session, if it is a mpty.TitleVarser.
 func (t *TeaTYProgram) WindowTitleVariables() map[string]any {
+	if v, ok := t.session.model.(mpty.TitleVarser); ok {
+		return v.TitleVars()
+	}
 	return map[string]any{}
 }
 
+// ResizeTerminal sends the size of the browser terminal to the program, there
+// is no pty to resize.
 func (t *TeaTYProgram) ResizeTerminal(width, height int) error {
-	exp := &backoff.ExponentialBackOff{
-		InitialInterval:     10 * time.Millisecond,
-		RandomizationFactor: 0.0,
-		Multiplier:          1.1,
-		MaxInterval:         500 * time.Millisecond,
-	}
-	_, err := backoff.Retry(t.ctx, func() (struct{}, error) {
-		return struct{}{}, errors.Join(
-			pty.Setsize(t.pty, &pty.Winsize{
-				Cols: uint16(width),
-				Rows: uint16(height),
-			}),
-			pty.Setsize(t.tty, &pty.Winsize{
-				Cols: uint16(width),
-				Rows: uint16(height),
-			}),
-		)
-	},
-		backoff.WithBackOff(exp),
-		backoff.WithMaxElapsedTime(2*time.Second),
-		backoff.WithNotify(func(err error, d time.Duration) {
-			log.Warn("pty resize", "error", err, "retrying", d)
-		}),
-	)
-	if err != nil {
-		log.Warn("pty resize retry exhausted", "error", err)
-		return err
-	}
-	t.program.Send(tea.WindowSizeMsg{
+	t.session.prog.Send(tea.WindowSizeMsg{
 		Width:  width,
 		Height: height,
 	})
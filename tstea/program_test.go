@@ -0,0 +1,69 @@
+package tstea
+
+import (
+	"net/url"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ghthor/webtea/mpty"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebWindowSize(t *testing.T) {
+	size := func(cols, rows string) map[string][]string {
+		return map[string][]string{"cols": {cols}, "rows": {rows}}
+	}
+	require.Equal(t, tea.WindowSizeMsg{Width: 120, Height: 35}, webWindowSize(size("120", "35")))
+	require.Zero(t, webWindowSize(nil))
+	require.Zero(t, webWindowSize(size("120", "")))
+	require.Zero(t, webWindowSize(size("0", "35")))
+	require.Zero(t, webWindowSize(size("120", "-1")))
+	require.Zero(t, webWindowSize(size("100000", "35")))
+}
+
+func TestNewWebTerminal(t *testing.T) {
+	term := newWebTerminal(map[string][]string{
+		"room":     {"dev"},
+		"theme":    {"dark"},
+		"password": {"secret"},
+		"cols":     {"100"},
+		"rows":     {"30"},
+	})
+	require.Equal(t, tea.WindowSizeMsg{Width: 100, Height: 30}, term.Window)
+	require.Equal(t, url.Values{"room": {"dev"}, "theme": {"dark"}}, term.Params)
+}
+
+func TestProgramOptions(t *testing.T) {
+	var seen []mpty.ClientModel
+	cfg := newConfig([]Option{
+		WithProgramOptions(func(m mpty.ClientModel) []tea.ProgramOption {
+			seen = append(seen, m)
+			return []tea.ProgramOption{tea.WithMouseCellMotion()}
+		}),
+		WithProgramOptions(func(mpty.ClientModel) []tea.ProgramOption { return nil }),
+	})
+
+	m := newRPCClient(&mpty.ClientInfoModel{})
+	opts := cfg.programOptions(m, []tea.ProgramOption{tea.WithAltScreen()})
+	require.Len(t, opts, 2)
+	require.Equal(t, []mpty.ClientModel{m}, seen)
+
+	require.Empty(t, cfg.programOptions(nil, nil))
+}
+
+type titledClient struct {
+	*rpcClient
+}
+
+func (titledClient) TitleVars() map[string]any {
+	return map[string]any{"room": "dev"}
+}
+
+func TestWindowTitleVariables(t *testing.T) {
+	m := newRPCClient(&mpty.ClientInfoModel{})
+	p := &TeaTYProgram{session: &webSession{model: m}}
+	require.Empty(t, p.WindowTitleVariables())
+
+	p.session.model = titledClient{m}
+	require.Equal(t, map[string]any{"room": "dev"}, p.WindowTitleVariables())
+}
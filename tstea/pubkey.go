@@ -0,0 +1,128 @@
+package tstea
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+	"github.com/ghthor/webtea/mpty"
+	gossh "golang.org/x/crypto/ssh"
+	"tailscale.com/client/tailscale/apitype"
+	"tailscale.com/tailcfg"
+)
+
+// AuthorizedKeys are the public keys allowed to log in over ssh from outside
+// of the tailnet, in the format of ~/.ssh/authorized_keys. The comment of a
+// key is the login of its profile, keys without one log in as their
+// fingerprint.
+type AuthorizedKeys struct {
+	logins map[string]string
+}
+
+// LoadAuthorizedKeys reads the authorized keys file at path.
+func LoadAuthorizedKeys(path string) (*AuthorizedKeys, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := ParseAuthorizedKeys(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return keys, nil
+}
+
+// ParseAuthorizedKeys parses the keys of an authorized keys file, options
+// are ignored.
+func ParseAuthorizedKeys(b []byte) (*AuthorizedKeys, error) {
+	keys := &AuthorizedKeys{logins: make(map[string]string)}
+	line := 0
+	for l := range bytes.Lines(b) {
+		line++
+		l = bytes.TrimSpace(l)
+		if len(l) == 0 || l[0] == '#' {
+			continue
+		}
+		key, comment, _, _, err := gossh.ParseAuthorizedKey(l)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		fp := gossh.FingerprintSHA256(key)
+		if comment == "" {
+			comment = fp
+		}
+		keys.logins[fp] = comment
+	}
+	return keys, nil
+}
+
+// Profile returns the profile of key if it is authorized.
+func (k *AuthorizedKeys) Profile(key ssh.PublicKey) (*apitype.WhoIsResponse, bool) {
+	if k == nil || key == nil {
+		return nil, false
+	}
+	login, ok := k.logins[gossh.FingerprintSHA256(key)]
+	if !ok {
+		return nil, false
+	}
+	return &apitype.WhoIsResponse{
+		UserProfile: &tailcfg.UserProfile{
+			LoginName:   login,
+			DisplayName: login,
+		},
+	}, true
+}
+
+// authorizes reports if key is authorized for login.
+func (k *AuthorizedKeys) authorizes(key gossh.PublicKey, login string) bool {
+	if k == nil || key == nil {
+		return false
+	}
+	l, ok := k.logins[gossh.FingerprintSHA256(key)]
+	return ok && l == login
+}
+
+// PublicKeyFallback resolves peers with id, and peers id doesn't know by the
+// public key they authenticated the ssh session with. Use it together with
+// WithPublicKeyFallback so only authorized keys get that far.
+func PublicKeyFallback(id Identity, keys *AuthorizedKeys) Identity {
+	return IdentityFunc(func(ctx context.Context, remoteAddr string, sess mpty.Session) (*apitype.WhoIsResponse, error) {
+		who, err := id.Resolve(ctx, remoteAddr, sess)
+		if err == nil {
+			return who, nil
+		}
+		if s, ok := sess.(interface{ PublicKey() ssh.PublicKey }); ok {
+			if who, ok := keys.Profile(s.PublicKey()); ok {
+				return who, nil
+			}
+		}
+		return nil, err
+	})
+}
+
+// WithPublicKeyFallback requires peers id can't resolve, e.g. because they
+// aren't on the tailnet, to authenticate with one of keys. Peers id resolves
+// are let in with any key, or none at all.
+func WithPublicKeyFallback(id Identity, keys *AuthorizedKeys) ssh.Option {
+	resolves := func(ctx ssh.Context) bool {
+		_, err := id.Resolve(ctx, ctx.RemoteAddr().String(), nil)
+		return err == nil
+	}
+	return func(s *ssh.Server) error {
+		return errors.Join(
+			wish.WithPublicKeyAuth(func(ctx ssh.Context, key ssh.PublicKey) bool {
+				if _, ok := keys.Profile(key); ok {
+					return true
+				}
+				return resolves(ctx)
+			})(s),
+			wish.WithKeyboardInteractiveAuth(func(ctx ssh.Context, _ gossh.KeyboardInteractiveChallenge) bool {
+				return resolves(ctx)
+			})(s),
+		)
+	}
+}
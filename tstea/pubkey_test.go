@@ -0,0 +1,46 @@
+package tstea
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestAuthorizedKeys(t *testing.T) {
+	newKey := func() gossh.PublicKey {
+		pub, _, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		key, err := gossh.NewPublicKey(pub)
+		require.NoError(t, err)
+		return key
+	}
+	alice, anon, unknown := newKey(), newKey(), newKey()
+
+	line := func(key gossh.PublicKey) string {
+		return strings.TrimSpace(string(gossh.MarshalAuthorizedKey(key)))
+	}
+	file := "# operators\n" +
+		line(alice) + " alice\n" +
+		"\n" +
+		"no-pty " + line(anon) + "\n"
+	keys, err := ParseAuthorizedKeys([]byte(file))
+	require.NoError(t, err)
+
+	who, ok := keys.Profile(alice)
+	require.True(t, ok)
+	require.Equal(t, "alice", who.UserProfile.LoginName)
+
+	who, ok = keys.Profile(anon)
+	require.True(t, ok)
+	require.Equal(t, gossh.FingerprintSHA256(anon), who.UserProfile.LoginName)
+
+	_, ok = keys.Profile(unknown)
+	require.False(t, ok)
+
+	_, err = ParseAuthorizedKeys([]byte("ssh-ed25519 garbage\n"))
+	require.ErrorContains(t, err, "line 1")
+}
@@ -0,0 +1,193 @@
+package tstea
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ghthor/webtea/mpty"
+	"github.com/gorilla/websocket"
+	"golang.org/x/sync/errgroup"
+)
+
+// WithReattach keeps the program of a web terminal running for grace after
+// its websocket closes. A browser tab that reconnects within grace, e.g.
+// after a reload, is attached to the same program, and keeps its client and
+// scrollback, if it is the same login and passes the same mpty.TabParam. The
+// page sets the parameter with webtea.WebUI.Reattach.
+//
+// Programs of terminals without the parameter end with their websocket.
+func WithReattach(grace time.Duration) Option {
+	return func(c *config) {
+		c.reattach = grace
+	}
+}
+
+// ErrReattachExpired ends a detached program that wasn't reattached in time.
+var ErrReattachExpired = errors.New("web terminal was not reattached in time")
+
+// webSession is a program of a web terminal, it outlives the websockets
+// attached to it while it is detached.
+type webSession struct {
+	// key is the login and the tab of the terminal, it is empty when the
+	// session can't be reattached
+	key string
+	// grace is how long a closed session waits in detached to be reattached
+	grace    time.Duration
+	detached *detachedSessions
+
+	prog   *tea.Program
+	model  mpty.ClientModel
+	pipe   *termPipe
+	grp    *errgroup.Group
+	cancel context.CancelCauseFunc
+
+	mu sync.Mutex
+	// conn is the websocket attached to the session, nil while detached
+	conn  *websocket.Conn
+	timer *time.Timer
+	ended bool
+}
+
+// sessionKey returns the key a reattaching terminal must present, or "" if
+// the terminal didn't pass its tab.
+func sessionKey(login, tab string) string {
+	if tab == "" {
+		return ""
+	}
+	return login + " " + tab
+}
+
+// attach connects conn to the program, replacing any websocket already
+// attached, and redraws the screen. It returns nil if the program has ended,
+// or is ending because the grace period is over.
+func (s *webSession) attach(conn *websocket.Conn) *TeaTYProgram {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return nil
+	}
+	if s.timer != nil {
+		expired := !s.timer.Stop()
+		s.timer = nil
+		if expired {
+			return nil
+		}
+	}
+	if s.conn != nil && s.conn != conn {
+		s.conn.Close()
+	}
+	s.conn = conn
+	t := &TeaTYProgram{session: s, conn: conn, out: s.pipe.attach()}
+	if s.prog != nil {
+		go func() {
+			// leaving and entering the alt screen repaints all of it
+			s.prog.Send(tea.ExitAltScreen())
+			s.prog.Send(tea.EnterAltScreen())
+		}()
+	}
+	return t
+}
+
+// close detaches t from the session. The program is ended, unless it can be
+// reattached, in which case it ends after the grace period unless it's
+// reattached first.
+func (s *webSession) close(t *TeaTYProgram) error {
+	s.mu.Lock()
+	if s.conn != t.conn {
+		// another websocket has been attached since
+		s.mu.Unlock()
+		return nil
+	}
+	if s.key == "" || s.grace <= 0 || s.ended {
+		s.mu.Unlock()
+		s.pipe.Close()
+		s.prog.Quit()
+		return s.grp.Wait()
+	}
+	s.conn = nil
+	s.pipe.detach()
+	s.timer = time.AfterFunc(s.grace, func() {
+		s.mu.Lock()
+		expired := s.conn == nil
+		s.mu.Unlock()
+		if expired {
+			s.cancel(ErrReattachExpired)
+		}
+	})
+	s.mu.Unlock()
+	s.detached.put(s)
+	return nil
+}
+
+// reaper returns the func that ends the program when conn stops answering
+// heartbeats. The program isn't kept to be reattached, a laptop that wakes up
+// reconnects as a new session.
+func (s *webSession) reaper(conn *websocket.Conn) func(error) {
+	return func(err error) {
+		s.mu.Lock()
+		attached := s.conn == conn
+		s.mu.Unlock()
+		if !attached {
+			return
+		}
+		s.cancel(err)
+		s.pipe.Close()
+		s.prog.Quit()
+	}
+}
+
+// end is called once the program has ended, it closes the attached websocket.
+func (s *webSession) end() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ended = true
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.pipe.Close()
+}
+
+// detachedSessions are the sessions of a TeaTYFactory waiting to be
+// reattached.
+type detachedSessions struct {
+	mu sync.Mutex
+	m  map[string]*webSession
+}
+
+func (d *detachedSessions) put(s *webSession) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.m == nil {
+		d.m = make(map[string]*webSession)
+	}
+	// a duplicated tab replaces the older session, which ends with its grace
+	// period
+	d.m[s.key] = s
+}
+
+// take removes and returns the session waiting for key.
+func (d *detachedSessions) take(key string) *webSession {
+	if key == "" {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := d.m[key]
+	delete(d.m, key)
+	return s
+}
+
+// remove forgets s once its program has ended.
+func (d *detachedSessions) remove(s *webSession) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.m[s.key] == s {
+		delete(d.m, s.key)
+	}
+}
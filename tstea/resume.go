@@ -0,0 +1,43 @@
+package tstea
+
+import (
+	"context"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/ghthor/webtea/mpty"
+)
+
+// withResume resumes the program started with ctx after the messages token
+// says the client has already applied. Invalid tokens start the client
+// from the recent history like any other.
+func withResume(ctx context.Context, token string) context.Context {
+	if token == "" {
+		return ctx
+	}
+	seq, err := mpty.ParseResumeToken(token)
+	if err != nil {
+		log.Warn("resume", "error", err)
+		return ctx
+	}
+	return mpty.WithResume(ctx, seq)
+}
+
+// sshEnv returns the value of key in the environment of an ssh session, e.g.
+// the token passed with mpty.ResumeEnv.
+func sshEnv(env []string, key string) string {
+	for _, kv := range env {
+		if v, ok := strings.CutPrefix(kv, key+"="); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// param returns the first value of key in the web terminal arguments.
+func param(params map[string][]string, key string) string {
+	if v := params[key]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
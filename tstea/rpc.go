@@ -0,0 +1,393 @@
+package tstea
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/log"
+	"github.com/ghthor/webtea/logpolicy"
+	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/mpty/mptymsg"
+	"github.com/ghthor/webtea/mpty/remote"
+	"github.com/ghthor/webtea/roles"
+	"tailscale.com/client/tailscale/apitype"
+)
+
+const (
+	// rpcQueueLen is the most messages kept for a frontend between calls to
+	// Room.Next, the oldest are dropped and show up as a gap in the sequence
+	rpcQueueLen = 1000
+	// rpcMaxWait bounds how long Room.Next waits for messages
+	rpcMaxWait = remote.MaxWait
+)
+
+var (
+	ErrNotJoined     = errors.New("join the room first")
+	ErrAlreadyJoined = errors.New("already joined the room")
+	ErrLeft          = errors.New("left the room")
+)
+
+// NewRPCInfo returns the info of a frontend connected over rpc, typically
+// mpty.NewClientInfoModelFromRPC with the Access of who.
+type NewRPCInfo func(context.Context, mpty.Session, *apitype.WhoIsResponse) *mpty.ClientInfoModel
+
+// Accept validates a message sent by a frontend over rpc and returns what is
+// sent to the Program on its behalf. It must not trust the fields that say who
+// sent it, they are set from id.
+type Accept func(id mpty.ClientId, access roles.Access, msg mptymsg.Recordable) (tea.Msg, error)
+
+// RPCServer lets frontends without a terminal, e.g. a mobile app, take part in
+// the room. Every connection is identified by the remote address like the ssh
+// and web sessions, and served the Room service with the JSON-RPC codec of
+// net/rpc:
+//
+//	Room.Join  joins the room, with the password or resume token of the client
+//	Room.Next  waits for the messages broadcast since the last call
+//	Room.Send  sends a message, which Accept must allow
+//
+// Messages are encoded as mptymsg envelopes. A joined frontend is a session of
+// the Program, it is announced and can be kicked like any other.
+type RPCServer struct {
+	ctx context.Context
+	id  Identity
+
+	newInfo NewRPCInfo
+	newProg mpty.NewClientProgram
+	accept  Accept
+
+	config
+}
+
+func NewRPCServer(ctx context.Context, id Identity, newInfo NewRPCInfo, newProg mpty.NewClientProgram, accept Accept, opts ...Option) *RPCServer {
+	return &RPCServer{
+		ctx: ctx,
+		id:  id,
+
+		newInfo: newInfo,
+		newProg: newProg,
+		accept:  accept,
+
+		config: newConfig(opts),
+	}
+}
+
+// Serve serves the connections of l until it is closed.
+func (s *RPCServer) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *RPCServer) serveConn(conn net.Conn) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	room := &RPCRoom{server: s, ctx: ctx}
+
+	// refused connections are still served, so the frontend is told why on
+	// its first call
+	who, err := s.id.Resolve(ctx, conn.RemoteAddr().String(), conn)
+	if err == nil {
+		err = s.authorizeWho(ctx, who)
+	}
+	if err == nil {
+		var release func()
+		release, err = s.limiter.Acquire(who.UserProfile.LoginName, func(error) { cancel() })
+		if err == nil {
+			defer release()
+		}
+	}
+	if err != nil {
+		log.Info("rpc refused", "raddr", conn.RemoteAddr(), "error", err)
+		room.err = err
+	} else {
+		room.info = s.newInfo(ctx, conn, who)
+	}
+
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("Room", room); err != nil {
+		log.Error("rpc", "error", err)
+		return
+	}
+	srv.ServeCodec(jsonrpc.NewServerCodec(conn))
+}
+
+// The arguments and replies of the Room service, frontends written in Go call
+// it with remote.Conn.
+type (
+	RPCJoinArgs  = remote.JoinArgs
+	RPCJoinReply = remote.JoinReply
+
+	RPCNextArgs  = remote.NextArgs
+	RPCNextReply = remote.NextReply
+
+	RPCSendArgs  = remote.SendArgs
+	RPCSendReply = remote.SendReply
+)
+
+// RPCRoom is the Room service of a single connection, see RPCServer.
+type RPCRoom struct {
+	server *RPCServer
+	ctx    context.Context
+	// err is why the connection was refused
+	err  error
+	info *mpty.ClientInfoModel
+
+	mu     sync.Mutex
+	client *rpcClient
+}
+
+func (r *RPCRoom) Join(args RPCJoinArgs, reply *RPCJoinReply) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.client != nil {
+		return ErrAlreadyJoined
+	}
+
+	ctx, cancel := context.WithCancel(r.ctx)
+	c := newRPCClient(r.info)
+	progCtx := withResume(ctx, args.Resume)
+	progCtx = mpty.WithPassword(progCtx, args.Password)
+	prog := r.server.newProg(progCtx, c,
+		tea.WithInput(nil),
+		tea.WithOutput(io.Discard),
+		tea.WithoutRenderer(),
+	)
+	if prog == nil {
+		cancel()
+		return errors.Join(errors.New("program initialization failed"), ctx.Err())
+	}
+
+	audit := r.server.auditStart(c, r.info.Who, "rpc", r.info.RemoteAddr())
+	go func() {
+		defer cancel()
+		defer audit.end()
+		final, err := prog.Run()
+		if err == nil {
+			if m, ok := final.(interface{ Err() error }); ok {
+				err = m.Err()
+			}
+		}
+		c.left(err)
+	}()
+
+	select {
+	case <-c.joined:
+	case <-c.done:
+		cancel()
+		return c.err
+	}
+
+	r.client = c
+	reply.Id = string(r.info.Id())
+	return nil
+}
+
+func (r *RPCRoom) joined() (*rpcClient, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.client == nil {
+		return nil, ErrNotJoined
+	}
+	return r.client, nil
+}
+
+func (r *RPCRoom) Next(args RPCNextArgs, reply *RPCNextReply) error {
+	c, err := r.joined()
+	if err != nil {
+		return err
+	}
+	wait := rpcMaxWait
+	if args.Wait > 0 {
+		wait = min(args.Wait, rpcMaxWait)
+	}
+	reply.Msgs, reply.Resume, reply.Left = c.next(r.ctx, wait)
+	return nil
+}
+
+func (r *RPCRoom) Send(args RPCSendArgs, reply *RPCSendReply) error {
+	c, err := r.joined()
+	if err != nil {
+		return err
+	}
+	rec, err := mptymsg.JsonUnmarshal(args.Msg)
+	if err != nil {
+		return err
+	}
+	msg, err := r.server.accept(r.info.Id(), r.info.Access, rec)
+	if err != nil {
+		return err
+	}
+	return c.send(r.ctx, msg)
+}
+
+// rpcClient is the model of a frontend, it queues the recorded messages of
+// the broadcast until they are read with Room.Next.
+type rpcClient struct {
+	*mpty.ClientInfoModel
+
+	// joined is closed once the client is subscribed, done once the program
+	// has ended with err
+	joined, done chan struct{}
+	err          error
+	joinOnce     sync.Once
+
+	mu     sync.Mutex
+	input  mpty.Input
+	queue  []json.RawMessage
+	ready  chan struct{}
+	resume string
+}
+
+func newRPCClient(info *mpty.ClientInfoModel) *rpcClient {
+	return &rpcClient{
+		ClientInfoModel: info,
+		joined:          make(chan struct{}),
+		done:            make(chan struct{}),
+		ready:           make(chan struct{}),
+	}
+}
+
+var _ mpty.ClientModel = &rpcClient{}
+
+func (c *rpcClient) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	return c.UpdateClient(msg)
+}
+
+func (c *rpcClient) UpdateClient(msg tea.Msg) (mpty.ClientModel, tea.Cmd) {
+	c.ClientInfoModel.UpdateInfo(msg)
+
+	switch msg := msg.(type) {
+	case mpty.Input:
+		c.mu.Lock()
+		c.input = msg
+		c.mu.Unlock()
+		c.joinOnce.Do(func() { close(c.joined) })
+
+	case mpty.ResumeMsg:
+		c.mu.Lock()
+		c.resume = msg.Token
+		c.mu.Unlock()
+
+	case []mptymsg.Recordable:
+		for _, msg := range msg {
+			c.push(msg)
+		}
+
+	case []tea.Msg:
+		for _, msg := range msg {
+			switch msg := msg.(type) {
+			case mptymsg.Recordable:
+				c.push(msg)
+			case mpty.ResumeMsg:
+				c.UpdateClient(msg)
+			}
+		}
+	}
+	return c, nil
+}
+
+func (c *rpcClient) View() string { return "" }
+
+// Access is the role of the frontend, it is checked when joining the room.
+func (c *rpcClient) Access() roles.Access {
+	return c.ClientInfoModel.Access
+}
+
+func (c *rpcClient) Err() error { return nil }
+
+func (c *rpcClient) push(msg mptymsg.Recordable) {
+	b, err := mptymsg.JsonMarshal(msg)
+	if err != nil {
+		logpolicy.Warn("tstea.rpc_encode."+msg.TypeName(), "rpc encode", "type", msg.TypeName(), "error", err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.queue) >= rpcQueueLen {
+		c.queue = c.queue[1:]
+	}
+	c.queue = append(c.queue, b)
+	select {
+	case <-c.ready:
+	default:
+		close(c.ready)
+	}
+}
+
+// next waits up to wait for queued messages and takes them.
+func (c *rpcClient) next(ctx context.Context, wait time.Duration) ([]json.RawMessage, string, bool) {
+	t := time.NewTimer(wait)
+	defer t.Stop()
+
+	c.mu.Lock()
+	ready := c.ready
+	c.mu.Unlock()
+	left := false
+	select {
+	case <-ready:
+	case <-c.done:
+		left = true
+	case <-ctx.Done():
+		left = true
+	case <-t.C:
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	msgs := c.queue
+	c.queue = nil
+	if len(msgs) > 0 {
+		c.ready = make(chan struct{})
+	}
+	return msgs, c.resume, left && len(msgs) == 0
+}
+
+func (c *rpcClient) send(ctx context.Context, msg tea.Msg) error {
+	c.mu.Lock()
+	input := c.input
+	c.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return ErrLeft
+	default:
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.done:
+		return ErrLeft
+	case input <- msg:
+		return nil
+	}
+}
+
+func (c *rpcClient) left(err error) {
+	c.err = err
+	close(c.done)
+}
@@ -0,0 +1,69 @@
+package tstea
+
+import (
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ghthor/webtea/mpty"
+	"github.com/ghthor/webtea/mpty/mptymsg"
+	"github.com/stretchr/testify/require"
+	"tailscale.com/client/tailscale/apitype"
+	"tailscale.com/tailcfg"
+)
+
+type rpcTestMsg struct {
+	At  time.Time
+	Str string
+}
+
+func init() {
+	mptymsg.Register(rpcTestMsg{})
+}
+
+func (m rpcTestMsg) TypeName() string               { return "tstea.rpcTestMsg" }
+func (m rpcTestMsg) Ts() time.Time                  { return m.At }
+func (m rpcTestMsg) SetId(int64) mptymsg.Recordable { return m }
+
+func TestRPCClient(t *testing.T) {
+	who := &apitype.WhoIsResponse{UserProfile: &tailcfg.UserProfile{LoginName: "alice@example.com"}}
+	c := newRPCClient(mpty.NewClientInfoModelFromRPC(nil, who))
+	require.Equal(t, "rpc", c.Transport())
+
+	input := make(chan tea.Msg, 1)
+	c.UpdateClient(mpty.Input(input))
+	<-c.joined
+
+	c.UpdateClient([]mptymsg.Recordable{rpcTestMsg{Str: "recorded"}})
+	c.UpdateClient([]tea.Msg{
+		mpty.ClientConnectMsg("bob@example.com s2"),
+		rpcTestMsg{Str: "live"},
+		mpty.ResumeMsg{Token: "1"},
+	})
+
+	msgs, resume, left := c.next(t.Context(), time.Second)
+	require.False(t, left)
+	require.Equal(t, "1", resume)
+	require.Len(t, msgs, 2)
+	msg, err := mptymsg.JsonUnmarshal(msgs[1])
+	require.NoError(t, err)
+	require.Equal(t, "live", msg.(rpcTestMsg).Str)
+
+	msgs, _, left = c.next(t.Context(), time.Millisecond)
+	require.Empty(t, msgs)
+	require.False(t, left)
+
+	for range rpcQueueLen + 1 {
+		c.push(rpcTestMsg{Str: "flood"})
+	}
+	msgs, _, _ = c.next(t.Context(), time.Second)
+	require.Len(t, msgs, rpcQueueLen)
+
+	require.NoError(t, c.send(t.Context(), rpcTestMsg{Str: "sent"}))
+	require.Equal(t, rpcTestMsg{Str: "sent"}, <-input)
+
+	c.left(nil)
+	_, _, left = c.next(t.Context(), time.Second)
+	require.True(t, left)
+	require.ErrorIs(t, c.send(t.Context(), rpcTestMsg{}), ErrLeft)
+}
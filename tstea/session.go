@@ -0,0 +1,76 @@
+package tstea
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ghthor/webtea/mpty"
+)
+
+// DefaultExpiryWarnings are how long before a session or the server ends that
+// users are warned.
+var DefaultExpiryWarnings = []time.Duration{15 * time.Minute, 5 * time.Minute, time.Minute, 10 * time.Second}
+
+type sessionCap struct {
+	max      time.Duration
+	warnings []time.Duration
+}
+
+// WithMaxSessionDuration ends sessions once they have been connected for
+// max. The program is sent a mpty.SessionExpiringMsg at each of the warnings
+// before the session ends, DefaultExpiryWarnings when none are given.
+func WithMaxSessionDuration(max time.Duration, warnings ...time.Duration) Option {
+	if len(warnings) == 0 {
+		warnings = DefaultExpiryWarnings
+	}
+	return func(c *config) {
+		c.sessionCap = sessionCap{max, warnings}
+	}
+}
+
+func (c sessionCap) enforce(ctx context.Context, prog *tea.Program) {
+	if c.max <= 0 {
+		return
+	}
+
+	deadline := time.Now().Add(c.max)
+	go func() {
+		for _, at := range warningTimes(deadline, c.warnings) {
+			if !sleepUntil(ctx, at) {
+				return
+			}
+			prog.Send(mpty.SessionExpiringMsg{At: deadline})
+		}
+		if sleepUntil(ctx, deadline) {
+			prog.Quit()
+		}
+	}()
+}
+
+// warningTimes returns the times to warn at in order, skipping those that
+// have already passed.
+func warningTimes(deadline time.Time, warnings []time.Duration) []time.Time {
+	now := time.Now()
+	times := make([]time.Time, 0, len(warnings))
+	for _, w := range warnings {
+		if at := deadline.Add(-w); at.After(now) {
+			times = append(times, at)
+		}
+	}
+	slices.SortFunc(times, time.Time.Compare)
+	return times
+}
+
+// sleepUntil returns false if ctx is done before t
+func sleepUntil(ctx context.Context, t time.Time) bool {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
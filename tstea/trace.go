@@ -0,0 +1,34 @@
+package tstea
+
+import (
+	"net"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+)
+
+type acceptedKey struct{}
+
+// TraceHandshake records when each ssh connection is accepted so the session
+// spans, see the tracing package, include the handshake. Any ConnCallback
+// already set on the server is still called.
+func TraceHandshake() ssh.Option {
+	return func(srv *ssh.Server) error {
+		next := srv.ConnCallback
+		srv.ConnCallback = func(ctx ssh.Context, conn net.Conn) net.Conn {
+			ctx.SetValue(acceptedKey{}, time.Now())
+			if next != nil {
+				return next(ctx, conn)
+			}
+			return conn
+		}
+		return nil
+	}
+}
+
+// acceptedAt is when the connection of the session was accepted, or zero
+// without TraceHandshake.
+func acceptedAt(ctx ssh.Context) time.Time {
+	t, _ := ctx.Value(acceptedKey{}).(time.Time)
+	return t
+}
@@ -0,0 +1,36 @@
+package webtea
+
+import (
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webtea.sock")
+
+	l, err := ListenUnix(path, 0o660)
+	require.NoError(t, err)
+
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, fs.FileMode(0o660), fi.Mode().Perm())
+
+	_, err = ListenUnix(path, 0o660)
+	require.ErrorContains(t, err, "in use")
+	require.NoError(t, l.Close())
+
+	// leave a stale socket behind like a crashed process would
+	ul, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	require.NoError(t, err)
+	ul.SetUnlinkOnClose(false)
+	require.NoError(t, ul.Close())
+
+	l, err = ListenUnix(path, 0o600)
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+}
@@ -103,3 +103,19 @@ func (r *Buffer[T]) IterRecent(n int) func(yield func(T) bool) {
 		}
 	}
 }
+
+// Resize changes the capacity of the buffer to size, which must be positive,
+// keeping the most recent elements that fit.
+func (r *Buffer[T]) Resize(size int) {
+	if size == r.size {
+		return
+	}
+	data := make([]T, size)
+	n := copy(data, r.ReadRecent(min(size, r.Len())))
+	r.data, r.size, r.count, r.write = data, size, n, n%size
+}
+
+// Cap returns the number of elements the buffer can hold.
+func (r *Buffer[T]) Cap() int {
+	return r.size
+}
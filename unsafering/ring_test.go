@@ -31,3 +31,21 @@ func TestBuffer(t *testing.T) {
 	assert.False(t, ok)
 	require.Equal(t, 0, v)
 }
+
+func TestBufferResize(t *testing.T) {
+	r := New[int](5)
+	for i := range 7 {
+		r.Push(i)
+	}
+
+	r.Resize(3)
+	require.Equal(t, 3, r.Cap())
+	require.Equal(t, []int{4, 5, 6}, slices.Collect(r.Iter()))
+	r.Push(7)
+	require.Equal(t, []int{5, 6, 7}, slices.Collect(r.Iter()))
+
+	r.Resize(6)
+	require.Equal(t, []int{5, 6, 7}, slices.Collect(r.Iter()))
+	r.Push(8)
+	require.Equal(t, []int{5, 6, 7, 8}, slices.Collect(r.Iter()))
+}
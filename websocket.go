@@ -0,0 +1,222 @@
+package webtea
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/gorilla/websocket"
+)
+
+// WebSocketOptions tunes the websocket between the browser and webtea.
+type WebSocketOptions struct {
+	// Compression negotiates permessage-deflate with the browser
+	Compression bool
+
+	// CompressionLevel is the flate level used when compression was
+	// negotiated, zero uses the default level
+	CompressionLevel int
+
+	// PingInterval is how often the browser is pinged, zero disables pings
+	PingInterval time.Duration
+
+	// PongTimeout is how long the browser has to answer a ping before the
+	// connection is closed, zero waits another PingInterval
+	PongTimeout time.Duration
+
+	// WriteTimeout bounds every frame written to the browser so a stalled
+	// link closes the connection instead of buffering output, zero disables
+	// the deadline
+	WriteTimeout time.Duration
+}
+
+// WithWebSocket terminates the terminal websocket in webtea, applying o to
+// the connection with the browser, and relays the frames to gotty. The
+// websocket upgrader of gotty can't be configured so this is how compression
+// and deadlines are controlled.
+//
+// Pings sent by a server.Factory, like the tstea keepalive, are forwarded to
+// the browser and its pongs back, so the keepalive reaps the sessions of
+// browsers that stopped answering. PingInterval only closes the connection
+// with the browser, which ends the session once gotty notices.
+func WithWebSocket(o WebSocketOptions) HTTPOption {
+	return func(c *httpConfig) {
+		c.websocket = &o
+	}
+}
+
+func (o WebSocketOptions) pongWait() time.Duration {
+	if o.PongTimeout > 0 {
+		return o.PingInterval + o.PongTimeout
+	}
+	return 2 * o.PingInterval
+}
+
+func (o WebSocketOptions) writeDeadline() time.Time {
+	if o.WriteTimeout > 0 {
+		return time.Now().Add(o.WriteTimeout)
+	}
+	return time.Time{}
+}
+
+// websocketRelay accepts the websocket of the browser and dials gotty over l
+// with the headers gotty uses to check the origin and authenticate.
+func websocketRelay(prefix string, l *pipeListener, o WebSocketOptions) http.Handler {
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:    1024,
+		WriteBufferSize:   1024,
+		EnableCompression: o.Compression,
+		// gotty checks the origin when the relay connects
+		CheckOrigin: func(*http.Request) bool { return true },
+	}
+	dialer := websocket.Dialer{
+		NetDialContext:   l.DialContext,
+		HandshakeTimeout: 10 * time.Second,
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !websocket.IsWebSocketUpgrade(r) {
+			http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+			return
+		}
+
+		target := url.URL{
+			Scheme:   "ws",
+			Host:     r.Host,
+			Path:     "/" + strings.TrimPrefix(r.URL.Path, prefix),
+			RawQuery: r.URL.RawQuery,
+		}
+		header := http.Header{}
+		for _, k := range []string{"Origin", "Cookie", "Authorization", "User-Agent"} {
+			if v := r.Header.Values(k); len(v) > 0 {
+				header[k] = v
+			}
+		}
+
+		d := dialer
+		d.Subprotocols = websocket.Subprotocols(r)
+		ctx := context.WithValue(r.Context(), remoteAddrKey{}, r.RemoteAddr)
+		backend, resp, err := d.DialContext(ctx, target.String(), header)
+		if err != nil {
+			if resp != nil {
+				http.Error(w, http.StatusText(resp.StatusCode), resp.StatusCode)
+				return
+			}
+			http.Error(w, "terminal unavailable", http.StatusBadGateway)
+			return
+		}
+
+		respHeader := http.Header{}
+		if p := backend.Subprotocol(); p != "" {
+			respHeader.Set("Sec-WebSocket-Protocol", p)
+		}
+		client, err := upgrader.Upgrade(w, r, respHeader)
+		if err != nil {
+			backend.Close()
+			return
+		}
+		if o.Compression && o.CompressionLevel != 0 {
+			client.SetCompressionLevel(o.CompressionLevel)
+		}
+
+		o.relay(client, backend)
+	})
+}
+
+// relay copies frames between the browser and gotty until either side closes.
+func (o WebSocketOptions) relay(client, backend *websocket.Conn) {
+	var stopOnce sync.Once
+	done := make(chan struct{})
+	stop := func() {
+		stopOnce.Do(func() {
+			close(done)
+			client.Close()
+			backend.Close()
+		})
+	}
+	defer stop()
+
+	// the pings of gotty's side, e.g. the tstea keepalive, are forwarded to
+	// the browser and its pongs back, so they prove the browser is alive
+	// rather than the relay
+	backend.SetPingHandler(func(data string) error {
+		client.WriteControl(websocket.PingMessage, []byte(data), time.Now().Add(time.Second))
+		return nil
+	})
+	client.SetPongHandler(func(data string) error {
+		backend.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		if o.PingInterval > 0 {
+			return client.SetReadDeadline(time.Now().Add(o.pongWait()))
+		}
+		return nil
+	})
+
+	if o.PingInterval > 0 {
+		client.SetReadDeadline(time.Now().Add(o.pongWait()))
+
+		go func() {
+			ticker := time.NewTicker(o.PingInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+				}
+
+				deadline := o.writeDeadline()
+				if deadline.IsZero() {
+					deadline = time.Now().Add(o.pongWait())
+				}
+				if err := client.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+					log.Warn("websocket ping failed, closing connection", "error", err, "raddr", client.RemoteAddr())
+					stop()
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer stop()
+		copyFrames(backend, client, WebSocketOptions{})
+	}()
+	copyFrames(client, backend, o)
+}
+
+// copyFrames writes every frame read from src to dst, forwarding the close
+// frame of src.
+func copyFrames(dst, src *websocket.Conn, o WebSocketOptions) {
+	for {
+		typ, data, err := src.ReadMessage()
+		if err != nil {
+			msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "")
+			if ce := (*websocket.CloseError)(nil); errors.As(err, &ce) && sendableClose(ce.Code) {
+				msg = websocket.FormatCloseMessage(ce.Code, ce.Text)
+			}
+			dst.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+			return
+		}
+
+		dst.SetWriteDeadline(o.writeDeadline())
+		if err := dst.WriteMessage(typ, data); err != nil {
+			return
+		}
+	}
+}
+
+// sendableClose reports if code may be sent in a close frame, some codes are
+// reserved for reporting a connection that ended without one.
+func sendableClose(code int) bool {
+	switch code {
+	case websocket.CloseNoStatusReceived, websocket.CloseAbnormalClosure, websocket.CloseTLSHandshake:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,142 @@
+package webtea
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWebSocketRelay(t *testing.T) {
+	pl := newPipeListener(&net.TCPAddr{})
+	defer pl.Close()
+
+	gotOrigin := make(chan string, 1)
+	backend := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotOrigin <- r.Header.Get("Origin")
+		up := websocket.Upgrader{
+			Subprotocols: []string{"webtty"},
+			CheckOrigin:  func(*http.Request) bool { return true },
+		}
+		conn, err := up.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			typ, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(typ, data)
+		}
+	})}
+	go backend.Serve(pl)
+	defer backend.Close()
+
+	mux := http.NewServeMux()
+	mux.Handle("/chat/ws", websocketRelay("/chat/", pl, WebSocketOptions{
+		Compression:  true,
+		PingInterval: time.Second,
+		WriteTimeout: time.Second,
+	}))
+	front := httptest.NewServer(mux)
+	defer front.Close()
+
+	d := websocket.Dialer{Subprotocols: []string{"webtty"}, EnableCompression: true}
+	conn, resp, err := d.Dial("ws"+strings.TrimPrefix(front.URL, "http")+"/chat/ws", http.Header{
+		"Origin": {"http://webtea"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if origin := <-gotOrigin; origin != "http://webtea" {
+		t.Errorf("backend saw origin %q", origin)
+	}
+	if conn.Subprotocol() != "webtty" {
+		t.Errorf("subprotocol %q", conn.Subprotocol())
+	}
+	if ext := resp.Header.Get("Sec-WebSocket-Extensions"); !strings.Contains(ext, "permessage-deflate") {
+		t.Errorf("compression not negotiated: %q", ext)
+	}
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("echo %q", data)
+	}
+}
+
+func TestWebSocketRelayForwardsPings(t *testing.T) {
+	pl := newPipeListener(&net.TCPAddr{})
+	defer pl.Close()
+
+	pongs := make(chan string, 1)
+	backend := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		up := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+		conn, err := up.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetPongHandler(func(data string) error {
+			pongs <- data
+			return nil
+		})
+		conn.WriteControl(websocket.PingMessage, []byte("alive?"), time.Now().Add(time.Second))
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})}
+	go backend.Serve(pl)
+	defer backend.Close()
+
+	front := httptest.NewServer(websocketRelay("/", pl, WebSocketOptions{}))
+	defer front.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(front.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	pings := make(chan string, 1)
+	conn.SetPingHandler(func(data string) error {
+		pings <- data
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for _, got := range []struct {
+		name string
+		ch   chan string
+	}{{"browser ping", pings}, {"backend pong", pongs}} {
+		select {
+		case data := <-got.ch:
+			if data != "alive?" {
+				t.Errorf("%s %q", got.name, data)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no %s, the relay answered the ping itself", got.name)
+		}
+	}
+}
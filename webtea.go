@@ -5,14 +5,19 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/http"
+	"net/http/pprof"
 	"time"
 
 	"github.com/charmbracelet/ssh"
 	"github.com/ghthor/gotty/v2/server"
 	"github.com/ghthor/gotty/v2/utils"
+	"golang.org/x/crypto/acme/autocert"
 	"golang.org/x/sync/errgroup"
 )
 
+// RunSSH serves s on l until it is shut down, see ShutdownSSH. Several
+// listeners are served by joining them, see JoinListeners.
 func RunSSH(ctx context.Context, grp *errgroup.Group, cancel context.CancelCauseFunc, l net.Listener, s *ssh.Server) error {
 	grp.Go(func() error {
 		if err := s.Serve(l); err != nil && !errors.Is(err, ssh.ErrServerClosed) {
@@ -41,18 +46,109 @@ func ShutdownSSH(s *ssh.Server, timeout time.Duration) error {
 	return nil
 }
 
-func RunHTTP(ctx context.Context, grp *errgroup.Group, cancel context.CancelCauseFunc, l net.Listener, fact server.Factory, hostname string) error {
+// HTTPOption configures the web terminal served by RunHTTP.
+type HTTPOption func(*httpConfig)
+
+type httpConfig struct {
+	gotty *server.Options
+
+	handlers   map[string]http.Handler
+	middleware []func(http.Handler) http.Handler
+
+	webUI *WebUI
+
+	frameGuard bool
+	authToken  bool
+
+	assetMaxAge time.Duration
+
+	websocket *WebSocketOptions
+
+	acme *ACMEOptions
+
+	// served is given the http.Server so a Server can shut it down
+	served func(*http.Server)
+}
+
+// WithGottyOptions applies fn to the gotty server.Options after the webtea
+// defaults have been set.
+func WithGottyOptions(fn func(*server.Options)) HTTPOption {
+	return func(c *httpConfig) {
+		fn(c.gotty)
+	}
+}
+
+// WithPreferences applies fn to the hterm preferences sent to the browser.
+func WithPreferences(fn func(*server.HtermPrefernces)) HTTPOption {
+	return func(c *httpConfig) {
+		fn(c.gotty.Preferences)
+	}
+}
+
+// WithPermitWrite controls whether browser input is forwarded to the
+// program. It defaults to true.
+func WithPermitWrite(permit bool) HTTPOption {
+	return func(c *httpConfig) {
+		c.gotty.PermitWrite = permit
+	}
+}
+
+// WithHandler serves h for pattern on the same listener as the web
+// terminals. Patterns follow http.ServeMux.
+func WithHandler(pattern string, h http.Handler) HTTPOption {
+	return func(c *httpConfig) {
+		if c.handlers == nil {
+			c.handlers = make(map[string]http.Handler)
+		}
+		c.handlers[pattern] = h
+	}
+}
+
+// WithMiddleware wraps every request served by the listener, including the
+// websocket upgrades and static assets of the web terminals. Middleware is
+// applied in order, so the first one added sees requests first.
+//
+// Websockets are proxied by hijacking the connection, so a middleware that
+// wraps the http.ResponseWriter must implement Unwrap for
+// http.ResponseController to find the http.Hijacker.
+func WithMiddleware(mw func(http.Handler) http.Handler) HTTPOption {
+	return func(c *httpConfig) {
+		c.middleware = append(c.middleware, mw)
+	}
+}
+
+// WithPprof mounts the net/http/pprof handlers on /debug/pprof/. Requests
+// are only served when allow returns true, e.g. for the tailnet identities
+// of the operators.
+func WithPprof(allow func(*http.Request) bool) HTTPOption {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	return WithHandler("/debug/pprof/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if allow == nil || !allow(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+}
+
+func newGottyOptions(hostname string) (*server.Options, error) {
 	var (
 		err        error
 		appOptions = &server.Options{}
 	)
 
 	if err = utils.ApplyDefaultValues(appOptions); err != nil {
-		return fmt.Errorf("gotty default options failure: %w", err)
+		return nil, fmt.Errorf("gotty default options failure: %w", err)
 	}
 	appOptions.Preferences = &server.HtermPrefernces{}
 	if err = utils.ApplyDefaultValues(appOptions.Preferences); err != nil {
-		return fmt.Errorf("gotty default hterm preferences failure: %w", err)
+		return nil, fmt.Errorf("gotty default hterm preferences failure: %w", err)
 	}
 	appOptions.Preferences.EnableWebGL = true
 	appOptions.PermitWrite = true
@@ -61,23 +157,122 @@ func RunHTTP(ctx context.Context, grp *errgroup.Group, cancel context.CancelCaus
 		"hostname": hostname,
 	}
 
+	return appOptions, nil
+}
+
+// RunHTTP serves a web terminal for fact on l, which may be a tcp, tailscale
+// or unix listener, see ListenUnix, or several of them joined with
+// JoinListeners. A plain tcp listener can serve https with WithACME.
+func RunHTTP(ctx context.Context, grp *errgroup.Group, cancel context.CancelCauseFunc, l net.Listener, fact server.Factory, hostname string, opts ...HTTPOption) error {
+	return RunHTTPMux(ctx, grp, cancel, l, map[string]server.Factory{"/": fact}, hostname, opts...)
+}
+
+// RunHTTPMux serves a web terminal for each server.Factory in mounts on the
+// URL path it is keyed by, e.g. "/chat" and "/blokfall", from a single
+// listener. Every mount shares the same gotty options.
+func RunHTTPMux(ctx context.Context, grp *errgroup.Group, cancel context.CancelCauseFunc, l net.Listener, mounts map[string]server.Factory, hostname string, opts ...HTTPOption) error {
+	appOptions, err := newGottyOptions(hostname)
+	if err != nil {
+		return err
+	}
+
+	cfg := &httpConfig{gotty: appOptions, assetMaxAge: DefaultAssetMaxAge}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
 	if err = appOptions.Validate(); err != nil {
 		return fmt.Errorf("gotty options validation failure: %w", err)
 	}
+	if appOptions.EnableTLS {
+		return errors.New("gotty TLS is not supported, pass a TLS net.Listener to RunHTTP instead")
+	}
+	var certs *autocert.Manager
+	if cfg.acme != nil {
+		if certs, err = cfg.acme.manager(); err != nil {
+			return err
+		}
+	}
 
-	var gottySrv *server.Server
-	gottySrv, err = server.New(fact, appOptions)
-	if err != nil {
-		return fmt.Errorf("error creating gotty server: %w", err)
+	// the gotty servers are only run once every pattern is registered so
+	// nothing is left running when a mount is rejected
+	var gottySrvs []func() error
+
+	mux := newMountMux()
+	for path, fact := range mounts {
+		var gottySrv *server.Server
+		gottySrv, err = cfg.newGottyServer(fact)
+		if err != nil {
+			return fmt.Errorf("error creating gotty server for %s: %w", path, err)
+		}
+
+		prefix := cleanMountPath(path)
+		pl := newPipeListener(l.Addr())
+		var mount http.Handler = mountHandler(prefix, pl)
+		if cfg.frameGuard {
+			mount = noFraming(mount)
+		}
+		mux.Handle(prefix, mount)
+		if cfg.websocket != nil {
+			mux.Handle(prefix+"ws", websocketRelay(prefix, pl, *cfg.websocket))
+		}
+		if cfg.authToken {
+			mux.Handle(prefix+"auth_token.js", sameOrigin(mount))
+		}
+		assets := http.StripPrefix(prefix, assetHandler(cfg.assetMaxAge))
+		mux.Handle(prefix+"js/", assets)
+		mux.Handle(prefix+"css/", assets)
+		if cfg.webUI != nil {
+			cfg.webUI.handle(mux, prefix)
+		}
+		if cfg.webUI == nil || len(cfg.webUI.Favicon) == 0 {
+			mux.Handle(prefix+"favicon.png", assets)
+		}
+
+		gottySrvs = append(gottySrvs, func() error {
+			if serr := gottySrv.Run(ctx, server.WithListener(pl)); serr != nil && !errors.Is(serr, context.Canceled) {
+				cancel(serr)
+				return serr
+			}
+			return nil
+		})
+	}
+
+	for pattern, h := range cfg.handlers {
+		mux.Handle(pattern, h)
+	}
+	if mux.err != nil {
+		return mux.err
+	}
+	for _, run := range gottySrvs {
+		grp.Go(run)
+	}
+	if certs != nil {
+		l = cfg.acme.listen(ctx, grp, cancel, l, certs)
+	}
+
+	var handler http.Handler = mux
+	for i := len(cfg.middleware) - 1; i >= 0; i-- {
+		handler = cfg.middleware[i](handler)
 	}
 
+	srv := &http.Server{
+		Handler: handler,
+	}
+	if cfg.served != nil {
+		cfg.served(srv)
+	}
 	grp.Go(func() error {
-		if serr := gottySrv.Run(ctx, server.WithListener(l)); serr != nil && !errors.Is(serr, context.Canceled) {
+		if serr := srv.Serve(l); serr != nil && !errors.Is(serr, http.ErrServerClosed) {
 			cancel(serr)
 			return serr
 		}
 		return nil
 	})
+	grp.Go(func() error {
+		<-ctx.Done()
+		return srv.Close()
+	})
 
 	return nil
 }
@@ -0,0 +1,177 @@
+package webtea
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/ghthor/gotty/v2/server"
+)
+
+// WebUI customizes the page gotty serves around the terminal.
+type WebUI struct {
+	// Title replaces the TitleFormat of the terminal when set
+	Title string
+
+	// Favicon is a PNG served instead of the gotty favicon
+	Favicon []byte
+
+	// BackgroundColor is a CSS color used for the page and the terminal
+	BackgroundColor string
+
+	// CSS is an additional stylesheet included after the gotty styles
+	CSS string
+
+	// Banner is shown above the terminal, it must be trusted HTML
+	Banner template.HTML
+
+	// Reattach gives every browser tab an id it passes to the terminal as
+	// mpty.TabParam, so a reloaded tab gets its program back, see
+	// tstea.WithReattach. It needs TerminalOptions.PermitArguments.
+	Reattach bool
+}
+
+// WithWebUI customizes the web page of every terminal served by RunHTTP.
+func WithWebUI(ui WebUI) HTTPOption {
+	return func(c *httpConfig) {
+		c.webUI = &ui
+		if ui.Title != "" {
+			c.gotty.TitleFormat = ui.Title
+		}
+		if ui.BackgroundColor != "" {
+			c.gotty.Preferences.BackgroundColor = ui.BackgroundColor
+		}
+	}
+}
+
+const (
+	webUIStylesheet = "webtea.css"
+	webUIFavicon    = "favicon.png"
+	webUITabScript  = "webtea-tab.js"
+)
+
+// webUITab keeps the id of the tab in its sessionStorage, which survives a
+// reload but isn't shared with other tabs, and adds it to the arguments gotty
+// sends from the location. The id isn't a secret, a tab is only reattached
+// to a program of the same login.
+const webUITab = `(function () {
+  var key = "webtea.tab";
+  var tab = sessionStorage.getItem(key);
+  if (!tab) {
+    // randomUUID is only available in secure contexts
+    tab = crypto.randomUUID ? crypto.randomUUID() :
+      Math.random().toString(36).slice(2) + Date.now().toString(36);
+    sessionStorage.setItem(key, tab);
+  }
+  var url = new URL(window.location.href);
+  url.searchParams.set("tab", tab);
+  history.replaceState(history.state, "", url);
+})();
+`
+
+// webUIIndex mirrors the gotty index.html. It is rendered once by webtea and
+// the result is parsed again by gotty, which fills in the title.
+var webUIIndex = template.Must(template.New("index").Parse(`<!doctype html>
+<html>
+  <head>
+    <title>{{ "{{" }} .title {{ "}}" }}</title>
+    <link rel="icon" type="image/png" href="favicon.png">
+    <link rel="stylesheet" href="./css/index.css" />
+    <link rel="stylesheet" href="./css/xterm.css" />
+    <link rel="stylesheet" href="./css/xterm_customize.css" />
+    <link rel="stylesheet" href="./` + webUIStylesheet + `" />
+  </head>
+  <body>
+    {{- if .Banner }}
+    <div id="webtea-banner">{{ .Banner }}</div>
+    {{- end }}
+    <div id="terminal"></div>
+    <script src="./auth_token.js"></script>
+    <script src="./config.js"></script>
+    {{- if .Reattach }}
+    <script src="./` + webUITabScript + `"></script>
+    {{- end }}
+    <script src="./js/gotty-bundle.js"></script>
+  </body>
+</html>
+`))
+
+// writeIndex renders the index for gotty into a temporary file, which can be
+// removed once the gotty server has been created.
+func (ui *WebUI) writeIndex() (path string, err error) {
+	var b bytes.Buffer
+	if err := webUIIndex.Execute(&b, ui); err != nil {
+		return "", err
+	}
+	// the rendered page is parsed again as a template by gotty
+	page := strings.NewReplacer("{{ .title }}", "\x00", "{{", `{{"{{"}}`).Replace(b.String())
+	page = strings.Replace(page, "\x00", "{{ .title }}", 1)
+
+	f, err := os.CreateTemp("", "webtea-index-*.html")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.WriteString(page); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+func (ui *WebUI) stylesheet() string {
+	var b strings.Builder
+	if ui.BackgroundColor != "" {
+		fmt.Fprintf(&b, "html, body, #terminal { background: %s; }\n", ui.BackgroundColor)
+	}
+	if ui.Banner != "" {
+		b.WriteString(`body { display: flex; flex-direction: column; }
+#webtea-banner { flex: none; }
+#terminal { flex: 1; height: auto; min-height: 0; }
+`)
+	}
+	b.WriteString(ui.CSS)
+	return b.String()
+}
+
+// handle serves the stylesheet and favicon of the mount at prefix
+func (ui *WebUI) handle(mux *mountMux, prefix string) {
+	css := ui.stylesheet()
+	mux.HandleFunc(prefix+webUIStylesheet, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css")
+		fmt.Fprint(w, css)
+	})
+	if ui.Reattach {
+		mux.HandleFunc(prefix+webUITabScript, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/javascript")
+			fmt.Fprint(w, webUITab)
+		})
+	}
+	if len(ui.Favicon) > 0 {
+		mux.HandleFunc(prefix+webUIFavicon, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(ui.Favicon)
+		})
+	}
+}
+
+// newGottyServer creates the gotty server for a mount, with the custom
+// index when there is a WebUI.
+func (c *httpConfig) newGottyServer(fact server.Factory) (*server.Server, error) {
+	if c.webUI == nil || c.gotty.IndexFile != "" {
+		return server.New(fact, c.gotty)
+	}
+
+	path, err := c.webUI.writeIndex()
+	if err != nil {
+		return nil, fmt.Errorf("error writing web ui index: %w", err)
+	}
+	defer os.Remove(path)
+
+	opts := *c.gotty
+	opts.IndexFile = path
+	return server.New(fact, &opts)
+}
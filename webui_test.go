@@ -0,0 +1,43 @@
+package webtea
+
+import (
+	"bytes"
+	"html/template"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWebUIIndex(t *testing.T) {
+	ui := &WebUI{Banner: `<b>{{ not a template }}</b>`, Reattach: true}
+	path, err := ui.writeIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(path)
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := template.New("index").Parse(string(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, map[string]any{"title": "host"}); err != nil {
+		t.Fatal(err)
+	}
+
+	page := b.String()
+	for _, want := range []string{
+		"<title>host</title>",
+		`<div id="webtea-banner"><b>{{ not a template }}</b></div>`,
+		`href="./webtea.css"`,
+		`<script src="./webtea-tab.js"></script>`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("index missing %q:\n%s", want, page)
+		}
+	}
+}